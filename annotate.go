@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/asn"
+	"github.com/google/namebench/report"
+)
+
+var annotate = flag.Bool("annotate", true,
+	"Label nameservers with their operator and AS, e.g. 1.1.1.1 (Cloudflare, AS13335), and reverse DNS name, from embedded data or -asn_source (cli mode)")
+
+// REVERSE_DNS_TIMEOUT bounds each nameserver's reverse DNS lookup.
+const REVERSE_DNS_TIMEOUT = 2 * time.Second
+
+// nameserverIP returns the address of a nameserver, given as an address,
+// host:port, or URL such as https://dns.google/dns-query, resolving names.
+func nameserverIP(ns string) (net.IP, error) {
+	host := ns
+	if strings.Contains(ns, "://") {
+		u, err := url.Parse(ns)
+		if err != nil {
+			return nil, err
+		}
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(ns); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	return ips[0], nil
+}
+
+// annotateOwners sets the Owner and Hostname of each nameserver, looking it
+// up in the embedded list of resolver operators, then -asn_source.
+func annotateOwners(summaries []*report.Summary) error {
+	embedded, err := asn.NewEmbedded()
+	if err != nil {
+		return err
+	}
+	lookup := asn.Fallback{embedded}
+	if *asn_source != "" {
+		source, err := asn.Open(*asn_source)
+		if err != nil {
+			log.Printf("ASN lookup unavailable: %s", err)
+		} else {
+			lookup = append(lookup, source)
+		}
+	}
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		ip, err := nameserverIP(s.Nameserver)
+		if err != nil {
+			log.Printf("%s: %s", s.Nameserver, err)
+			continue
+		}
+		if info, err := lookup.Lookup(ip); err == nil {
+			s.Owner = fmt.Sprintf("AS%d", info.Number)
+			if info.Name != "" {
+				s.Owner = info.Name + ", " + s.Owner
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), REVERSE_DNS_TIMEOUT)
+		names, err := net.DefaultResolver.LookupAddr(ctx, ip.String())
+		cancel()
+		if err == nil && len(names) > 0 {
+			s.Hostname = strings.TrimSuffix(names[0], ".")
+			s.AddFinding(report.ANALYSIS, "reverse_dns", s.Hostname, false)
+		}
+	}
+	return nil
+}
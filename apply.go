@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/osdns"
+	"github.com/google/namebench/report"
+)
+
+var dry_run = flag.Bool("dry_run", false, "apply: print the commands that would change the system resolvers instead of running them")
+var restore = flag.Bool("restore", false, "apply: put back the resolvers this computer used before namebench apply changed them")
+
+// runApply implements the apply subcommand: it benchmarks -nameservers and
+// makes this computer use the recommended ones, or with -restore the ones
+// it used before. The previous settings are backed up first, and restored
+// at once if changing them fails.
+func runApply() error {
+	if *restore {
+		return restoreResolvers()
+	}
+	servers, _, err := bootstrapNameservers(*nameservers)
+	if err != nil {
+		return err
+	}
+	if servers, err = familyServers(servers); err != nil {
+		return err
+	}
+	if servers = applicable(servers); len(servers) < 2 {
+		return fmt.Errorf("name at least two nameservers by IP address on port 53 to pick from")
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	if err := parseRecordTypes(); err != nil {
+		return err
+	}
+	hostnames, err := cliHostnames()
+	if err != nil {
+		return err
+	}
+	// An interrupted benchmark returns an error, so nothing is done with
+	// partial results.
+	ctx, stop := interruptContext()
+	defer stop()
+	summaries, err := runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{dnssecOK: *dnssec})
+	if err != nil {
+		return err
+	}
+	nameResolvers(summaries)
+	var winners []string
+	for _, r := range report.Recommend(summaries, report.DEFAULT_RECOMMEND_WEIGHTS) {
+		if r.Summary.Total() == 0 {
+			log.Printf("Not applying %s, it was not queried", r.Summary.Nameserver)
+			continue
+		}
+		if float64(r.Summary.FailureCount())/float64(r.Summary.Total()) > MAX_FAILURE_RATE {
+			log.Printf("Not applying %s, it failed too many queries", r.Summary.Nameserver)
+			continue
+		}
+		log.Printf("%s %s, score %.1f", r.Role, r.Summary.Label(), r.Score)
+		host, _, _ := net.SplitHostPort(r.Summary.Nameserver)
+		winners = append(winners, host)
+	}
+	if len(winners) == 0 {
+		return fmt.Errorf("no nameserver was reliable enough to apply")
+	}
+
+	ifaces, err := osdns.Current()
+	if err != nil {
+		return err
+	}
+	commands := osdns.SetCommands(ifaces, winners)
+	if *dry_run {
+		printCommands(commands)
+		return nil
+	}
+	// Keep an earlier backup, so that applying twice still restores the
+	// settings from before namebench changed them.
+	// A backup that can not be read is not replaced, as it may be the only
+	// record of those settings.
+	backup, err := osdns.LoadBackup()
+	switch {
+	case err == nil:
+		log.Printf("Keeping the backup of resolver settings from %s", backup.Time.Format(time.RFC1123))
+	case os.IsNotExist(err):
+		backup = osdns.Backup{Time: time.Now(), Interfaces: ifaces}
+		if err := osdns.SaveBackup(backup); err != nil {
+			return fmt.Errorf("backing up the resolver settings failed, leaving them alone: %s", err)
+		}
+	default:
+		return fmt.Errorf("reading the backup of resolver settings failed, leaving them alone: %s", err)
+	}
+	if err := osdns.Run(commands); err != nil {
+		log.Printf("Changing the resolvers failed, restoring the previous ones: %s", err)
+		if rerr := osdns.Run(osdns.RestoreCommands(osdns.Backup{Interfaces: ifaces})); rerr != nil {
+			return fmt.Errorf("%s; restoring failed too: %s", err, rerr)
+		}
+		return err
+	}
+	fmt.Printf("This computer now uses %v; namebench apply -restore puts back the previous resolvers.\n", winners)
+	return nil
+}
+
+// applicable returns the servers the operating system can be set to use:
+// IP addresses on port 53, not encrypted or other ports.
+func applicable(servers []string) (plain []string) {
+	for _, ns := range servers {
+		host, port, err := net.SplitHostPort(ns)
+		if err != nil || port != parse.DEFAULT_PORT || net.ParseIP(host) == nil {
+			log.Printf("Leaving out %s, which the system can not be set to use", ns)
+			continue
+		}
+		plain = append(plain, ns)
+	}
+	return plain
+}
+
+// restoreResolvers puts back the resolvers saved by runApply, and deletes
+// the backup.
+func restoreResolvers() error {
+	backup, err := osdns.LoadBackup()
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no backup of earlier resolver settings: %s", err)
+	}
+	if err != nil {
+		return err
+	}
+	commands := osdns.RestoreCommands(backup)
+	if *dry_run {
+		printCommands(commands)
+		return nil
+	}
+	if err := osdns.Run(commands); err != nil {
+		return err
+	}
+	fmt.Printf("Restored the resolvers used before %s.\n", backup.Time.Format(time.RFC1123))
+	return osdns.RemoveBackup()
+}
+
+// printCommands prints commands for -dry_run.
+func printCommands(commands []osdns.Command) {
+	for _, c := range commands {
+		fmt.Println(c)
+	}
+}
@@ -0,0 +1,143 @@
+// the asn package maps IP addresses to the autonomous system announcing them,
+// using Team Cymru's DNS service or a local MMDB (e.g. GeoLite2 ASN) file.
+package asn
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info describes an autonomous system.
+type Info struct {
+	Number uint
+	Name   string
+}
+
+// String returns e.g. "AS13335 (CLOUDFLARENET)".
+func (i Info) String() string {
+	if i.Name == "" {
+		return fmt.Sprintf("AS%d", i.Number)
+	}
+	return fmt.Sprintf("AS%d (%s)", i.Number, i.Name)
+}
+
+// Lookup resolves the AS announcing an IP address.
+type Lookup interface {
+	Lookup(ip net.IP) (Info, error)
+}
+
+// Open returns a Lookup for source: "cymru" for Team Cymru's DNS service,
+// otherwise the path of an MMDB ASN database. Results are cached.
+func Open(source string) (Lookup, error) {
+	if source == "cymru" {
+		return NewCache(Cymru{}), nil
+	}
+	r, err := maxminddb.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(&MMDB{reader: r}), nil
+}
+
+// Cymru looks up ASNs via TXT records under asn.cymru.com.
+type Cymru struct{}
+
+// cymruFields splits a Cymru TXT answer, e.g. "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11".
+func cymruFields(name string) ([]string, error) {
+	txts, err := net.LookupTXT(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(txts) == 0 {
+		return nil, fmt.Errorf("no TXT record for %s", name)
+	}
+	fields := strings.Split(txts[0], "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+func (Cymru) Lookup(ip net.IP) (info Info, err error) {
+	rev, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return info, err
+	}
+	var origin string
+	if ip.To4() != nil {
+		origin = strings.TrimSuffix(rev, "in-addr.arpa.") + "origin.asn.cymru.com"
+	} else {
+		origin = strings.TrimSuffix(rev, "ip6.arpa.") + "origin6.asn.cymru.com"
+	}
+	fields, err := cymruFields(origin)
+	if err != nil {
+		return info, err
+	}
+	// Multiple origin ASes are space separated; take the first.
+	n, err := strconv.ParseUint(strings.Fields(fields[0])[0], 10, 32)
+	if err != nil {
+		return info, fmt.Errorf("unexpected Cymru answer for %s: %v", ip, fields)
+	}
+	info.Number = uint(n)
+
+	// The AS name is the last field of AS<n>.asn.cymru.com, e.g. "CLOUDFLARENET, US".
+	if fields, err := cymruFields(fmt.Sprintf("AS%d.asn.cymru.com", n)); err == nil {
+		info.Name = strings.TrimSpace(strings.SplitN(fields[len(fields)-1], ",", 2)[0])
+	}
+	return info, nil
+}
+
+// MMDB looks up ASNs in a MaxMind-format ASN database.
+type MMDB struct {
+	reader *maxminddb.Reader
+}
+
+func (m *MMDB) Lookup(ip net.IP) (info Info, err error) {
+	var r struct {
+		Number uint   `maxminddb:"autonomous_system_number"`
+		Name   string `maxminddb:"autonomous_system_organization"`
+	}
+	if err := m.reader.Lookup(ip, &r); err != nil {
+		return info, err
+	}
+	if r.Number == 0 {
+		return info, fmt.Errorf("%s not found in ASN database", ip)
+	}
+	return Info{Number: r.Number, Name: r.Name}, nil
+}
+
+// Cache memoizes another Lookup. It is safe for concurrent use.
+type Cache struct {
+	lookup Lookup
+	mu     sync.Mutex
+	seen   map[string]Info
+}
+
+// NewCache wraps lookup with a cache.
+func NewCache(lookup Lookup) *Cache {
+	return &Cache{lookup: lookup, seen: make(map[string]Info)}
+}
+
+func (c *Cache) Lookup(ip net.IP) (Info, error) {
+	key := ip.String()
+	c.mu.Lock()
+	info, ok := c.seen[key]
+	c.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+	info, err := c.lookup.Lookup(ip)
+	if err != nil {
+		return info, err
+	}
+	c.mu.Lock()
+	c.seen[key] = info
+	c.mu.Unlock()
+	return info, nil
+}
@@ -0,0 +1,81 @@
+// part of the asn package, names the operators of well-known resolvers from
+// an embedded list, without a lookup service.
+package asn
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/networks.txt
+var networksData string
+
+// network is a line of the embedded list.
+type network struct {
+	prefix *net.IPNet
+	info   Info
+}
+
+// Embedded looks up addresses in the embedded list of resolver operators'
+// networks, which names operators rather than their ASes, e.g. "Cloudflare".
+type Embedded struct {
+	networks []network
+}
+
+// NewEmbedded parses the embedded list.
+func NewEmbedded() (*Embedded, error) {
+	e := &Embedded{}
+	scanner := bufio.NewScanner(strings.NewReader(networksData))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid network line: %q", line)
+		}
+		_, prefix, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AS number in %q", line)
+		}
+		e.networks = append(e.networks, network{prefix, Info{Number: uint(n), Name: strings.Join(fields[2:], " ")}})
+	}
+	return e, scanner.Err()
+}
+
+// Lookup returns the operator of the most specific network containing ip.
+func (e *Embedded) Lookup(ip net.IP) (info Info, err error) {
+	best := -1
+	for _, n := range e.networks {
+		if ones, _ := n.prefix.Mask.Size(); n.prefix.Contains(ip) && ones > best {
+			best = ones
+			info = n.info
+		}
+	}
+	if best < 0 {
+		return info, fmt.Errorf("%s is not in a known resolver network", ip)
+	}
+	return info, nil
+}
+
+// Fallback tries each lookup in turn, returning the first answer.
+type Fallback []Lookup
+
+func (f Fallback) Lookup(ip net.IP) (info Info, err error) {
+	err = fmt.Errorf("no lookup for %s", ip)
+	for _, l := range f {
+		if info, err = l.Lookup(ip); err == nil {
+			return info, nil
+		}
+	}
+	return info, err
+}
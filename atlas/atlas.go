@@ -0,0 +1,196 @@
+// the atlas package fetches recent RIPE Atlas DNS measurements of a
+// resolver, as seen by probes in one country, to compare against local results.
+package atlas
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// API is the RIPE Atlas REST API.
+	API = "https://atlas.ripe.net/api/v2"
+
+	// WINDOW is how far back measurement results are read.
+	WINDOW = 24 * time.Hour
+
+	// MAX_MEASUREMENTS bounds how many measurements of one resolver are read.
+	MAX_MEASUREMENTS = 5
+
+	// MAX_PROBES bounds how many probe IDs are sent in one results request.
+	MAX_PROBES = 500
+
+	// REQUEST_TIMEOUT bounds each API request.
+	REQUEST_TIMEOUT = 30 * time.Second
+)
+
+// Stats summarizes Atlas results for one resolver.
+type Stats struct {
+	Median       time.Duration
+	Results      int
+	Probes       int
+	Measurements []int
+}
+
+// Client reads from the RIPE Atlas API. Probe lists are cached per country.
+type Client struct {
+	http   *http.Client
+	probes map[string][]int
+}
+
+// NewClient returns a Client.
+func NewClient() *Client {
+	return &Client{
+		http:   &http.Client{Timeout: REQUEST_TIMEOUT},
+		probes: make(map[string][]int),
+	}
+}
+
+// get decodes the JSON response of an API path into v.
+func (c *Client) get(path string, query url.Values, v interface{}) error {
+	resp, err := c.http.Get(API + path + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("RIPE Atlas %s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// countryProbes returns the IDs of connected probes in a country.
+func (c *Client) countryProbes(country string) ([]int, error) {
+	if ids, ok := c.probes[country]; ok {
+		return ids, nil
+	}
+	var page struct {
+		Results []struct {
+			ID int `json:"id"`
+		} `json:"results"`
+	}
+	query := url.Values{
+		"country_code": {strings.ToUpper(country)},
+		"status":       {"1"},
+		"fields":       {"id"},
+		"page_size":    {strconv.Itoa(MAX_PROBES)},
+	}
+	if err := c.get("/probes/", query, &page); err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, p := range page.Results {
+		ids = append(ids, p.ID)
+	}
+	c.probes[country] = ids
+	return ids, nil
+}
+
+// measurements returns ongoing DNS measurements that target ip.
+func (c *Client) measurements(ip string) ([]int, error) {
+	var page struct {
+		Results []struct {
+			ID int `json:"id"`
+		} `json:"results"`
+	}
+	query := url.Values{
+		"type":      {"dns"},
+		"target_ip": {ip},
+		"status":    {"2"},
+		"page_size": {strconv.Itoa(MAX_MEASUREMENTS)},
+	}
+	if err := c.get("/measurements/", query, &page); err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, m := range page.Results {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// result is a single DNS measurement result. Probes querying more than one
+// resolver report a resultset instead.
+type result struct {
+	Probe  int `json:"prb_id"`
+	Result *struct {
+		RT float64 `json:"rt"`
+	} `json:"result"`
+	ResultSet []struct {
+		Result *struct {
+			RT float64 `json:"rt"`
+		} `json:"result"`
+	} `json:"resultset"`
+}
+
+// rtts returns the response times of a result in milliseconds.
+func (r result) rtts() (rtts []float64) {
+	if r.Result != nil && r.Result.RT > 0 {
+		rtts = append(rtts, r.Result.RT)
+	}
+	for _, rs := range r.ResultSet {
+		if rs.Result != nil && rs.Result.RT > 0 {
+			rtts = append(rtts, rs.Result.RT)
+		}
+	}
+	return
+}
+
+// Resolver returns the median response time of ip over the last WINDOW, as
+// measured by probes in country (an ISO 3166 code).
+func (c *Client) Resolver(ip, country string) (stats Stats, err error) {
+	probes, err := c.countryProbes(country)
+	if err != nil {
+		return stats, err
+	}
+	if len(probes) == 0 {
+		return stats, fmt.Errorf("no connected RIPE Atlas probes in %s", country)
+	}
+	stats.Measurements, err = c.measurements(ip)
+	if err != nil {
+		return stats, err
+	}
+	if len(stats.Measurements) == 0 {
+		return stats, fmt.Errorf("no ongoing RIPE Atlas DNS measurements of %s", ip)
+	}
+
+	var ids []string
+	for _, p := range probes {
+		ids = append(ids, strconv.Itoa(p))
+	}
+	query := url.Values{
+		"start":     {strconv.FormatInt(time.Now().Add(-WINDOW).Unix(), 10)},
+		"probe_ids": {strings.Join(ids, ",")},
+	}
+	var rtts []float64
+	seen := make(map[int]bool)
+	for _, m := range stats.Measurements {
+		var results []result
+		if err := c.get(fmt.Sprintf("/measurements/%d/results/", m), query, &results); err != nil {
+			log.Printf("Skipping RIPE Atlas measurement %d: %s", m, err)
+			continue
+		}
+		for _, r := range results {
+			found := r.rtts()
+			if len(found) > 0 {
+				seen[r.Probe] = true
+			}
+			rtts = append(rtts, found...)
+		}
+	}
+	if len(rtts) == 0 {
+		return stats, fmt.Errorf("no RIPE Atlas results for %s from probes in %s", ip, country)
+	}
+	sort.Float64s(rtts)
+	stats.Median = time.Duration(rtts[len(rtts)/2] * float64(time.Millisecond))
+	stats.Results = len(rtts)
+	stats.Probes = len(seen)
+	return stats, nil
+}
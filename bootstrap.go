@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/report"
+)
+
+// BOOTSTRAP_TIMEOUT bounds resolving a nameserver given by hostname.
+const BOOTSTRAP_TIMEOUT = 5 * time.Second
+
+var bootstrap = flag.String("bootstrap", "",
+	"Nameserver that resolves nameservers given by hostname, e.g. -nameservers=dns.quad9.net (default: the system resolver)")
+
+// bootstrapNameservers is parse.Nameservers, also accepting plain DNS
+// nameservers given by hostname, with an optional port. Each is resolved
+// with -bootstrap and replaced by all of its addresses, which are returned
+// with the hostname they came from.
+func bootstrapNameservers(list string) (servers []string, hostnames map[string]string, err error) {
+	fields := parse.NameserverFields(list)
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("no nameservers given")
+	}
+	hostnames = make(map[string]string)
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		ns, perr := parse.Nameserver(f)
+		if perr == nil {
+			if !seen[ns] {
+				seen[ns] = true
+				servers = append(servers, ns)
+			}
+			continue
+		}
+		host, port, ok := nameserverHost(f)
+		if !ok {
+			return nil, nil, perr
+		}
+		addrs, err := bootstrapLookup(host)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving nameserver %s: %s", host, err)
+		}
+		log.Printf("Nameserver %s resolved to %v", host, addrs)
+		for _, addr := range addrs {
+			ns := net.JoinHostPort(addr, port)
+			if !seen[ns] {
+				seen[ns] = true
+				hostnames[ns] = host
+				servers = append(servers, ns)
+			}
+		}
+	}
+	return servers, hostnames, nil
+}
+
+// nameserverHost splits a plain DNS nameserver given as hostname[:port].
+func nameserverHost(s string) (host string, port string, ok bool) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") {
+		return "", "", false
+	}
+	host, port = s, parse.DEFAULT_PORT
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, port = h, p
+	}
+	host, err := parse.Domain(host)
+	if err != nil || parse.Port(port) != nil {
+		return "", "", false
+	}
+	// A numeric top level domain is a mistyped address, e.g. 8.8.8.
+	labels := strings.Split(host, ".")
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", "", false
+	}
+	return host, port, true
+}
+
+// bootstrapLookup returns the addresses of host, asking -bootstrap if it is
+// set and the system resolver otherwise.
+func bootstrapLookup(host string) ([]string, error) {
+	resolver := net.DefaultResolver
+	if *bootstrap != "" {
+		server, err := parse.Nameserver(*bootstrap)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAllowed([]string{server}); err != nil {
+			return nil, err
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), BOOTSTRAP_TIMEOUT)
+	defer cancel()
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, ip := range ips {
+		addrs = append(addrs, ip.IP.String())
+	}
+	return addrs, nil
+}
+
+// labelHostnames labels nameservers given by hostname with it, e.g.
+// "dns.quad9.net 9.9.9.9:53".
+func labelHostnames(summaries []*report.Summary, hostnames map[string]string) {
+	for _, s := range summaries {
+		if host, ok := hostnames[s.Nameserver]; ok && s.Alias == "" {
+			s.Alias = host
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var cache_latency = flag.Bool("cache_latency", false,
+	"Query each hostname again, and a random subdomain of it, to report cached and uncached latency and the estimated cache hit ratio (cli mode)")
+
+// missHostnames returns a random, never before queried subdomain of each
+// hostname, which no nameserver can have cached.
+func missHostnames(hostnames []string) []string {
+	misses := make([]string, len(hostnames))
+	for i, h := range hostnames {
+		misses[i] = fmt.Sprintf("nb%012x.%s", rand.Int63n(1<<48), h)
+	}
+	return misses
+}
+
+// answeredDurations queries hostnames against ns, returning the duration of
+// every query that was answered, whatever the answer: a random subdomain is
+// usually NXDOMAIN, which is as much work for the nameserver as an address.
+func answeredDurations(ctx context.Context, ns string, hostnames []string) ([]time.Duration, error) {
+	var durations []time.Duration
+	q := dnsqueue.StartQueue(ctx, ui.QUEUE_LENGTH, ui.WORKERS)
+	q.Deadline = ui.JOB_DEADLINE
+	q.LimitDestinationWorkers(ui.WORKERS)
+	limitRate(q)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, h := range hostnames {
+			for _, r := range benchmarkRequests(ns, h, benchmarkOptions{dnssecOK: *dnssec}) {
+				if err := add(r); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		if !r.Failed() {
+			durations = append(durations, r.Duration)
+		}
+	})
+	if _, ok := err.(*dnsqueue.MissingResultsError); ok {
+		return durations, nil
+	}
+	return durations, err
+}
+
+// analyzeCache measures each nameserver's latency for answers it has cached,
+// by querying the benchmark's hostnames again, and for ones it can not have,
+// random subdomains of them, and records an estimate of how much of the
+// benchmark was answered from cache.
+func analyzeCache(ctx context.Context, summaries []*report.Summary, hostnames []string) {
+	cached := make(map[string][]time.Duration)
+	uncached := make(map[string][]time.Duration)
+	misses := missHostnames(hostnames)
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" {
+			continue
+		}
+		log.Printf("Measuring cached and uncached latency of %s", s.Nameserver)
+		var err error
+		if cached[s.Nameserver], err = answeredDurations(ctx, s.Nameserver, hostnames); err != nil {
+			log.Printf("%s: cached latency failed: %s", s.Nameserver, err)
+			continue
+		}
+		if uncached[s.Nameserver], err = answeredDurations(ctx, s.Nameserver, misses); err != nil {
+			log.Printf("%s: uncached latency failed: %s", s.Nameserver, err)
+		}
+	}
+	report.AnalyzeCache(summaries, cached, uncached)
+}
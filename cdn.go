@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/namebench/dnschecks"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/stats"
+)
+
+var ecs = flag.String("ecs", "",
+	"Send this EDNS Client Subnet, e.g. 203.0.113.0/24, so resolvers that honour it answer as if you were there (cli mode)")
+var cdn_alignment = flag.Bool("cdn_alignment", false,
+	"Resolve popular CDN hostnames on each nameserver and time connections to the endpoints returned, to see whose are closest (cli mode)")
+
+// client_subnet is -ecs, once validated.
+var client_subnet *net.IPNet
+
+// CDN_SLOWER is how much slower than the best nameserver's endpoints a
+// nameserver's may be to connect to before it is flagged, as long as they
+// are also CDN_MIN_SLOWER slower.
+const (
+	CDN_SLOWER     = 1.5
+	CDN_MIN_SLOWER = 5 * time.Millisecond
+)
+
+// checkCDNAlignment records a "cdn" finding per nameserver with the median
+// time to connect to the CDN endpoints it returned. The fastest DNS answer
+// is little use if it points at a far away edge.
+func checkCDNAlignment(summaries []*report.Summary) error {
+	var nameservers []string
+	for _, s := range summaries {
+		if !s.Local && s.Vantage == "" {
+			nameservers = append(nameservers, s.Nameserver)
+		}
+	}
+	found, err := dnschecks.CDNAlignment(nameservers, dnschecks.CDN_HOSTNAMES, client_subnet)
+	if err != nil {
+		return err
+	}
+	medians := make(map[string]time.Duration)
+	var best time.Duration
+	for ns, endpoints := range found {
+		var connects []time.Duration
+		for _, e := range endpoints {
+			if e.Error == "" {
+				connects = append(connects, e.Connect)
+			}
+		}
+		if len(connects) == 0 {
+			continue
+		}
+		medians[ns] = stats.Median(connects)
+		if best == 0 || medians[ns] < best {
+			best = medians[ns]
+		}
+	}
+	for _, s := range summaries {
+		endpoints, ok := found[s.Nameserver]
+		if !ok || s.Local || s.Vantage != "" {
+			continue
+		}
+		median, reached := medians[s.Nameserver]
+		if !reached {
+			s.AddFinding(report.ANALYSIS, "cdn", fmt.Sprintf("no CDN endpoint of %d reachable", len(endpoints)), true)
+			continue
+		}
+		n := 0
+		for _, e := range endpoints {
+			if e.Error == "" {
+				n += 1
+			}
+		}
+		result := fmt.Sprintf("median connect %.2fms to %d/%d CDN endpoints", float64(median)/float64(time.Millisecond), n, len(endpoints))
+		slower := median > best+CDN_MIN_SLOWER && float64(median) > float64(best)*CDN_SLOWER
+		if median == best {
+			result += " (closest)"
+		} else {
+			result += fmt.Sprintf(" (closest: %.2fms)", float64(best)/float64(time.Millisecond))
+		}
+		s.AddFinding(report.ANALYSIS, "cdn", result, slower)
+	}
+	return nil
+}
+
+// parseClientSubnet validates -ecs into client_subnet.
+func parseClientSubnet() (err error) {
+	if *ecs == "" {
+		return nil
+	}
+	if client_subnet, err = dnsqueue.ParseClientSubnet(*ecs); err == nil {
+		log.Printf("Sending client subnet %s", client_subnet)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/google/namebench/asn"
+	"github.com/google/namebench/dnschecks"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/profile"
+	"github.com/google/namebench/report"
+)
+
+var tampering = flag.Bool("tampering", false,
+	"Compare each nameserver's answers for commonly blocked domains with -trusted_resolver, ranking bogus or filtered answers as failures (cli mode)")
+var sentinels = flag.String("sentinels", "",
+	"File of commonly blocked domains for -tampering, one per line (default: the censorship profile's list)")
+var trusted_resolver = flag.String("trusted_resolver", dnschecks.TRUSTED_RESOLVER,
+	"Nameserver or DNS over HTTPS URL whose answers -tampering and -filtering trust")
+
+// runCensorshipChecks records findings in the censorship section: the
+// domains each nameserver blocks while most others answer them, where its
+// queries really come from, and, in a local summary that is returned,
+// whether port 53 is intercepted on the network path.
+func runCensorshipChecks(summaries []*report.Summary, domains []string) *report.Summary {
+	blocked := dnschecks.CheckBlocking(summaries, domains)
+	var lookup asn.Lookup
+	if *asn_source != "" {
+		var err error
+		if lookup, err = asn.Open(*asn_source); err != nil {
+			log.Printf("ASN lookup unavailable: %s", err)
+		}
+	}
+
+	for _, s := range summaries {
+		var parts []string
+		for _, b := range blocked[s.Nameserver] {
+			parts = append(parts, fmt.Sprintf("%s (%s)", b.Domain, b.Reason))
+		}
+		result := fmt.Sprintf("%d/%d blocked", len(parts), len(domains))
+		if len(parts) > 0 {
+			result += ": " + strings.Join(parts, ", ")
+		}
+		s.AddFinding(report.CENSORSHIP, "blocking", result, len(parts) > 0)
+		if !dnsqueue.Encrypted(s.Nameserver) {
+			result, warning := egressResult(s.Nameserver, lookup)
+			s.AddFinding(report.CENSORSHIP, "egress", result, warning)
+		}
+	}
+
+	local := report.NewLocalSummary()
+	if dnschecks.PathIntercepted() {
+		local.AddFinding(report.CENSORSHIP, "interception",
+			fmt.Sprintf("%s answered: DNS on this network is intercepted, whichever resolver is chosen", dnschecks.UNROUTED), true)
+	} else {
+		local.AddFinding(report.CENSORSHIP, "interception", "no DNS interception on the network path", false)
+	}
+	return local
+}
+
+// egressResult describes where a nameserver's queries reach authoritative
+// servers from, warning if a public nameserver's egress is in another AS.
+// Private nameservers, such as home routers, always forward elsewhere.
+func egressResult(nameserver string, lookup asn.Lookup) (string, bool) {
+	egress, err := dnschecks.Egress(nameserver)
+	if err != nil {
+		return err.Error(), false
+	}
+	result := "egress " + egress.String()
+	host, _, err := net.SplitHostPort(nameserver)
+	if lookup == nil || err != nil || dnschecks.Bogon(net.ParseIP(host)) {
+		return result, false
+	}
+	theirs, err1 := lookup.Lookup(net.ParseIP(host))
+	ours, err2 := lookup.Lookup(egress)
+	if err1 != nil || err2 != nil {
+		return result, false
+	}
+	result += " in " + ours.String()
+	if ours.Number != theirs.Number {
+		return result + fmt.Sprintf(", not %s: likely intercepted", theirs), true
+	}
+	return result, false
+}
+
+// sentinelDomains returns the domains -tampering compares.
+func sentinelDomains() ([]string, error) {
+	if *sentinels != "" {
+		return parse.DomainFile(*sentinels)
+	}
+	p, err := profile.Lookup(profile.CENSORSHIP)
+	if err != nil {
+		return nil, err
+	}
+	return p.Domains, nil
+}
+
+// checkTampering compares each nameserver's answers for the sentinel domains
+// with the trusted resolver's, recording a "tampering" finding and counting
+// bogus and filtered answers against the nameserver's score.
+func checkTampering(summaries []*report.Summary) error {
+	domains, err := sentinelDomains()
+	if err != nil {
+		return err
+	}
+	trusted := *trusted_resolver
+	if !dnsqueue.IsDoH(trusted) {
+		if trusted, err = parse.Nameserver(trusted); err != nil {
+			return err
+		}
+	}
+	if err := checkAllowed([]string{trusted}); err != nil {
+		return err
+	}
+	tampered, compared, err := dnschecks.CheckTampering(summaries, domains, trusted)
+	if err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		var penalized, differs []string
+		for _, t := range tampered[s.Nameserver] {
+			part := fmt.Sprintf("%s (%s: %s)", t.Domain, t.Kind, t.Detail)
+			if t.Penalized() {
+				penalized = append(penalized, part)
+			} else {
+				differs = append(differs, part)
+			}
+		}
+		s.Tampered = len(penalized)
+		s.Sentinels = compared
+		result := fmt.Sprintf("%d/%d sentinel domains bogus or filtered", len(penalized), compared)
+		if len(penalized) > 0 {
+			result += ": " + strings.Join(penalized, ", ")
+		}
+		if len(differs) > 0 {
+			result += fmt.Sprintf("; %d answered differently from %s: %s", len(differs), trusted, strings.Join(differs, ", "))
+		}
+		s.AddFinding(report.CENSORSHIP, "tampering", result, len(penalized) > 0)
+	}
+	return nil
+}
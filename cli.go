@@ -0,0 +1,1061 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/asn"
+	"github.com/google/namebench/atlas"
+	"github.com/google/namebench/cluster"
+	"github.com/google/namebench/ddr"
+	"github.com/google/namebench/dnschecks"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/geo"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/i18n"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/managed"
+	"github.com/google/namebench/profile"
+	"github.com/google/namebench/providers"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/runs"
+	"github.com/google/namebench/tui"
+	"github.com/google/namebench/ui"
+	"github.com/google/namebench/upstream"
+)
+
+var mode = flag.String("mode", "ui", "Mode to run in without a command: ui, cli or monitor")
+var nameservers = flag.String("nameservers", "8.8.8.8,8.8.4.4,208.67.222.222,4.2.2.1",
+	"Comma separated list of nameservers to benchmark, by address or hostname (each of its addresses, see -bootstrap), with dot://host[:port] for DNS over TLS and doq://host[:port] for DNS over QUIC (cli mode)")
+var domain_source = flag.String("domain_source", "", "Domain source to read hostnames from, e.g. chrome, tranco (top sites), pihole:PATH, dnsmasq:PATH, unbound:PATH (query logs) or a source plugin (cli mode, default: the first that has any)")
+
+// -source was the name of -domain_source before it was renamed, and still works.
+func init() {
+	flag.StringVar(domain_source, "source", "", "Alias for -domain_source (cli mode)")
+}
+
+var domains = flag.String("domains", "", "File of domains to query, one per line (cli mode, default: browser history)")
+var count = flag.Int("count", ui.COUNT, "Number of domains to query per nameserver (cli mode)")
+var sampling = flag.String("sampling", history.WEIGHTED,
+	"How -count hostnames are picked: weighted (by visits, so often visited sites appear as often as they are used), uniform, or legacy (deprecated: may repeat hostnames) (cli mode)")
+var reuse_connections = flag.Bool("reuse_connections", true,
+	"Reuse TCP and DNS over TLS connections across queries; false pays the handshake on every query (cli mode)")
+var protocol = flag.String("protocol", dnsqueue.PROTOCOL_UDP,
+	"Transport for plain DNS: udp, tcp, or auto to retry truncated UDP answers over TCP (cli mode)")
+var dnssec = flag.Bool("dnssec", false, "Set the DNSSEC OK bit on queries (cli mode)")
+var edns = flag.Bool("edns", false,
+	"Send EDNS with every query, so filtering resolvers can say why they blocked a domain with extended DNS errors (cli mode)")
+var dnssec_validation = flag.Bool("dnssec_validation", false,
+	"Check whether each nameserver validates DNSSEC, adding a DNSSEC column; also part of -security_checks (cli mode)")
+var dnssec_compare = flag.Bool("dnssec_compare", false,
+	"Repeat the benchmark with the DNSSEC OK bit flipped and report what DNSSEC costs each nameserver (cli mode)")
+var recommend = flag.Bool("recommend", true,
+	"Recommend a primary, secondary and tertiary nameserver by latency, reliability, correctness and DNSSEC, explaining each (cli mode)")
+var security_checks = flag.Bool("security_checks", false, "Run security checks against each nameserver after the benchmark (cli mode)")
+var geoip_db = flag.String("geoip_db", "", "MaxMind-format (MMDB) city database used to geolocate answers (cli mode)")
+var my_location = flag.String("my_location", "", "Your location as lat,lon for -geoip_db (default: geolocate your public IP)")
+var asn_source = flag.String("asn_source", "", "Label resolvers and answers with their AS: \"cymru\" or the path of an MMDB ASN database (cli mode)")
+var upstreams = flag.String("upstreams", "",
+	"Benchmark the upstreams of pihole:<setupVars.conf|pihole.toml> or adguard:<AdGuardHome.yaml|API URL> plus suggested alternatives, and print a new ordering (cli mode)")
+var include_doh_providers = flag.Bool("include_doh_providers", false,
+	fmt.Sprintf("Add up to %d public resolvers of the curl DoH and dnscrypt-proxy lists to the candidates, skipping any already given (cli mode)", MAX_DOH_PROVIDERS))
+var include = flag.String("include", "",
+	"Add built-in public resolvers: a comma separated selection of global, regional (for -country), preferred, dnssec or filtering (cli mode)")
+var ripe_atlas = flag.Bool("ripe_atlas", false,
+	"Compare each nameserver with recent RIPE Atlas measurements from probes in your country (cli mode)")
+var country = flag.String("country", "", "Your ISO 3166 country code for -ripe_atlas and -results_server (default: geolocated with -geoip_db)")
+var internal_zones = flag.String("internal_zones", "",
+	"JSON file of internal zones and the resolvers expected to serve them, checked against every nameserver (cli mode)")
+var ttl_records = flag.String("ttl_records", "",
+	"Names you publish with short TTLs, as name:ttl pairs (e.g. failover.example.com:0), to check each nameserver honours them (cli mode)")
+var ttl_watch = flag.Duration("ttl_watch", 0,
+	"Query the first hostnames again every 10s for this long, e.g. 3m, to find nameservers that rewrite TTLs: raising them to a minimum or answering 0 (cli mode)")
+var edns_compliance = flag.Bool("edns_compliance", false,
+	"Probe how each nameserver handles EDNS versions, flags and options, large answers and cookies, as ISC's ednscomp does, reporting problems that make queries fail (cli mode)")
+var rate_limit = flag.Bool("rate_limit", false,
+	"Ramp the query rate against each nameserver to find roughly how many queries per second one client may send (cli mode)")
+var time_of_day = flag.Bool("time_of_day", false,
+	"Break each nameserver's latency in the results database down by hour of day and day of week (cli mode)")
+var profile_name = flag.String("profile", "", "Benchmark preset: "+strings.Join(profile.Names(), ", ")+" (cli mode)")
+var socks = flag.String("socks", "",
+	"Also benchmark through a SOCKS5 proxy, e.g. socks5://127.0.0.1:9050 for Tor, as a separate vantage point (cli mode)")
+var output = flag.String("output", "text", "Report format: text, html, markdown (to paste into issues and forums), csv (for spreadsheets) or json, which namebench report renders later (cli mode)")
+var lang = flag.String("lang", i18n.DEFAULT_LANG, "Language for reports")
+var record_type = flag.String("record_type", dnsqueue.DEFAULT_RECORD_TYPE,
+	"Comma separated record types to query for each hostname, e.g. A,AAAA,MX, with latency and failures reported per type (cli mode)")
+
+var query_mix = flag.String("query_mix", "",
+	"Query each hostname for one record type, spread by weight like real traffic, e.g. A:60,AAAA:25,PTR:5,MX:5,TXT:5, reporting blended and per-type latency; replaces -record_type (cli mode)")
+
+// record_types is -record_type, once validated, or the types of -query_mix.
+var record_types = []string{dnsqueue.DEFAULT_RECORD_TYPE}
+
+// record_mix is -query_mix, once parsed, or nil.
+var record_mix *dnsqueue.QueryMix
+
+// parseRecordTypes sets record_types and record_mix from -record_type and
+// -query_mix.
+func parseRecordTypes() (err error) {
+	if *query_mix == "" {
+		record_types, err = dnsqueue.ParseRecordTypes(*record_type)
+		return err
+	}
+	if *record_type != dnsqueue.DEFAULT_RECORD_TYPE {
+		return fmt.Errorf("-query_mix picks the record types, and can not be combined with -record_type")
+	}
+	if record_mix, err = dnsqueue.ParseQueryMix(*query_mix); err != nil {
+		return err
+	}
+	record_types = record_mix.Types
+	return nil
+}
+
+// queryTypes describes the record types queried, for logging.
+func queryTypes() string {
+	if record_mix != nil {
+		return record_mix.String()
+	}
+	return strings.Join(record_types, ",")
+}
+
+// queriesPerHostname returns how many queries benchmarkRequests makes for
+// each hostname.
+func queriesPerHostname() int {
+	if record_mix != nil {
+		return 1
+	}
+	return len(record_types)
+}
+
+var query_timeout = flag.Duration("timeout", dnsqueue.DEFAULT_TIMEOUT, "How long to wait for each answer (cli mode)")
+var retries = flag.Int("retries", 0, "Times to resend a query that got no answer, reporting which nameservers lose queries (cli mode)")
+var kernel_timestamps = flag.Bool("kernel_timestamps", false,
+	"Measure RTTs with kernel receive timestamps where supported (Linux), excluding scheduling delays (cli mode)")
+
+// cliHostnames returns the hostnames to benchmark, from -domains or the browser history.
+func cliHostnames() ([]string, error) {
+	seedWorkload()
+	var hosts []history.HostCount
+	if *domains != "" {
+		hostnames, err := parse.DomainFile(*domains)
+		if err != nil || len(hostnames) <= *count {
+			return hostnames, err
+		}
+		hosts = history.CountHostnames(hostnames)
+	} else if *domain_source != "" {
+		source, err := history.Lookup(*domain_source)
+		if err != nil {
+			return nil, err
+		}
+		if hosts, err = history.Counts(source, ui.HISTORY_DAYS); err != nil {
+			return nil, err
+		}
+	} else {
+		found, source, err := history.HostCounts(ui.HISTORY_DAYS)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using hostnames from %s", source)
+		hosts = found
+	}
+	return history.Sample(nil, *sampling, *count, hosts)
+}
+
+// benchmarkOptions changes how runCliBenchmark sends its queries.
+type benchmarkOptions struct {
+	// proxy is a SOCKS proxy URL to query through, if not empty.
+	proxy string
+	// dnssecOK sets the DNSSEC OK bit.
+	dnssecOK bool
+	// source is the local address and interface to query from, if not nil.
+	source *dnsqueue.Source
+	// display shows progress with -tui, and which nameservers were aborted.
+	display *tui.Display
+	// stats, if not nil, watches every queue the benchmark runs through.
+	stats *dnsqueue.StatsSet
+}
+
+// benchmarkRequests returns a query for hostname h to nameserver ns for
+// each of record_types, or for the one record_mix picks for h.
+func benchmarkRequests(ns string, h string, opts benchmarkOptions) []*dnsqueue.Request {
+	r := &dnsqueue.Request{
+		Destination:      ns,
+		RecordName:       h + ".",
+		VerifySignature:  opts.dnssecOK,
+		EDNS:             *edns,
+		KernelTimestamps: *kernel_timestamps,
+		Proxy:            opts.proxy,
+		FreshConnection:  !*reuse_connections,
+		Protocol:         *protocol,
+		Timeout:          *query_timeout,
+		MaxRetries:       *retries,
+		ClientSubnet:     client_subnet,
+	}
+	if record_mix != nil {
+		return r.ForTypes([]string{record_mix.Type(h)})
+	}
+	return r.ForTypes(record_types)
+}
+
+// runCliBenchmark benchmarks each nameserver in turn against the same
+// hostnames, or all of them at once with -interleave.
+func runCliBenchmark(ctx context.Context, servers []string, hostnames []string, opts benchmarkOptions) ([]*report.Summary, error) {
+	if *interleave {
+		return runInterleavedBenchmark(ctx, servers, hostnames, opts)
+	}
+	var summaries []*report.Summary
+	for _, ns := range servers {
+		if opts.display.Aborted(ns) {
+			continue
+		}
+		log.Printf("Benchmarking %s with %d hostnames, %s", ns, len(hostnames), queryTypes())
+		warmUp(ctx, []string{ns}, opts)
+		summary := report.NewSummary(ns)
+		q := dnsqueue.StartQueueFrom(ctx, ui.QUEUE_LENGTH, ui.WORKERS, opts.source)
+		q.Deadline = ui.JOB_DEADLINE
+		q.LimitDestinationWorkers(ui.WORKERS)
+		limitRate(q)
+		opts.display.Watch(q)
+		opts.stats.Watch(q)
+		ordered, flush := dnsqueue.InOrder(func(r *dnsqueue.Result) {
+			summary.Add(r)
+			opts.display.Add(r)
+		})
+		err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+			for _, h := range hostnames {
+				if opts.display.Aborted(ns) {
+					break
+				}
+				for _, r := range benchmarkRequests(ns, h, opts) {
+					if err := add(r); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}, ordered)
+		flush()
+		if missing, ok := err.(*dnsqueue.MissingResultsError); ok && (missing.Cause == nil || missing.Cause == dnsqueue.ErrDeadline) {
+			log.Printf("%s: %s", ns, missing)
+			summary.AddMissing(len(missing.Requests))
+		} else if interrupted(ctx, err) {
+			markInterrupted([]*report.Summary{summary}, len(hostnames)*queriesPerHostname())
+			return withoutAborted(append(summaries, summary), opts.display), err
+		} else if err != nil {
+			return summaries, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return withoutAborted(summaries, opts.display), nil
+}
+
+// runVantageBenchmark repeats the benchmark through -socks, labelling the
+// summaries with the proxy, without any credentials in its URL.
+func runVantageBenchmark(ctx context.Context, servers []string, hostnames []string) ([]*report.Summary, error) {
+	u, err := url.Parse(*socks)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Benchmarking through %s", u.Host)
+	via, err := runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{proxy: *socks, dnssecOK: *dnssec})
+	u.User = nil
+	for _, s := range via {
+		s.Vantage = u.String()
+	}
+	return via, err
+}
+
+// SECURITY_CHECK_NAMES is how many hostnames each security check queries.
+const SECURITY_CHECK_NAMES = 5
+
+// runSecurityChecks runs the dnschecks against each nameserver, recording findings.
+func runSecurityChecks(summaries []*report.Summary, hostnames []string) {
+	names := hostnames
+	if len(names) > SECURITY_CHECK_NAMES {
+		names = names[:SECURITY_CHECK_NAMES]
+	}
+	for _, s := range summaries {
+		checkHijack(s)
+		checkDNSSEC(s)
+		// The audit watches for stray UDP datagrams, which DoT and DoH cannot receive.
+		if dnsqueue.Encrypted(s.Nameserver) {
+			continue
+		}
+		checkCase(s, names)
+		checkPorts(s)
+		audit, err := dnschecks.AuditResponses(s.Nameserver, names)
+		if err != nil {
+			s.AddFinding(report.SECURITY, "responses", err.Error(), true)
+			continue
+		}
+		s.AddFinding(report.SECURITY, "responses", fmt.Sprintf("%d duplicate, %d mismatched, %d stray of %d",
+			audit.Duplicates, audit.Mismatched, audit.Stray, audit.Queries), audit.Suspicious())
+	}
+}
+
+// checkHijack records whether s's nameserver answers nonexistent domains
+// with addresses, with a finding listing them if it does.
+func checkHijack(s *report.Summary) {
+	h, err := dnschecks.WildcardHijack(s.Nameserver)
+	if err != nil {
+		log.Printf("%s: NXDOMAIN hijack check failed: %s", s.Nameserver, err)
+		return
+	}
+	hijacks := h.Hijacks()
+	s.HijacksNXDOMAIN = &hijacks
+	if hijacks {
+		var addrs []string
+		for _, ip := range h.Addresses {
+			addrs = append(addrs, ip.String())
+		}
+		s.AddFinding(report.SECURITY, "nxdomain", fmt.Sprintf("%d/%d nonexistent domains answered with %s",
+			h.Hijacked, h.Answered, strings.Join(addrs, ", ")), true)
+	}
+}
+
+// checkCase records whether s's nameserver returns 0x20 mixed case
+// questions unchanged, with a finding.
+func checkCase(s *report.Summary, names []string) {
+	c, err := dnschecks.CheckCase(s.Nameserver, names)
+	if err != nil {
+		log.Printf("%s: 0x20 check failed: %s", s.Nameserver, err)
+		return
+	}
+	preserves := c.Preserves()
+	s.PreservesCase = &preserves
+	s.AddFinding(report.SECURITY, "case_0x20", fmt.Sprintf("%d/%d mixed case questions returned unchanged", c.Preserved, c.Answered), !preserves)
+}
+
+// checkPorts records whether s's nameserver queries from random source
+// ports, with a finding giving its rating. Nameservers that can not reach
+// the test zone, such as forwarders limited to local zones, are not rated.
+func checkPorts(s *report.Summary) {
+	p, err := dnschecks.CheckPorts(s.Nameserver)
+	if err != nil {
+		log.Printf("%s: source port randomization check failed: %s", s.Nameserver, err)
+		return
+	}
+	randomizes := p.Randomizes()
+	s.RandomizesPorts = &randomizes
+	s.AddFinding(report.SECURITY, "source_ports", p.String(), !randomizes)
+}
+
+// checkDNSSEC records whether s's nameserver validates DNSSEC, with a
+// finding describing how it handled signed and bogus zones.
+func checkDNSSEC(s *report.Summary) {
+	v, err := dnschecks.ValidateDNSSEC(s.Nameserver)
+	if err != nil {
+		log.Printf("%s: DNSSEC validation check failed: %s", s.Nameserver, err)
+		return
+	}
+	validates := v.Validates()
+	s.ValidatesDNSSEC = &validates
+	s.AddFinding(report.SECURITY, "dnssec_validation", v.Describe(), !validates)
+}
+
+// checkNameFallback returns a summary holding a "fallback" finding about
+// this computer, or nil where the check is not supported.
+func checkNameFallback() *report.Summary {
+	f, err := dnschecks.CheckNameFallback()
+	if err == dnschecks.ErrUnsupported {
+		return nil
+	}
+	local := report.NewLocalSummary()
+	if err != nil {
+		local.AddFinding(report.SECURITY, "fallback", err.Error(), true)
+		return local
+	}
+	var parts []string
+	if f.LLMNR {
+		parts = append(parts, "LLMNR enabled: "+dnschecks.LLMNR_REMEDIATION)
+	}
+	if f.NetBIOSInterfaces > 0 {
+		parts = append(parts, fmt.Sprintf("NetBIOS enabled on %d/%d interfaces: %s",
+			f.NetBIOSInterfaces, f.Interfaces, dnschecks.NETBIOS_REMEDIATION))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "failed lookups do not fall back to LLMNR or NetBIOS")
+	}
+	local.AddFinding(report.SECURITY, "fallback", strings.Join(parts, "; "), f.Suspicious())
+	return local
+}
+
+// checkInternalZones records an "internal" finding per nameserver, saying
+// whether it resolves, or correctly refuses, the zones in -internal_zones.
+func checkInternalZones(summaries []*report.Summary) error {
+	zones, err := dnschecks.LoadInternalZones(*internal_zones)
+	if err != nil {
+		return err
+	}
+	checker := dnschecks.NewZoneChecker(zones)
+	for _, s := range summaries {
+		check := checker.Check(s.Nameserver)
+		result := fmt.Sprintf("%d/%d internal names correct", check.Correct, check.Checked)
+		if len(check.Problems) > 0 {
+			result += ": " + strings.Join(check.Problems, "; ")
+		}
+		s.AddFinding(report.ANALYSIS, "internal", result, len(check.Problems) > 0)
+	}
+	return nil
+}
+
+// checkTTLs records a "ttl" finding per nameserver, saying whether it
+// honours the short TTLs of -ttl_records. Nameservers are checked at the
+// same time, as each record takes several seconds.
+func checkTTLs(summaries []*report.Summary) error {
+	records, err := dnschecks.ParseTTLRecords(*ttl_records)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		wg.Add(1)
+		go func(s *report.Summary) {
+			defer wg.Done()
+			var parts []string
+			warning := false
+			for _, record := range records {
+				result, err := dnschecks.CheckTTL(s.Nameserver, record)
+				if err != nil {
+					parts = append(parts, fmt.Sprintf("%s: %s", record.Name, err))
+					warning = true
+					continue
+				}
+				verdict, bad := result.Verdict()
+				parts = append(parts, verdict)
+				warning = warning || bad
+			}
+			s.AddFinding(report.ANALYSIS, "ttl", strings.Join(parts, "; "), warning)
+		}(s)
+	}
+	wg.Wait()
+	return nil
+}
+
+// watchTTLs records a "ttl_watch" finding per nameserver, saying whether
+// the TTLs it returns for the first hostnames count down as they should
+// during -ttl_watch. Nameservers are watched at the same time, so they can be
+// compared.
+func watchTTLs(summaries []*report.Summary, hostnames []string) {
+	if len(hostnames) > dnschecks.TTL_WATCH_NAMES {
+		hostnames = hostnames[:dnschecks.TTL_WATCH_NAMES]
+	}
+	log.Printf("Watching the TTLs of %d hostnames for %s", len(hostnames), *ttl_watch)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	watches := make(map[string][]dnschecks.TTLWatch)
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(s *report.Summary) {
+			defer wg.Done()
+			w, err := dnschecks.WatchTTLs(s.Nameserver, hostnames, *ttl_watch, dnschecks.TTL_WATCH_SPACING)
+			if err != nil {
+				log.Printf("%s: TTL watch failed: %s", s.Nameserver, err)
+				return
+			}
+			mu.Lock()
+			watches[s.Nameserver] = w
+			mu.Unlock()
+		}(s)
+	}
+	wg.Wait()
+	behaviors := dnschecks.CompareTTLs(watches)
+	for _, s := range summaries {
+		if b, ok := behaviors[s.Nameserver]; ok && s.Vantage == "" {
+			result, warning := b.Verdict()
+			s.AddFinding(report.ANALYSIS, "ttl_watch", result, warning)
+		}
+	}
+}
+
+// checkEDNS records an "edns" finding per nameserver, listing the EDNS
+// probes it failed. The probes are sent over UDP, so DoT and DoH nameservers
+// are left out.
+func checkEDNS(summaries []*report.Summary) {
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" || dnsqueue.Encrypted(s.Nameserver) {
+			continue
+		}
+		c, err := dnschecks.CheckEDNS(s.Nameserver)
+		if err != nil {
+			log.Printf("%s: EDNS compliance check failed: %s", s.Nameserver, err)
+			continue
+		}
+		s.AddFinding(report.ANALYSIS, "edns", c.Describe(), len(c.Problems()) > 0)
+	}
+}
+
+// checkRateLimits records a "ratelimit" finding per nameserver with the
+// approximate per-client query rate it tolerates. Nameservers are probed one
+// at a time, so that they do not share the local network's capacity.
+func checkRateLimits(summaries []*report.Summary, hostnames []string) {
+	for _, s := range summaries {
+		if s.Local || dnsqueue.IsDoH(s.Nameserver) {
+			continue
+		}
+		log.Printf("Probing %s for rate limiting", s.Nameserver)
+		limit, err := dnschecks.CheckRateLimit(s.Nameserver, hostnames)
+		if err != nil {
+			s.AddFinding(report.ANALYSIS, "ratelimit", err.Error(), true)
+			continue
+		}
+		s.AddFinding(report.ANALYSIS, "ratelimit", limit.Describe(), limit.Limited)
+	}
+}
+
+// userLocation returns -my_location, or the location of the user's public IP.
+func userLocation(db *geo.DB) (geo.Location, error) {
+	if *my_location != "" {
+		return geo.ParseLocation(*my_location)
+	}
+	ip, err := publicIP()
+	if err != nil {
+		return geo.Location{}, err
+	}
+	loc, ok := db.Locate(ip)
+	if !ok {
+		return loc, fmt.Errorf("could not geolocate %s, use -my_location", ip)
+	}
+	log.Printf("Public IP %s is in %s", ip, loc)
+	return loc, nil
+}
+
+// analyzeGeo records where each nameserver is and how far its answers are from the user.
+func analyzeGeo(summaries []*report.Summary) error {
+	db, err := geo.Open(*geoip_db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	user, err := userLocation(db)
+	if err != nil {
+		return err
+	}
+	report.AnalyzeResolverLocation(summaries, db, user)
+	report.AnalyzeAnswerDistance(summaries, db, user)
+	return nil
+}
+
+// includedResolvers returns the built-in resolvers -include selects. Regional
+// ones are limited to the user's country when it is known.
+func includedResolvers(selections []string) ([]providers.Provider, error) {
+	region := ""
+	for _, s := range selections {
+		if s == providers.REGIONAL {
+			var err error
+			if region, err = userCountry(); err != nil {
+				log.Printf("Including regional resolvers for every country: %s", err)
+			}
+			break
+		}
+	}
+	return providers.Select(selections, region)
+}
+
+// MAX_DOH_PROVIDERS is the most resolvers -include_doh_providers adds from
+// the community lists, which name hundreds.
+const MAX_DOH_PROVIDERS = 20
+
+// providerKey returns the destination of a provider, or a nameserver, in the
+// form used to spot duplicates: an address with its port, or a URL with a
+// lowercase host and no trailing slash.
+func providerKey(dest string) string {
+	if ns, err := parse.Nameserver(dest); err == nil {
+		return ns
+	}
+	if u, err := url.Parse(dest); err == nil && u.Host != "" {
+		u.Host = strings.ToLower(u.Host)
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		return u.String()
+	}
+	return dest
+}
+
+// addProviders appends the destinations of found that are not already in
+// servers, at most limit of them if limit is positive.
+func addProviders(servers []string, found []providers.Provider, limit int) []string {
+	seen := make(map[string]bool)
+	for _, ns := range servers {
+		seen[providerKey(ns)] = true
+	}
+	added := 0
+	for _, p := range found {
+		if limit > 0 && added == limit {
+			break
+		}
+		key := providerKey(p.Destination())
+		if !seen[key] {
+			seen[key] = true
+			servers = append(servers, p.Destination())
+			added += 1
+		}
+	}
+	log.Printf("Adding %d of %d public resolvers", added, len(found))
+	return servers
+}
+
+// ATLAS_SLOWDOWN is how many times slower than RIPE Atlas probes in the same
+// country a nameserver must be for the slowness to be considered local.
+const ATLAS_SLOWDOWN = 2
+
+// userCountry returns -country, or the country of the user's location.
+func userCountry() (string, error) {
+	if *country != "" {
+		return strings.ToUpper(*country), nil
+	}
+	if *geoip_db == "" {
+		return "", fmt.Errorf("your country is unknown, use -country or -geoip_db")
+	}
+	db, err := geo.Open(*geoip_db)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	loc, err := userLocation(db)
+	if err != nil {
+		return "", err
+	}
+	if loc.Country == "" {
+		return "", fmt.Errorf("could not tell your country, use -country")
+	}
+	return loc.Country, nil
+}
+
+// compareAtlas records an "atlas" finding per nameserver with the median
+// response time RIPE Atlas probes in the user's country see, to tell local
+// slowness from a resolver that is slow for everyone.
+func compareAtlas(summaries []*report.Summary) error {
+	cc, err := userCountry()
+	if err != nil {
+		return err
+	}
+	client := atlas.NewClient()
+	for _, s := range summaries {
+		host, _, err := net.SplitHostPort(s.Nameserver)
+		if err != nil {
+			continue
+		}
+		stats, err := client.Resolver(host, cc)
+		if err != nil {
+			log.Printf("%s: %s", s.Nameserver, err)
+			continue
+		}
+		local := s.Average() > ATLAS_SLOWDOWN*stats.Median
+		result := fmt.Sprintf("median %.2fms from %d probes in %s, %.2fms here",
+			float64(stats.Median)/float64(time.Millisecond), stats.Probes, cc,
+			float64(s.Average())/float64(time.Millisecond))
+		if local {
+			result += ": the slowness is local"
+		}
+		s.AddFinding(report.ANALYSIS, "atlas", result, local)
+	}
+	return nil
+}
+
+// writeReport writes summaries in the format selected by -output.
+func writeReport(w io.Writer, summaries []*report.Summary) error {
+	switch *output {
+	case "text":
+		return report.WriteText(w, summaries, *lang)
+	case "html":
+		return report.WriteHTML(w, summaries, *lang)
+	case "json":
+		return report.WriteJSON(w, summaries)
+	case "markdown":
+		return report.WriteMarkdown(w, summaries, *lang)
+	case "csv":
+		return report.WriteCSV(w, summaries)
+	}
+	return fmt.Errorf("unknown output format: %s", *output)
+}
+
+// MAX_FAILURE_RATE excludes unreliable nameservers from an upstream ordering.
+const MAX_FAILURE_RATE = 0.1
+
+// rankUpstreams orders the reliable nameservers that configuration for kind
+// can use by average latency, keeping as many as were configured (at least
+// two, so there is a fallback).
+func rankUpstreams(summaries []*report.Summary, configured int, kind string) (servers []string) {
+	var ranked []*report.Summary
+	for _, s := range summaries {
+		if !upstream.Supports(kind, s.Nameserver) {
+			continue
+		}
+		if s.Total() > 0 && float64(s.FailureCount())/float64(s.Total()) <= MAX_FAILURE_RATE {
+			ranked = append(ranked, s)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Average() < ranked[j].Average()
+	})
+	if configured < 2 {
+		configured = 2
+	}
+	for i := 0; i < len(ranked) && i < configured; i++ {
+		servers = append(servers, ranked[i].Nameserver)
+	}
+	return servers
+}
+
+// checkAllowed fails if the managed configuration forbids any of servers.
+func checkAllowed(servers []string) error {
+	config, _ := managed.Active()
+	return config.Allowed(servers)
+}
+
+// outputPath returns where a file given by a flag is written, which the
+// managed configuration may move into its output directory.
+func outputPath(path string) string {
+	config, _ := managed.Active()
+	return config.Path(path)
+}
+
+// publicIP is geo.PublicIP, if the managed configuration allows its resolver.
+func publicIP() (net.IP, error) {
+	if err := checkAllowed([]string{geo.PUBLIC_IP_RESOLVER}); err != nil {
+		return nil, err
+	}
+	return geo.PublicIP()
+}
+
+// nopCloser keeps stdout open when a report written to it is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// reportOutput returns where the report goes: stdout, or a new file in the
+// managed configuration's output directory.
+func reportOutput() (io.WriteCloser, error) {
+	config, _ := managed.Active()
+	if config == nil || config.OutputDir == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	ext := *output
+	if ext == "text" {
+		ext = "txt"
+	} else if ext == "markdown" {
+		ext = "md"
+	}
+	path := config.Path(fmt.Sprintf("namebench-%s.%s", time.Now().Format("20060102-150405"), ext))
+	log.Printf("Writing report to %s", path)
+	return os.Create(path)
+}
+
+// runCli implements -mode=cli.
+func runCli() error {
+	var servers []string
+	var named map[string]string
+	var err error
+	var upstreamKind string
+	var configured int
+	if err := dnsqueue.CheckProtocol(*protocol); err != nil {
+		return err
+	}
+	if err := parseRecordTypes(); err != nil {
+		return err
+	}
+	if err := parseClientSubnet(); err != nil {
+		return err
+	}
+	if err := parseRateLimits(); err != nil {
+		return err
+	}
+	if *upstreams != "" {
+		if upstreamKind, servers, err = upstream.Import(*upstreams); err != nil {
+			return err
+		}
+		log.Printf("Imported %d %s upstreams: %v", len(servers), upstreamKind, servers)
+		configured = len(servers)
+		servers = upstream.WithSuggestions(servers)
+	} else if servers, named, err = bootstrapNameservers(*nameservers); err != nil {
+		return err
+	}
+	var system map[string]bool
+	if *system_resolvers && *upstreams == "" && !*kubernetes {
+		servers, system = withSystemResolvers(servers)
+	}
+	if *include_doh_providers {
+		found, perr := providers.All()
+		if perr != nil {
+			return perr
+		}
+		servers = addProviders(servers, found, MAX_DOH_PROVIDERS)
+	}
+	if *include != "" {
+		curated, cerr := includedResolvers(strings.Split(*include, ","))
+		if cerr != nil {
+			return cerr
+		}
+		servers = addProviders(servers, curated, 0)
+	}
+	var designated map[string][]ddr.Designated
+	if *discover_designated {
+		var added []string
+		added, designated = discoverDesignated(servers)
+		servers = append(servers, added...)
+	}
+	if servers, err = familyServers(servers); err != nil {
+		return err
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	// The first Ctrl-C stops whichever benchmark is running, and the report
+	// covers what it had measured.
+	ctx, stop := interruptContext()
+	defer stop()
+	if *large {
+		if *domains == "" {
+			return fmt.Errorf("-large requires -domains")
+		}
+		return runLargeBenchmark(ctx, servers, *domains)
+	}
+	if *replay && (*domains != "" || *profile_name != "" || *kubernetes) {
+		return fmt.Errorf("-replay uses browser history, and can not be combined with -domains, -profile or -kubernetes")
+	}
+	var preset *profile.Profile
+	if *profile_name != "" {
+		if preset, err = profile.Lookup(*profile_name); err != nil {
+			return err
+		}
+	}
+	var hostnames []string
+	if !*replay && (preset == nil || preset.Domains == nil || *domains != "") {
+		if hostnames, err = cliHostnames(); err != nil {
+			return err
+		}
+	}
+	if preset != nil {
+		hostnames = preset.Workload(hostnames)
+		log.Printf("Profile %s: %s", preset.Name, preset.Description)
+	}
+	var conf *cluster.ResolvConf
+	var relative []string
+	if *kubernetes {
+		if conf, err = cluster.Detect(); err != nil {
+			return err
+		}
+		servers, hostnames, relative = kubernetesWorkload(conf, servers, hostnames)
+		log.Printf("Cluster nameservers %v, search %v, ndots:%d", servers, conf.Search, conf.Ndots)
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	servers, unreachable, err := skipUnreachable(ctx, servers)
+	if err != nil {
+		return err
+	}
+	var summaries []*report.Summary
+	if *replay {
+		summaries, hostnames, err = runReplayBenchmark(ctx, servers, benchmarkOptions{dnssecOK: *dnssec})
+	} else {
+		display := startDisplay(servers, len(hostnames)*queriesPerHostname())
+		summaries, err = runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{dnssecOK: *dnssec, display: display})
+		display.Close()
+	}
+	tampering_checked := false
+	if err == nil && (*tampering || (preset != nil && preset.Has(profile.CENSORSHIP))) {
+		if terr := checkTampering(summaries); terr != nil {
+			log.Printf("Tampering check failed: %s", terr)
+		} else {
+			tampering_checked = true
+		}
+	}
+	if err == nil && *dnssec_compare {
+		log.Printf("Repeating the benchmark with -dnssec=%t", !*dnssec)
+		var other []*report.Summary
+		if other, err = runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{dnssecOK: !*dnssec}); err == nil {
+			report.CompareDNSSEC(summaries, other, *dnssec)
+		}
+	}
+	if err == nil && conf != nil {
+		analyzeSearchPath(ctx, conf, summaries, relative)
+	}
+	if err == nil && *loss_probes > 0 {
+		if lerr := checkLoss(summaries, hostnames); lerr != nil {
+			log.Printf("Loss measurement failed: %s", lerr)
+		}
+	}
+	if len(summaries) > 1 {
+		report.AnalyzeChains(summaries)
+	}
+	report.AnalyzeBlocking(summaries)
+	report.AnalyzeConsensus(summaries)
+	if record_mix != nil {
+		report.AnalyzeQueryMix(summaries, record_mix.Types)
+	}
+	if err == nil && *cache_latency {
+		analyzeCache(ctx, summaries, hostnames)
+	}
+	if *retries > 0 {
+		report.AnalyzeRetries(summaries)
+	}
+	if designated != nil {
+		analyzeDesignated(summaries, designated)
+	}
+	if err == nil && *filtering {
+		if ferr := checkFiltering(summaries); ferr != nil {
+			log.Printf("Filtering check failed: %s", ferr)
+		}
+	}
+	if err == nil && *cdn_alignment {
+		if cerr := checkCDNAlignment(summaries); cerr != nil {
+			log.Printf("CDN alignment check failed: %s", cerr)
+		}
+	}
+	if err == nil && *geoip_db != "" {
+		if gerr := analyzeGeo(summaries); gerr != nil {
+			log.Printf("GeoIP analysis failed: %s", gerr)
+		}
+	}
+	if err == nil && *asn_source != "" {
+		if lookup, aerr := asn.Open(*asn_source); aerr != nil {
+			log.Printf("ASN lookup unavailable: %s", aerr)
+		} else {
+			report.AnalyzeASN(summaries, lookup)
+		}
+	}
+	if err == nil && *ripe_atlas {
+		if aerr := compareAtlas(summaries); aerr != nil {
+			log.Printf("RIPE Atlas comparison failed: %s", aerr)
+		}
+	}
+	if err == nil && *results_server != "" {
+		if perr := shareResults(summaries); perr != nil {
+			log.Printf("Results service failed: %s", perr)
+		}
+	}
+	if err == nil && *mdns_check {
+		if merr := analyzeLocalNames(ctx, summaries); merr != nil {
+			log.Printf("mDNS check failed: %s", merr)
+		}
+	}
+	if err == nil && *internal_zones != "" {
+		if zerr := checkInternalZones(summaries); zerr != nil {
+			return zerr
+		}
+	}
+	if err == nil && *ttl_records != "" {
+		if terr := checkTTLs(summaries); terr != nil {
+			return terr
+		}
+	}
+	if err == nil && *ttl_watch > 0 {
+		watchTTLs(summaries, hostnames)
+	}
+	if err == nil && *edns_compliance {
+		checkEDNS(summaries)
+	}
+	if err == nil && *rate_limit {
+		checkRateLimits(summaries, hostnames)
+	}
+	// Findings about this computer go in local summaries, and results from
+	// other vantage points in summaries of their own. Both only join the
+	// nameservers' summaries once every per-nameserver check is done, so
+	// that none of the checks runs against them.
+	var local, vantages []*report.Summary
+	if err == nil && preset != nil && preset.Has(profile.CENSORSHIP) {
+		local = append(local, runCensorshipChecks(summaries, hostnames))
+	}
+	if err == nil && *dnssec_validation && !*security_checks {
+		for _, s := range summaries {
+			checkDNSSEC(s)
+		}
+	}
+	if err == nil && *security_checks {
+		runSecurityChecks(summaries, hostnames)
+		if fallback := checkNameFallback(); fallback != nil {
+			local = append(local, fallback)
+		}
+	}
+	// Ranking waits for the security checks, so that their sub-score counts
+	// in the "rank" finding as it does in the recommendations.
+	if err == nil && preset != nil && !preset.Weights.IsZero() {
+		report.Rank(summaries, preset.Weights)
+	} else if err == nil && (tampering_checked || *security_checks) {
+		report.Rank(summaries, report.DEFAULT_WEIGHTS)
+	}
+	if err == nil && *socks != "" {
+		var via []*report.Summary
+		if via, err = runVantageBenchmark(ctx, servers, hostnames); err == nil {
+			report.CompareVantage(summaries, via)
+			vantages = append(vantages, via...)
+		}
+	}
+	if err == nil && *per_interface {
+		var bound []*report.Summary
+		if bound, err = runInterfaceBenchmarks(ctx, servers, hostnames); err == nil {
+			report.AnalyzeVantages(bound)
+			vantages = append(vantages, bound...)
+		}
+	}
+	summaries = append(append(flagUnreachable(summaries, unreachable), vantages...), local...)
+	if interrupted(ctx, err) {
+		err = fmt.Errorf("interrupted, the report only covers the queries made so far")
+	}
+	if err == nil {
+		runPluginChecks(summaries)
+	}
+	if err == nil && (*store_results || *compare_last) {
+		if serr := storeResults(summaries); serr != nil {
+			log.Printf("Results database failed: %s", serr)
+		}
+	}
+	if err == nil && *time_of_day {
+		if terr := runs.AnalyzeTimeOfDay(summaries); terr != nil {
+			return terr
+		}
+	}
+	if *annotate {
+		if aerr := annotateOwners(summaries); aerr != nil {
+			log.Printf("Annotating nameservers failed: %s", aerr)
+		}
+	}
+	nameResolvers(summaries)
+	labelSystem(summaries, system)
+	labelHostnames(summaries, named)
+	if err == nil && *recommend {
+		report.Recommend(summaries, report.DEFAULT_RECOMMEND_WEIGHTS)
+	}
+	out, oerr := reportOutput()
+	if oerr != nil {
+		return oerr
+	}
+	defer out.Close()
+	if rerr := writeReport(out, summaries); err == nil {
+		err = rerr
+	}
+	if err == nil {
+		runPluginExporters(summaries)
+	}
+	if err == nil && upstreamKind != "" {
+		ranked := rankUpstreams(summaries, configured, upstreamKind)
+		if len(ranked) == 0 {
+			return fmt.Errorf("no upstream was reliable enough to recommend")
+		}
+		config, ferr := upstream.Format(upstreamKind, ranked)
+		if ferr != nil {
+			return ferr
+		}
+		fmt.Fprintf(out, "\n%s", config)
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+// the cluster package supports benchmarking DNS from inside a Kubernetes
+// cluster: it reads the pod's resolver configuration, generates
+// service-style names and expands names along the search path the way the
+// pod's stub resolver does.
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/namebench/internal/parse"
+)
+
+// RESOLV_CONF is where the kubelet writes each pod's resolver configuration.
+const RESOLV_CONF = "/etc/resolv.conf"
+
+// DEFAULT_NDOTS is the resolver default when resolv.conf sets no ndots option.
+const DEFAULT_NDOTS = 1
+
+// ResolvConf is the part of resolv.conf that affects how names are queried.
+type ResolvConf struct {
+	Nameservers []string
+	Search      []string
+	Ndots       int
+}
+
+// ReadResolvConf parses a resolv.conf file. Nameservers are returned as host:port.
+func ReadResolvConf(path string) (*ResolvConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := &ResolvConf{Ndots: DEFAULT_NDOTS}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			ns, err := parse.Nameserver(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+			conf.Nameservers = append(conf.Nameservers, ns)
+		case "search", "domain":
+			conf.Search = fields[1:]
+		case "options":
+			for _, opt := range fields[1:] {
+				if strings.HasPrefix(opt, "ndots:") {
+					if n, err := strconv.Atoi(opt[len("ndots:"):]); err == nil {
+						conf.Ndots = n
+					}
+				}
+			}
+		}
+	}
+	return conf, scanner.Err()
+}
+
+// Domain returns the cluster domain, e.g. "cluster.local", found from the
+// "svc.<domain>" entry the kubelet puts in every pod's search path.
+func (c *ResolvConf) Domain() (string, bool) {
+	for _, s := range c.Search {
+		if strings.HasPrefix(s, "svc.") {
+			return strings.TrimPrefix(s, "svc."), true
+		}
+	}
+	return "", false
+}
+
+// Detect reads RESOLV_CONF and checks that it belongs to a Kubernetes pod.
+// Its nameserver is the kube-dns (CoreDNS) service IP.
+func Detect() (*ResolvConf, error) {
+	conf, err := ReadResolvConf(RESOLV_CONF)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conf.Domain(); !ok {
+		return nil, fmt.Errorf("%s has no svc.<cluster domain> search entry, not running in a Kubernetes pod?", RESOLV_CONF)
+	}
+	if len(conf.Nameservers) == 0 {
+		return nil, fmt.Errorf("%s lists no nameserver", RESOLV_CONF)
+	}
+	return conf, nil
+}
+
+// ServiceNames returns names of services present in every cluster, in the
+// short forms pods in any namespace use; Expand turns them into queries.
+func ServiceNames() []string {
+	return []string{
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kube-dns.kube-system",
+	}
+}
+
+// FQDN returns the fully qualified form of a short service name, e.g.
+// kubernetes.default.svc.cluster.local for "kubernetes.default".
+func FQDN(name, namespace, domain string) string {
+	switch strings.Count(name, ".") {
+	case 0:
+		return fmt.Sprintf("%s.%s.svc.%s", name, namespace, domain)
+	case 1:
+		return fmt.Sprintf("%s.svc.%s", name, domain)
+	}
+	return fmt.Sprintf("%s.%s", name, domain)
+}
+
+// Namespace returns the pod's namespace, from the "<namespace>.svc.<domain>"
+// search entry.
+func (c *ResolvConf) Namespace() string {
+	domain, _ := c.Domain()
+	for _, s := range c.Search {
+		if strings.HasSuffix(s, ".svc."+domain) {
+			return strings.TrimSuffix(s, ".svc."+domain)
+		}
+	}
+	return "default"
+}
+
+// Expand returns the names a stub resolver queries, in order, to resolve
+// name: with fewer than Ndots dots each search domain is tried before the
+// name itself, otherwise the name is tried first. Every name has a trailing dot.
+func (c *ResolvConf) Expand(name string) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	var searched []string
+	for _, s := range c.Search {
+		searched = append(searched, name+"."+s+".")
+	}
+	if strings.Count(name, ".") >= c.Ndots {
+		return append([]string{name + "."}, searched...)
+	}
+	return append(searched, name+".")
+}
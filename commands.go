@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/report"
+)
+
+// command is a subcommand, e.g. "namebench benchmark", along with the flags
+// that apply to it.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// flags returns true for each global flag the command accepts.
+	flags func(name string) bool
+	run   func(args []string) error
+}
+
+// Flags that only apply to some commands.
+var (
+	SERVE_FLAGS   = []string{"port", "bind", "tls_cert", "tls_key", "tls_self_signed", "auth_token"}
+	MONITOR_FLAGS = []string{"interval", "webhook", "slack_webhook", "health_addr", "assert"}
+	EXPORT_FLAGS  = []string{"target"}
+	APPLY_FLAGS   = []string{"dry_run", "restore"}
+	// WORKLOAD_FLAGS pick the nameservers, hostnames and queries of a benchmark.
+	WORKLOAD_FLAGS = []string{"nameservers", "bootstrap", "ipv4", "ipv6", "domains", "domain_source", "source", "count", "sampling", "seed", "record_type", "query_mix", "protocol", "timeout",
+		"retries", "dnssec", "edns", "ecs", "interleave", "server_qps", "server_burst", "qps_limits", "warmup", "no_prime", "reuse_connections", "kernel_timestamps"}
+	// SHARED_FLAGS apply to every command.
+	SHARED_FLAGS = []string{"config", "groups", "plugins", "plugin_dir", "lang"}
+	CHECK_FLAGS  = []string{"output", "bootstrap", "tampering", "trusted_resolver", "sentinels", "filtering", "annotate", "asn_source"}
+)
+
+// flagNames returns the set of names in lists.
+func flagNames(lists ...[]string) map[string]bool {
+	names := make(map[string]bool)
+	for _, list := range lists {
+		for _, name := range list {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// oneOf returns a flag filter accepting the names in any of lists, and SHARED_FLAGS.
+func oneOf(lists ...[]string) func(string) bool {
+	names := flagNames(append(lists, SHARED_FLAGS)...)
+	return func(name string) bool { return names[name] }
+}
+
+// benchmarkFlags accepts every flag but -mode and those only other commands use.
+func benchmarkFlags(name string) bool {
+	return name != "mode" && !flagNames(SERVE_FLAGS, MONITOR_FLAGS, EXPORT_FLAGS, APPLY_FLAGS)[name]
+}
+
+var commands = []*command{
+	{
+		name:    "benchmark",
+		summary: "benchmark nameservers against your browsing history and report the results",
+		flags:   benchmarkFlags,
+		run:     withoutArgs(runCli),
+	},
+	{
+		name:    "check",
+		args:    "nameserver...",
+		summary: "run the security checks (NXDOMAIN hijacking, DNSSEC validation, response audit) on nameservers",
+		flags:   oneOf(CHECK_FLAGS),
+		run:     runCheck,
+	},
+	{
+		name:    "monitor",
+		summary: "benchmark continuously, alerting on -assert conditions and serving /healthz and /metrics",
+		flags:   oneOf(WORKLOAD_FLAGS, MONITOR_FLAGS),
+		run:     withoutArgs(runMonitor),
+	},
+	{
+		name:    "report",
+		args:    "results.json",
+		summary: "render results saved with -output=json as text, HTML or Markdown",
+		flags:   oneOf([]string{"output"}),
+		run:     runReport,
+	},
+	{
+		name:    "serve",
+		summary: "serve the user interface, opening it in your browser unless -port is set",
+		flags:   oneOf(SERVE_FLAGS),
+		run:     withoutArgs(runServe),
+	},
+	{
+		name:    "export-config",
+		summary: "benchmark nameservers and print a forwarder configuration for -target listing the best",
+		flags:   func(name string) bool { return benchmarkFlags(name) || name == "target" },
+		run:     withoutArgs(runExportConfig),
+	},
+	{
+		name:    "apply",
+		summary: "benchmark nameservers and make this computer use the recommended ones, backing up its settings (-restore puts them back); on Linux only with systemd-resolved",
+		flags:   oneOf(WORKLOAD_FLAGS, APPLY_FLAGS),
+		run:     withoutArgs(runApply),
+	},
+	{
+		name:    "config",
+		args:    "init",
+		summary: "write a settings file for -config with the current defaults",
+		flags:   func(name string) bool { return name == "config" },
+		run:     runConfig,
+	},
+}
+
+// withoutArgs adapts a command that takes no positional arguments.
+func withoutArgs(run func() error) func([]string) error {
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		return run()
+	}
+}
+
+// flagSet returns the command's flag set. It shares the global flag
+// variables, so code reading them works the same for every command.
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("namebench "+c.name, flag.ExitOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		if c.flags(f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\n%s.\n\nFlags:\n", strings.TrimSpace("namebench "+c.name+" [flags] "+c.args), strings.ToUpper(c.summary[:1])+c.summary[1:])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usage lists the commands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: namebench <command> [flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(out, "\nRun namebench <command> -help for the flags of each. Without a command the user interface is\nserved, or -mode=cli|monitor runs as before commands existed.\n")
+}
+
+// modeUsage lists the commands, then the flags for running without one.
+func modeUsage() {
+	usage()
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags without a command:\n")
+	flag.PrintDefaults()
+}
+
+// runCommand parses the flags of the named command and runs it, exiting if
+// it fails.
+func runCommand(name string, args []string) {
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		fs := c.flagSet()
+		parseFlags(fs, args)
+		applyManaged()
+		loadPlugins()
+		if err := c.run(fs.Args()); err != nil {
+			log.Fatalf("%s failed: %s", name, err)
+		}
+		return
+	}
+	if name != "help" {
+		fmt.Fprintf(flag.CommandLine.Output(), "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+// runCheck implements "namebench check": the security checks alone, reported
+// without benchmarking.
+func runCheck(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("name at least one nameserver, e.g. namebench check 8.8.8.8")
+	}
+	servers, named, err := bootstrapNameservers(strings.Join(args, ","))
+	if err != nil {
+		return err
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	src, err := history.Lookup(history.DEFAULT_SOURCE)
+	if err != nil {
+		return err
+	}
+	names, err := src.Hostnames(0)
+	if err != nil {
+		return err
+	}
+	var summaries []*report.Summary
+	for _, ns := range servers {
+		summaries = append(summaries, report.NewSummary(ns))
+	}
+	runSecurityChecks(summaries, names)
+	if *tampering {
+		if err := checkTampering(summaries); err != nil {
+			return err
+		}
+	}
+	if *filtering {
+		if err := checkFiltering(summaries); err != nil {
+			return err
+		}
+	}
+	if *annotate {
+		if err := annotateOwners(summaries); err != nil {
+			log.Printf("Annotating nameservers failed: %s", err)
+		}
+	}
+	labelHostnames(summaries, named)
+	return writeReportOutput(summaries)
+}
+
+// runReport implements "namebench report": renders saved JSON results.
+func runReport(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("name one results file, saved with -output=json")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	summaries, err := report.ReadJSON(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", args[0], err)
+	}
+	return writeReportOutput(summaries)
+}
+
+// runConfig implements "namebench config init".
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "init" {
+		return fmt.Errorf("unknown config command %q, want: namebench config init", strings.Join(args, " "))
+	}
+	return runConfigInit()
+}
+
+// writeReportOutput writes summaries in the -output format to reportOutput.
+func writeReportOutput(summaries []*report.Summary) error {
+	out, err := reportOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return writeReport(out, summaries)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/results"
+)
+
+var store_results = flag.Bool("store_results", true,
+	"Keep every run's per-nameserver results (no hostnames) in the results database in the data directory (cli mode)")
+var compare_last = flag.Bool("compare_last", false,
+	"Show each nameserver's latency change since the previous run of the same nameservers (cli mode)")
+
+// COMPARE_SLOWER is how much slower than in the previous run a nameserver's
+// average may get before the comparison is flagged, as long as it is also
+// COMPARE_MIN_MS slower; sub-millisecond averages vary too much otherwise.
+const (
+	COMPARE_SLOWER = 1.25
+	COMPARE_MIN_MS = 1.0
+)
+
+// storeResults compares the run with the previous one of the same
+// nameservers when -compare_last is set, then saves it.
+func storeResults(summaries []*report.Summary) error {
+	store, err := results.Open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	now := time.Now()
+	if *compare_last {
+		previous, err := store.Last(results.NameserverSet(summaries))
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			log.Printf("No previous run of these nameservers to compare with")
+		} else {
+			compareRuns(summaries, previous, results.NewRun(summaries, now))
+		}
+	}
+	if *store_results {
+		_, err = store.Save(summaries, now)
+	}
+	return err
+}
+
+// compareRuns records a "previous_run" finding for each nameserver in both runs.
+func compareRuns(summaries []*report.Summary, previous, current *results.Run) {
+	deltas := make(map[string]results.Delta)
+	for _, d := range results.Compare(previous, current) {
+		deltas[d.Nameserver+" "+d.Vantage] = d
+	}
+	when := previous.Time.Format("2006-01-02 15:04")
+	for _, s := range summaries {
+		d, ok := deltas[s.Nameserver+" "+s.Vantage]
+		if !ok {
+			continue
+		}
+		result := fmt.Sprintf("avg %+.2fms (was %.2fms on %s), p95 %+.2fms, failures %+.1f%%",
+			d.AverageMs, d.Previous.AverageMs, when, d.P95Ms, d.FailureRate*100)
+		slower := d.AverageMs > COMPARE_MIN_MS && d.Previous.AverageMs+d.AverageMs > d.Previous.AverageMs*COMPARE_SLOWER
+		s.AddFinding(report.ANALYSIS, "previous_run", result, slower)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/namebench/settings"
+)
+
+var config_file = flag.String("config", "",
+	"YAML file of settings named after flags, with nameservers optionally in named groups; flags on the command line win")
+var groups = flag.String("groups", "", "Comma separated nameserver groups of -config to benchmark (default: all)")
+
+// INIT_SETTINGS are the flags "namebench config init" writes, in order.
+var INIT_SETTINGS = []string{
+	"domains", "domain_source", "count", "record_type", "query_mix", "protocol", "timeout", "retries",
+	"interleave", "server_qps", "include", "output", "lang",
+}
+
+// applyConfigFile sets each flag in -config that was not given on the command
+// line, as parsed by fs. Settings for flags fs does not have, because they
+// belong to other commands, are skipped with a warning.
+func applyConfigFile(fs *flag.FlagSet) error {
+	if *config_file == "" {
+		return nil
+	}
+	f, err := settings.Load(*config_file)
+	if err != nil {
+		return err
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		given[fl.Name] = true
+	})
+	for name, value := range f.Settings {
+		if name == "config" || flag.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", f.Path, name)
+		}
+		if fs.Lookup(name) == nil {
+			log.Printf("%s: ignoring setting %q, which does not apply to %s", f.Path, name, fs.Name())
+			continue
+		}
+		if given[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %s", f.Path, name, err)
+		}
+	}
+	if len(f.GroupNames) > 0 && fs.Lookup(settings.NAMESERVERS) != nil && !given[settings.NAMESERVERS] {
+		var selected []string
+		if *groups != "" {
+			selected = strings.Split(*groups, ",")
+		}
+		servers, err := f.Nameservers(selected)
+		if err != nil {
+			return err
+		}
+		if len(servers) == 0 {
+			return fmt.Errorf("%s: the selected nameserver groups are empty", f.Path)
+		}
+		fs.Set(settings.NAMESERVERS, strings.Join(servers, ","))
+	}
+	log.Printf("Loaded settings from %s", f.Path)
+	return nil
+}
+
+// runConfigInit implements "namebench config init": writes a settings file
+// with the current defaults, to -config or settings.DEFAULT_FILE. It will
+// not overwrite an existing file.
+func runConfigInit() error {
+	path := *config_file
+	if path == "" {
+		path = settings.DEFAULT_FILE
+	}
+	path = outputPath(path)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	var b strings.Builder
+	b.WriteString("# namebench settings: each is named after a flag (see namebench -help), and flags\n")
+	b.WriteString("# given on the command line override them. Use with: namebench -config=" + path + "\n\n")
+	b.WriteString("# Nameservers to benchmark, as a list or in named groups picked with -groups.\n")
+	b.WriteString(settings.NAMESERVERS + ":\n  default:\n")
+	for _, ns := range strings.Split(*nameservers, ",") {
+		fmt.Fprintf(&b, "    - %s\n", ns)
+	}
+	for _, name := range INIT_SETTINGS {
+		fl := flag.Lookup(name)
+		fmt.Fprintf(&b, "\n# %s\n", fl.Usage)
+		if fl.Value.String() == "" {
+			fmt.Fprintf(&b, "#%s:\n", name)
+		} else {
+			fmt.Fprintf(&b, "%s: %s\n", name, fl.Value)
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s", path)
+	return nil
+}
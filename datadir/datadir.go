@@ -0,0 +1,65 @@
+// the datadir package locates the directory namebench keeps its state in.
+package datadir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ENV_VAR overrides the default data directory when set.
+const ENV_VAR = "NAMEBENCH_DATA_DIR"
+
+// override, if set by Use, replaces the data directory.
+var override string
+
+// Use makes dir the data directory, e.g. the output directory of a managed
+// configuration, which every file namebench writes must stay within.
+func Use(dir string) {
+	override = dir
+}
+
+// Dir returns the namebench data directory, creating it if necessary.
+func Dir() (string, error) {
+	dir := override
+	if dir == "" {
+		dir = os.Getenv(ENV_VAR)
+	}
+	if dir == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "namebench")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Path returns the path of a file within the data directory.
+func Path(elem ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{dir}, elem...)...), nil
+}
+
+// WriteFile atomically replaces path with data, via a temporary file and rename.
+func WriteFile(path string, data []byte) error {
+	t, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(t.Name())
+	if _, err := t.Write(data); err != nil {
+		t.Close()
+		return err
+	}
+	if err := t.Close(); err != nil {
+		return err
+	}
+	return os.Rename(t.Name(), path)
+}
@@ -0,0 +1,150 @@
+// the ddr package discovers the encrypted endpoints a plain DNS resolver
+// designates for itself (RFC 9462), as operating systems do to upgrade to
+// encrypted DNS.
+package ddr
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QUERY_NAME is the special-use name resolvers answer with their designations.
+const QUERY_NAME = "_dns.resolver.arpa."
+
+// TIMEOUT bounds the discovery query and each verification handshake.
+const TIMEOUT = 3 * time.Second
+
+// Default ports of the encrypted protocols.
+const (
+	DOH_PORT = 443
+	DOT_PORT = 853
+)
+
+// Designated is an encrypted endpoint a resolver designates.
+type Designated struct {
+	// Resolver is the plain resolver that designated it, as host:port.
+	Resolver string
+	Target   string
+	Port     uint16
+	ALPN     []string
+	Path     string
+	Hints    []net.IP
+
+	// Verified is set by Verify once the endpoint proved it is operated by Resolver.
+	Verified bool
+}
+
+// DoH returns true if the endpoint offers DNS over HTTPS.
+func (d Designated) DoH() bool {
+	if d.Path == "" {
+		return false
+	}
+	for _, p := range d.ALPN {
+		if p == "h2" || p == "h3" {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the DoH URL of the endpoint, without the URI template's variables.
+func (d Designated) URL() string {
+	path := d.Path
+	if i := strings.Index(path, "{"); i >= 0 {
+		path = path[:i]
+	}
+	host := d.Target
+	if d.Port != DOH_PORT {
+		host = net.JoinHostPort(host, strconv.Itoa(int(d.Port)))
+	}
+	return "https://" + host + path
+}
+
+// String describes the endpoint, e.g. "dns.google:443 (h2,h3) /dns-query{?dns}".
+func (d Designated) String() string {
+	s := fmt.Sprintf("%s:%d (%s)", d.Target, d.Port, strings.Join(d.ALPN, ","))
+	if d.Path != "" {
+		s += " " + d.Path
+	}
+	return s
+}
+
+// Discover asks resolver, as host:port, for the SVCB records of QUERY_NAME,
+// returning the endpoints it designates in priority order.
+func Discover(resolver string) (found []Designated, err error) {
+	m := new(dns.Msg)
+	m.SetQuestion(QUERY_NAME, dns.TypeSVCB)
+	in, _, err := (&dns.Client{Timeout: TIMEOUT}).Exchange(m, resolver)
+	if err != nil {
+		return nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s answered %s", QUERY_NAME, dns.RcodeToString[in.Rcode])
+	}
+	for _, rr := range in.Answer {
+		svcb, ok := rr.(*dns.SVCB)
+		// Priority 0 is an alias, which RFC 9462 does not allow here.
+		if !ok || svcb.Priority == 0 {
+			continue
+		}
+		d := Designated{Resolver: resolver, Target: strings.TrimSuffix(svcb.Target, ".")}
+		for _, kv := range svcb.Value {
+			switch v := kv.(type) {
+			case *dns.SVCBAlpn:
+				d.ALPN = v.Alpn
+			case *dns.SVCBPort:
+				d.Port = v.Port
+			case *dns.SVCBDoHPath:
+				d.Path = v.Template
+			case *dns.SVCBIPv4Hint:
+				d.Hints = append(d.Hints, v.Hint...)
+			case *dns.SVCBIPv6Hint:
+				d.Hints = append(d.Hints, v.Hint...)
+			}
+		}
+		if d.Port == 0 {
+			d.Port = DOT_PORT
+			if d.DoH() {
+				d.Port = DOH_PORT
+			}
+		}
+		found = append(found, d)
+	}
+	return found, nil
+}
+
+// Verify connects to the endpoint over TLS and checks, as RFC 9462 verified
+// discovery requires, that its certificate is valid for the target name and
+// also covers the IP address of the resolver that designated it.
+func Verify(d *Designated) error {
+	host, _, err := net.SplitHostPort(d.Resolver)
+	if err != nil {
+		return err
+	}
+	addr := d.Target
+	if len(d.Hints) > 0 {
+		addr = d.Hints[0].String()
+	}
+	dialer := &net.Dialer{Timeout: TIMEOUT}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(addr, strconv.Itoa(int(d.Port))),
+		&tls.Config{ServerName: d.Target, NextProtos: d.ALPN})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("%s presented no certificate", d.Target)
+	}
+	if err := certs[0].VerifyHostname(host); err != nil {
+		return fmt.Errorf("certificate of %s does not cover %s", d.Target, host)
+	}
+	d.Verified = true
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/namebench/ddr"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+var discover_designated = flag.Bool("ddr", false,
+	"Discover the encrypted endpoints each nameserver designates (RFC 9462 DDR) and benchmark the verified DoH ones too (cli mode)")
+
+// discoverDesignated asks each plain nameserver for its designated
+// resolvers, returning the verified DoH endpoints that are not already in
+// servers, and every designation by nameserver.
+func discoverDesignated(servers []string) (added []string, designated map[string][]ddr.Designated) {
+	designated = make(map[string][]ddr.Designated)
+	seen := make(map[string]bool)
+	for _, s := range servers {
+		seen[s] = true
+	}
+	for _, ns := range servers {
+		if dnsqueue.Encrypted(ns) {
+			continue
+		}
+		found, err := ddr.Discover(ns)
+		if err != nil {
+			log.Printf("DDR discovery for %s failed: %s", ns, err)
+			continue
+		}
+		for i := range found {
+			d := &found[i]
+			if err := ddr.Verify(d); err != nil {
+				log.Printf("%s designates %s, which failed verification: %s", ns, d, err)
+				continue
+			}
+			if d.DoH() && !seen[d.URL()] {
+				log.Printf("%s designates %s", ns, d.URL())
+				seen[d.URL()] = true
+				added = append(added, d.URL())
+			}
+		}
+		designated[ns] = found
+	}
+	return added, designated
+}
+
+// analyzeDesignated records a "ddr" finding on each nameserver that
+// designates encrypted endpoints, and on each endpoint benchmarked, naming
+// the nameserver that designated it.
+func analyzeDesignated(summaries []*report.Summary, designated map[string][]ddr.Designated) {
+	byURL := make(map[string][]string)
+	for ns, found := range designated {
+		for _, d := range found {
+			if d.Verified && d.DoH() {
+				byURL[d.URL()] = append(byURL[d.URL()], ns)
+			}
+		}
+	}
+	for _, s := range summaries {
+		if found, ok := designated[s.Nameserver]; ok {
+			var parts []string
+			unverified := false
+			for _, d := range found {
+				status := "verified"
+				if !d.Verified {
+					status = "unverified"
+					unverified = true
+				}
+				parts = append(parts, fmt.Sprintf("%s %s", d, status))
+			}
+			if len(parts) == 0 {
+				parts = append(parts, "no designated resolvers")
+			}
+			s.AddFinding(report.ANALYSIS, "ddr", strings.Join(parts, "; "), unverified)
+		}
+		if by, ok := byURL[s.Nameserver]; ok {
+			s.AddFinding(report.ANALYSIS, "ddr", "designated by "+strings.Join(by, ", "), false)
+		}
+	}
+}
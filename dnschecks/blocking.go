@@ -0,0 +1,124 @@
+// part of the dnschecks package, detects domains a resolver blocks that
+// other resolvers answer.
+package dnschecks
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+// BLOCKING_WORKERS is how many queries the blocking check runs at once.
+const BLOCKING_WORKERS = 8
+
+// bogons are networks a blocking resolver answers with instead of the real address.
+var bogons = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10",
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return
+}()
+
+// Bogon returns true if ip can not be a public web server.
+func Bogon(ip net.IP) bool {
+	for _, n := range bogons {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// outcome returns "" if a result holds a public answer, or why it does not.
+func outcome(r *dnsqueue.Result) string {
+	if r.Error != "" {
+		return r.Error
+	}
+	if r.Rcode != "NOERROR" {
+		return r.Rcode
+	}
+	found := false
+	for _, a := range r.Answers {
+		if a.IP == nil {
+			continue
+		}
+		if Bogon(a.IP) {
+			return fmt.Sprintf("answered %s", a.IP)
+		}
+		found = true
+	}
+	if !found {
+		return "no address"
+	}
+	return ""
+}
+
+// Blocked is a domain a resolver did not answer while most resolvers did.
+type Blocked struct {
+	Domain string
+	Reason string
+}
+
+// answered returns "" if s has a public answer for domain from the
+// benchmark, or why it does not: its failure class, or its addresses.
+func answered(s *report.Summary, domain string) string {
+	class, ok := s.Outcomes[domain]
+	if !ok {
+		return "no result"
+	}
+	if class != "" {
+		return class
+	}
+	addrs := s.Addresses[domain]
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil && Bogon(ip) {
+			return "answered " + a
+		}
+	}
+	if len(addrs) == 0 {
+		return "no address"
+	}
+	return ""
+}
+
+// CheckBlocking compares the answers the benchmark got for every domain
+// from every nameserver, without querying again. A domain most nameservers
+// answer with a public address is considered reachable, and a nameserver
+// that failed it, or answered a bogon, is blocking it. It returns the
+// blocked domains of each nameserver. Answers through other vantage points
+// are left out.
+func CheckBlocking(summaries []*report.Summary, domains []string) map[string][]Blocked {
+	var resolvers []*report.Summary
+	for _, s := range summaries {
+		if !s.Local && s.Vantage == "" {
+			resolvers = append(resolvers, s)
+		}
+	}
+	blocked := make(map[string][]Blocked)
+	for _, d := range domains {
+		reasons := make([]string, len(resolvers))
+		count := 0
+		for i, s := range resolvers {
+			if reasons[i] = answered(s, d); reasons[i] == "" {
+				count += 1
+			}
+		}
+		if count*2 <= len(resolvers) {
+			log.Printf("%s: only %d of %d nameservers answer, no consensus", d, count, len(resolvers))
+			continue
+		}
+		for i, s := range resolvers {
+			if reasons[i] != "" {
+				blocked[s.Nameserver] = append(blocked[s.Nameserver], Blocked{Domain: d, Reason: reasons[i]})
+			}
+		}
+	}
+	return blocked
+}
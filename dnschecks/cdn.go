@@ -0,0 +1,143 @@
+// part of the dnschecks package, measures how close the CDN endpoints each
+// resolver hands out are, by connecting to them.
+package dnschecks
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+)
+
+// CDN_HOSTNAMES are popular hostnames served by CDNs that pick an edge by
+// where the resolver (or the client subnet it sends) is.
+var CDN_HOSTNAMES = []string{
+	"www.apple.com", "www.microsoft.com", "www.amazon.com", "www.youtube.com", "www.facebook.com",
+	"www.netflix.com", "www.linkedin.com", "www.ebay.com", "cdn.jsdelivr.net", "www.bbc.co.uk",
+}
+
+const (
+	// CONNECT_PORT is where endpoints are connected to: every CDN serves HTTPS.
+	CONNECT_PORT = "443"
+	// CONNECT_TIMEOUT bounds each connection attempt.
+	CONNECT_TIMEOUT = 2 * time.Second
+	// CONNECT_ATTEMPTS is how many connections are timed per endpoint,
+	// keeping the fastest to discount one-off delays.
+	CONNECT_ATTEMPTS = 2
+)
+
+// CDNEndpoint is the address a resolver returned for a CDN hostname, and
+// the time a TCP connection to it took.
+type CDNEndpoint struct {
+	Hostname string
+	IP       net.IP
+	Connect  time.Duration
+	// Error is why the hostname was not resolved or connected to, if it was not.
+	Error string
+}
+
+// connectTime returns the fastest of CONNECT_ATTEMPTS TCP connections to ip.
+func connectTime(ip net.IP) (best time.Duration, err error) {
+	addr := net.JoinHostPort(ip.String(), CONNECT_PORT)
+	for i := 0; i < CONNECT_ATTEMPTS; i++ {
+		start := time.Now()
+		conn, cerr := net.DialTimeout("tcp", addr, CONNECT_TIMEOUT)
+		elapsed := time.Since(start)
+		if cerr != nil {
+			err = cerr
+			continue
+		}
+		conn.Close()
+		if best == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	if best > 0 {
+		err = nil
+	}
+	return best, err
+}
+
+// CDNAlignment resolves each hostname on every nameserver, sending subnet
+// as the client subnet if it is set, and times a connection to the first
+// public address of each answer. Addresses several nameservers return are
+// only timed once. It returns the endpoints by nameserver.
+func CDNAlignment(nameservers, hostnames []string, subnet *net.IPNet) (map[string][]CDNEndpoint, error) {
+	endpoints := make(map[string]map[string]*CDNEndpoint)
+	for _, ns := range nameservers {
+		endpoints[ns] = make(map[string]*CDNEndpoint)
+		for _, h := range hostnames {
+			endpoints[ns][h] = &CDNEndpoint{Hostname: h, Error: "no result"}
+		}
+	}
+	q := dnsqueue.StartQueue(context.Background(), BLOCKING_WORKERS*4, BLOCKING_WORKERS)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, ns := range nameservers {
+			for _, h := range hostnames {
+				if err := add(&dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: h + ".", ClientSubnet: subnet}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		name := r.Request.RecordName
+		e := endpoints[r.Request.Destination][name[:len(name)-1]]
+		if e.Error = outcome(r); e.Error != "" {
+			return
+		}
+		for _, a := range r.Answers {
+			if a.IP != nil {
+				e.IP = a.IP
+				break
+			}
+		}
+	})
+	if _, missing := err.(*dnsqueue.MissingResultsError); err != nil && !missing {
+		return nil, err
+	}
+
+	unique := make(map[string]net.IP)
+	for _, byHost := range endpoints {
+		for _, e := range byHost {
+			if e.IP != nil {
+				unique[e.IP.String()] = e.IP
+			}
+		}
+	}
+	times := make(map[string]time.Duration)
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan bool, BLOCKING_WORKERS)
+	for key, ip := range unique {
+		wg.Add(1)
+		sem <- true
+		go func(key string, ip net.IP) {
+			defer wg.Done()
+			d, cerr := connectTime(ip)
+			mu.Lock()
+			times[key], errs[key] = d, cerr
+			mu.Unlock()
+			<-sem
+		}(key, ip)
+	}
+	wg.Wait()
+
+	found := make(map[string][]CDNEndpoint)
+	for _, ns := range nameservers {
+		for _, h := range hostnames {
+			e := endpoints[ns][h]
+			if e.IP != nil {
+				e.Connect = times[e.IP.String()]
+				if cerr := errs[e.IP.String()]; cerr != nil {
+					e.Error = cerr.Error()
+				}
+			}
+			found[ns] = append(found[ns], *e)
+		}
+	}
+	return found, nil
+}
@@ -1,26 +1,12 @@
 package dnschecks
 
 import (
-	"github.com/google/namebench/dnsqueue"
 	"log"
-	"strings"
 )
 
+// DnsSec returns true if the nameserver validates DNSSEC; see ValidateDNSSEC.
 func DnsSec(ip string) (ok bool, err error) {
-	r := &dnsqueue.Request{
-		Destination:     ip,
-		RecordType:      "A",
-		RecordName:      "www.dnssec-failed.org.",
-		VerifySignature: true,
-	}
-	result, err := dnsqueue.SendQuery(r)
-	for _, answer := range result.Answers {
-		// TODO(tstromberg): Implement properly.
-		if strings.Contains(answer.String, "RRSIG") {
-			log.Printf("DnsSec for %s: true", ip)
-			return true, err
-		}
-	}
-	log.Printf("DnsSec for %s: false", ip)
-	return false, err
+	v, err := ValidateDNSSEC(ip)
+	log.Printf("DnsSec for %s: %s", ip, v.Describe())
+	return v.Validates(), err
 }
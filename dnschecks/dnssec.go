@@ -0,0 +1,83 @@
+// part of the dnschecks package, tells resolvers that validate DNSSEC from
+// those that only pass the DO bit along.
+package dnschecks
+
+import (
+	"fmt"
+
+	"github.com/google/namebench/dnsqueue"
+)
+
+// DNSSEC_SIGNED are zones with valid signatures: a validating resolver
+// answers them with the AD bit set.
+var DNSSEC_SIGNED = []string{"ietf.org.", "isc.org."}
+
+// DNSSEC_BOGUS are zones deliberately signed wrong: a validating resolver
+// refuses them with SERVFAIL.
+var DNSSEC_BOGUS = []string{"www.dnssec-failed.org.", "sigfail.verteiltesysteme.net."}
+
+// DNSSECValidation is the outcome of ValidateDNSSEC.
+type DNSSECValidation struct {
+	// Signed counts DNSSEC_SIGNED zones answered, Authenticated those with the AD bit.
+	Signed        int
+	Authenticated int
+	// Bogus counts DNSSEC_BOGUS zones answered, Rejected those with SERVFAIL.
+	Bogus    int
+	Rejected int
+}
+
+// Validates returns true if the resolver rejected every bogus zone and
+// authenticated every signed one.
+func (v DNSSECValidation) Validates() bool {
+	return v.Signed > 0 && v.Bogus > 0 && v.Authenticated == v.Signed && v.Rejected == v.Bogus
+}
+
+// Describe summarizes the outcome for a report.
+func (v DNSSECValidation) Describe() string {
+	detail := fmt.Sprintf("%d/%d signed zones authenticated, %d/%d bogus zones rejected",
+		v.Authenticated, v.Signed, v.Rejected, v.Bogus)
+	switch {
+	case v.Validates():
+		return "validates: " + detail
+	case v.Authenticated > 0 && v.Rejected < v.Bogus:
+		return "sets the AD bit but answers bogus zones: " + detail
+	case v.Rejected > 0 && v.Authenticated < v.Signed:
+		return "rejects bogus zones without setting the AD bit: " + detail
+	}
+	return "does not validate: " + detail
+}
+
+// ValidateDNSSEC queries server for signed and bogus zones with the DO bit
+// set. It fails if no zone of either kind was answered, as nothing can then
+// be told about the resolver.
+func ValidateDNSSEC(server string) (v DNSSECValidation, err error) {
+	query := func(name string) (dnsqueue.Result, bool) {
+		r, err := dnsqueue.SendQuery(&dnsqueue.Request{
+			Destination:     server,
+			RecordType:      "A",
+			RecordName:      name,
+			VerifySignature: true,
+		})
+		return r, err == nil && r.Error == ""
+	}
+	for _, name := range DNSSEC_SIGNED {
+		if r, ok := query(name); ok && r.Rcode == "NOERROR" {
+			v.Signed++
+			if r.Authenticated {
+				v.Authenticated++
+			}
+		}
+	}
+	for _, name := range DNSSEC_BOGUS {
+		if r, ok := query(name); ok {
+			v.Bogus++
+			if r.Rcode == "SERVFAIL" {
+				v.Rejected++
+			}
+		}
+	}
+	if v.Signed == 0 || v.Bogus == 0 {
+		return v, fmt.Errorf("answered %d/%d signed and %d/%d bogus zones", v.Signed, len(DNSSEC_SIGNED), v.Bogus, len(DNSSEC_BOGUS))
+	}
+	return v, nil
+}
@@ -0,0 +1,109 @@
+// part of the dnschecks package, detects duplicate, late or spoofed responses.
+package dnschecks
+
+import (
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// How long a socket stays open after its answer, listening for extras.
+	LINGER = 500 * time.Millisecond
+
+	// How long to wait for the first answer.
+	ANSWER_TIMEOUT = 2 * time.Second
+)
+
+// ResponseAudit counts unexpected packets seen while lingering after answers.
+type ResponseAudit struct {
+	Queries  int
+	Answered int
+	// Extra responses identical to the first answer.
+	Duplicates int
+	// Extra responses for the same query with different answers: a sign of
+	// spoofing or a middlebox injecting its own replies.
+	Mismatched int
+	// Responses that did not match any outstanding query.
+	Stray int
+}
+
+// Suspicious returns true if any mismatched or stray responses were seen.
+func (a ResponseAudit) Suspicious() bool {
+	return a.Mismatched > 0 || a.Stray > 0
+}
+
+// answerKey summarizes an answer section without TTLs, so copies compare equal.
+func answerKey(m *dns.Msg) string {
+	var parts []string
+	for _, rr := range m.Answer {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		parts = append(parts, rr.String())
+	}
+	sort.Strings(parts)
+	return dns.RcodeToString[m.Rcode] + "|" + strings.Join(parts, "|")
+}
+
+// auditQuery sends one query over a fresh socket and listens for LINGER after
+// the answer, classifying any extra packets.
+func auditQuery(ip string, name string, audit *ResponseAudit) error {
+	conn, err := dns.Dial("udp", ip)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	audit.Queries += 1
+	if err := conn.WriteMsg(m); err != nil {
+		return err
+	}
+
+	var first string
+	conn.SetReadDeadline(time.Now().Add(ANSWER_TIMEOUT))
+	for {
+		in, err := conn.ReadMsg()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil
+			}
+			// Unparseable packets, or ones with the wrong ID, are stray.
+			audit.Stray += 1
+			continue
+		}
+		if in.Id != m.Id || len(in.Question) == 0 || !strings.EqualFold(in.Question[0].Name, name) {
+			audit.Stray += 1
+			continue
+		}
+		key := answerKey(in)
+		switch {
+		case first == "":
+			first = key
+			audit.Answered += 1
+			conn.SetReadDeadline(time.Now().Add(LINGER))
+		case key == first:
+			audit.Duplicates += 1
+		default:
+			audit.Mismatched += 1
+		}
+	}
+}
+
+// AuditResponses queries each name on ip, keeping sockets open briefly after
+// each answer to catch duplicate, late or conflicting responses.
+func AuditResponses(ip string, names []string) (audit ResponseAudit, err error) {
+	for _, name := range names {
+		if err = auditQuery(ip, dns.Fqdn(name), &audit); err != nil {
+			log.Printf("Response audit of %s for %s failed: %s", ip, name, err)
+			return audit, err
+		}
+	}
+	log.Printf("Response audit for %s: %+v", ip, audit)
+	return audit, nil
+}
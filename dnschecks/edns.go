@@ -0,0 +1,245 @@
+// part of the dnschecks package, probes how resolvers handle EDNS, after
+// ISC's EDNS compliance tester (ednscomp): resolvers that mishandle it time
+// out or fail on queries that clients send every day.
+package dnschecks
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// EDNS_QUESTION is the zone the probes ask about: its SOA, as ednscomp asks
+// for, which every resolver can answer.
+const EDNS_QUESTION = "."
+
+// EDNS_BUFFER is the UDP buffer size the probes advertise, as ednscomp does.
+const EDNS_BUFFER = 4096
+
+// EDNS_UNKNOWN_OPTION is an unassigned option code.
+const EDNS_UNKNOWN_OPTION = 100
+
+// EDNS_UNKNOWN_FLAG is an undefined bit of the EDNS flags, the one ednscomp
+// sets.
+const EDNS_UNKNOWN_FLAG = 0x80
+
+// EDNSProbe is the outcome of one probe: Problem is empty if the resolver
+// answered as the standard requires.
+type EDNSProbe struct {
+	Name    string
+	Problem string
+}
+
+// EDNSCompliance is the outcome of CheckEDNS.
+type EDNSCompliance struct {
+	Probes []EDNSProbe
+	// Cookies is true if the resolver answered a client cookie with a
+	// server cookie. They are optional, so lacking them is no problem.
+	Cookies bool
+	// Buffer is the UDP buffer size the resolver advertised.
+	Buffer uint16
+}
+
+// Problems returns the failed probes, as "name: problem".
+func (c EDNSCompliance) Problems() (problems []string) {
+	for _, p := range c.Probes {
+		if p.Problem != "" {
+			problems = append(problems, p.Name+": "+p.Problem)
+		}
+	}
+	return problems
+}
+
+// Describe summarizes the outcome for a report.
+func (c EDNSCompliance) Describe() string {
+	problems := c.Problems()
+	detail := fmt.Sprintf("%d/%d EDNS probes passed, %d byte UDP buffer", len(c.Probes)-len(problems), len(c.Probes), c.Buffer)
+	if c.Cookies {
+		detail += ", cookies"
+	}
+	if len(problems) > 0 {
+		detail += "; " + strings.Join(problems, "; ")
+	}
+	return detail
+}
+
+// ednsQuery returns a query for name with an OPT record.
+func ednsQuery(name string, qtype uint16) (*dns.Msg, *dns.OPT) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(EDNS_BUFFER, false)
+	return m, m.IsEdns0()
+}
+
+// rcodeName returns the name of an rcode, with 16 named BADVERS as EDNS
+// answers it rather than TSIG's BADSIG.
+func rcodeName(rcode int) string {
+	if rcode == dns.RcodeBadVers {
+		return "BADVERS"
+	}
+	return dns.RcodeToString[rcode]
+}
+
+// checkOPT returns the problem with an answer to an EDNS query, or "" if
+// there is none: it must carry an OPT record of version 0, with rcode as its
+// result.
+func checkOPT(in *dns.Msg, rcode int) string {
+	opt := in.IsEdns0()
+	switch {
+	case in.Rcode != rcode:
+		return fmt.Sprintf("answered %s instead of %s", rcodeName(in.Rcode), rcodeName(rcode))
+	case opt == nil:
+		return "no OPT record in the answer"
+	case opt.Version() != 0:
+		return fmt.Sprintf("answered with EDNS version %d", opt.Version())
+	}
+	return ""
+}
+
+// EDNS_PROBES are ednscomp's tests that apply to resolvers, each building
+// its query and judging the answer.
+var EDNS_PROBES = []struct {
+	name  string
+	query func() *dns.Msg
+	judge func(in *dns.Msg) string
+}{
+	{"edns", func() *dns.Msg {
+		m, _ := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+		return m
+	}, func(in *dns.Msg) string {
+		return checkOPT(in, dns.RcodeSuccess)
+	}},
+	// Version 1 does not exist: the answer must be BADVERS, with no records.
+	{"edns1", func() *dns.Msg {
+		m, opt := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+		opt.SetVersion(1)
+		return m
+	}, func(in *dns.Msg) string {
+		if problem := checkOPT(in, dns.RcodeBadVers); problem != "" {
+			return problem
+		}
+		if len(in.Answer) > 0 {
+			return "answered records to an unknown EDNS version"
+		}
+		return ""
+	}},
+	// Unknown flags must be ignored, and cleared in the answer.
+	{"ednsflags", func() *dns.Msg {
+		m, opt := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+		opt.SetZ(EDNS_UNKNOWN_FLAG)
+		return m
+	}, func(in *dns.Msg) string {
+		if problem := checkOPT(in, dns.RcodeSuccess); problem != "" {
+			return problem
+		}
+		if in.IsEdns0().Z()&EDNS_UNKNOWN_FLAG != 0 {
+			return "echoed an unknown EDNS flag"
+		}
+		return ""
+	}},
+	// Unknown options must be ignored, and not echoed.
+	{"ednsopt", func() *dns.Msg {
+		m, opt := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+		opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: EDNS_UNKNOWN_OPTION})
+		return m
+	}, func(in *dns.Msg) string {
+		if problem := checkOPT(in, dns.RcodeSuccess); problem != "" {
+			return problem
+		}
+		for _, o := range in.IsEdns0().Option {
+			if o.Option() == EDNS_UNKNOWN_OPTION {
+				return "echoed an unknown EDNS option"
+			}
+		}
+		return ""
+	}},
+	// The DO bit must be copied to the answer.
+	{"do", func() *dns.Msg {
+		m, opt := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+		opt.SetDo()
+		return m
+	}, func(in *dns.Msg) string {
+		if problem := checkOPT(in, dns.RcodeSuccess); problem != "" {
+			return problem
+		}
+		if !in.IsEdns0().Do() {
+			return "cleared the DO bit"
+		}
+		return ""
+	}},
+	// A large answer, the root's signed DNSKEY set, must arrive or be
+	// truncated so the client retries over TCP; losing it means fragments
+	// are dropped on the way.
+	{"large", func() *dns.Msg {
+		m, opt := ednsQuery(EDNS_QUESTION, dns.TypeDNSKEY)
+		opt.SetDo()
+		return m
+	}, func(in *dns.Msg) string {
+		return checkOPT(in, dns.RcodeSuccess)
+	}},
+}
+
+// checkCookie sends a client cookie, returning whether the resolver answered
+// with a server cookie, and the problem with its answer, if any.
+func checkCookie(client *dns.Client, ip string) (supported bool, problem string, err error) {
+	m, opt := ednsQuery(EDNS_QUESTION, dns.TypeSOA)
+	clientCookie := make([]byte, 8)
+	if _, err := rand.Read(clientCookie); err != nil {
+		return false, "", err
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: fmt.Sprintf("%x", clientCookie)})
+	in, _, err := client.Exchange(m, ip)
+	if err != nil {
+		return false, "", err
+	}
+	if problem := checkOPT(in, dns.RcodeSuccess); problem != "" {
+		return false, problem, nil
+	}
+	for _, o := range in.IsEdns0().Option {
+		cookie, ok := o.(*dns.EDNS0_COOKIE)
+		if !ok {
+			continue
+		}
+		// The client cookie, then a server cookie of 8 to 32 bytes.
+		echoed := fmt.Sprintf("%x", clientCookie)
+		if n := len(cookie.Cookie) / 2; !strings.HasPrefix(cookie.Cookie, echoed) || n < 16 || n > 40 {
+			return false, fmt.Sprintf("answered a malformed cookie %s", cookie.Cookie), nil
+		}
+		return true, "", nil
+	}
+	return false, "", nil
+}
+
+// CheckEDNS runs EDNS_PROBES and a cookie probe against ip over UDP. A probe
+// that gets no answer fails, as a client would time out; CheckEDNS itself
+// fails only if a plain query gets no answer either, as nothing can then be
+// told about the resolver.
+func CheckEDNS(ip string) (c EDNSCompliance, err error) {
+	client := &dns.Client{Timeout: ANSWER_TIMEOUT, UDPSize: EDNS_BUFFER}
+	plain := new(dns.Msg)
+	plain.SetQuestion(EDNS_QUESTION, dns.TypeSOA)
+	if _, _, err := client.Exchange(plain, ip); err != nil {
+		return c, err
+	}
+	for _, probe := range EDNS_PROBES {
+		result := EDNSProbe{Name: probe.name}
+		in, _, err := client.Exchange(probe.query(), ip)
+		if err != nil {
+			result.Problem = "no answer, " + err.Error()
+		} else {
+			result.Problem = probe.judge(in)
+			if opt := in.IsEdns0(); probe.name == "edns" && opt != nil {
+				c.Buffer = opt.UDPSize()
+			}
+		}
+		c.Probes = append(c.Probes, result)
+	}
+	cookie := EDNSProbe{Name: "cookie"}
+	if c.Cookies, cookie.Problem, err = checkCookie(client, ip); err != nil {
+		cookie.Problem = "no answer, " + err.Error()
+	}
+	c.Probes = append(c.Probes, cookie)
+	return c, nil
+}
@@ -0,0 +1,67 @@
+// part of the dnschecks package, checks whether failed DNS lookups fall back
+// to LLMNR or NetBIOS name resolution.
+package dnschecks
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned by checks not implemented on this platform.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// Remediation notes for enterprise users, shown alongside the finding.
+const (
+	LLMNR_REMEDIATION = "disable with the Group Policy \"Turn off multicast name resolution\" " +
+		"(Computer Configuration > Administrative Templates > Network > DNS Client)"
+	NETBIOS_REMEDIATION = "disable NetBIOS over TCP/IP on each adapter (IPv4 > Advanced > WINS), " +
+		"or with DHCP option 001 for Microsoft clients"
+)
+
+// NameFallback describes which broadcast protocols Windows falls back to when
+// a DNS lookup fails. Both answer to anyone on the LAN, which allows spoofing
+// (and credential capture), and both add latency to every failed lookup.
+type NameFallback struct {
+	LLMNR bool
+	// NetBIOSInterfaces counts network interfaces with NetBIOS over TCP/IP
+	// enabled, out of Interfaces.
+	NetBIOSInterfaces int
+	Interfaces        int
+}
+
+// Suspicious returns true if either fallback is enabled.
+func (f NameFallback) Suspicious() bool {
+	return f.LLMNR || f.NetBIOSInterfaces > 0
+}
+
+// regDwords returns every REG_DWORD value named name in reg query output,
+// which lists values as "    NetbiosOptions    REG_DWORD    0x2".
+func regDwords(output, name string) (values []uint64) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.EqualFold(fields[0], name) || fields[1] != "REG_DWORD" {
+			continue
+		}
+		if v, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32); err == nil {
+			values = append(values, v)
+		}
+	}
+	return
+}
+
+// parseFallback interprets the EnableMulticast policy (LLMNR is on unless it
+// is 0) and each interface's NetbiosOptions (NetBIOS is on unless it is 2).
+func parseFallback(llmnrPolicy, netbiosOptions string) (f NameFallback) {
+	f.LLMNR = true
+	for _, v := range regDwords(llmnrPolicy, "EnableMulticast") {
+		f.LLMNR = v != 0
+	}
+	for _, v := range regDwords(netbiosOptions, "NetbiosOptions") {
+		f.Interfaces += 1
+		if v != 2 {
+			f.NetBIOSInterfaces += 1
+		}
+	}
+	return
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+// part of the dnschecks package, the name resolution fallback check is Windows only.
+package dnschecks
+
+// CheckNameFallback returns ErrUnsupported outside of Windows.
+func CheckNameFallback() (NameFallback, error) {
+	return NameFallback{}, ErrUnsupported
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+// part of the dnschecks package, reads name resolution fallback settings from the registry.
+package dnschecks
+
+import "os/exec"
+
+const (
+	LLMNR_POLICY_KEY = `HKLM\SOFTWARE\Policies\Microsoft\Windows NT\DNSClient`
+	NETBT_KEY        = `HKLM\SYSTEM\CurrentControlSet\Services\NetBT\Parameters\Interfaces`
+)
+
+// CheckNameFallback reads the LLMNR policy and per-interface NetBIOS settings.
+func CheckNameFallback() (NameFallback, error) {
+	// A missing policy value makes reg query fail, and means LLMNR is on.
+	llmnr, _ := exec.Command("reg", "query", LLMNR_POLICY_KEY, "/v", "EnableMulticast").Output()
+	netbios, err := exec.Command("reg", "query", NETBT_KEY, "/s", "/v", "NetbiosOptions").Output()
+	if err != nil {
+		return NameFallback{}, err
+	}
+	return parseFallback(string(llmnr), string(netbios)), nil
+}
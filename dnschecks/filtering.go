@@ -0,0 +1,116 @@
+// part of the dnschecks package, finds out whether resolvers filter malware
+// or adult content, from the test domains filtering services publish.
+package dnschecks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+// Content categories filtering resolvers block.
+const (
+	MALWARE = "malware"
+	ADULT   = "adult"
+)
+
+// FILTER_CATEGORIES lists the categories, in report order.
+var FILTER_CATEGORIES = []string{MALWARE, ADULT}
+
+// FILTER_TEST_DOMAINS are domains filtering services publish for checking
+// that their filters work, by category. They resolve normally elsewhere.
+var FILTER_TEST_DOMAINS = map[string][]string{
+	MALWARE: {
+		"internetbadguys.com",      // OpenDNS phishing test
+		"examplemalwaredomain.com", // OpenDNS malware test
+		"malware.testcategory.com", // Cloudflare for Families
+		"isitblocked.org",          // Quad9
+	},
+	ADULT: {
+		"exampleadultsite.com",    // OpenDNS
+		"nudity.testcategory.com", // Cloudflare for Families
+	},
+}
+
+// Filtering is whether a resolver filters one category.
+type Filtering struct {
+	Category string
+	// Tested counts the category's test domains the trusted resolver
+	// answered, and Filtered lists those the resolver did not.
+	Tested   int
+	Filtered []string
+}
+
+// Filters returns true if the resolver filtered most of the test domains.
+func (f Filtering) Filters() bool {
+	return f.Tested > 0 && len(f.Filtered)*2 > f.Tested
+}
+
+// filtered returns true if r blocks its domain: a block page or extended
+// error, NXDOMAIN, a refusal, or no public address.
+func filtered(r *dnsqueue.Result) bool {
+	return r == nil || report.Blocked(r) || outcome(r) != ""
+}
+
+// CheckFiltering queries the FILTER_TEST_DOMAINS on every nameserver and on
+// trusted, an unfiltered resolver, returning for each nameserver which
+// categories it filters. Test domains trusted does not answer with a public
+// address are skipped; it fails if that is all of them.
+func CheckFiltering(nameservers []string, trusted string) (map[string][]Filtering, error) {
+	results := make(map[string]map[string]*dnsqueue.Result)
+	for _, ns := range append([]string{trusted}, nameservers...) {
+		results[ns] = make(map[string]*dnsqueue.Result)
+	}
+	q := dnsqueue.StartQueue(context.Background(), BLOCKING_WORKERS*4, BLOCKING_WORKERS)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for ns := range results {
+			for _, category := range FILTER_CATEGORIES {
+				for _, d := range FILTER_TEST_DOMAINS[category] {
+					if err := add(&dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: d + ".", EDNS: true}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		name := r.Request.RecordName
+		results[r.Request.Destination][name[:len(name)-1]] = r
+	})
+	if _, missing := err.(*dnsqueue.MissingResultsError); err != nil && !missing {
+		return nil, err
+	}
+
+	filtering := make(map[string][]Filtering)
+	tested := 0
+	for _, category := range FILTER_CATEGORIES {
+		byServer := make(map[string]*Filtering)
+		for _, ns := range nameservers {
+			byServer[ns] = &Filtering{Category: category}
+		}
+		for _, d := range FILTER_TEST_DOMAINS[category] {
+			if filtered(results[trusted][d]) {
+				continue
+			}
+			tested++
+			for _, ns := range nameservers {
+				f := byServer[ns]
+				f.Tested++
+				if filtered(results[ns][d]) {
+					f.Filtered = append(f.Filtered, d)
+				}
+			}
+		}
+		for _, ns := range nameservers {
+			if byServer[ns].Tested > 0 {
+				filtering[ns] = append(filtering[ns], *byServer[ns])
+			}
+		}
+	}
+	if tested == 0 {
+		return nil, fmt.Errorf("trusted resolver %s answered none of the filter test domains", trusted)
+	}
+	return filtering, nil
+}
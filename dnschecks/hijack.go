@@ -0,0 +1,75 @@
+// part of the dnschecks package, detects resolvers that answer nonexistent
+// domains with addresses of their own, e.g. ISP search or advertising pages.
+package dnschecks
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+
+	"github.com/google/namebench/dnsqueue"
+)
+
+// HIJACK_QUERIES is how many random nonexistent domains are queried.
+const HIJACK_QUERIES = 3
+
+// HIJACK_TLDS are where the random domains are made up. They are real TLDs,
+// as some resolvers leave reserved ones such as .invalid alone.
+var HIJACK_TLDS = []string{"com", "net", "org"}
+
+// Hijack is the outcome of WildcardHijack.
+type Hijack struct {
+	Queries  int
+	Answered int
+	// Hijacked counts nonexistent domains answered with A records.
+	Hijacked int
+	// Addresses are those returned for nonexistent domains.
+	Addresses []net.IP
+}
+
+// Hijacks returns true if any nonexistent domain was given an address.
+func (h Hijack) Hijacks() bool {
+	return h.Hijacked > 0
+}
+
+// nonexistentDomain returns a random domain under tld that will not exist.
+func nonexistentDomain(tld string) string {
+	return fmt.Sprintf("nb%016x.%s.", rand.Uint64(), tld)
+}
+
+// WildcardHijack queries server for HIJACK_QUERIES random nonexistent
+// domains, counting those answered with A records instead of NXDOMAIN. It
+// fails if none were answered at all.
+func WildcardHijack(server string) (h Hijack, err error) {
+	seen := make(map[string]bool)
+	for i := 0; i < HIJACK_QUERIES; i++ {
+		h.Queries++
+		r, err := dnsqueue.SendQuery(&dnsqueue.Request{
+			Destination: server,
+			RecordType:  "A",
+			RecordName:  nonexistentDomain(HIJACK_TLDS[i%len(HIJACK_TLDS)]),
+		})
+		if err != nil || r.Error != "" {
+			continue
+		}
+		h.Answered++
+		hijacked := false
+		for _, a := range r.Answers {
+			if a.IP == nil || a.IP.To4() == nil {
+				continue
+			}
+			hijacked = true
+			if !seen[a.IP.String()] {
+				seen[a.IP.String()] = true
+				h.Addresses = append(h.Addresses, a.IP)
+			}
+		}
+		if hijacked {
+			h.Hijacked++
+		}
+	}
+	if h.Answered == 0 {
+		return h, fmt.Errorf("none of %d nonexistent domains were answered", h.Queries)
+	}
+	return h, nil
+}
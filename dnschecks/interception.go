@@ -0,0 +1,51 @@
+// part of the dnschecks package, detects DNS interception on the network path.
+package dnschecks
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// UNROUTED is an address no DNS server listens on (TEST-NET-1, RFC 5737).
+	// An answer from it means something on the path answers port 53 itself.
+	UNROUTED = "192.0.2.1:53"
+
+	// WHOAMI returns the address of the resolver querying Akamai's
+	// authoritative servers, i.e. the egress of whoever really resolved it.
+	WHOAMI = "whoami.akamai.net."
+
+	INTERCEPTION_TIMEOUT = 2 * time.Second
+)
+
+// PathIntercepted returns true if a query sent to UNROUTED was answered.
+// Timeouts and ICMP errors are the expected outcome.
+func PathIntercepted() bool {
+	c := &dns.Client{Timeout: INTERCEPTION_TIMEOUT}
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	_, _, err := c.Exchange(m, UNROUTED)
+	return err == nil
+}
+
+// Egress returns the address nameserver's queries reach authoritative
+// servers from. For a public resolver it should be in the resolver's own
+// network; anything else suggests a middlebox answered instead.
+func Egress(nameserver string) (net.IP, error) {
+	c := &dns.Client{Timeout: INTERCEPTION_TIMEOUT}
+	m := new(dns.Msg)
+	m.SetQuestion(WHOAMI, dns.TypeA)
+	in, _, err := c.Exchange(m, nameserver)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range in.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			return a.A, nil
+		}
+	}
+	return nil, fmt.Errorf("no answer for %s", WHOAMI)
+}
@@ -0,0 +1,140 @@
+// part of the dnschecks package, verifies resolvers against split-horizon
+// (internal) zones.
+package dnschecks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+)
+
+// InternalZone is a zone only some resolvers serve correctly, such as an
+// intranet domain. Names are queried to test it, defaulting to the zone itself.
+type InternalZone struct {
+	Zone      string   `json:"zone"`
+	Names     []string `json:"names"`
+	Resolvers []string `json:"resolvers"`
+}
+
+// LoadInternalZones reads a JSON file of the form
+// {"internal_zones": [{"zone": "corp.example.com", "names": [...], "resolvers": ["10.0.0.53"]}]}.
+func LoadInternalZones(path string) ([]InternalZone, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Zones []InternalZone `json:"internal_zones"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for i := range file.Zones {
+		z := &file.Zones[i]
+		if z.Zone, err = parse.Domain(z.Zone); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if len(z.Names) == 0 {
+			z.Names = []string{z.Zone}
+		}
+		for j, name := range z.Names {
+			if z.Names[j], err = parse.Domain(name); err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+		}
+		if len(z.Resolvers) == 0 {
+			return nil, fmt.Errorf("%s: zone %s lists no resolvers", path, z.Zone)
+		}
+		for j, ns := range z.Resolvers {
+			if z.Resolvers[j], err = parse.Nameserver(ns); err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+		}
+	}
+	return file.Zones, nil
+}
+
+// ZoneCheck is the outcome of testing one resolver against internal zones.
+type ZoneCheck struct {
+	Checked  int
+	Correct  int
+	Problems []string
+}
+
+// ZoneChecker tests resolvers against internal zones, comparing their
+// answers with those of each zone's expected resolvers.
+type ZoneChecker struct {
+	zones    []InternalZone
+	expected map[string]string
+}
+
+// lookup returns the sorted A records a nameserver returns for name, or "" if
+// it returned none, along with the response code.
+func lookup(ns, name string) (answers string, rcode string, err error) {
+	result, err := dnsqueue.SendQuery(&dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: name + "."})
+	if err == nil && result.Error != "" {
+		err = fmt.Errorf("%s", result.Error)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var ips []string
+	for _, a := range result.Answers {
+		if a.IP != nil {
+			ips = append(ips, a.IP.String())
+		}
+	}
+	sort.Strings(ips)
+	return strings.Join(ips, ","), result.Rcode, nil
+}
+
+// NewZoneChecker queries each zone's expected resolvers for the reference
+// answers. A name is expected to resolve if any of them answers it.
+func NewZoneChecker(zones []InternalZone) *ZoneChecker {
+	c := &ZoneChecker{zones: zones, expected: make(map[string]string)}
+	for _, z := range zones {
+		for _, name := range z.Names {
+			for _, ns := range z.Resolvers {
+				if answers, _, err := lookup(ns, name); err == nil && answers != "" {
+					c.expected[name] = answers
+					break
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Check tests a nameserver. A zone's expected resolvers must return the
+// internal answers; any other resolver must either refuse the names (no
+// answer) or return the same answers, since a different answer sends
+// intranet users somewhere else.
+func (c *ZoneChecker) Check(nameserver string) (check ZoneCheck) {
+	for _, z := range c.zones {
+		internal := false
+		for _, ns := range z.Resolvers {
+			internal = internal || ns == nameserver
+		}
+		for _, name := range z.Names {
+			check.Checked += 1
+			want := c.expected[name]
+			answers, rcode, err := lookup(nameserver, name)
+			switch {
+			case err != nil && internal:
+				check.Problems = append(check.Problems, fmt.Sprintf("%s: %s", name, err))
+			case internal && answers != want:
+				check.Problems = append(check.Problems, fmt.Sprintf("%s: %s [%s], expected %s", name, answers, rcode, want))
+			case !internal && answers != "" && answers != want:
+				check.Problems = append(check.Problems, fmt.Sprintf("%s: answered %s, internal answer is %s", name, answers, want))
+			default:
+				check.Correct += 1
+			}
+		}
+	}
+	return check
+}
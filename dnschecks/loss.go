@@ -0,0 +1,80 @@
+// part of the dnschecks package, measures packet loss and jitter by sending
+// a steady stream of identical queries to each nameserver.
+package dnschecks
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/stats"
+)
+
+// LOSS_QPS paces the probes to each nameserver, so that loss comes from the
+// network rather than a burst of queries.
+const LOSS_QPS = 10
+
+// Loss is the outcome of the probes sent to one nameserver.
+type Loss struct {
+	Sent      int
+	Answered  int
+	Durations []time.Duration
+}
+
+// Lost returns the number of probes that got no answer.
+func (l *Loss) Lost() int {
+	return l.Sent - l.Answered
+}
+
+// Rate returns the share of probes lost, from 0 to 1.
+func (l *Loss) Rate() float64 {
+	if l.Sent == 0 {
+		return 0
+	}
+	return float64(l.Lost()) / float64(l.Sent)
+}
+
+// Jitter returns the standard deviation and interquartile range of the
+// answered probes' durations.
+func (l *Loss) Jitter() (stddev, iqr time.Duration) {
+	sorted := stats.Sorted(l.Durations)
+	return stats.Stddev(sorted), stats.IQR(sorted)
+}
+
+// MeasureLoss sends probes queries for hostname to each nameserver, at most
+// LOSS_QPS a second and without retries. The first answer warms the cache,
+// so the rest mostly time the network. Any answer, even an error code,
+// counts as delivered.
+func MeasureLoss(nameservers []string, hostname string, probes int) (map[string]*Loss, error) {
+	losses := make(map[string]*Loss)
+	for _, ns := range nameservers {
+		losses[ns] = &Loss{}
+	}
+	q := dnsqueue.StartQueue(context.Background(), BLOCKING_WORKERS*4, BLOCKING_WORKERS)
+	q.LimitRate(LOSS_QPS)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for i := 0; i < probes; i++ {
+			for _, ns := range nameservers {
+				if err := add(&dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: hostname + "."}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		l := losses[r.Request.Destination]
+		l.Sent++
+		if r.Error == "" {
+			l.Answered++
+			l.Durations = append(l.Durations, r.Duration)
+		}
+	})
+	if missing, ok := err.(*dnsqueue.MissingResultsError); ok {
+		for _, r := range missing.Requests {
+			losses[r.Destination].Sent++
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return losses, nil
+}
@@ -0,0 +1,128 @@
+// part of the dnschecks package, checks the randomness that makes spoofing a
+// resolver's answers hard: 0x20 mixed case in query names, which must come
+// back unchanged, and the resolver's choice of source ports.
+package dnschecks
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// PORT_TEST_NAME is DNS-OARC's port randomization test: its nameservers
+// answer with a TXT record rating the source ports the resolver used to
+// reach them.
+const PORT_TEST_NAME = "porttest.dns-oarc.net."
+
+// PORT_TEST_GOOD are the ratings of adequately random source ports.
+var PORT_TEST_GOOD = map[string]bool{"GREAT": true, "GOOD": true}
+
+// portTestPattern matches the TXT record of PORT_TEST_NAME, e.g.
+// "192.0.2.1 is GREAT: 26 queries in 2.1 seconds from 26 ports with std dev 18250".
+var portTestPattern = regexp.MustCompile(`is ([A-Z]+): (\d+) queries in [\d.]+ seconds from (\d+) ports with std dev (\d+)`)
+
+// CaseCheck is the outcome of CheckCase.
+type CaseCheck struct {
+	Queries  int
+	Answered int
+	// Preserved counts answers whose question kept the query's mixed case.
+	Preserved int
+}
+
+// Preserves returns true if every answer kept the mixed case.
+func (c CaseCheck) Preserves() bool {
+	return c.Answered > 0 && c.Preserved == c.Answered
+}
+
+// mixCase returns name with each letter randomly upper or lower case, as a
+// client using 0x20 encoding sends it.
+func mixCase(name string) string {
+	b := []byte(strings.ToLower(name))
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' && rand.Intn(2) == 0 {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// CheckCase queries each name on ip with 0x20 mixed case, counting answers
+// that return the question exactly as asked. Clients that check the case to
+// reject spoofed answers fail against resolvers that change it. It fails if
+// none were answered at all.
+func CheckCase(ip string, names []string) (c CaseCheck, err error) {
+	client := &dns.Client{Timeout: ANSWER_TIMEOUT}
+	for _, name := range names {
+		m := new(dns.Msg)
+		m.SetQuestion(mixCase(dns.Fqdn(name)), dns.TypeA)
+		c.Queries += 1
+		in, _, err := client.Exchange(m, ip)
+		if err != nil || len(in.Question) == 0 {
+			continue
+		}
+		c.Answered += 1
+		if in.Question[0].Name == m.Question[0].Name {
+			c.Preserved += 1
+		}
+	}
+	if c.Answered == 0 {
+		return c, fmt.Errorf("no answers to mixed case queries")
+	}
+	log.Printf("0x20 check for %s: %+v", ip, c)
+	return c, nil
+}
+
+// PortTest is the outcome of CheckPorts.
+type PortTest struct {
+	// Rating is GREAT, GOOD, FAIR or POOR.
+	Rating  string
+	Queries int
+	Ports   int
+	StdDev  int
+}
+
+// Randomizes returns true if the resolver's source ports were rated random
+// enough.
+func (p PortTest) Randomizes() bool {
+	return PORT_TEST_GOOD[p.Rating]
+}
+
+// String describes the test as DNS-OARC's rating does.
+func (p PortTest) String() string {
+	return fmt.Sprintf("%s: %d queries from %d ports, std dev %d", p.Rating, p.Queries, p.Ports, p.StdDev)
+}
+
+// CheckPorts asks ip to resolve PORT_TEST_NAME, which makes it send a series
+// of queries to the test zone, and returns how random their source ports
+// were. Only recursive resolvers that can reach the test zone can be rated.
+func CheckPorts(ip string) (p PortTest, err error) {
+	client := &dns.Client{Timeout: 2 * ANSWER_TIMEOUT}
+	m := new(dns.Msg)
+	m.SetQuestion(PORT_TEST_NAME, dns.TypeTXT)
+	in, _, err := client.Exchange(m, ip)
+	if err != nil {
+		return p, err
+	}
+	for _, rr := range in.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		match := portTestPattern.FindStringSubmatch(strings.Join(txt.Txt, ""))
+		if match == nil {
+			continue
+		}
+		p.Rating = match[1]
+		p.Queries, _ = strconv.Atoi(match[2])
+		p.Ports, _ = strconv.Atoi(match[3])
+		p.StdDev, _ = strconv.Atoi(match[4])
+		log.Printf("Port randomization for %s: %s", ip, p)
+		return p, nil
+	}
+	return p, fmt.Errorf("no rating from %s (%s)", PORT_TEST_NAME, dns.RcodeToString[in.Rcode])
+}
@@ -0,0 +1,125 @@
+// part of the dnschecks package, finds the query rate at which a resolver
+// starts limiting a single client.
+package dnschecks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+)
+
+// RATE_STEPS are the query rates tried, in queries per second.
+var RATE_STEPS = []int{25, 50, 100, 200, 400, 800}
+
+// RATE_STEP_DURATION is how long each rate is sustained.
+const RATE_STEP_DURATION = time.Second
+
+// RATE_LIMIT_THRESHOLD is the share of limited queries that marks the onset
+// of rate limiting, above ordinary packet loss.
+const RATE_LIMIT_THRESHOLD = 0.05
+
+// RateStep counts how queries fared at one rate.
+type RateStep struct {
+	QPS       int
+	Sent      int
+	Dropped   int
+	Refused   int
+	Truncated int
+}
+
+// Limited returns the number of queries that were dropped, refused or truncated.
+func (s RateStep) Limited() int {
+	return s.Dropped + s.Refused + s.Truncated
+}
+
+// String describes the step, e.g. "at 400 qps: 12 dropped, 30 refused, 0 truncated".
+func (s RateStep) String() string {
+	return fmt.Sprintf("at %d qps: %d dropped, %d refused, %d truncated of %d",
+		s.QPS, s.Dropped, s.Refused, s.Truncated, s.Sent)
+}
+
+// RateLimit is the outcome of ramping the query rate against a resolver.
+type RateLimit struct {
+	Steps []RateStep
+
+	// Ceiling is the highest rate answered without limiting, 0 if even the
+	// first step was limited. Limited is false if no step was.
+	Ceiling int
+	Limited bool
+}
+
+// Describe summarizes the probe, e.g. "~200 qps ceiling (at 400 qps: ...)".
+func (r RateLimit) Describe() string {
+	if len(r.Steps) == 0 {
+		return "not probed"
+	}
+	last := r.Steps[len(r.Steps)-1]
+	if !r.Limited {
+		return fmt.Sprintf("no limiting up to %d qps", last.QPS)
+	}
+	if r.Ceiling == 0 {
+		return fmt.Sprintf("limited below %d qps (%s)", last.QPS, last)
+	}
+	return fmt.Sprintf("~%d qps ceiling (%s)", r.Ceiling, last)
+}
+
+// CheckRateLimit sends names to nameserver at each of RATE_STEPS in turn,
+// stopping at the first rate where more than RATE_LIMIT_THRESHOLD of the
+// queries went unanswered, were refused or were truncated.
+func CheckRateLimit(nameserver string, names []string) (RateLimit, error) {
+	var limit RateLimit
+	if len(names) == 0 {
+		return limit, fmt.Errorf("no names to query")
+	}
+	for _, qps := range RATE_STEPS {
+		step := rateStep(nameserver, names, qps)
+		limit.Steps = append(limit.Steps, step)
+		if float64(step.Limited()) > RATE_LIMIT_THRESHOLD*float64(step.Sent) {
+			limit.Limited = true
+			return limit, nil
+		}
+		limit.Ceiling = qps
+	}
+	return limit, nil
+}
+
+// rateStep sends qps queries, evenly spaced over RATE_STEP_DURATION, and
+// waits for all of them to complete.
+func rateStep(nameserver string, names []string, qps int) RateStep {
+	step := RateStep{QPS: qps}
+	n := int(float64(qps) * RATE_STEP_DURATION.Seconds())
+	interval := RATE_STEP_DURATION / time.Duration(n)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			<-ticker.C
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			r, err := dnsqueue.SendQuery(&dnsqueue.Request{
+				Destination: nameserver,
+				RecordType:  "A",
+				RecordName:  name + ".",
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			step.Sent += 1
+			switch {
+			case err != nil || r.Error != "":
+				step.Dropped += 1
+			case r.Rcode == "REFUSED":
+				step.Refused += 1
+			case r.Truncated:
+				step.Truncated += 1
+			}
+		}(names[i%len(names)])
+	}
+	wg.Wait()
+	return step
+}
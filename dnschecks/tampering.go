@@ -0,0 +1,149 @@
+// part of the dnschecks package, compares each resolver's answers for
+// commonly blocked domains with those of a trusted resolver.
+package dnschecks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+// TRUSTED_RESOLVER is the default baseline: an encrypted resolver, which a
+// network can block but not quietly answer for.
+const TRUSTED_RESOLVER = "https://cloudflare-dns.com/dns-query"
+
+// Ways a resolver's answer can differ from the trusted resolver's.
+const (
+	// BOGUS is NXDOMAIN, no address or a bogon for a domain the trusted
+	// resolver answers: the resolver lies.
+	BOGUS = "bogus"
+	// FILTERED is REFUSED, SERVFAIL or no answer at all.
+	FILTERED = "filtered"
+	// DIFFERS is public addresses in none of the networks the trusted
+	// resolver returns. CDNs answer differently by location, so this is
+	// reported but not held against the resolver.
+	DIFFERS = "differs"
+)
+
+// Tampered is a domain a resolver answered differently from the trusted resolver.
+type Tampered struct {
+	Domain string
+	Kind   string
+	Detail string
+}
+
+// Penalized returns true if the answer was bogus or filtered, rather than
+// merely different.
+func (t Tampered) Penalized() bool {
+	return t.Kind == BOGUS || t.Kind == FILTERED
+}
+
+// compareAnswer returns how s's answer for domain differs from the trusted
+// resolver's, or nil if it does not.
+func compareAnswer(domain string, s *report.Summary, baseline *report.Summary) *Tampered {
+	class, ok := s.Outcomes[domain]
+	switch {
+	case !ok:
+		return &Tampered{domain, FILTERED, "no result"}
+	case class == report.NXDOMAIN || class == report.BLOCKED:
+		return &Tampered{domain, BOGUS, class}
+	case class != "":
+		return &Tampered{domain, FILTERED, class}
+	}
+	if reason := answered(s, domain); reason != "" {
+		return &Tampered{domain, BOGUS, reason}
+	}
+	ips, trusted := s.Addresses[domain], baseline.Addresses[domain]
+	if report.SameService(ips, trusted) {
+		return nil
+	}
+	return &Tampered{domain, DIFFERS, fmt.Sprintf("answered %s, trusted resolver %s",
+		strings.Join(ips, " "), strings.Join(trusted, " "))}
+}
+
+// CheckTampering compares every nameserver's answers for domains with
+// trusted's. The benchmark's answers in summaries are used where it queried
+// a domain; only trusted, and the domains a nameserver was not benchmarked
+// with, are queried. Domains trusted answers with a public address are
+// compared, and those a nameserver answers differently are returned, by
+// nameserver, along with how many domains were compared. It fails if
+// trusted answers none of them.
+func CheckTampering(summaries []*report.Summary, domains []string, trusted string) (map[string][]Tampered, int, error) {
+	baseline := report.NewSummary(trusted)
+	queried := make(map[string]*report.Summary)
+	requests := requestsFor(trusted, domains)
+	var resolvers []*report.Summary
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" {
+			continue
+		}
+		resolvers = append(resolvers, s)
+		var missing []string
+		for _, d := range domains {
+			if !benchmarked(s, d) {
+				missing = append(missing, d)
+			}
+		}
+		if len(missing) > 0 {
+			queried[s.Nameserver] = report.NewSummary(s.Nameserver)
+			requests = append(requests, requestsFor(s.Nameserver, missing)...)
+		}
+	}
+	q := dnsqueue.StartQueue(context.Background(), BLOCKING_WORKERS*4, BLOCKING_WORKERS)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, r := range requests {
+			if err := add(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		if r.Request.Destination == trusted {
+			baseline.Add(r)
+		} else {
+			queried[r.Request.Destination].Add(r)
+		}
+	})
+	if _, missing := err.(*dnsqueue.MissingResultsError); err != nil && !missing {
+		return nil, 0, err
+	}
+
+	tampered := make(map[string][]Tampered)
+	compared := 0
+	for _, d := range domains {
+		if answered(baseline, d) != "" {
+			continue
+		}
+		compared++
+		for _, s := range resolvers {
+			if !benchmarked(s, d) {
+				s = queried[s.Nameserver]
+			}
+			if t := compareAnswer(d, s, baseline); t != nil {
+				tampered[s.Nameserver] = append(tampered[s.Nameserver], *t)
+			}
+		}
+	}
+	if compared == 0 {
+		return nil, 0, fmt.Errorf("trusted resolver %s answered none of %d domains", trusted, len(domains))
+	}
+	return tampered, compared, nil
+}
+
+// benchmarked returns true if s holds the benchmark's answer to an A query
+// for domain, which the trusted resolver's can be compared with.
+func benchmarked(s *report.Summary, domain string) bool {
+	_, ok := s.Outcomes[domain]
+	return ok && s.Types["A"] != nil
+}
+
+// requestsFor returns an A query to ns for each of domains.
+func requestsFor(ns string, domains []string) (requests []*dnsqueue.Request) {
+	for _, d := range domains {
+		requests = append(requests, &dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: d + "."})
+	}
+	return requests
+}
@@ -0,0 +1,117 @@
+// part of the dnschecks package, tests how resolvers treat very short TTLs,
+// which DNS-based failover relies on.
+package dnschecks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+)
+
+// TTL_QUERIES is how many times each record is queried.
+const TTL_QUERIES = 4
+
+// TTL_SPACING separates the queries, long enough for a TTL of 1 to expire.
+const TTL_SPACING = 1500 * time.Millisecond
+
+// TTLRecord is a name published with a known, short TTL.
+type TTLRecord struct {
+	Name string
+	TTL  uint32
+}
+
+// ParseTTLRecords parses a comma separated list of name:ttl pairs.
+func ParseTTLRecords(s string) (records []TTLRecord, err error) {
+	for _, field := range strings.Split(s, ",") {
+		i := strings.LastIndex(field, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid TTL record %q, expected name:ttl", field)
+		}
+		var r TTLRecord
+		if r.Name, err = parse.Domain(field[:i]); err != nil {
+			return nil, err
+		}
+		ttl, err := strconv.ParseUint(strings.TrimSpace(field[i+1:]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL record %q: %s", field, err)
+		}
+		r.TTL = uint32(ttl)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// TTLResult is the TTL a resolver returned for each query of a record.
+type TTLResult struct {
+	Record TTLRecord
+	Seen   []uint32
+}
+
+// Verdict describes whether the resolver honoured the published TTL,
+// returning true if it did not.
+func (r TTLResult) Verdict() (string, bool) {
+	if len(r.Seen) == 0 {
+		return fmt.Sprintf("%s: no answer", r.Record.Name), true
+	}
+	max := r.Seen[0]
+	stale := false
+	for i, ttl := range r.Seen {
+		if ttl > max {
+			max = ttl
+		}
+		// A TTL that counted down since the last query was answered from cache,
+		// although the published TTL expired in between.
+		if i > 0 && ttl < r.Seen[i-1] && ttl > r.Record.TTL {
+			stale = true
+		}
+	}
+	switch {
+	case stale:
+		return fmt.Sprintf("%s: TTL %d served from cache after expiry (saw %v)", r.Record.Name, r.Record.TTL, r.Seen), true
+	case max > r.Record.TTL:
+		return fmt.Sprintf("%s: TTL %d clamped up to %d", r.Record.Name, r.Record.TTL, max), true
+	}
+	return fmt.Sprintf("%s: TTL %d honoured", r.Record.Name, r.Record.TTL), false
+}
+
+// CheckTTL queries record TTL_QUERIES times, TTL_SPACING apart, noting the
+// TTL the nameserver returns for the name itself rather than any CNAME.
+func CheckTTL(nameserver string, record TTLRecord) (TTLResult, error) {
+	result := TTLResult{Record: record}
+	for i := 0; i < TTL_QUERIES; i++ {
+		if i > 0 {
+			time.Sleep(TTL_SPACING)
+		}
+		r, err := dnsqueue.SendQuery(&dnsqueue.Request{
+			Destination: nameserver,
+			RecordType:  "A",
+			RecordName:  record.Name + ".",
+		})
+		if err != nil {
+			return result, err
+		}
+		if r.Error != "" {
+			return result, errors.New(r.Error)
+		}
+		if ttl, ok := ownerTTL(r.Answers, record.Name); ok {
+			result.Seen = append(result.Seen, ttl)
+		}
+	}
+	return result, nil
+}
+
+// ownerTTL returns the TTL of the answer for name itself, not any record a
+// CNAME leads to.
+func ownerTTL(answers []dnsqueue.Answer, name string) (uint32, bool) {
+	for _, a := range answers {
+		if strings.EqualFold(strings.TrimSuffix(a.Name, "."), name) {
+			return a.Ttl, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,187 @@
+// part of the dnschecks package, watches the TTLs resolvers return for the
+// same names over a few minutes, to find ones that rewrite them.
+package dnschecks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+)
+
+// TTL_WATCH_NAMES is how many names are watched.
+const TTL_WATCH_NAMES = 10
+
+// TTL_WATCH_SPACING separates the queries of each name.
+const TTL_WATCH_SPACING = 10 * time.Second
+
+// TTLSample is the TTL returned for a name some time after watching began.
+type TTLSample struct {
+	After time.Duration
+	TTL   uint32
+}
+
+// TTLWatch is every TTL a resolver returned for a name, in order.
+type TTLWatch struct {
+	Name    string
+	Samples []TTLSample
+}
+
+// WatchTTLs queries each of names every spacing for window, noting the TTL
+// the nameserver returns for the name itself. Unanswered queries are left
+// out rather than failing the watch.
+func WatchTTLs(nameserver string, names []string, window, spacing time.Duration) ([]TTLWatch, error) {
+	watches := make([]TTLWatch, len(names))
+	for i, name := range names {
+		watches[i].Name = name
+	}
+	start := time.Now()
+	for round := 0; time.Duration(round)*spacing <= window; round++ {
+		time.Sleep(time.Until(start.Add(time.Duration(round) * spacing)))
+		for i := range watches {
+			r, err := dnsqueue.SendQuery(&dnsqueue.Request{
+				Destination: nameserver,
+				RecordType:  "A",
+				RecordName:  watches[i].Name + ".",
+			})
+			if err != nil {
+				return watches, err
+			}
+			if r.Error != "" {
+				continue
+			}
+			if ttl, ok := ownerTTL(r.Answers, watches[i].Name); ok {
+				watches[i].Samples = append(watches[i].Samples, TTLSample{time.Since(start), ttl})
+			}
+		}
+	}
+	return watches, nil
+}
+
+// highest returns the highest TTL in the watch, and how long it lasted.
+func (w TTLWatch) highest() (max uint32, span time.Duration) {
+	for _, s := range w.Samples {
+		if s.TTL > max {
+			max = s.TTL
+		}
+	}
+	if n := len(w.Samples); n > 0 {
+		span = w.Samples[n-1].After - w.Samples[0].After
+	}
+	return max, span
+}
+
+// TTLBehavior sums up how a resolver treated the TTLs of the watched names.
+type TTLBehavior struct {
+	// Names is how many names were answered at least twice.
+	Names int
+	// CountedDown is how many names' TTLs fell between queries, as a cache
+	// serving the record it fetched does.
+	CountedDown int
+	// Fixed is how many names' TTLs stayed the same, never counting down,
+	// although they were long enough to still be cached.
+	Fixed int
+	// Zero lists names only ever answered with TTL 0, although other
+	// resolvers gave them a longer one: answers clients can not cache.
+	Zero []string
+	// Clamped lists names answered with a higher TTL than the one published,
+	// as far as the other resolvers tell.
+	Clamped []string
+	// Floor is the lowest TTL of the Clamped names, roughly the minimum the
+	// resolver raises TTLs to.
+	Floor uint32
+}
+
+// CompareTTLs returns the TTL behavior of each nameserver, from its watches
+// and those of the others. The published TTLs are not known; they are
+// estimated by publishedTTL, so raised TTLs are only found for names with
+// TTLs shorter than the watch.
+func CompareTTLs(watches map[string][]TTLWatch) map[string]TTLBehavior {
+	behaviors := make(map[string]TTLBehavior)
+	for ns, mine := range watches {
+		var b TTLBehavior
+		for _, w := range mine {
+			if len(w.Samples) < 2 {
+				continue
+			}
+			b.Names += 1
+			down, fixed, zero := false, false, true
+			for i, s := range w.Samples {
+				zero = zero && s.TTL == 0
+				if i > 0 && s.TTL < w.Samples[i-1].TTL {
+					down = true
+				} else if i > 0 && s.TTL == w.Samples[i-1].TTL && time.Duration(s.TTL)*time.Second > s.After-w.Samples[i-1].After {
+					fixed = true
+				}
+			}
+			if down {
+				b.CountedDown += 1
+			} else if fixed {
+				b.Fixed += 1
+			}
+			published, cached := publishedTTL(watches, ns, w.Name)
+			max, _ := w.highest()
+			if zero && cached {
+				b.Zero = append(b.Zero, w.Name)
+			}
+			if published > 0 && max > published {
+				b.Clamped = append(b.Clamped, fmt.Sprintf("%s %d vs %d", w.Name, max, published))
+				if b.Floor == 0 || max < b.Floor {
+					b.Floor = max
+				}
+			}
+		}
+		sort.Strings(b.Zero)
+		sort.Strings(b.Clamped)
+		behaviors[ns] = b
+	}
+	return behaviors
+}
+
+// publishedTTL estimates the TTL published for name from the other
+// nameservers' watches: a resolver whose highest TTL was shorter than its
+// watch fetched the name again during it, and returned the published TTL or,
+// if it clamps TTLs, more; the lowest such TTL is the estimate, or 0 if no
+// resolver fetched the name again. It also returns whether any of them gave
+// the name a TTL above 0.
+func publishedTTL(watches map[string][]TTLWatch, ns string, name string) (published uint32, cached bool) {
+	for other, theirs := range watches {
+		if other == ns {
+			continue
+		}
+		for _, w := range theirs {
+			if w.Name != name {
+				continue
+			}
+			high, span := w.highest()
+			cached = cached || high > 0
+			if high > 0 && time.Duration(high)*time.Second < span && (published == 0 || high < published) {
+				published = high
+			}
+		}
+	}
+	return published, cached
+}
+
+// Verdict describes the resolver's TTL behavior, returning true if it
+// rewrites TTLs.
+func (b TTLBehavior) Verdict() (string, bool) {
+	if b.Names == 0 {
+		return "too few answers to watch TTLs", false
+	}
+	parts := []string{fmt.Sprintf("TTLs counted down on %d/%d names", b.CountedDown, b.Names)}
+	if b.Fixed > 0 {
+		parts = append(parts, fmt.Sprintf("stayed the same on %d", b.Fixed))
+	}
+	if len(b.Zero) > 0 {
+		parts = append(parts, fmt.Sprintf("always 0 on %d where others cache them (0-TTL cache busting): %s",
+			len(b.Zero), strings.Join(b.Zero, ", ")))
+	}
+	if len(b.Clamped) > 0 {
+		parts = append(parts, fmt.Sprintf("raised above the published TTL on %d, to at least %ds (minimum TTL clamping): %s",
+			len(b.Clamped), b.Floor, strings.Join(b.Clamped, ", ")))
+	}
+	return strings.Join(parts, "; "), len(b.Zero) > 0 || len(b.Clamped) > 0
+}
@@ -0,0 +1,27 @@
+// part of the dnsqueue package, binds queries to a network interface.
+package dnsqueue
+
+import (
+	"net"
+)
+
+// Source is where a queue's queries leave from on a multi-homed host.
+type Source struct {
+	// Interface names the network interface queries are bound to, so that
+	// they leave through it whatever the routing table prefers.
+	Interface string
+	// IP is the interface's address queries are sent from.
+	IP net.IP
+}
+
+// dialer returns a dialer sending from the source's address, bound to its
+// interface, for connections of the given kind: "udp" or "tcp".
+func (s *Source) dialer(kind string) *net.Dialer {
+	d := &net.Dialer{Control: bindControl(s.Interface, s.IP.To4() == nil)}
+	if kind == "udp" {
+		d.LocalAddr = &net.UDPAddr{IP: s.IP}
+	} else {
+		d.LocalAddr = &net.TCPAddr{IP: s.IP}
+	}
+	return d
+}
@@ -0,0 +1,38 @@
+//go:build darwin
+
+// part of the dnsqueue package, binds sockets to an interface on macOS.
+package dnsqueue
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// InterfaceBindingSupported is true on platforms where Source can bind
+// queries to an interface.
+const InterfaceBindingSupported = true
+
+// bindControl returns a Dialer.Control binding sockets to iface with
+// IP_BOUND_IF, or IPV6_BOUND_IF for IPv6 sockets.
+func bindControl(iface string, ipv6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			return err
+		}
+		var serr error
+		err = c.Control(func(fd uintptr) {
+			if ipv6 {
+				serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, ifi.Index)
+			} else {
+				serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BOUND_IF, ifi.Index)
+			}
+		})
+		if err == nil {
+			err = serr
+		}
+		return err
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+// part of the dnsqueue package, binds sockets to an interface on Linux.
+package dnsqueue
+
+import (
+	"syscall"
+)
+
+// InterfaceBindingSupported is true on platforms where Source can bind
+// queries to an interface.
+const InterfaceBindingSupported = true
+
+// bindControl returns a Dialer.Control binding sockets to iface with
+// SO_BINDTODEVICE, which before Linux 5.7 needs CAP_NET_RAW.
+func bindControl(iface string, ipv6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err == nil {
+			err = serr
+		}
+		return err
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+
+// part of the dnsqueue package, binding to an interface is only implemented
+// on Linux and macOS.
+package dnsqueue
+
+import (
+	"errors"
+	"syscall"
+)
+
+// InterfaceBindingSupported is true on platforms where Source can bind
+// queries to an interface.
+const InterfaceBindingSupported = false
+
+// bindControl returns a Dialer.Control that fails, as sockets can not be
+// bound to an interface here.
+func bindControl(iface string, ipv6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return errors.New("binding to a network interface is not supported on this platform")
+	}
+}
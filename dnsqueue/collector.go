@@ -0,0 +1,75 @@
+// part of the dnsqueue package, accounts for requests still awaiting a result.
+package dnsqueue
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MissingResultsError is returned by Stream when requests were added but
+// their results never arrived, e.g. because a worker died, the queue was
+// cancelled, or the deadline passed.
+type MissingResultsError struct {
+	// Requests that never produced a result, in ID order.
+	Requests []*Request
+	// Cause is the error that stopped the queue, if any.
+	Cause error
+}
+
+func (e *MissingResultsError) Error() string {
+	first := e.Requests[0]
+	msg := fmt.Sprintf("%d results missing (first: #%d %s %s)", len(e.Requests), first.ID, first.Destination, first.RecordName)
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *MissingResultsError) Unwrap() error {
+	return e.Cause
+}
+
+// outstanding tracks requests that have been added but not yet collected.
+type outstanding struct {
+	mu       sync.Mutex
+	requests map[uint64]*Request
+}
+
+func newOutstanding() *outstanding {
+	return &outstanding{requests: make(map[uint64]*Request)}
+}
+
+func (o *outstanding) add(r *Request) {
+	o.mu.Lock()
+	o.requests[r.ID] = r
+	o.mu.Unlock()
+}
+
+func (o *outstanding) remove(id uint64) {
+	o.mu.Lock()
+	delete(o.requests, id)
+	o.mu.Unlock()
+}
+
+func (o *outstanding) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.requests)
+}
+
+// list returns the outstanding requests in ID order.
+func (o *outstanding) list() (requests []*Request) {
+	o.mu.Lock()
+	for _, r := range o.requests {
+		requests = append(requests, r)
+	}
+	o.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return
+}
+
+// Queue.Outstanding returns the requests added but not yet collected, in ID order.
+func (q *Queue) Outstanding() []*Request {
+	return q.outstanding.list()
+}
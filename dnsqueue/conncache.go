@@ -0,0 +1,218 @@
+// part of the dnsqueue package, provides connection reuse across workers.
+package dnsqueue
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// How long an unused connection is kept before it is closed.
+	IDLE_TIMEOUT = 30 * time.Second
+
+	// Maximum idle connections kept per destination.
+	MAX_IDLE_PER_DEST = 8
+)
+
+// idleConn is a connection waiting in the cache.
+type idleConn struct {
+	conn *dns.Conn
+	used time.Time
+}
+
+// connCache hands out per-destination connections shared by all workers, and
+// closes the ones that sit unused for longer than IDLE_TIMEOUT. DNS over TLS
+// destinations are dialed with tlsClient, so their handshakes are reused too,
+// and destinations prefixed with tcpScheme with tcpClient. UDP sockets are
+// never cached: every UDP query gets a new one, and with it a new random
+// source port, which a spoofed answer would have to guess.
+type connCache struct {
+	source    *Source
+	client    *dns.Client
+	tcpClient *dns.Client
+	tlsClient *dns.Client
+
+	mu    sync.Mutex
+	idle  map[string][]idleConn
+	stop  chan bool
+	close sync.Once
+}
+
+// newConnCache returns a cache, with a janitor evicting idle connections.
+// Connections are made from source, bound to its interface, unless it is nil.
+func newConnCache(source *Source) *connCache {
+	client := new(dns.Client)
+	tcpClient := &dns.Client{Net: "tcp"}
+	tlsClient := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{}}
+	if source != nil {
+		client.Dialer = source.dialer("udp")
+		tcpClient.Dialer = source.dialer("tcp")
+		tlsClient.Dialer = source.dialer("tcp")
+	}
+	c := &connCache{
+		source:    source,
+		client:    client,
+		tcpClient: tcpClient,
+		tlsClient: tlsClient,
+		idle:      make(map[string][]idleConn),
+		stop:      make(chan bool),
+	}
+	go c.janitor()
+	return c
+}
+
+// clientFor returns the client and address to dial dest with.
+func (c *connCache) clientFor(dest string) (*dns.Client, string) {
+	if IsDoT(dest) {
+		return c.tlsClient, dotAddress(dest)
+	}
+	if tcp, addr := isTCP(dest); tcp {
+		return c.tcpClient, addr
+	}
+	return c.client, dest
+}
+
+// takeIdle removes an idle connection to dest from the cache and returns
+// it, or returns nil if there is none.
+func (c *connCache) takeIdle(dest string) *dns.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conns := c.idle[dest]
+	n := len(conns)
+	if n == 0 {
+		return nil
+	}
+	c.idle[dest] = conns[:n-1]
+	return conns[n-1].conn
+}
+
+// dial connects to dest, also returning how long establishing the
+// connection took (for encrypted transports this includes the handshake).
+func (c *connCache) dial(dest string) (*dns.Conn, time.Duration, error) {
+	client, addr := c.clientFor(dest)
+	start := time.Now()
+	conn, err := client.Dial(addr)
+	return conn, time.Since(start), err
+}
+
+// put returns a healthy connection to the cache.
+func (c *connCache) put(dest string, conn *dns.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle[dest]) >= MAX_IDLE_PER_DEST {
+		conn.Close()
+		return
+	}
+	c.idle[dest] = append(c.idle[dest], idleConn{conn: conn, used: time.Now()})
+}
+
+// timing splits a query's cost into connection setup and the round trip.
+type timing struct {
+	rtt     time.Duration
+	connect time.Duration
+	newConn bool
+}
+
+// reusable reports whether connections to dest are cached: TCP and TLS
+// ones are, UDP sockets are not.
+func reusable(dest string) bool {
+	tcp, _ := isTCP(dest)
+	return tcp || IsDoT(dest)
+}
+
+// exchange sends m to dest over a cached connection, or a new one used only
+// for this query if fresh is true or dest is a UDP destination. Connections
+// that see an error are closed rather than returned, as they may hold a
+// stray response. Nameservers close idle connections as they please, so a
+// query that fails on a cached connection is sent once more on a new one
+// rather than counted against the nameserver. A UDP socket has no setup to
+// report.
+func (c *connCache) exchange(ctx context.Context, m *dns.Msg, dest string, fresh bool) (in *dns.Msg, t timing, err error) {
+	connected := reusable(dest)
+	fresh = fresh || !connected
+	client, _ := c.clientFor(dest)
+	if !fresh {
+		if conn := c.takeIdle(dest); conn != nil {
+			if in, t.rtt, err = client.ExchangeWithConnContext(ctx, m, conn); err == nil {
+				c.put(dest, conn)
+				return in, t, nil
+			}
+			conn.Close()
+			if ctx.Err() != nil {
+				return in, t, err
+			}
+		}
+	}
+	conn, connect, err := c.dial(dest)
+	if connected {
+		t.connect = connect
+		t.newConn = true
+	}
+	if err != nil {
+		return nil, t, err
+	}
+	in, t.rtt, err = client.ExchangeWithConnContext(ctx, m, conn)
+	if err != nil || fresh {
+		conn.Close()
+		return in, t, err
+	}
+	c.put(dest, conn)
+	return in, t, nil
+}
+
+// evict closes connections idle for longer than IDLE_TIMEOUT.
+func (c *connCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cutoff := time.Now().Add(-IDLE_TIMEOUT)
+	for dest, conns := range c.idle {
+		kept := conns[:0]
+		for _, ic := range conns {
+			if ic.used.Before(cutoff) {
+				ic.conn.Close()
+			} else {
+				kept = append(kept, ic)
+			}
+		}
+		if len(kept) == 0 {
+			delete(c.idle, dest)
+		} else {
+			c.idle[dest] = kept
+		}
+	}
+}
+
+// janitor periodically evicts idle connections until Close is called.
+func (c *connCache) janitor() {
+	ticker := time.NewTicker(IDLE_TIMEOUT / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.evict()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the janitor and closes every idle connection.
+func (c *connCache) Close() {
+	c.close.Do(func() {
+		close(c.stop)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for dest, conns := range c.idle {
+			for _, ic := range conns {
+				ic.conn.Close()
+			}
+			delete(c.idle, dest)
+		}
+		log.Printf("Connection cache closed.")
+	})
+}
@@ -0,0 +1,150 @@
+package dnsqueue
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// answeringServer starts a UDP nameserver answering every query with an
+// empty NOERROR response, returning its address.
+func answeringServer(t testing.TB) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// TestUDPSourcePortsVary checks that UDP sockets are not reused, so that
+// every query leaves from a new source port.
+func TestUDPSourcePortsVary(t *testing.T) {
+	const QUERIES = 20
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	ports := make(map[int]bool)
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		ports[w.RemoteAddr().(*net.UDPAddr).Port] = true
+		mu.Unlock()
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	cache := newConnCache(nil)
+	defer cache.Close()
+	for i := 0; i < QUERIES; i++ {
+		request := &Request{Destination: pc.LocalAddr().String(), RecordType: "A", RecordName: "example.com."}
+		result, err := sendQuery(context.Background(), cache, request)
+		if err != nil {
+			t.Fatalf("query %d: %s", i, err)
+		}
+		if result.NewConnection {
+			t.Errorf("query %d over UDP reported a new connection", i)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ports) != QUERIES {
+		t.Errorf("%d queries were sent from %d source ports, want one each", QUERIES, len(ports))
+	}
+}
+
+// TestStaleConnectionRedialed checks that a query failing on a cached
+// connection the nameserver has since closed is sent again on a new one.
+func TestStaleConnectionRedialed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// Answer one query on each connection, then close it.
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn := &dns.Conn{Conn: nc}
+			if r, err := conn.ReadMsg(); err == nil {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				conn.WriteMsg(m)
+			}
+			conn.Close()
+		}
+	}()
+
+	cache := newConnCache(nil)
+	defer cache.Close()
+	dest := tcpScheme + ln.Addr().String()
+	for i := 0; i < 3; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		_, timing, err := cache.exchange(context.Background(), m, dest, false)
+		if err != nil {
+			t.Fatalf("query %d: %s", i, err)
+		}
+		if !timing.newConn {
+			t.Errorf("query %d was answered on the closed connection", i)
+		}
+	}
+}
+
+// BenchmarkQueue50k streams 50,000 UDP queries through a queue to a local
+// nameserver, which every query gets its own socket for.
+func BenchmarkQueue50k(b *testing.B) {
+	const (
+		QUERIES = 50000
+		WORKERS = 32
+	)
+	dest := answeringServer(b)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		q := StartQueue(context.Background(), WORKERS, WORKERS)
+		q.LimitDestinationWorkers(WORKERS)
+		failed := 0
+		err := q.Stream(func(add func(*Request) error) error {
+			for i := 0; i < QUERIES; i++ {
+				if err := add(&Request{Destination: dest, RecordType: "A", RecordName: "example.com."}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, func(r *Result) {
+			if r.Error != "" {
+				failed += 1
+			}
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if failed > 0 {
+			b.Fatalf("%d of %d queries failed", failed, QUERIES)
+		}
+	}
+}
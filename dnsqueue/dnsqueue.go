@@ -2,20 +2,63 @@
 package dnsqueue
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/miekg/dns"
+	"golang.org/x/sync/errgroup"
 	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Request contains data for making a DNS request
 type Request struct {
+	// ID is assigned by the queue, increasing by one for every request added.
+	ID uint64
+
+	// Destination is a nameserver as host:port, a DNS over TLS or QUIC
+	// destination (dot://host:port, doq://host:port), or a DNS over HTTPS URL.
 	Destination     string
 	RecordType      string
 	RecordName      string
 	VerifySignature bool
 
+	// EDNS adds an OPT record advertising EDNS_BUFFER_SIZE, which resolvers
+	// need to return extended DNS errors. VerifySignature and ClientSubnet
+	// send one regardless; other queries go without.
+	EDNS bool
+
+	// Proxy is a SOCKS5 URL, e.g. socks5://127.0.0.1:9050 for Tor, to send
+	// the query through. Plain DNS goes over TCP, as Tor does not carry UDP.
+	Proxy string
+
+	// FreshConnection opens a new connection for the query, closing it
+	// afterwards, so that every query to a TCP, TLS or QUIC server pays for
+	// the connection setup rather than reusing an earlier one.
+	FreshConnection bool
+
+	// KernelTimestamps measures the RTT against the kernel's receive
+	// timestamp where supported, instead of when the worker reads the reply.
+	KernelTimestamps bool
+
+	// Protocol is how plain DNS is sent: PROTOCOL_UDP (also if empty),
+	// PROTOCOL_TCP or PROTOCOL_AUTO. Encrypted and proxied queries ignore it.
+	Protocol string
+
+	// Timeout bounds each attempt at the query; zero leaves it to the
+	// queue's context, or DEFAULT_TIMEOUT. A query that gets no answer is
+	// sent again up to MaxRetries times.
+	Timeout    time.Duration
+	MaxRetries int
+
+	// ClientSubnet, if set, is sent as the EDNS Client Subnet option, so
+	// resolvers that honour it answer as if the query came from there.
+	ClientSubnet *net.IPNet
+
 	exit bool
 }
 
@@ -24,14 +67,60 @@ type Answer struct {
 	Ttl    uint32
 	Name   string
 	String string
+	// IP is set for A and AAAA answers.
+	IP net.IP
 }
 
 // Result contains metadata relating to a set of DNS server results.
 type Result struct {
-	Request  Request
+	ID       uint64
+	Request  *Request
 	Duration time.Duration
 	Answers  []Answer
+	Rcode    string
 	Error    string
+
+	// Failure is why the query failed, or "" if it succeeded. It is set for
+	// every error and for answers other than NOERROR; FAILURE_TRUNCATED
+	// answers are kept, as they may still hold some records.
+	Failure Failure
+
+	// KernelTimestamp is true if Duration was measured with kernel timestamps.
+	KernelTimestamp bool
+
+	// Connect is the time spent establishing a new connection (including any
+	// TLS or QUIC handshake) before the query was sent; it is not part of
+	// Duration. NewConnection is false when an existing connection was reused.
+	Connect       time.Duration
+	NewConnection bool
+
+	// CNAMEChain lists the CNAME targets followed to reach the answer, in order.
+	CNAMEChain []string
+
+	// Authenticated is true if the resolver set the AD bit, claiming it
+	// validated the answer with DNSSEC.
+	Authenticated bool
+
+	// Authoritative and RecursionAvailable are the AA and RA bits of the
+	// answer, and AnswerCount the number of records in its answer section.
+	Authoritative      bool
+	RecursionAvailable bool
+	AnswerCount        int
+
+	// Truncated is true if the answer had the TC bit set. With PROTOCOL_AUTO
+	// a truncated UDP answer is retried over TCP, and Fallback set: Answers
+	// are then from TCP, Duration covers both round trips and Connect is the
+	// TCP connection's.
+	Truncated bool
+	Fallback  bool
+
+	// ExtendedErrors lists the extended DNS error (RFC 8914) info codes returned.
+	ExtendedErrors []uint16
+
+	// Attempts is how many times the query was sent. Retried is true if
+	// the answer only came after a retry; Duration is then the last attempt's.
+	Attempts int
+	Retried  bool
 }
 
 // Queue contains methods and state for setting up a request queue.
@@ -40,81 +129,347 @@ type Queue struct {
 	Results     chan *Result
 	WorkerCount int
 	Quit        chan bool
+
+	// Deadline bounds how long Stream waits for all results. Zero means no limit.
+	Deadline time.Duration
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cache       *connCache
+	limiter     *destLimiter
+	pools       *pools
+	budget      chan bool
+	outstanding *outstanding
+	counters    *counters
+	lastID      uint64
+	done        chan bool
+	err         error
 }
 
+// DEFAULT_TIMEOUT bounds a single query when the context has no deadline.
+const DEFAULT_TIMEOUT = 2 * time.Second
+
+// EDNS_BUFFER_SIZE is the UDP payload size advertised by queries sending
+// EDNS: the DNS Flag Day 2020 size, small enough to avoid IP fragmentation.
+const EDNS_BUFFER_SIZE = 1232
+
+// ErrDeadline is the cause reported when a Stream deadline passes.
+var ErrDeadline = errors.New("queue deadline exceeded")
+
+var (
+	// msgPool recycles outgoing messages between queries.
+	msgPool = sync.Pool{New: func() interface{} { return new(dns.Msg) }}
+
+	// defaultCache serves SendQuery calls made outside of a Queue.
+	defaultCache     *connCache
+	defaultCacheOnce sync.Once
+)
+
 // StartQueue starts a new queue with max length of X with worker count Y.
-func StartQueue(size, workers int) (q *Queue) {
+// Each destination gets its own workers, half of Y unless
+// LimitDestinationWorkers says otherwise, while no more than Y queries are
+// in flight at once across all of them: a destination that times out only
+// holds up its own queries. Workers share one dns.Client and a
+// per-destination connection cache, and run in an errgroup tied to ctx:
+// cancelling ctx, or any worker failing, stops all of them. Once every
+// worker has exited the cache and the Results channel are closed, and Wait
+// returns.
+//
+// The queue length only needs to cover a few requests per worker: use Stream
+// to produce requests and consume results concurrently. Requests for a
+// destination whose workers are all busy wait in that destination's own
+// backlog of up to MAX_BACKLOG requests; adding more blocks until it drains.
+func StartQueue(ctx context.Context, size, workers int) (q *Queue) {
+	return StartQueueFrom(ctx, size, workers, nil)
+}
+
+// StartQueueFrom starts a queue like StartQueue, sending every query from
+// source, bound to its network interface so that it leaves through it. A nil
+// source lets the system choose. Queries that can not be bound, over DNS over
+// HTTPS or QUIC or through a proxy, fail with FAILURE_INVALID rather than
+// leave through another interface.
+func StartQueueFrom(ctx context.Context, size, workers int, source *Source) (q *Queue) {
+	ctx, cancel := context.WithCancel(ctx)
+	g, ctx := errgroup.WithContext(ctx)
 	q = &Queue{
 		Requests:    make(chan *Request, size),
 		Results:     make(chan *Result, size),
 		WorkerCount: workers,
+		ctx:         ctx,
+		cancel:      cancel,
+		cache:       newConnCache(source),
+		limiter:     newDestLimiter(),
+		pools:       newPools(size, (workers+1)/2),
+		budget:      make(chan bool, workers),
+		outstanding: newOutstanding(),
+		counters:    newCounters(),
+		done:        make(chan bool),
 	}
-	for i := 0; i < q.WorkerCount; i++ {
-		go startWorker(q.Requests, q.Results)
-	}
+	g.Go(func() error {
+		return q.dispatch(ctx, g)
+	})
+	go func() {
+		q.err = g.Wait()
+		cancel()
+		q.cache.Close()
+		close(q.Results)
+		close(q.done)
+	}()
 	return
 }
 
-// Queue.Add adds a request to the queue. Only blocks if queue is full.
-func (q *Queue) Add(dest, record_type, record_name string) {
-	q.Requests <- &Request{
+// Queue.Wait blocks until every worker has exited, returning the first worker
+// error or the context error if the queue was cancelled.
+func (q *Queue) Wait() error {
+	<-q.done
+	return q.err
+}
+
+// Queue.LimitRate spaces out queries so that no more than qps per second are
+// sent to any one destination, however many workers are free. Zero, the
+// default, sends as fast as workers allow.
+func (q *Queue) LimitRate(qps float64) {
+	q.limiter.setRate(qps)
+}
+
+// Queue.LimitDestination overrides LimitRate for one destination, e.g. a
+// resolver known to rate limit its clients; zero lifts the limit.
+func (q *Queue) LimitDestination(dest string, qps float64) {
+	q.limiter.setDestRate(dest, qps)
+}
+
+// Queue.LimitBurst lets up to n queries go to a rate limited destination at
+// once, as a token bucket of n, before its rate applies. The default is one.
+func (q *Queue) LimitBurst(n int) {
+	q.limiter.setBurst(n)
+}
+
+// Queue.LimitDestinationWorkers sets how many workers serve each
+// destination, at least one, e.g. all of them for a queue with a single
+// destination. It applies to destinations first added after the call.
+func (q *Queue) LimitDestinationWorkers(n int) {
+	q.pools.setWorkers(n)
+}
+
+// Queue.Stream runs generate to produce requests, while a dedicated collector
+// goroutine hands every result to collect. Add may block when the queue is
+// full, which is safe because results are drained concurrently. Stream sends
+// the completion signal itself and returns once the last result is collected.
+//
+// Stream never waits forever: if Deadline passes, the queue is cancelled. Any
+// request whose result did not arrive is reported in a *MissingResultsError.
+func (q *Queue) Stream(generate func(add func(*Request) error) error, collect func(*Result)) error {
+	var timeout <-chan time.Time
+	if q.Deadline > 0 {
+		timer := time.NewTimer(q.Deadline)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	collected := make(chan bool)
+	timedOut := false
+	go func() {
+		defer close(collected)
+		for {
+			select {
+			case result, ok := <-q.Results:
+				if !ok {
+					return
+				}
+				q.outstanding.remove(result.ID)
+				collect(result)
+			case <-timeout:
+				log.Printf("Deadline of %s passed with %d results outstanding", q.Deadline, q.outstanding.len())
+				timedOut = true
+				q.cancel()
+				timeout = nil
+			}
+		}
+	}()
+	err := generate(q.AddRequest)
+	if serr := q.SendCompletionSignal(); err == nil {
+		err = serr
+	}
+	<-collected
+	if werr := q.Wait(); werr != nil {
+		err = werr
+	}
+	if timedOut {
+		err = ErrDeadline
+	}
+	if missing := q.Outstanding(); len(missing) > 0 {
+		return &MissingResultsError{Requests: missing, Cause: err}
+	}
+	return err
+}
+
+// Queue.Add adds a request to the queue. Only blocks if queue is full, and
+// returns an error if the queue is cancelled while blocked.
+func (q *Queue) Add(dest, record_type, record_name string) error {
+	return q.AddRequest(&Request{
 		Destination: dest,
 		RecordType:  record_type,
 		RecordName:  record_name,
+	})
+}
+
+// Queue.AddRequest adds a fully specified request to the queue, assigning its ID.
+func (q *Queue) AddRequest(r *Request) error {
+	r.ID = atomic.AddUint64(&q.lastID, 1)
+	q.outstanding.add(r)
+	if err := q.send(r); err != nil {
+		q.outstanding.remove(r.ID)
+		return err
+	}
+	return nil
+}
+
+// send puts a request on the queue unless the queue context is done.
+func (q *Queue) send(r *Request) error {
+	select {
+	case q.Requests <- r:
+		return nil
+	case <-q.ctx.Done():
+		return q.ctx.Err()
 	}
 }
 
 // Queue.SendDieSignal sends a signal to the workers that they can go home now.
-func (q *Queue) SendCompletionSignal() {
+func (q *Queue) SendCompletionSignal() error {
 	log.Printf("Sending completion signal...")
 	for i := 0; i < q.WorkerCount; i++ {
-		q.Requests <- &Request{exit: true}
+		if err := q.send(&Request{exit: true}); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// startWorker starts a thread to watch the request channel and populate result channel.
-func startWorker(queue <-chan *Request, results chan<- *Result) {
-	for request := range queue {
-		if request.exit {
-			log.Printf("Completion received, worker is done.")
-			return
+// startWorker watches a destination's requests and populates the result
+// channel until they are closed or ctx is cancelled, taking a slot of the
+// queue's budget for every query. A panic while handling a request is
+// returned as an error so the whole group stops.
+func (q *Queue) startWorker(ctx context.Context, requests <-chan *Request) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("worker panic: %v", r)
 		}
-		result, err := SendQuery(request)
+	}()
+	for {
+		var request *Request
+		select {
+		case r, ok := <-requests:
+			if !ok {
+				return nil
+			}
+			request = r
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := q.limiter.wait(ctx, request.Destination); err != nil {
+			return err
+		}
+		if err := q.acquire(ctx); err != nil {
+			return err
+		}
+		q.counters.start()
+		result, err := sendQuery(ctx, q.cache, request)
+		q.counters.finish(&result)
+		q.release()
 		if err != nil {
 			log.Printf("Error sending query: %s", err)
 		}
-		log.Printf("Sending back result: %s", result)
-		results <- &result
+		log.Printf("Sending back result: %+v", result)
+		select {
+		case q.Results <- &result:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
-// Send a DNS query via UDP, configured by a Request object. If successful,
-// stores response details in Result object, otherwise, returns Result object
-// with an error string.
+// Send a DNS query via UDP, or the Request's Protocol, configured by a Request
+// object. If successful, stores response details in Result object, otherwise,
+// returns Result object with an error string.
 func SendQuery(request *Request) (result Result, err error) {
-	log.Printf("Sending query: %s", request)
-	result.Request = *request
+	defaultCacheOnce.Do(func() {
+		defaultCache = newConnCache(nil)
+	})
+	return sendQuery(context.Background(), defaultCache, request)
+}
+
+// sendQuery implements SendQuery using connections from cache.
+func sendQuery(ctx context.Context, cache *connCache, request *Request) (result Result, err error) {
+	log.Printf("Sending query: %+v", request)
+	result.ID = request.ID
+	result.Request = request
 
 	record_type, ok := dns.StringToType[request.RecordType]
 	if !ok {
 		result.Error = fmt.Sprintf("Invalid type: %s", request.RecordType)
-		return result, errors.New(result.Error)
+		result.Failure = FAILURE_INVALID
+		return result, &QueryError{FAILURE_INVALID, errors.New(result.Error)}
+	}
+	if err := CheckProtocol(request.Protocol); err != nil {
+		result.Error = err.Error()
+		result.Failure = FAILURE_INVALID
+		return result, &QueryError{FAILURE_INVALID, err}
 	}
 
-	m := new(dns.Msg)
-	if request.VerifySignature == true {
-		log.Printf("SetEdns0 for %s", request.RecordName)
-		m.SetEdns0(4096, true)
+	m := newMsg(request.RecordName, record_type)
+	if request.EDNS || request.VerifySignature || request.ClientSubnet != nil {
+		m.SetEdns0(EDNS_BUFFER_SIZE, request.VerifySignature)
+	}
+	if request.ClientSubnet != nil {
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, subnetOption(request.ClientSubnet))
 	}
-	m.SetQuestion(request.RecordName, record_type)
-	c := new(dns.Client)
-	in, rtt, err := c.Exchange(m, request.Destination)
+	var in *dns.Msg
+	var t timing
+	for {
+		result.Attempts += 1
+		in, t, result.KernelTimestamp, err = attempt(ctx, cache, m, request)
+		if err == nil || result.Attempts > request.MaxRetries || ctx.Err() != nil {
+			break
+		}
+		log.Printf("Attempt %d of %s to %s failed, retrying: %s", result.Attempts, request.RecordName, request.Destination, err)
+	}
+	result.Retried = err == nil && result.Attempts > 1
+	if err == nil && in.Truncated && request.Protocol == PROTOCOL_AUTO && request.Proxy == "" && !Encrypted(request.Destination) {
+		udp := t.rtt
+		in, t, err = cache.exchange(ctx, m, tcpScheme+request.Destination, request.FreshConnection)
+		t.rtt += udp
+		result.Truncated = true
+		result.Fallback = true
+	}
+	msgPool.Put(m)
 	// log.Printf("Answer: %s [%d] %s", in, rtt, err)
 
-	result.Duration = rtt
+	result.Duration = t.rtt
+	result.Connect = t.connect
+	result.NewConnection = t.newConn
 	if err != nil {
 		result.Error = err.Error()
+		result.Failure = errorFailure(err)
 	} else {
+		result.Rcode = dns.RcodeToString[in.Rcode]
+		result.Authenticated = in.AuthenticatedData
+		result.Authoritative = in.Authoritative
+		result.RecursionAvailable = in.RecursionAvailable
+		result.AnswerCount = len(in.Answer)
+		result.Truncated = result.Truncated || in.Truncated
+		result.Failure = rcodeFailure(in.Rcode)
+		if result.Failure == "" && in.Truncated {
+			result.Failure = FAILURE_TRUNCATED
+		}
+		if opt := in.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if ede, ok := o.(*dns.EDNS0_EDE); ok {
+					result.ExtendedErrors = append(result.ExtendedErrors, ede.InfoCode)
+				}
+			}
+		}
+		result.Answers = make([]Answer, 0, len(in.Answer))
 		for _, rr := range in.Answer {
 			answer := Answer{
 				Ttl:    rr.Header().Ttl,
@@ -122,7 +477,60 @@ func SendQuery(request *Request) (result Result, err error) {
 				String: rr.String(),
 			}
 			result.Answers = append(result.Answers, answer)
+			switch v := rr.(type) {
+			case *dns.CNAME:
+				result.CNAMEChain = append(result.CNAMEChain, v.Target)
+			case *dns.A:
+				result.Answers[len(result.Answers)-1].IP = v.A
+			case *dns.AAAA:
+				result.Answers[len(result.Answers)-1].IP = v.AAAA
+			}
 		}
 	}
 	return result, nil
 }
+
+// attempt sends m once, as request asks, within request.Timeout if set. It
+// returns whether the RTT was measured with kernel timestamps.
+func attempt(ctx context.Context, cache *connCache, m *dns.Msg, request *Request) (in *dns.Msg, t timing, kernel bool, err error) {
+	if request.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.Timeout)
+		defer cancel()
+	}
+	if cache.source != nil && (request.Proxy != "" || IsDoH(request.Destination) || IsDoQ(request.Destination)) {
+		err = &QueryError{FAILURE_INVALID, fmt.Errorf("%s can not be sent from interface %s", request.Destination, cache.source.Interface)}
+	} else if request.Proxy != "" && IsDoH(request.Destination) {
+		var client *http.Client
+		if client, err = proxyClient(request.Proxy); err == nil {
+			in, t, err = exchangeDoH(ctx, client, m, request.Destination)
+		}
+	} else if request.Proxy != "" && IsDoQ(request.Destination) {
+		err = &QueryError{FAILURE_INVALID, fmt.Errorf("DNS over QUIC can not be sent through a SOCKS proxy")}
+	} else if request.Proxy != "" {
+		in, t, err = exchangeSOCKS(ctx, m, request.Proxy, request.Destination)
+	} else if IsDoH(request.Destination) {
+		in, t, err = exchangeDoH(ctx, dohClient, m, request.Destination)
+	} else if IsDoQ(request.Destination) {
+		in, t, err = exchangeDoQ(ctx, m, request.Destination, request.FreshConnection)
+	} else if request.KernelTimestamps && KernelTimestampsSupported && !IsDoT(request.Destination) && request.Protocol != PROTOCOL_TCP {
+		in, t.rtt, err = exchangeKernelTimestamp(ctx, m, request.Destination, cache.client.Dialer)
+		kernel = err == nil
+	} else {
+		in, t, err = cache.exchange(ctx, m, plainDestination(request.Destination, request.Protocol), request.FreshConnection)
+	}
+	return in, t, kernel, err
+}
+
+// newMsg returns a pooled query message for name, reusing its slices.
+func newMsg(name string, record_type uint16) *dns.Msg {
+	m := msgPool.Get().(*dns.Msg)
+	question := m.Question[:0]
+	extra := m.Extra[:0]
+	*m = dns.Msg{}
+	m.Id = dns.Id()
+	m.RecursionDesired = true
+	m.Question = append(question, dns.Question{Name: name, Qtype: record_type, Qclass: dns.ClassINET})
+	m.Extra = extra
+	return m
+}
@@ -0,0 +1,91 @@
+// part of the dnsqueue package, sends queries over DNS over HTTPS (RFC 8484).
+package dnsqueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DOH_CONTENT_TYPE is the media type of DNS messages sent over HTTPS.
+const DOH_CONTENT_TYPE = "application/dns-message"
+
+// dohClient is shared by every DoH query, so connections to a server are reused.
+var dohClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     &tls.Config{},
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: MAX_IDLE_PER_DEST,
+		IdleConnTimeout:     IDLE_TIMEOUT,
+	},
+}
+
+// IsDoH returns true if dest is a DNS over HTTPS URL rather than host:port.
+func IsDoH(dest string) bool {
+	return strings.HasPrefix(dest, "https://")
+}
+
+// exchangeDoH POSTs m to the DoH URL dest using client. Connection setup
+// (TCP and TLS) is timed separately from the query, as for other transports.
+func exchangeDoH(ctx context.Context, client *http.Client, m *dns.Msg, dest string) (in *dns.Msg, t timing, err error) {
+	// RFC 8484 asks for an ID of 0, which makes responses cache friendly.
+	id := m.Id
+	m.Id = 0
+	packed, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, t, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DEFAULT_TIMEOUT)
+		defer cancel()
+	}
+
+	var getConn, gotConn time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			gotConn = time.Now()
+			t.newConn = !info.Reused
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "POST", dest, bytes.NewReader(packed))
+	if err != nil {
+		return nil, t, err
+	}
+	req.Header.Set("Content-Type", DOH_CONTENT_TYPE)
+	req.Header.Set("Accept", DOH_CONTENT_TYPE)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, t, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if t.newConn {
+		t.connect = gotConn.Sub(getConn)
+	}
+	t.rtt = time.Since(gotConn)
+	if err != nil {
+		return nil, t, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, t, &QueryError{FAILURE_BAD_RESPONSE, fmt.Errorf("DoH server returned %s", resp.Status)}
+	}
+	in = new(dns.Msg)
+	if err := in.Unpack(body); err != nil {
+		return nil, t, &QueryError{FAILURE_BAD_RESPONSE, err}
+	}
+	in.Id = id
+	return in, t, nil
+}
@@ -0,0 +1,22 @@
+// part of the dnsqueue package, names DNS over QUIC (RFC 9250) destinations.
+package dnsqueue
+
+import (
+	"strings"
+)
+
+// DOQ_SCHEME prefixes DNS over QUIC destinations, e.g. doq://94.140.14.14:853.
+const DOQ_SCHEME = "doq://"
+
+// DOQ_ALPN identifies DNS over QUIC in the TLS handshake.
+const DOQ_ALPN = "doq"
+
+// IsDoQ returns true if dest is a DNS over QUIC destination rather than host:port.
+func IsDoQ(dest string) bool {
+	return strings.HasPrefix(dest, DOQ_SCHEME)
+}
+
+// doqAddress returns the host:port of a DNS over QUIC destination.
+func doqAddress(dest string) string {
+	return strings.TrimPrefix(dest, DOQ_SCHEME)
+}
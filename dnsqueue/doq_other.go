@@ -0,0 +1,21 @@
+//go:build !doq
+
+// part of the dnsqueue package, DNS over QUIC is only built with -tags doq.
+package dnsqueue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// DoQSupported is true if namebench was built with DNS over QUIC.
+const DoQSupported = false
+
+// errNoDoQ is returned for DNS over QUIC queries in builds without it.
+var errNoDoQ = errors.New("DNS over QUIC needs namebench built with -tags doq")
+
+func exchangeDoQ(ctx context.Context, m *dns.Msg, dest string, fresh bool) (*dns.Msg, timing, error) {
+	return nil, timing{}, errNoDoQ
+}
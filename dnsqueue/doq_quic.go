@@ -0,0 +1,177 @@
+//go:build doq
+
+// part of the dnsqueue package, sends queries over DNS over QUIC (RFC 9250).
+// It needs github.com/quic-go/quic-go, so is only built with -tags doq.
+package dnsqueue
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"golang.org/x/sync/singleflight"
+)
+
+// DoQSupported is true if namebench was built with DNS over QUIC.
+const DoQSupported = true
+
+// DOQ_NO_ERROR is the application error code connections are closed with.
+const DOQ_NO_ERROR = 0
+
+// DOQ_HANDSHAKE_TIMEOUT bounds a handshake shared by queries waiting for the
+// same destination, which no single query's deadline applies to.
+const DOQ_HANDSHAKE_TIMEOUT = 5 * time.Second
+
+var (
+	// doqConns holds a QUIC connection per destination, shared by every query.
+	doqConns = make(map[string]*quic.Conn)
+	doqMu    sync.Mutex
+	// doqDials lets queries to a destination without a connection share one
+	// handshake, made without holding doqMu.
+	doqDials singleflight.Group
+)
+
+// doqDialed is the outcome of a shared dial.
+type doqDialed struct {
+	conn    *quic.Conn
+	connect time.Duration
+}
+
+// doqConn returns the open connection to addr, or dials one, returning how
+// long the handshake took. If fresh is true a new connection is always
+// dialed, and it is not kept for other queries. Queries waiting for the
+// same destination's handshake share it, and report its duration; those to
+// other destinations are not held up by it. The shared handshake outlives
+// the query that started it, and each query stops waiting for it when its
+// own ctx is done.
+func doqConn(ctx context.Context, addr string, fresh bool) (*quic.Conn, time.Duration, error) {
+	if fresh {
+		return dialDoQ(ctx, addr)
+	}
+	doqMu.Lock()
+	conn, ok := doqConns[addr]
+	doqMu.Unlock()
+	if ok && conn.Context().Err() == nil {
+		return conn, 0, nil
+	}
+	dialed := doqDials.DoChan(addr, func() (interface{}, error) {
+		dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DOQ_HANDSHAKE_TIMEOUT)
+		defer cancel()
+		conn, connect, err := dialDoQ(dctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		doqMu.Lock()
+		doqConns[addr] = conn
+		doqMu.Unlock()
+		return doqDialed{conn, connect}, nil
+	})
+	select {
+	case r := <-dialed:
+		if r.Err != nil {
+			return nil, 0, r.Err
+		}
+		d := r.Val.(doqDialed)
+		return d.conn, d.connect, nil
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// dialDoQ opens a new QUIC connection to addr, timing the handshake.
+func dialDoQ(ctx context.Context, addr string) (*quic.Conn, time.Duration, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	conn, err := quic.DialAddr(ctx, addr, &tls.Config{ServerName: host, NextProtos: []string{DOQ_ALPN}}, nil)
+	return conn, time.Since(start), err
+}
+
+// forgetDoQ drops a failed connection, so the next query dials a new one.
+func forgetDoQ(addr string, conn *quic.Conn) {
+	doqMu.Lock()
+	defer doqMu.Unlock()
+	if doqConns[addr] == conn {
+		delete(doqConns, addr)
+	}
+	conn.CloseWithError(DOQ_NO_ERROR, "")
+}
+
+// exchangeDoQ sends m to the DoQ destination dest on a new stream of a
+// shared connection, or of a connection of its own if fresh is true. The
+// QUIC handshake is timed separately from the query.
+func exchangeDoQ(ctx context.Context, m *dns.Msg, dest string, fresh bool) (in *dns.Msg, t timing, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DEFAULT_TIMEOUT)
+		defer cancel()
+	}
+	addr := doqAddress(dest)
+	conn, connect, err := doqConn(ctx, addr, fresh)
+	t.connect = connect
+	t.newConn = connect > 0
+	if err != nil {
+		return nil, t, err
+	}
+	if fresh {
+		defer conn.CloseWithError(DOQ_NO_ERROR, "")
+	}
+
+	// RFC 9250 requires an ID of 0, and a 2 byte length before each message.
+	id := m.Id
+	m.Id = 0
+	packed, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, t, err
+	}
+	buf := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(buf, uint16(len(packed)))
+	copy(buf[2:], packed)
+
+	start := time.Now()
+	in, err = doqStream(ctx, conn, buf)
+	t.rtt = time.Since(start)
+	if err != nil {
+		if !fresh {
+			forgetDoQ(addr, conn)
+		}
+		return nil, t, err
+	}
+	in.Id = id
+	return in, t, nil
+}
+
+// doqStream sends a length-prefixed query on a new stream of conn, closing
+// its sending side as RFC 9250 asks, and reads the response.
+func doqStream(ctx context.Context, conn *quic.Conn, query []byte) (*dns.Msg, error) {
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	if _, err := stream.Write(query); err != nil {
+		return nil, err
+	}
+	stream.Close()
+	var length [2]byte
+	if _, err := io.ReadFull(stream, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(stream, resp); err != nil {
+		return nil, err
+	}
+	in := new(dns.Msg)
+	return in, in.Unpack(resp)
+}
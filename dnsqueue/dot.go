@@ -0,0 +1,24 @@
+// part of the dnsqueue package, sends queries over DNS over TLS (RFC 7858).
+package dnsqueue
+
+import (
+	"strings"
+)
+
+// DOT_SCHEME prefixes DNS over TLS destinations, e.g. dot://9.9.9.9:853.
+const DOT_SCHEME = "dot://"
+
+// IsDoT returns true if dest is a DNS over TLS destination rather than host:port.
+func IsDoT(dest string) bool {
+	return strings.HasPrefix(dest, DOT_SCHEME)
+}
+
+// Encrypted returns true if queries to dest are encrypted, with DoT, DoQ or DoH.
+func Encrypted(dest string) bool {
+	return IsDoT(dest) || IsDoQ(dest) || IsDoH(dest)
+}
+
+// dotAddress returns the host:port of a DNS over TLS destination.
+func dotAddress(dest string) string {
+	return strings.TrimPrefix(dest, DOT_SCHEME)
+}
@@ -0,0 +1,52 @@
+// part of the dnsqueue package, adds the EDNS Client Subnet option (RFC 7871).
+package dnsqueue
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Prefix lengths used for a client subnet given as a bare address, the
+// longest RFC 7871 recommends sending so as not to identify the client.
+const (
+	ECS_PREFIX_V4 = 24
+	ECS_PREFIX_V6 = 56
+)
+
+// ParseClientSubnet parses a client subnet such as 203.0.113.0/24 or
+// 2001:db8::/56. A bare address is truncated to ECS_PREFIX_V4 or ECS_PREFIX_V6.
+func ParseClientSubnet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid client subnet %q", s)
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip.To4().Mask(net.CIDRMask(ECS_PREFIX_V4, 32)), Mask: net.CIDRMask(ECS_PREFIX_V4, 32)}, nil
+		}
+		return &net.IPNet{IP: ip.Mask(net.CIDRMask(ECS_PREFIX_V6, 128)), Mask: net.CIDRMask(ECS_PREFIX_V6, 128)}, nil
+	}
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %q: %s", s, err)
+	}
+	return subnet, nil
+}
+
+// subnetOption returns the EDNS0 option announcing subnet to the resolver.
+func subnetOption(subnet *net.IPNet) *dns.EDNS0_SUBNET {
+	bits, _ := subnet.Mask.Size()
+	o := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, SourceNetmask: uint8(bits)}
+	if v4 := subnet.IP.To4(); v4 != nil {
+		o.Family = 1
+		o.Address = v4
+	} else {
+		o.Family = 2
+		o.Address = subnet.IP
+	}
+	return o
+}
@@ -0,0 +1,84 @@
+// part of the dnsqueue package, classifies why queries fail.
+package dnsqueue
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Failure is why a query got no usable answer, so that results can be
+// aggregated by cause without parsing Result.Error.
+type Failure string
+
+const (
+	// No answer arrived in time.
+	FAILURE_TIMEOUT Failure = "timeout"
+	// The nameserver could not be reached, or the connection to it failed.
+	FAILURE_UNREACHABLE Failure = "unreachable"
+	// An answer arrived but could not be used: it was malformed, did not
+	// match the query, or DNS over HTTPS returned an HTTP error.
+	FAILURE_BAD_RESPONSE Failure = "bad_response"
+	// The answer had the TC bit set and was not retried over TCP.
+	FAILURE_TRUNCATED Failure = "truncated"
+	// The answer's response code.
+	FAILURE_SERVFAIL Failure = "servfail"
+	FAILURE_REFUSED  Failure = "refused"
+	FAILURE_NXDOMAIN Failure = "nxdomain"
+	// Any other response code than NOERROR.
+	FAILURE_RCODE Failure = "rcode"
+	// The request could not be sent, e.g. an unknown record type.
+	FAILURE_INVALID Failure = "invalid"
+)
+
+// QueryError is the error of a query whose Failure is known where it occurred.
+type QueryError struct {
+	Failure Failure
+	Err     error
+}
+
+func (e *QueryError) Error() string { return e.Err.Error() }
+
+func (e *QueryError) Unwrap() error { return e.Err }
+
+// errorFailure returns why err stopped a query from being answered.
+func errorFailure(err error) Failure {
+	var qe *QueryError
+	var ne net.Error
+	var de *dns.Error
+	switch {
+	case errors.As(err, &qe):
+		return qe.Failure
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return FAILURE_TIMEOUT
+	case errors.As(err, &de):
+		// Unpacking, ID mismatch and short reads.
+		return FAILURE_BAD_RESPONSE
+	}
+	return FAILURE_UNREACHABLE
+}
+
+// rcodeFailure returns the Failure for an answer's response code, or "" for
+// NOERROR.
+func rcodeFailure(rcode int) Failure {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return ""
+	case dns.RcodeServerFailure:
+		return FAILURE_SERVFAIL
+	case dns.RcodeRefused:
+		return FAILURE_REFUSED
+	case dns.RcodeNameError:
+		return FAILURE_NXDOMAIN
+	}
+	return FAILURE_RCODE
+}
+
+// Failed returns true if the query got no answer, or the nameserver answered
+// SERVFAIL or REFUSED: a fast failure is not a fast response. NXDOMAIN and
+// other response codes are answers, which callers judge themselves.
+func (r *Result) Failed() bool {
+	return r.Error != "" || r.Failure == FAILURE_SERVFAIL || r.Failure == FAILURE_REFUSED
+}
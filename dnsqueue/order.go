@@ -0,0 +1,44 @@
+// part of the dnsqueue package, puts results back into request order.
+package dnsqueue
+
+import "sort"
+
+// InOrder wraps collect so that results reach it in request ID order, no
+// matter which worker finished first. Results are buffered until every
+// earlier ID has been seen. Call flush once collection is over to pass on
+// results held back by an ID that never arrived. It holds no more results
+// than the queue has requests outstanding, which MAX_BACKLOG bounds for
+// each destination.
+func InOrder(collect func(*Result)) (ordered func(*Result), flush func()) {
+	next := uint64(1)
+	pending := make(map[uint64]*Result)
+	ordered = func(r *Result) {
+		pending[r.ID] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				return
+			}
+			delete(pending, next)
+			next += 1
+			collect(r)
+		}
+	}
+	flush = func() {
+		var rest []*Result
+		for _, r := range pending {
+			rest = append(rest, r)
+		}
+		SortResults(rest)
+		for _, r := range rest {
+			collect(r)
+		}
+		pending = make(map[uint64]*Result)
+	}
+	return ordered, flush
+}
+
+// SortResults orders results by request ID.
+func SortResults(results []*Result) {
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+}
@@ -0,0 +1,238 @@
+// part of the dnsqueue package, gives each destination its own workers under
+// a budget shared by the whole queue.
+package dnsqueue
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MAX_BACKLOG is how many requests may wait for one destination's workers.
+// Once a destination's backlog is full, dispatch waits for it to drain, and
+// the producer in turn blocks in Add, so a destination that never answers
+// holds at most this many requests in memory.
+const MAX_BACKLOG = 1024
+
+// pools holds the requests waiting for each destination's workers. Every
+// destination gets its own backlog, feeder and workers, so that a slow
+// destination's timeouts only hold up its own queries until its backlog
+// fills, while the queue's budget of WorkerCount slots bounds how many
+// queries are in flight altogether.
+type pools struct {
+	mu      sync.Mutex
+	size    int
+	limit   int
+	workers int
+	dests   map[string]*backlog
+}
+
+func newPools(size, workers int) *pools {
+	return &pools{size: size, limit: MAX_BACKLOG, workers: workers, dests: make(map[string]*backlog)}
+}
+
+// backlog is one destination's queue of requests. The dispatcher appends to
+// pending until it holds limit requests, and the destination's feeder moves
+// them on to its workers through requests as fast as they take them.
+type backlog struct {
+	mu       sync.Mutex
+	pending  []*Request
+	limit    int
+	closed   bool
+	ready    chan bool
+	space    chan bool
+	requests chan *Request
+}
+
+// push appends a request to the backlog and wakes the feeder, first waiting
+// for the feeder to make room if the backlog is full. It fails once ctx is
+// done.
+func (b *backlog) push(ctx context.Context, r *Request) error {
+	for {
+		b.mu.Lock()
+		if len(b.pending) < b.limit {
+			b.pending = append(b.pending, r)
+			b.mu.Unlock()
+			b.wake()
+			return nil
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.space:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// close tells the feeder no more requests will come.
+func (b *backlog) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.wake()
+}
+
+func (b *backlog) wake() {
+	select {
+	case b.ready <- true:
+	default:
+	}
+}
+
+// next pops the oldest pending request, or returns nil and whether the
+// backlog is closed if none is pending.
+func (b *backlog) next() (r *Request, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		return nil, b.closed
+	}
+	r = b.pending[0]
+	b.pending[0] = nil
+	b.pending = b.pending[1:]
+	select {
+	case b.space <- true:
+	default:
+	}
+	return r, false
+}
+
+// feed hands pending requests to the destination's workers until the
+// backlog is closed and empty, then closes requests so they exit.
+func (b *backlog) feed(ctx context.Context) error {
+	for {
+		r, closed := b.next()
+		if r == nil {
+			if closed {
+				close(b.requests)
+				return nil
+			}
+			select {
+			case <-b.ready:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		select {
+		case b.requests <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// depth returns how many requests wait in the backlog or for a worker.
+func (b *backlog) depth() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending) + len(b.requests)
+}
+
+// setWorkers sets how many workers each destination started later gets, at
+// least one.
+func (p *pools) setWorkers(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers = 1
+	if n > 1 {
+		p.workers = n
+	}
+}
+
+// get returns dest's backlog, and whether it was just created: it then
+// needs a feeder and workers, as many as the second value says.
+func (p *pools) get(dest string) (b *backlog, workers int, created bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if b, ok := p.dests[dest]; ok {
+		return b, 0, false
+	}
+	b = &backlog{
+		limit:    p.limit,
+		ready:    make(chan bool, 1),
+		space:    make(chan bool, 1),
+		requests: make(chan *Request, p.size),
+	}
+	p.dests[dest] = b
+	return b, p.workers, true
+}
+
+// depth returns how many requests wait for workers, in every destination.
+func (p *pools) depth() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range p.dests {
+		n += b.depth()
+	}
+	return n
+}
+
+// close tells every destination's feeder that no more requests will come.
+func (p *pools) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range p.dests {
+		b.close()
+	}
+}
+
+// dispatch hands each request on the queue to its destination's backlog,
+// starting its feeder and workers in g on its first request. It only waits
+// for a destination whose backlog is full, so one that stops answering
+// holds up the others no sooner than MAX_BACKLOG requests for it are
+// queued, and then pushes back on the producer rather than buffering the
+// rest of the workload. Once every worker's completion signal has arrived,
+// the destinations are closed and their workers exit when they have drained
+// them.
+func (q *Queue) dispatch(ctx context.Context, g *errgroup.Group) error {
+	defer q.pools.close()
+	exits := 0
+	for {
+		var request *Request
+		select {
+		case request = <-q.Requests:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if request.exit {
+			if exits += 1; exits == q.WorkerCount {
+				log.Printf("Completion received, workers are done.")
+				return nil
+			}
+			continue
+		}
+		b, workers, created := q.pools.get(request.Destination)
+		if created {
+			g.Go(func() error {
+				return b.feed(ctx)
+			})
+		}
+		for i := 0; i < workers; i++ {
+			g.Go(func() error {
+				return q.startWorker(ctx, b.requests)
+			})
+		}
+		if err := b.push(ctx, request); err != nil {
+			return err
+		}
+	}
+}
+
+// acquire takes one of the queue's budget slots, or fails once ctx is done.
+func (q *Queue) acquire(ctx context.Context) error {
+	select {
+	case q.budget <- true:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a budget slot taken by acquire.
+func (q *Queue) release() {
+	<-q.budget
+}
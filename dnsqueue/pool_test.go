@@ -0,0 +1,119 @@
+package dnsqueue
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blackholeServer starts a UDP listener that reads every query and never
+// answers, returning its address.
+func blackholeServer(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			if _, _, err := pc.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { pc.Close() })
+	return pc.LocalAddr().String()
+}
+
+// TestBlackholeDoesNotBlockOthers checks that a destination that never
+// answers does not hold up the queries to one that does, even once far more
+// requests for it are queued than its workers and buffers can hold.
+func TestBlackholeDoesNotBlockOthers(t *testing.T) {
+	const QUERIES = 200
+	good, blackhole := answeringServer(t), blackholeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := StartQueue(ctx, 32, 4)
+	answered := make(chan bool)
+	var got int64
+	errc := make(chan error, 1)
+	go func() {
+		errc <- q.Stream(func(add func(*Request) error) error {
+			for i := 0; i < QUERIES; i++ {
+				for _, dest := range []string{blackhole, good} {
+					r := &Request{Destination: dest, RecordType: "A", RecordName: "example.com.", Timeout: time.Second}
+					if err := add(r); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}, func(r *Result) {
+			if r.Request.Destination != good {
+				return
+			}
+			if r.Error != "" {
+				t.Errorf("query to %s failed: %s", good, r.Error)
+			}
+			if atomic.AddInt64(&got, 1) == QUERIES {
+				close(answered)
+			}
+		})
+	}()
+
+	select {
+	case <-answered:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%d of %d queries to %s answered while %s was blackholed", atomic.LoadInt64(&got), QUERIES, good, blackhole)
+	}
+	cancel()
+	select {
+	case <-errc:
+	case <-time.After(10 * time.Second):
+		t.Fatal("queue did not stop after being cancelled")
+	}
+}
+
+// TestFullBacklogBlocksAdd checks that requests for a destination that never
+// answers stop being accepted once its backlog is full, rather than piling
+// up in memory.
+func TestFullBacklogBlocksAdd(t *testing.T) {
+	const QUERIES = 100
+	blackhole := blackholeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := StartQueue(ctx, 4, 2)
+	q.pools.limit = 8
+	var added int64
+	errc := make(chan error, 1)
+	go func() {
+		errc <- q.Stream(func(add func(*Request) error) error {
+			for i := 0; i < QUERIES; i++ {
+				r := &Request{Destination: blackhole, RecordType: "A", RecordName: "example.com.", Timeout: 5 * time.Second}
+				if err := add(r); err != nil {
+					return err
+				}
+				atomic.AddInt64(&added, 1)
+			}
+			return nil
+		}, func(r *Result) {})
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	if n := atomic.LoadInt64(&added); n >= QUERIES {
+		t.Errorf("all %d requests for %s were accepted, want the full backlog to block", n, blackhole)
+	}
+	if depth := q.pools.depth(); depth > q.pools.limit+4 {
+		t.Errorf("%d requests wait for %s, want at most %d", depth, blackhole, q.pools.limit+4)
+	}
+	cancel()
+	select {
+	case <-errc:
+	case <-time.After(10 * time.Second):
+		t.Fatal("queue did not stop after being cancelled")
+	}
+}
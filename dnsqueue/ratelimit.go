@@ -0,0 +1,94 @@
+// part of the dnsqueue package, spaces out the queries sent to each destination.
+package dnsqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bucket holds the tokens of one destination. Tokens go negative as queries
+// are booked ahead, each waiting until its token has been refilled.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// destLimiter is a token bucket per destination: up to burst queries are sent
+// at once, then no more than the destination's rate per second. A zero rate
+// is no limit.
+type destLimiter struct {
+	mu      sync.Mutex
+	qps     float64
+	burst   float64
+	rates   map[string]float64
+	buckets map[string]*bucket
+}
+
+func newDestLimiter() *destLimiter {
+	return &destLimiter{burst: 1, rates: make(map[string]float64), buckets: make(map[string]*bucket)}
+}
+
+// setRate sets the most queries per second sent to each destination.
+func (l *destLimiter) setRate(qps float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.qps = qps
+}
+
+// setDestRate sets the most queries per second sent to dest, overriding setRate.
+func (l *destLimiter) setDestRate(dest string, qps float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rates[dest] = qps
+}
+
+// setBurst sets how many queries may be sent to a destination at once, at
+// least one.
+func (l *destLimiter) setBurst(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.burst = 1
+	if n > 1 {
+		l.burst = float64(n)
+	}
+}
+
+// wait blocks until a query may be sent to dest, or ctx is done.
+func (l *destLimiter) wait(ctx context.Context, dest string) error {
+	l.mu.Lock()
+	rate, ok := l.rates[dest]
+	if !ok {
+		rate = l.qps
+	}
+	if rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	b := l.buckets[dest]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[dest] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	b.tokens -= 1
+	delay := time.Duration(-b.tokens / rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
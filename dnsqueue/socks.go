@@ -0,0 +1,180 @@
+// part of the dnsqueue package, sends queries through a SOCKS5 proxy such as Tor.
+package dnsqueue
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	// proxyClients holds a DoH client per proxy URL, so connections are reused.
+	proxyClients   = make(map[string]*http.Client)
+	proxyClientsMu sync.Mutex
+)
+
+// proxyClient returns the DoH client for a SOCKS proxy URL.
+func proxyClient(proxy string) (*http.Client, error) {
+	proxyClientsMu.Lock()
+	defer proxyClientsMu.Unlock()
+	if c, ok := proxyClients[proxy]; ok {
+		return c, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := dohClient.Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	c := &http.Client{Transport: transport}
+	proxyClients[proxy] = c
+	return c, nil
+}
+
+// socksDial connects to dest ("host:port") through the SOCKS5 proxy at
+// proxy, e.g. socks5://127.0.0.1:9050, using the CONNECT command (RFC 1928).
+func socksDial(ctx context.Context, proxy string, dest string) (net.Conn, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	host, portStr, err := net.SplitHostPort(dest)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := socksHandshake(conn, u.User, host, port); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// socksHandshake negotiates authentication and sends a CONNECT request.
+func socksHandshake(conn net.Conn, user *url.Userinfo, host string, port int) error {
+	method := byte(0x00) // no authentication
+	if user != nil {
+		method = 0x02 // username/password
+	}
+	if _, err := conn.Write([]byte{5, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 || reply[1] != method {
+		return fmt.Errorf("SOCKS proxy refused authentication method %d", method)
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req := []byte{1, byte(len(user.Username()))}
+		req = append(req, user.Username()...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("SOCKS proxy rejected credentials")
+		}
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 1), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 4), ip.To16()...)
+	} else {
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("SOCKS proxy could not connect (reply %d)", head[1])
+	}
+	// Skip the bound address and port.
+	skip := 0
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len + 2
+	case 4:
+		skip = net.IPv6len + 2
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	}
+	_, err := io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// exchangeSOCKS sends m to dest over TCP, or TLS for DNS over TLS, through a
+// SOCKS proxy. Connecting through the proxy is timed separately from the query.
+func exchangeSOCKS(ctx context.Context, m *dns.Msg, proxy string, dest string) (in *dns.Msg, t timing, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DEFAULT_TIMEOUT)
+		defer cancel()
+	}
+	start := time.Now()
+	addr := dest
+	if IsDoT(dest) {
+		addr = dotAddress(dest)
+	}
+	conn, err := socksDial(ctx, proxy, addr)
+	if err == nil && IsDoT(dest) {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		deadline, _ := ctx.Deadline()
+		tlsConn.SetDeadline(deadline)
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+		}
+		conn = tlsConn
+	}
+	t.connect = time.Since(start)
+	t.newConn = true
+	if err != nil {
+		return nil, t, err
+	}
+	defer conn.Close()
+	client := &dns.Client{Net: "tcp"}
+	in, t.rtt, err = client.ExchangeWithConnContext(ctx, m, &dns.Conn{Conn: conn})
+	return in, t, err
+}
@@ -0,0 +1,182 @@
+// part of the dnsqueue package, tracks queue and worker metrics.
+package dnsqueue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a point-in-time snapshot of a queue's activity. The progress
+// displays and monitor metrics read their query counts from it.
+type Stats struct {
+	// Queries handed to the network, currently awaiting a reply, and finished.
+	Sent      int64
+	InFlight  int64
+	Completed int64
+
+	// Completed queries per destination.
+	DestinationCompleted map[string]int64
+
+	// Completed queries that failed, in total, per destination and by cause,
+	// and by cause for each destination.
+	Errors              int64
+	DestinationErrors   map[string]int64
+	Failures            map[Failure]int64
+	DestinationFailures map[string]map[Failure]int64
+
+	// Requests waiting in the queue, and requests added but not yet collected.
+	QueueDepth  int
+	Outstanding int
+
+	// Workers currently sending a query, and the fraction of the queue's
+	// budget of concurrent queries that is.
+	BusyWorkers int64
+	Workers     int
+	Utilization float64
+}
+
+// counters are updated by workers as queries progress.
+type counters struct {
+	sent      int64
+	inFlight  int64
+	completed int64
+	errors    int64
+
+	mu            sync.Mutex
+	destCompleted map[string]int64
+	destErrors    map[string]int64
+	failures      map[Failure]int64
+	destFailures  map[string]map[Failure]int64
+}
+
+func newCounters() *counters {
+	return &counters{
+		destCompleted: make(map[string]int64),
+		destErrors:    make(map[string]int64),
+		failures:      make(map[Failure]int64),
+		destFailures:  make(map[string]map[Failure]int64),
+	}
+}
+
+// start records a query being sent.
+func (c *counters) start() {
+	atomic.AddInt64(&c.sent, 1)
+	atomic.AddInt64(&c.inFlight, 1)
+}
+
+// finish records a query completing, and its error if it failed.
+func (c *counters) finish(result *Result) {
+	dest := result.Request.Destination
+	atomic.AddInt64(&c.inFlight, -1)
+	atomic.AddInt64(&c.completed, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.destCompleted[dest] += 1
+	if !result.Failed() {
+		return
+	}
+	atomic.AddInt64(&c.errors, 1)
+	c.destErrors[dest] += 1
+	c.failures[result.Failure] += 1
+	if c.destFailures[dest] == nil {
+		c.destFailures[dest] = make(map[Failure]int64)
+	}
+	c.destFailures[dest][result.Failure] += 1
+}
+
+// Queue.Stats returns a snapshot of the queue's metrics. It is safe to call
+// from any goroutine while the queue is running.
+func (q *Queue) Stats() Stats {
+	c := q.counters
+	s := Stats{
+		Sent:        atomic.LoadInt64(&c.sent),
+		InFlight:    atomic.LoadInt64(&c.inFlight),
+		Completed:   atomic.LoadInt64(&c.completed),
+		Errors:      atomic.LoadInt64(&c.errors),
+		QueueDepth:  len(q.Requests) + q.pools.depth(),
+		Outstanding: q.outstanding.len(),
+		Workers:     q.WorkerCount,
+	}
+	// Each worker has at most one query in flight.
+	s.BusyWorkers = s.InFlight
+	if s.Workers > 0 {
+		s.Utilization = float64(s.BusyWorkers) / float64(s.Workers)
+	}
+	c.mu.Lock()
+	s.add(Stats{
+		DestinationCompleted: c.destCompleted,
+		DestinationErrors:    c.destErrors,
+		Failures:             c.failures,
+		DestinationFailures:  c.destFailures,
+	})
+	c.mu.Unlock()
+	return s
+}
+
+// add sums o's counters into s, copying its maps, which s allocates if needed.
+func (s *Stats) add(o Stats) {
+	s.Sent += o.Sent
+	s.InFlight += o.InFlight
+	s.Completed += o.Completed
+	s.Errors += o.Errors
+	s.QueueDepth += o.QueueDepth
+	s.Outstanding += o.Outstanding
+	s.BusyWorkers += o.BusyWorkers
+	s.Workers += o.Workers
+	if s.DestinationCompleted == nil {
+		s.DestinationCompleted = make(map[string]int64)
+		s.DestinationErrors = make(map[string]int64)
+		s.Failures = make(map[Failure]int64)
+		s.DestinationFailures = make(map[string]map[Failure]int64)
+	}
+	for dest, n := range o.DestinationCompleted {
+		s.DestinationCompleted[dest] += n
+	}
+	for dest, n := range o.DestinationErrors {
+		s.DestinationErrors[dest] += n
+	}
+	for f, n := range o.Failures {
+		s.Failures[f] += n
+	}
+	for dest, failures := range o.DestinationFailures {
+		if s.DestinationFailures[dest] == nil {
+			s.DestinationFailures[dest] = make(map[Failure]int64)
+		}
+		for f, n := range failures {
+			s.DestinationFailures[dest][f] += n
+		}
+	}
+}
+
+// StatsSet sums the Stats of several queues, e.g. the one per nameserver a
+// benchmark runs through. The zero value is empty and ready to use, and a
+// nil StatsSet ignores Watch.
+type StatsSet struct {
+	mu     sync.Mutex
+	queues []*Queue
+}
+
+// StatsSet.Watch adds q to the set. Its Stats keep counting once it is done.
+func (w *StatsSet) Watch(q *Queue) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queues = append(w.queues, q)
+}
+
+// StatsSet.Stats returns the sum of the Stats of every queue in the set.
+func (w *StatsSet) Stats() Stats {
+	w.mu.Lock()
+	queues := append([]*Queue{}, w.queues...)
+	w.mu.Unlock()
+	var s Stats
+	for _, q := range queues {
+		s.add(q.Stats())
+	}
+	if s.Workers > 0 {
+		s.Utilization = float64(s.BusyWorkers) / float64(s.Workers)
+	}
+	return s
+}
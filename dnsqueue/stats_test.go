@@ -0,0 +1,47 @@
+package dnsqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestStatsSetSumsQueues checks that a StatsSet counts the completed and
+// failed queries of every queue it watches, per destination.
+func TestStatsSetSumsQueues(t *testing.T) {
+	const QUERIES = 10
+	good := answeringServer(t)
+	stats := &StatsSet{}
+	for i := 0; i < 2; i++ {
+		q := StartQueue(context.Background(), 4, 2)
+		stats.Watch(q)
+		err := q.Stream(func(add func(*Request) error) error {
+			for j := 0; j < QUERIES; j++ {
+				for _, r := range []*Request{
+					{Destination: good, RecordType: "A", RecordName: "example.com."},
+					{Destination: good, RecordType: "BOGUS", RecordName: "example.com."},
+				} {
+					r.Timeout = time.Second
+					if err := add(r); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}, func(r *Result) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := stats.Stats()
+	if s.Completed != 4*QUERIES || s.DestinationCompleted[good] != 4*QUERIES {
+		t.Errorf("completed %d, %d for %s, want %d", s.Completed, s.DestinationCompleted[good], good, 4*QUERIES)
+	}
+	if s.DestinationErrors[good] != 2*QUERIES || s.DestinationFailures[good][FAILURE_INVALID] != 2*QUERIES {
+		t.Errorf("%d errors for %s, %d invalid, want %d", s.DestinationErrors[good], good, s.DestinationFailures[good][FAILURE_INVALID], 2*QUERIES)
+	}
+	if s.InFlight != 0 || s.Workers != 4 {
+		t.Errorf("%d in flight of %d workers, want 0 of 4", s.InFlight, s.Workers)
+	}
+}
@@ -0,0 +1,51 @@
+// part of the dnsqueue package, sends plain DNS over TCP, either always or
+// when a UDP answer is truncated.
+package dnsqueue
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PROTOCOL_UDP sends plain DNS over UDP only. It is the default.
+	PROTOCOL_UDP = "udp"
+
+	// PROTOCOL_TCP sends plain DNS over TCP only.
+	PROTOCOL_TCP = "tcp"
+
+	// PROTOCOL_AUTO sends plain DNS over UDP, retrying over TCP if the
+	// answer is truncated, as a stub resolver would.
+	PROTOCOL_AUTO = "auto"
+)
+
+// tcpScheme marks connection cache destinations that are dialed over TCP.
+const tcpScheme = "tcp://"
+
+// CheckProtocol returns an error unless protocol is one of the PROTOCOL_
+// values, or empty for the default.
+func CheckProtocol(protocol string) error {
+	switch protocol {
+	case "", PROTOCOL_UDP, PROTOCOL_TCP, PROTOCOL_AUTO:
+		return nil
+	}
+	return fmt.Errorf("unknown protocol %q: want %s, %s or %s", protocol, PROTOCOL_UDP, PROTOCOL_TCP, PROTOCOL_AUTO)
+}
+
+// plainDestination returns the connection cache destination for a plain or
+// DNS over TLS query to dest, sent with protocol.
+func plainDestination(dest string, protocol string) string {
+	if protocol == PROTOCOL_TCP && !IsDoT(dest) {
+		return tcpScheme + dest
+	}
+	return dest
+}
+
+// isTCP returns true if a connection cache destination is dialed over TCP,
+// and the address to dial.
+func isTCP(dest string) (bool, string) {
+	if strings.HasPrefix(dest, tcpScheme) {
+		return true, strings.TrimPrefix(dest, tcpScheme)
+	}
+	return false, dest
+}
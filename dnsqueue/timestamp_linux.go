@@ -0,0 +1,102 @@
+//go:build linux
+
+// part of the dnsqueue package, measures RTTs with kernel receive timestamps.
+package dnsqueue
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/miekg/dns"
+)
+
+// KernelTimestampsSupported is true on platforms implementing SO_TIMESTAMPNS.
+const KernelTimestampsSupported = true
+
+// exchangeKernelTimestamp sends m over a fresh UDP socket and measures the
+// RTT against the kernel's receive timestamp, so time the response spends
+// waiting for this goroutine to be scheduled is not counted. The kernel
+// stamps with the wall clock, so the send time is taken from the wall clock
+// as well. The socket is dialed with dialer, if not nil.
+func exchangeKernelTimestamp(ctx context.Context, m *dns.Msg, dest string, dialer *net.Dialer) (*dns.Msg, time.Duration, error) {
+	if dialer == nil {
+		dialer = new(net.Dialer)
+	}
+	c, err := dialer.DialContext(ctx, "udp", dest)
+	if err != nil {
+		return nil, 0, err
+	}
+	conn := c.(*net.UDPConn)
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, 0, err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DEFAULT_TIMEOUT)
+	}
+	conn.SetDeadline(deadline)
+
+	packed, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	sent := time.Now().Round(0)
+	if _, err := conn.Write(packed); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	oob := make([]byte, syscall.CmsgSpace(int(unsafe.Sizeof(syscall.Timespec{}))))
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+		if err != nil {
+			return nil, 0, err
+		}
+		received := time.Now().Round(0)
+		if stamp, ok := kernelTimestamp(oob[:oobn]); ok {
+			received = stamp
+		}
+		in := new(dns.Msg)
+		if err := in.Unpack(buf[:n]); err != nil {
+			return nil, 0, err
+		}
+		// Ignore stray responses to some other query.
+		if in.Id != m.Id {
+			continue
+		}
+		return in, received.Sub(sent), nil
+	}
+}
+
+// kernelTimestamp extracts the SCM_TIMESTAMPNS control message, if present.
+func kernelTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.SOL_SOCKET && msg.Header.Type == syscall.SCM_TIMESTAMPNS &&
+			len(msg.Data) >= int(unsafe.Sizeof(syscall.Timespec{})) {
+			ts := (*syscall.Timespec)(unsafe.Pointer(&msg.Data[0]))
+			return time.Unix(ts.Unix()), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+// part of the dnsqueue package, kernel timestamps are only implemented on Linux.
+package dnsqueue
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// KernelTimestampsSupported is true on platforms implementing SO_TIMESTAMPNS.
+const KernelTimestampsSupported = false
+
+func exchangeKernelTimestamp(ctx context.Context, m *dns.Msg, dest string, dialer *net.Dialer) (*dns.Msg, time.Duration, error) {
+	return nil, 0, errors.New("kernel timestamps are not supported on this platform")
+}
@@ -0,0 +1,131 @@
+// part of the dnsqueue package, fans a request out across record types, or
+// spreads hostnames across them.
+package dnsqueue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DEFAULT_RECORD_TYPE is queried when no record types are given.
+const DEFAULT_RECORD_TYPE = "A"
+
+// RecordTypes validates record type names, returning them uppercased and
+// without duplicates, or DEFAULT_RECORD_TYPE if there are none.
+func RecordTypes(types []string) (valid []string, err error) {
+	seen := make(map[string]bool)
+	for _, t := range types {
+		name := strings.ToUpper(strings.TrimSpace(t))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := dns.StringToType[name]; !ok {
+			return nil, fmt.Errorf("unknown record type: %s", t)
+		}
+		seen[name] = true
+		valid = append(valid, name)
+	}
+	if len(valid) == 0 {
+		valid = []string{DEFAULT_RECORD_TYPE}
+	}
+	return valid, nil
+}
+
+// ParseRecordTypes parses a comma separated list of record types, e.g. "A,AAAA,MX".
+func ParseRecordTypes(s string) ([]string, error) {
+	return RecordTypes(strings.Split(s, ","))
+}
+
+// ForTypes returns a copy of the request for each record type.
+func (r *Request) ForTypes(types []string) []*Request {
+	requests := make([]*Request, len(types))
+	for i, t := range types {
+		copied := *r
+		copied.RecordType = t
+		requests[i] = &copied
+	}
+	return requests
+}
+
+// QueryMix spreads hostnames across record types in proportion to weights,
+// so that a benchmark resembles real traffic rather than only A queries.
+type QueryMix struct {
+	// Types are the record types, in the order given.
+	Types []string
+	// Weights are the relative shares of Types.
+	Weights []float64
+
+	mu       sync.Mutex
+	credit   []float64
+	assigned map[string]string
+}
+
+// ParseQueryMix parses record types and their weights, e.g.
+// "A:60,AAAA:25,PTR:5,MX:5,TXT:5". The weights need not add up to 100.
+func ParseQueryMix(s string) (*QueryMix, error) {
+	m := &QueryMix{assigned: make(map[string]string)}
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(part, ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("query mix %q: want type:weight, got %q", s, part)
+		}
+		types, err := RecordTypes(fields[:1])
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("query mix %q: weight of %s must be a positive number", s, types[0])
+		}
+		for _, t := range m.Types {
+			if t == types[0] {
+				return nil, fmt.Errorf("query mix %q: %s is listed twice", s, t)
+			}
+		}
+		m.Types = append(m.Types, types[0])
+		m.Weights = append(m.Weights, weight)
+	}
+	m.credit = make([]float64, len(m.Types))
+	return m, nil
+}
+
+// Type returns the record type to query for hostname. A hostname keeps the
+// type it was first given, so every nameserver answers the same queries; new
+// hostnames take turns by smooth weighted round robin, which keeps any run
+// of them close to the weights.
+func (m *QueryMix) Type(hostname string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.assigned[hostname]; ok {
+		return t
+	}
+	total := 0.0
+	best := 0
+	for i, w := range m.Weights {
+		m.credit[i] += w
+		total += w
+		if m.credit[i] > m.credit[best] {
+			best = i
+		}
+	}
+	m.credit[best] -= total
+	m.assigned[hostname] = m.Types[best]
+	return m.Types[best]
+}
+
+// String returns each type's share of the mix, e.g. "A 60%, AAAA 25%".
+func (m *QueryMix) String() string {
+	total := 0.0
+	for _, w := range m.Weights {
+		total += w
+	}
+	shares := make([]string, len(m.Types))
+	for i, t := range m.Types {
+		shares[i] = fmt.Sprintf("%s %.0f%%", t, 100*m.Weights[i]/total)
+	}
+	return strings.Join(shares, ", ")
+}
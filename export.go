@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/google/namebench/upstream"
+)
+
+var target = flag.String("target", "", "export-config: write configuration for unbound, dnsmasq, systemd-resolved, pihole, pihole6 (pihole.toml) or adguard")
+
+// EXPORT_SERVERS is how many of the fastest reliable nameservers are exported.
+const EXPORT_SERVERS = 3
+
+// runExportConfig implements the export-config subcommand: it benchmarks
+// -nameservers and prints a forwarder configuration listing the winners.
+func runExportConfig() error {
+	// Check the target before spending time on the benchmark.
+	if _, err := upstream.Format(*target, nil); err != nil {
+		return err
+	}
+	servers, _, err := bootstrapNameservers(*nameservers)
+	if err != nil {
+		return err
+	}
+	if servers, err = familyServers(servers); err != nil {
+		return err
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	if err := parseRecordTypes(); err != nil {
+		return err
+	}
+	hostnames, err := cliHostnames()
+	if err != nil {
+		return err
+	}
+	// An interrupted benchmark returns an error, so nothing is done with
+	// partial results.
+	ctx, stop := interruptContext()
+	defer stop()
+	summaries, err := runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{dnssecOK: *dnssec})
+	if err != nil {
+		return err
+	}
+	winners := rankUpstreams(summaries, EXPORT_SERVERS, *target)
+	if len(winners) == 0 {
+		return fmt.Errorf("no nameserver was reliable enough to export")
+	}
+	log.Printf("Exporting %v", winners)
+	config, err := upstream.Format(*target, winners)
+	if err != nil {
+		return err
+	}
+	fmt.Print(config)
+	return nil
+}
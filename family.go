@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/providers"
+	"github.com/google/namebench/report"
+)
+
+var ipv4 = flag.Bool("ipv4", false,
+	"Only query nameservers over IPv4, using the IPv4 addresses of well-known resolvers given by IPv6 address; with -ipv6, query them over both (cli mode)")
+var ipv6 = flag.Bool("ipv6", false,
+	"Only query nameservers over IPv6, using the IPv6 addresses of well-known resolvers given by IPv4 address; with -ipv4, query them over both (cli mode)")
+
+// queryFamilies returns the address families -ipv4 and -ipv6 select, or nil
+// to query nameservers as given.
+func queryFamilies() (families []string) {
+	if *ipv4 {
+		families = append(families, parse.IPV4)
+	}
+	if *ipv6 {
+		families = append(families, parse.IPV6)
+	}
+	return families
+}
+
+// familyServers returns servers over the families -ipv4 and -ipv6 select.
+// Well-known resolvers are switched to, or with both flags also given, their
+// addresses in the selected families; other nameservers of an unselected
+// family are left out. Nameservers given by hostname or URL are kept.
+func familyServers(servers []string) ([]string, error) {
+	families := queryFamilies()
+	if families == nil {
+		return servers, nil
+	}
+	seen := make(map[string]bool)
+	var picked, dropped []string
+	for _, ns := range servers {
+		family := parse.Family(ns)
+		if family == "" {
+			family = families[0]
+		}
+		found := false
+		for _, f := range families {
+			addr, ok := ns, true
+			if f != family {
+				addr, ok = providers.FamilyAddress(ns, f)
+			}
+			if !ok {
+				continue
+			}
+			found = true
+			if !seen[addr] {
+				seen[addr] = true
+				picked = append(picked, addr)
+			}
+		}
+		if !found {
+			dropped = append(dropped, ns)
+		}
+	}
+	if len(dropped) > 0 {
+		log.Printf("Leaving out nameservers not reachable over %s: %v", strings.Join(families, " or "), dropped)
+	}
+	if len(picked) == 0 {
+		return nil, fmt.Errorf("no nameservers to query over %s", strings.Join(families, " or "))
+	}
+	return picked, nil
+}
+
+// nameResolvers names the well-known resolver each nameserver is an address
+// of, so that the report compares their IPv4 and IPv6 addresses.
+func nameResolvers(summaries []*report.Summary) {
+	for _, s := range summaries {
+		if name, ok := providers.ResolverName(s.Nameserver); ok {
+			s.Resolver = name
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/namebench/dnschecks"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/report"
+)
+
+var filtering = flag.Bool("filtering", false,
+	"Check whether each nameserver filters malware or adult content, using the test domains filtering services publish (cli mode)")
+
+// checkFiltering records which content categories each nameserver filters,
+// compared with -trusted_resolver. Filtering is a choice rather than a
+// fault, so it is never a warning.
+func checkFiltering(summaries []*report.Summary) error {
+	var servers []string
+	for _, s := range summaries {
+		if !s.Local {
+			servers = append(servers, s.Nameserver)
+		}
+	}
+	trusted := *trusted_resolver
+	if ns, err := parse.Nameserver(trusted); err == nil {
+		trusted = ns
+	}
+	if err := checkAllowed([]string{trusted}); err != nil {
+		return err
+	}
+	results, err := dnschecks.CheckFiltering(servers, *trusted_resolver)
+	if err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		var parts []string
+		for _, f := range results[s.Nameserver] {
+			verb := "does not filter"
+			if f.Filters() {
+				verb = "filters"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s (%d/%d test domains blocked)", verb, f.Category, len(f.Filtered), f.Tested))
+		}
+		if len(parts) > 0 {
+			s.AddFinding(report.SECURITY, "filtering", strings.Join(parts, ", "), false)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// the geo package geolocates IP addresses using a MaxMind-format (MMDB)
+// database, such as GeoLite2 City or an IP2Location MMDB export.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// EARTH_RADIUS_KM is the mean radius used for great-circle distances.
+const EARTH_RADIUS_KM = 6371.0
+
+// Location is where an IP address is believed to be.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	Country   string
+	City      string
+}
+
+// String returns "City, CC" or whatever subset is known.
+func (l Location) String() string {
+	switch {
+	case l.City != "" && l.Country != "":
+		return l.City + ", " + l.Country
+	case l.Country != "":
+		return l.Country
+	}
+	return fmt.Sprintf("%.2f,%.2f", l.Latitude, l.Longitude)
+}
+
+// record mirrors the fields we need from a GeoIP2/GeoLite2 City record.
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// DB is an open geolocation database.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// Open opens an MMDB file.
+func Open(path string) (*DB, error) {
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: r}, nil
+}
+
+// Close releases the database.
+func (db *DB) Close() error {
+	return db.reader.Close()
+}
+
+// Locate returns the location of ip, if the database knows it.
+func (db *DB) Locate(ip net.IP) (Location, bool) {
+	var r record
+	if err := db.reader.Lookup(ip, &r); err != nil {
+		return Location{}, false
+	}
+	if r.Location.Latitude == 0 && r.Location.Longitude == 0 {
+		return Location{}, false
+	}
+	return Location{
+		Latitude:  r.Location.Latitude,
+		Longitude: r.Location.Longitude,
+		Country:   r.Country.ISOCode,
+		City:      r.City.Names["en"],
+	}, true
+}
+
+// Distance returns the great-circle distance between two locations in km.
+func Distance(a, b Location) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS_KM * math.Asin(math.Sqrt(h))
+}
+
+// FIBER_KM_PER_MS is roughly how far light travels through fiber in a millisecond.
+const FIBER_KM_PER_MS = 200.0
+
+// MinRTT returns the shortest round trip physically possible to a host km away.
+func MinRTT(km float64) time.Duration {
+	return time.Duration(2 * km / FIBER_KM_PER_MS * float64(time.Millisecond))
+}
+
+// ParseLocation parses "lat,lon".
+func ParseLocation(s string) (Location, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return Location{}, fmt.Errorf("invalid location %q, want lat,lon", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Location{}, fmt.Errorf("invalid latitude in %q", s)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Location{}, fmt.Errorf("invalid longitude in %q", s)
+	}
+	return Location{Latitude: lat, Longitude: lon}, nil
+}
+
+// PUBLIC_IP_RESOLVER is the OpenDNS resolver PublicIP asks.
+const PUBLIC_IP_RESOLVER = "208.67.222.222:53"
+
+// PublicIP discovers the address the user's traffic comes from, using
+// OpenDNS's myip.opendns.com service.
+func PublicIP() (net.IP, error) {
+	m := new(dns.Msg)
+	m.SetQuestion("myip.opendns.com.", dns.TypeA)
+	in, _, err := new(dns.Client).Exchange(m, PUBLIC_IP_RESOLVER)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range in.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			return a.A, nil
+		}
+	}
+	return nil, fmt.Errorf("no address returned for myip.opendns.com")
+}
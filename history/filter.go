@@ -3,9 +3,8 @@ package history
 
 import (
 	"code.google.com/p/go.net/publicsuffix"
+	"github.com/google/namebench/internal/parse"
 	"log"
-	"math/rand"
-	"net/url"
 	"regexp"
 )
 
@@ -32,44 +31,35 @@ func ExternalHostnames(entries []string) (hostnames []string) {
 	counter := 0
 
 	for _, uString := range entries {
-		u, err := url.ParseRequestURI(uString)
-		if err != nil {
-			log.Printf("Error parsing %s: %s", uString, err)
-			continue
-		}
-		if !isPossiblyInternal(u.Host) {
+		if host, ok := externalHostname(uString); ok {
 			counter += 1
-			hostnames = append(hostnames, u.Host)
+			hostnames = append(hostnames, host)
 		}
 	}
 	return
 }
 
-// Filter input array for unique entries.
+// externalHostname returns the hostname of a URL, if it looks external.
+func externalHostname(uString string) (string, bool) {
+	host, err := parse.Hostname(uString)
+	if err != nil {
+		log.Printf("Error parsing %s: %s", uString, err)
+		return "", false
+	}
+	if isPossiblyInternal(host) {
+		return "", false
+	}
+	return host, true
+}
+
+// Filter input array for unique entries, keeping the first of each.
 func Uniq(input []string) (output []string) {
-	last := ""
+	seen := make(map[string]bool, len(input))
 	for _, i := range input {
-		if i != last {
+		if !seen[i] {
 			output = append(output, i)
+			seen[i] = true
 		}
 	}
 	return
 }
-
-// Randomly select X number of entries.
-func Random(count int, input []string) (output []string) {
-	selected := make(map[int]bool)
-
-	for {
-		if len(selected) >= count {
-			return
-		}
-		index := rand.Intn(len(input))
-		// If we have already picked this number, re-roll.
-		if _, exists := selected[index]; exists == true {
-			continue
-		}
-		output = append(output, input[index])
-		selected[index] = true
-	}
-}
@@ -4,13 +4,21 @@ package history
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
 )
 
+// SQLITE_DRIVER is the database/sql driver used for every SQLite file. The
+// pure-Go driver keeps namebench free of cgo, so it cross-compiles cleanly.
+const SQLITE_DRIVER = "sqlite"
+
 // unlockDatabase is a bad hack for opening potentially locked SQLite databases.
 func unlockDatabase(path string) (unlocked_path string, err error) {
 	f, err := os.Open(path)
@@ -33,53 +41,220 @@ func unlockDatabase(path string) (unlocked_path string, err error) {
 	return t.Name(), err
 }
 
-// Chrome returns an array of URLs found in Chrome's history within X days
-func Chrome(days int) (urls []string, err error) {
-	paths := []string{
-		"${HOME}/Library/Application Support/Google/Chrome/Default/History",
-		"${HOME}/.config/google-chrome/Default/History",
-		"${APPDATA}/Google/Chrome/User Data/Default/History",
-		"${USERPROFILE}/Local Settings/Application Data/Google/Chrome/User Data/Default/History",
+// readOnlyURI returns a SQLite URI that opens path without locking or writing to it.
+func readOnlyURI(path string) string {
+	p := filepath.ToSlash(path)
+	// A Windows drive letter goes after the slash, as in file:///C:/...
+	if filepath.VolumeName(path) != "" && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	u := url.URL{
+		Scheme:   "file",
+		Path:     p,
+		RawQuery: "mode=ro&immutable=1",
+	}
+	return u.String()
+}
+
+// openDatabase opens a possibly locked SQLite database. It first tries a
+// read-only immutable open of the original file, and only copies it to a
+// temporary file if that fails. The returned cleanup function must be called
+// once the database is no longer needed.
+func openDatabase(path string) (db *sql.DB, cleanup func(), err error) {
+	db, err = sql.Open(SQLITE_DRIVER, readOnlyURI(path))
+	if err == nil {
+		// sql.Open is lazy, so touch the schema to find out if it really works.
+		_, err = db.Exec("SELECT 1 FROM sqlite_master LIMIT 1")
+		if err == nil {
+			return db, func() { db.Close() }, nil
+		}
+		db.Close()
+	}
+	log.Printf("Read-only open of %s failed (%s), copying instead", path, err)
+
+	unlocked_path, err := unlockDatabase(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	db, err = sql.Open(SQLITE_DRIVER, unlocked_path)
+	if err != nil {
+		os.Remove(unlocked_path)
+		return nil, nil, err
+	}
+	return db, func() {
+		db.Close()
+		os.Remove(unlocked_path)
+	}, nil
+}
 
-	query := fmt.Sprintf(
+// chromeProfileFiles returns the History file of every profile found in the
+// given user data directories, e.g. Default and "Profile 1". Opera keeps its
+// only profile in the user data directory itself.
+func chromeProfileFiles(dirs []string) (files []string) {
+	for _, d := range dirs {
+		dir := os.ExpandEnv(d)
+		log.Printf("Checking %s", dir)
+		for _, pattern := range []string{"Default/History", "Profile */History", "History"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				continue
+			}
+			files = append(files, matches...)
+		}
+	}
+	return
+}
+
+// queryURLs runs query against the SQLite database at path, returning the first column.
+func queryURLs(path string, query string) (urls []string, err error) {
+	err = scanURLs(path, query, func(url string) {
+		urls = append(urls, url)
+	})
+	return urls, err
+}
+
+// scanURLs runs query against the SQLite database at path, calling fn with
+// the first column of each row as it is read.
+func scanURLs(path string, query string, fn func(string)) error {
+	db, cleanup, err := openDatabase(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Printf("Query failed: %s", err)
+		return err
+	}
+	defer rows.Close()
+	var url string
+	for rows.Next() {
+		if err := rows.Scan(&url); err != nil {
+			return err
+		}
+		fn(url)
+	}
+	return rows.Err()
+}
+
+// chromeDirs are the Chrome user data directories on each platform.
+var chromeDirs = []string{
+	"${HOME}/Library/Application Support/Google/Chrome",
+	"${HOME}/.config/google-chrome",
+	"${APPDATA}/Google/Chrome/User Data",
+	"${LOCALAPPDATA}/Google/Chrome/User Data",
+	"${USERPROFILE}/Local Settings/Application Data/Google/Chrome/User Data",
+}
+
+// chromiumBrowser is a browser built on Chromium, sharing Chrome's History schema.
+type chromiumBrowser struct {
+	name string
+	// dirs are its user data directories on each platform.
+	dirs []string
+}
+
+// chromiumBrowsers are the Chromium-based browsers whose history is read, Chrome first.
+var chromiumBrowsers = []chromiumBrowser{
+	{"chrome", chromeDirs},
+	{"edge", []string{
+		"${HOME}/Library/Application Support/Microsoft Edge",
+		"${HOME}/.config/microsoft-edge",
+		"${LOCALAPPDATA}/Microsoft/Edge/User Data",
+	}},
+	{"brave", []string{
+		"${HOME}/Library/Application Support/BraveSoftware/Brave-Browser",
+		"${HOME}/.config/BraveSoftware/Brave-Browser",
+		"${LOCALAPPDATA}/BraveSoftware/Brave-Browser/User Data",
+	}},
+	{"vivaldi", []string{
+		"${HOME}/Library/Application Support/Vivaldi",
+		"${HOME}/.config/vivaldi",
+		"${LOCALAPPDATA}/Vivaldi/User Data",
+	}},
+	{"opera", []string{
+		"${HOME}/Library/Application Support/com.operasoftware.Opera",
+		"${HOME}/.config/opera",
+		"${APPDATA}/Opera Software/Opera Stable",
+	}},
+	{"chromium", []string{
+		"${HOME}/Library/Application Support/Chromium",
+		"${HOME}/.config/chromium",
+		"${LOCALAPPDATA}/Chromium/User Data",
+	}},
+}
+
+// allChromiumFiles returns the History file of every profile of every Chromium-based browser.
+func allChromiumFiles() (files []string) {
+	for _, b := range chromiumBrowsers {
+		files = append(files, chromeProfileFiles(b.dirs)...)
+	}
+	return files
+}
+
+// chromeQuery returns the query listing every URL visited within X days.
+func chromeQuery(days int) string {
+	return fmt.Sprintf(
 		`SELECT urls.url FROM visits
 		 LEFT JOIN urls ON visits.url = urls.id
 		 WHERE (visit_time - 11644473600000000 >
 			    strftime('%%s', date('now', '-%d day')) * 1000000)
 		 ORDER BY visit_time DESC`, days)
+}
 
-	for _, p := range paths {
-		path := os.ExpandEnv(p)
-		log.Printf("Checking %s", path)
-		_, err := os.Stat(path)
-		if err != nil {
-			continue
-		}
+// Chrome returns an array of URLs found in Chrome's history within X days.
+// Every profile is read, concurrently, and the results merged.
+func Chrome(days int) (urls []string, err error) {
+	return chromiumURLs(chromeProfileFiles(chromeDirs), days)
+}
 
-		unlocked_path, err := unlockDatabase(path)
-		if err != nil {
-			return nil, err
-		}
-		defer os.Remove(unlocked_path)
+// AllBrowsers returns an array of URLs found within X days in the history of
+// every profile of Chrome, Edge, Brave, Vivaldi, Opera and Chromium, merged.
+func AllBrowsers(days int) (urls []string, err error) {
+	return chromiumURLs(allChromiumFiles(), days)
+}
 
-		db, err := sql.Open("sqlite3", unlocked_path)
-		if err != nil {
-			return nil, err
-		}
+// chromiumURLs reads the URLs visited within X days from Chromium History
+// files, concurrently, and merges them.
+func chromiumURLs(files []string, days int) (urls []string, err error) {
+	query := chromeQuery(days)
+	var tasks []readTask
+	for _, path := range files {
+		path := path
+		tasks = append(tasks, readTask{
+			name: path,
+			read: func() ([]string, error) { return queryURLs(path, query) },
+		})
+	}
+	return readParallel(tasks)
+}
 
-		rows, err := db.Query(query)
-		if err != nil {
-			log.Printf("Query failed: %s", err)
-			return nil, err
-		}
-		var url string
-		for rows.Next() {
-			rows.Scan(&url)
-			urls = append(urls, url)
-		}
-		rows.Close()
-		return urls, err
+// ChromeTop streams Chrome's history within X days and returns the k most
+// visited external hostnames. Unlike Chrome, memory use is bounded by k
+// rather than by the size of the history.
+func ChromeTop(days int, k int) ([]HostCount, error) {
+	return chromiumTop(chromeProfileFiles(chromeDirs), days, k)
+}
+
+// chromiumTop streams Chromium History files within X days and returns the k
+// most visited external hostnames.
+func chromiumTop(files []string, days int, k int) ([]HostCount, error) {
+	query := chromeQuery(days)
+	top := NewTopK(k)
+	var tasks []readTask
+	for _, path := range files {
+		path := path
+		tasks = append(tasks, readTask{
+			name: path,
+			read: func() ([]string, error) {
+				return nil, scanURLs(path, query, func(url string) {
+					if host, ok := externalHostname(url); ok {
+						top.Add(host)
+					}
+				})
+			},
+		})
 	}
-	return
+	_, err := readParallel(tasks)
+	return top.Top(), err
 }
@@ -0,0 +1,57 @@
+// part of the history package, reads several history files concurrently.
+package history
+
+import (
+	"log"
+	"sync"
+)
+
+// Maximum number of history databases read at the same time.
+const MAX_PARALLEL_READS = 4
+
+// readTask reads one history file or source, returning the entries it found.
+type readTask struct {
+	name string
+	read func() ([]string, error)
+}
+
+// readParallel runs tasks on a bounded pool of goroutines and merges their
+// results in task order. An error is only returned if every task failed.
+func readParallel(tasks []readTask) (merged []string, err error) {
+	results := make([][]string, len(tasks))
+	errs := make([]error, len(tasks))
+
+	sem := make(chan bool, MAX_PARALLEL_READS)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		sem <- true
+		go func(i int, task readTask) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = task.read()
+			if errs[i] != nil {
+				log.Printf("Reading %s failed: %s", task.name, errs[i])
+			} else {
+				log.Printf("Read %d entries from %s", len(results[i]), task.name)
+			}
+		}(i, task)
+	}
+	wg.Wait()
+
+	failures := 0
+	for i := range tasks {
+		if errs[i] != nil {
+			failures += 1
+			err = errs[i]
+			continue
+		}
+		merged = append(merged, results[i]...)
+	}
+	if failures < len(tasks) {
+		err = nil
+	}
+	return merged, err
+}
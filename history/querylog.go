@@ -0,0 +1,157 @@
+// part of the history package, reads the domains a whole network queries
+// from the logs of the DNS server it uses: dnsmasq, Pi-hole or unbound.
+package history
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Query log kinds, given to Lookup as kind:path, e.g.
+// "pihole:/etc/pihole/pihole-FTL.db".
+const (
+	// DNSMASQ reads a dnsmasq log written with log-queries.
+	DNSMASQ = "dnsmasq"
+	// PIHOLE reads the queries table of a Pi-hole FTL database.
+	PIHOLE = "pihole"
+	// UNBOUND reads an unbound log written with log-queries or log-replies.
+	UNBOUND = "unbound"
+)
+
+// MAX_LOG_LINE is the longest log line read; longer ones are skipped.
+const MAX_LOG_LINE = 64 * 1024
+
+var (
+	// e.g. "Oct 16 02:29:14 dnsmasq[1234]: query[A] example.com from 192.168.1.10"
+	dnsmasq_re = regexp.MustCompile(`query\[[A-Z0-9]+\] (\S+) from `)
+	// e.g. "[1697423354] unbound[1234:0] info: 192.168.1.10 example.com. A IN"
+	unbound_re = regexp.MustCompile(`info: \S+ (\S+)\. [A-Z0-9]+ IN\b`)
+)
+
+// queryLogs reads each kind of query log into top.
+var queryLogs = map[string]func(path string, days int, top *TopK) error{
+	DNSMASQ: func(path string, days int, top *TopK) error { return scanLog(path, dnsmasq_re, top) },
+	UNBOUND: func(path string, days int, top *TopK) error { return scanLog(path, unbound_re, top) },
+	PIHOLE:  scanPihole,
+}
+
+// queryLogSource reads the most queried domains from a DNS server's log.
+type queryLogSource struct {
+	kind string
+	path string
+}
+
+// lookupQueryLog returns the source for a kind:path name, or false if name
+// is not one.
+func lookupQueryLog(name string) (Source, bool) {
+	i := strings.Index(name, ":")
+	if i < 0 {
+		return nil, false
+	}
+	if _, ok := queryLogs[name[:i]]; !ok {
+		return nil, false
+	}
+	return queryLogSource{kind: name[:i], path: name[i+1:]}, true
+}
+
+func (s queryLogSource) Name() string { return s.kind + ":" + s.path }
+
+// Hostnames returns the most queried external domains, most queried first.
+// Log timestamps rarely carry a year, so only the Pi-hole database is
+// limited to the last X days; logs cover what rotation has kept.
+func (s queryLogSource) Hostnames(days int) ([]string, error) {
+	top, err := s.HostCounts(days)
+	return Names(top), err
+}
+
+func (s queryLogSource) HostCounts(days int) ([]HostCount, error) {
+	top := NewTopK(MAX_TRACKED_HOSTS)
+	if err := queryLogs[s.kind](s.path, days, top); err != nil {
+		return nil, err
+	}
+	counts := top.Top()
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("no external domains in %s", s.path)
+	}
+	return counts, nil
+}
+
+// queriedDomain returns a queried name as a hostname, if it looks external:
+// reverse lookups, single labels and internal names are left out. seen
+// caches the verdict for up to MAX_TRACKED_HOSTS names, and is emptied when
+// full so a log of mostly distinct names stays in bounded memory.
+func queriedDomain(name string, seen map[string]bool) (string, bool) {
+	host := strings.ToLower(strings.TrimSuffix(name, "."))
+	if external, ok := seen[host]; ok {
+		return host, external
+	}
+	if len(seen) >= MAX_TRACKED_HOSTS {
+		for h := range seen {
+			delete(seen, h)
+		}
+	}
+	external := strings.Contains(host, ".") && !strings.HasSuffix(host, ".arpa") && !isPossiblyInternal(host)
+	seen[host] = external
+	return host, external
+}
+
+// scanLog adds the domain each line of the log at path queries, as the
+// first group of re.
+func scanLog(path string, re *regexp.Regexp, top *TopK) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	seen := make(map[string]bool)
+	r := bufio.NewReader(f)
+	var line []byte
+	skip := false
+	for {
+		part, more, err := r.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !skip {
+			line = append(line, part...)
+			skip = len(line) > MAX_LOG_LINE
+		}
+		if more {
+			continue
+		}
+		if !skip {
+			if m := re.FindSubmatch(line); m != nil {
+				if host, ok := queriedDomain(string(m[1]), seen); ok {
+					top.Add(host)
+				}
+			}
+		}
+		line, skip = line[:0], false
+	}
+}
+
+// piholeQuery returns the query listing the domain of every query Pi-hole
+// answered within X days, or ever if days is 0.
+func piholeQuery(days int) string {
+	if days <= 0 {
+		return "SELECT domain FROM queries"
+	}
+	return fmt.Sprintf("SELECT domain FROM queries WHERE timestamp > strftime('%%s', 'now', '-%d day')", days)
+}
+
+// scanPihole adds the domain of each query in a Pi-hole FTL database.
+func scanPihole(path string, days int, top *TopK) error {
+	seen := make(map[string]bool)
+	return scanURLs(path, piholeQuery(days), func(domain string) {
+		if host, ok := queriedDomain(domain, seen); ok {
+			top.Add(host)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// part of the history package, picks the hostnames to benchmark from those a
+// source supplies.
+package history
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Sampling modes, given to Sample.
+const (
+	// WEIGHTED picks hostnames with a chance proportional to their visits,
+	// so frequently visited sites appear as often as they are really used.
+	WEIGHTED = "weighted"
+	// UNIFORM picks every hostname with the same chance.
+	UNIFORM = "uniform"
+	// LEGACY picks like namebench used to, by position in the history, so a
+	// hostname listed more than once may be picked more than once.
+	//
+	// Deprecated: use UNIFORM, which never repeats a hostname.
+	LEGACY = "legacy"
+)
+
+// SAMPLING_MODES lists the modes Sample accepts.
+var SAMPLING_MODES = []string{WEIGHTED, UNIFORM, LEGACY}
+
+// CountingSource is a Source that also knows how often each hostname was
+// visited.
+type CountingSource interface {
+	Source
+	// HostCounts returns external hostnames seen within the last X days
+	// with their number of visits, most visited first.
+	HostCounts(days int) ([]HostCount, error)
+}
+
+// Counts returns the hostnames s supplies within X days with their visits.
+// For sources that don't count visits, each hostname counts as often as it
+// is listed.
+func Counts(s Source, days int) ([]HostCount, error) {
+	if cs, ok := s.(CountingSource); ok {
+		return cs.HostCounts(days)
+	}
+	hostnames, err := s.Hostnames(days)
+	if err != nil {
+		return nil, err
+	}
+	return CountHostnames(hostnames), nil
+}
+
+// CountHostnames counts how often each hostname is listed, in the order they
+// first appear.
+func CountHostnames(hostnames []string) (counts []HostCount) {
+	index := make(map[string]int)
+	for _, h := range hostnames {
+		if i, ok := index[h]; ok {
+			counts[i].Count += 1
+			continue
+		}
+		index[h] = len(counts)
+		counts = append(counts, HostCount{Hostname: h, Count: 1})
+	}
+	return counts
+}
+
+// Names returns the hostnames of counts.
+func Names(counts []HostCount) (hostnames []string) {
+	for _, hc := range counts {
+		hostnames = append(hostnames, hc.Hostname)
+	}
+	return hostnames
+}
+
+// Sample picks count of hosts, without replacement, by mode, with the
+// randomness of src, or as newRand does if src is nil. All of them are returned
+// if there are no more than count.
+func Sample(src rand.Source, mode string, count int, hosts []HostCount) ([]string, error) {
+	switch mode {
+	case WEIGHTED:
+		return Weighted(src, count, hosts), nil
+	case UNIFORM:
+		return RandomFrom(src, count, Names(hosts)), nil
+	case LEGACY:
+		var listed []string
+		for _, hc := range hosts {
+			for i := 0; i < hc.Count; i++ {
+				listed = append(listed, hc.Hostname)
+			}
+		}
+		if len(listed) <= count {
+			return listed, nil
+		}
+		return RandomPositions(count, listed), nil
+	}
+	return nil, fmt.Errorf("unknown sampling mode %q, expected one of %v", mode, SAMPLING_MODES)
+}
+
+// seeded is set by Seed, with the seed for picks without a source.
+var (
+	seeded bool
+	seed   int64
+)
+
+// Seed makes every later pick without a source of its own, including the
+// Tranco sample, start from seed, so that runs pick the same hostnames.
+func Seed(s int64) {
+	seeded, seed = true, s
+}
+
+// newRand returns a generator reading src, or if src is nil seeded by Seed
+// or else from the clock.
+func newRand(src rand.Source) *rand.Rand {
+	if src == nil && seeded {
+		src = rand.NewSource(seed)
+	} else if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	return rand.New(src)
+}
+
+// Random picks count unique entries of input, each with the same chance, in
+// random order. All unique entries are returned if there are no more.
+func Random(count int, input []string) []string {
+	return RandomFrom(nil, count, input)
+}
+
+// RandomFrom is Random with the randomness of src, so that a seeded source
+// picks the same entries every time.
+func RandomFrom(src rand.Source, count int, input []string) []string {
+	unique := Uniq(input)
+	if count > len(unique) {
+		count = len(unique)
+	}
+	// A Fisher-Yates shuffle, stopped once the first count are in place.
+	r := newRand(src)
+	for i := 0; i < count; i++ {
+		j := i + r.Intn(len(unique)-i)
+		unique[i], unique[j] = unique[j], unique[i]
+	}
+	return unique[:count]
+}
+
+// RandomPositions picks count distinct positions of input at random, so
+// entries listed more than once may be picked more than once.
+//
+// Deprecated: use Random, which returns unique entries.
+func RandomPositions(count int, input []string) (output []string) {
+	if count > len(input) {
+		count = len(input)
+	}
+	r := newRand(nil)
+	selected := make(map[int]bool)
+	for len(selected) < count {
+		index := r.Intn(len(input))
+		// If we have already picked this number, re-roll.
+		if selected[index] {
+			continue
+		}
+		output = append(output, input[index])
+		selected[index] = true
+	}
+	return output
+}
+
+// Weighted picks count of hosts without replacement, the chance of each
+// proportional to its visits, with the randomness of src, or as newRand does
+// if src is nil. They are returned in the order of hosts.
+func Weighted(src rand.Source, count int, hosts []HostCount) []string {
+	weights := make([]float64, len(hosts))
+	for i, hc := range hosts {
+		weights[i] = float64(hc.Count)
+	}
+	var hostnames []string
+	for _, i := range weightedPick(newRand(src), weights, count) {
+		hostnames = append(hostnames, hosts[i].Hostname)
+	}
+	return hostnames
+}
+
+// weightedPick returns the indexes of n of weights, in increasing order,
+// picked without replacement with the chance of each proportional to its
+// weight.
+func weightedPick(r *rand.Rand, weights []float64, n int) []int {
+	order := make([]int, len(weights))
+	for i := range weights {
+		order[i] = i
+	}
+	if len(weights) <= n {
+		return order
+	}
+	// Weighted sampling by Efraimidis and Spirakis: the n largest keys
+	// u^(1/weight), compared here as their logarithms.
+	keys := make([]float64, len(weights))
+	for i, w := range weights {
+		keys[i] = math.Inf(-1)
+		if w > 0 {
+			keys[i] = math.Log(1-r.Float64()) / w
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return keys[order[a]] > keys[order[b]] })
+	picked := order[:n]
+	sort.Ints(picked)
+	return picked
+}
@@ -0,0 +1,133 @@
+package history
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// SEEDS is how many seeds each randomized test is repeated with.
+const SEEDS = 50
+
+func hosts(counts ...int) []HostCount {
+	names := []string{"a.com", "b.com", "c.com", "d.com", "e.com", "f.com"}
+	hcs := make([]HostCount, len(counts))
+	for i, c := range counts {
+		hcs[i] = HostCount{Hostname: names[i], Count: c}
+	}
+	return hcs
+}
+
+func TestUniq(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{"empty", nil, nil},
+		{"unique", []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"repeated", []string{"a", "b", "a", "c", "b"}, []string{"a", "b", "c"}},
+		{"all the same", []string{"a", "a", "a"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		if got := Uniq(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Uniq(%v) = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRandomFrom(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		input []string
+		want  int
+	}{
+		{"empty", 3, nil, 0},
+		{"none", 0, []string{"a", "b"}, 0},
+		{"fewer than count", 5, []string{"a", "b", "c"}, 3},
+		{"repeats are one entry", 5, []string{"a", "a", "b", "a"}, 2},
+		{"some", 2, []string{"a", "b", "c", "d", "e"}, 2},
+	}
+	for _, tt := range tests {
+		for seed := int64(0); seed < SEEDS; seed++ {
+			got := RandomFrom(rand.NewSource(seed), tt.count, append([]string{}, tt.input...))
+			if len(got) != tt.want {
+				t.Errorf("%s, seed %d: RandomFrom(%d, %v) = %v, want %d entries", tt.name, seed, tt.count, tt.input, got, tt.want)
+			}
+			if len(Uniq(got)) != len(got) {
+				t.Errorf("%s, seed %d: RandomFrom(%d, %v) = %v, has repeats", tt.name, seed, tt.count, tt.input, got)
+			}
+			again := RandomFrom(rand.NewSource(seed), tt.count, append([]string{}, tt.input...))
+			if !reflect.DeepEqual(got, again) {
+				t.Errorf("%s, seed %d: RandomFrom picked %v, then %v", tt.name, seed, got, again)
+			}
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		hosts []HostCount
+		want  []string
+	}{
+		{"empty", 3, nil, nil},
+		{"fewer than count", 5, hosts(1, 2, 3), []string{"a.com", "b.com", "c.com"}},
+		{"as many as count", 3, hosts(3, 2, 1), []string{"a.com", "b.com", "c.com"}},
+		{"never visited left out", 2, hosts(0, 5, 0, 5), []string{"b.com", "d.com"}},
+	}
+	for _, tt := range tests {
+		for seed := int64(0); seed < SEEDS; seed++ {
+			if got := Weighted(rand.NewSource(seed), tt.count, tt.hosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s, seed %d: Weighted(%d, %v) = %v, want %v", tt.name, seed, tt.count, tt.hosts, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestWeightedPick(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []float64
+		n       int
+		// always are indexes picked with every seed.
+		always []int
+		// rarely are indexes picked with fewer than a tenth of the seeds.
+		rarely []int
+	}{
+		{"all", []float64{1, 2, 3}, 3, []int{0, 1, 2}, nil},
+		{"more than there are", []float64{1, 2}, 5, []int{0, 1}, nil},
+		{"zero weight", []float64{0, 1, 0, 1}, 2, []int{1, 3}, []int{0, 2}},
+		{"heavy", []float64{1, 1000, 1, 1, 1}, 1, nil, []int{0, 2, 3, 4}},
+		{"heavy pair", []float64{1000, 1, 1000, 1, 1}, 2, nil, []int{1, 3, 4}},
+	}
+	for _, tt := range tests {
+		picks := make(map[int]int)
+		for seed := int64(0); seed < SEEDS; seed++ {
+			got := weightedPick(rand.New(rand.NewSource(seed)), tt.weights, tt.n)
+			want := tt.n
+			if want > len(tt.weights) {
+				want = len(tt.weights)
+			}
+			if len(got) != want || !sort.IntsAreSorted(got) {
+				t.Errorf("%s, seed %d: weightedPick(%v, %d) = %v, want %d increasing indexes", tt.name, seed, tt.weights, tt.n, got, want)
+			}
+			for _, i := range got {
+				picks[i]++
+			}
+		}
+		for _, i := range tt.always {
+			if picks[i] != SEEDS {
+				t.Errorf("%s: index %d picked with %d of %d seeds, want all", tt.name, i, picks[i], SEEDS)
+			}
+		}
+		for _, i := range tt.rarely {
+			if picks[i] > SEEDS/10 {
+				t.Errorf("%s: index %d picked with %d of %d seeds, want at most %d", tt.name, i, picks[i], SEEDS, SEEDS/10)
+			}
+		}
+	}
+}
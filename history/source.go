@@ -0,0 +1,151 @@
+// part of the history package, provides a registry of hostname sources.
+package history
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// DEFAULT_SOURCE is the name of the embedded fallback list.
+const DEFAULT_SOURCE = "default"
+
+//go:embed data/default_domains.txt
+var defaultDomains string
+
+// Source is anything that can supply hostnames to benchmark with.
+type Source interface {
+	// Name is the short name used in flags, preferences and reports.
+	Name() string
+	// Hostnames returns external hostnames seen within the last X days.
+	Hostnames(days int) ([]string, error)
+}
+
+// sources holds registered sources in the order they should be tried.
+var sources []Source
+
+// explicitSources holds sources that are only read when named.
+var explicitSources []Source
+
+// disabled is set by Disable; only the default list is used then.
+var disabled bool
+
+// Disable stops every registered source from being read, e.g. when managed
+// configuration forbids access to browser history.
+func Disable() {
+	disabled = true
+}
+
+// Register adds a source to the registry. Sources are tried in registration order.
+func Register(s Source) {
+	sources = append(sources, s)
+}
+
+// RegisterExplicit adds a source that is never tried in turn, only read when
+// named, e.g. by -domain_source: one that downloads a list, say.
+func RegisterExplicit(s Source) {
+	explicitSources = append(explicitSources, s)
+}
+
+// Sources returns the registered sources, those only read when named last.
+func Sources() []Source {
+	return append(append([]Source{}, sources...), explicitSources...)
+}
+
+// Lookup returns the source with the given name, or for a DNS server's
+// query log, e.g. "dnsmasq:/var/log/dnsmasq.log". While sources are
+// disabled, every name returns the default source.
+func Lookup(name string) (Source, error) {
+	if name == DEFAULT_SOURCE || disabled {
+		return defaultSource{}, nil
+	}
+	for _, s := range Sources() {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	if s, ok := lookupQueryLog(name); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown domain source: %s", name)
+}
+
+// Hostnames tries each source registered with Register in turn, falling
+// back to the embedded default list. It returns the hostnames and the name of
+// the source that supplied them.
+func Hostnames(days int) (hostnames []string, source string, err error) {
+	counts, source, err := HostCounts(days)
+	return Names(counts), source, err
+}
+
+// HostCounts is Hostnames with the number of visits to each hostname, for
+// sources that count them.
+func HostCounts(days int) (counts []HostCount, source string, err error) {
+	for _, s := range enabledSources() {
+		counts, err := Counts(s, days)
+		if err != nil {
+			log.Printf("%s source failed: %s", s.Name(), err)
+			continue
+		}
+		if len(counts) == 0 {
+			log.Printf("%s source returned no hostnames", s.Name())
+			continue
+		}
+		return counts, s.Name(), nil
+	}
+	counts, err = Counts(defaultSource{}, days)
+	return counts, DEFAULT_SOURCE, err
+}
+
+// enabledSources returns the registered sources, or none if disabled.
+func enabledSources() []Source {
+	if disabled {
+		return nil
+	}
+	return sources
+}
+
+// chromiumSource reads the history of a Chromium-based browser, e.g. Chrome or Edge.
+type chromiumSource struct {
+	browser chromiumBrowser
+}
+
+func (s chromiumSource) Name() string { return s.browser.name }
+
+func (s chromiumSource) Hostnames(days int) ([]string, error) {
+	top, err := s.HostCounts(days)
+	return Names(top), err
+}
+
+func (s chromiumSource) HostCounts(days int) ([]HostCount, error) {
+	top, err := chromiumTop(chromeProfileFiles(s.browser.dirs), days, MAX_TRACKED_HOSTS)
+	if err != nil {
+		return nil, err
+	}
+	return top, nil
+}
+
+// defaultSource returns the embedded list of popular hostnames.
+type defaultSource struct{}
+
+func (defaultSource) Name() string { return DEFAULT_SOURCE }
+
+func (defaultSource) Hostnames(days int) (hostnames []string, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(defaultDomains))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hostnames = append(hostnames, line)
+	}
+	return hostnames, scanner.Err()
+}
+
+func init() {
+	for _, b := range chromiumBrowsers {
+		Register(chromiumSource{b})
+	}
+}
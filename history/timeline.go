@@ -0,0 +1,113 @@
+// part of the history package, reads when each site was visited, so that
+// browsing can be replayed with its real timing.
+package history
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CHROME_EPOCH_OFFSET is the number of microseconds between 1601-01-01,
+// Chrome's epoch, and the Unix epoch.
+const CHROME_EPOCH_OFFSET int64 = 11644473600000000
+
+// Visit is a single visit to an external hostname.
+type Visit struct {
+	Time     time.Time
+	Hostname string
+}
+
+// chromeTimelineQuery returns the query listing every visit within X days, oldest first.
+func chromeTimelineQuery(days int) string {
+	return fmt.Sprintf(
+		`SELECT urls.url, visits.visit_time FROM visits
+		 LEFT JOIN urls ON visits.url = urls.id
+		 WHERE (visit_time - %d >
+			    strftime('%%s', date('now', '-%d day')) * 1000000)
+		 ORDER BY visit_time ASC`, CHROME_EPOCH_OFFSET, days)
+}
+
+// scanVisits runs query against the SQLite database at path, calling fn
+// with the URL and Chrome timestamp of each row as it is read.
+func scanVisits(path string, query string, fn func(string, int64)) error {
+	db, cleanup, err := openDatabase(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Printf("Query failed: %s", err)
+		return err
+	}
+	defer rows.Close()
+	var url string
+	var usec int64
+	for rows.Next() {
+		if err := rows.Scan(&url, &usec); err != nil {
+			return err
+		}
+		fn(url, usec)
+	}
+	return rows.Err()
+}
+
+// timelineFiles returns the History files of the named Chromium-based
+// browser, or of every one if name is empty. Other sources do not record
+// when each site was visited.
+func timelineFiles(name string) ([]string, error) {
+	if name == "" {
+		return allChromiumFiles(), nil
+	}
+	for _, b := range chromiumBrowsers {
+		if b.name == name {
+			return chromeProfileFiles(b.dirs), nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no visit times, only the history of a Chromium-based browser does", name)
+}
+
+// Timeline returns the visits to external hostnames within X days in the
+// history of the named browser, e.g. "chrome" or "edge", or of every
+// Chromium-based browser if name is empty, across every profile, oldest first.
+func Timeline(name string, days int) ([]Visit, error) {
+	if disabled {
+		return nil, fmt.Errorf("browser history is disabled")
+	}
+	files, err := timelineFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	query := chromeTimelineQuery(days)
+	var mu sync.Mutex
+	var visits []Visit
+	var tasks []readTask
+	for _, path := range files {
+		path := path
+		tasks = append(tasks, readTask{
+			name: path,
+			read: func() ([]string, error) {
+				return nil, scanVisits(path, query, func(url string, usec int64) {
+					if host, ok := externalHostname(url); ok {
+						t := time.Unix(0, (usec-CHROME_EPOCH_OFFSET)*int64(time.Microsecond))
+						mu.Lock()
+						visits = append(visits, Visit{Time: t, Hostname: host})
+						mu.Unlock()
+					}
+				})
+			},
+		})
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no browser history found")
+	}
+	if _, err := readParallel(tasks); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(visits, func(i, j int) bool { return visits[i].Time.Before(visits[j].Time) })
+	return visits, nil
+}
@@ -0,0 +1,123 @@
+// part of the history package, keeps the most visited hostnames in bounded memory.
+package history
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+const (
+	// Dimensions of the count-min sketch used to estimate visit counts. The
+	// width is several times MAX_TRACKED_HOSTS, so tracked hostnames rarely
+	// share a column in every row and their counts stay close to exact.
+	SKETCH_WIDTH = 1 << 15
+	SKETCH_DEPTH = 4
+
+	// Number of hostnames tracked while streaming history.
+	MAX_TRACKED_HOSTS = 10000
+)
+
+// HostCount is a hostname and its (estimated) number of visits.
+type HostCount struct {
+	Hostname string
+	Count    int
+}
+
+// hostHeap is a min-heap of tracked hostnames ordered by count.
+type hostHeap struct {
+	entries []*HostCount
+	index   map[string]int
+}
+
+func (h hostHeap) Len() int           { return len(h.entries) }
+func (h hostHeap) Less(i, j int) bool { return h.entries[i].Count < h.entries[j].Count }
+func (h hostHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].Hostname] = i
+	h.index[h.entries[j].Hostname] = j
+}
+
+func (h *hostHeap) Push(x interface{}) {
+	e := x.(*HostCount)
+	h.index[e.Hostname] = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *hostHeap) Pop() interface{} {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	delete(h.index, e.Hostname)
+	return e
+}
+
+// TopK estimates the K most frequent hostnames in a stream using a
+// count-min sketch and a min-heap, so memory stays flat no matter how many
+// rows are added. It is safe for concurrent use.
+type TopK struct {
+	mu     sync.Mutex
+	k      int
+	sketch [SKETCH_DEPTH][SKETCH_WIDTH]uint32
+	heap   hostHeap
+}
+
+// NewTopK returns a TopK tracking up to k hostnames.
+func NewTopK(k int) *TopK {
+	return &TopK{k: k, heap: hostHeap{index: make(map[string]int)}}
+}
+
+// increment adds one to host in the sketch and returns its new estimate.
+func (t *TopK) increment(host string) int {
+	h := fnv.New64a()
+	h.Write([]byte(host))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	estimate := uint32(0)
+	for i := 0; i < SKETCH_DEPTH; i++ {
+		col := (h1 + uint32(i)*h2) % SKETCH_WIDTH
+		t.sketch[i][col] += 1
+		if i == 0 || t.sketch[i][col] < estimate {
+			estimate = t.sketch[i][col]
+		}
+	}
+	return int(estimate)
+}
+
+// Add records one visit to host.
+func (t *TopK) Add(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.increment(host)
+	if i, ok := t.heap.index[host]; ok {
+		t.heap.entries[i].Count = count
+		heap.Fix(&t.heap, i)
+		return
+	}
+	if t.heap.Len() < t.k {
+		heap.Push(&t.heap, &HostCount{Hostname: host, Count: count})
+		return
+	}
+	if t.k > 0 && count > t.heap.entries[0].Count {
+		heap.Pop(&t.heap)
+		heap.Push(&t.heap, &HostCount{Hostname: host, Count: count})
+	}
+}
+
+// Top returns the tracked hostnames, most visited first.
+func (t *TopK) Top() []HostCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	top := make([]HostCount, len(t.heap.entries))
+	for i, e := range t.heap.entries {
+		top[i] = *e
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Hostname < top[j].Hostname
+	})
+	return top
+}
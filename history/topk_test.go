@@ -0,0 +1,44 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	top := NewTopK(MAX_TRACKED_HOSTS)
+	for i := 0; i < MAX_TRACKED_HOSTS; i++ {
+		for n := 0; n <= i%5; n++ {
+			top.Add(fmt.Sprintf("host%d.com", i))
+		}
+	}
+	got := top.Top()
+	if len(got) != MAX_TRACKED_HOSTS {
+		t.Fatalf("Top() returned %d hostnames, want %d", len(got), MAX_TRACKED_HOSTS)
+	}
+	wrong := 0
+	for i, hc := range got {
+		var n int
+		fmt.Sscanf(hc.Hostname, "host%d.com", &n)
+		if hc.Count != n%5+1 {
+			wrong++
+		}
+		if i > 0 && hc.Count > got[i-1].Count {
+			t.Errorf("Top()[%d] = %v, more visits than %v before it", i, hc, got[i-1])
+		}
+	}
+	if wrong > MAX_TRACKED_HOSTS/100 {
+		t.Errorf("Top() overcounted %d of %d hostnames, want at most %d", wrong, MAX_TRACKED_HOSTS, MAX_TRACKED_HOSTS/100)
+	}
+}
+
+func TestTopKEvicts(t *testing.T) {
+	top := NewTopK(2)
+	for _, h := range []string{"a.com", "b.com", "b.com", "c.com", "c.com", "c.com"} {
+		top.Add(h)
+	}
+	got := top.Top()
+	if len(got) != 2 || got[0] != (HostCount{"c.com", 3}) || got[1] != (HostCount{"b.com", 2}) {
+		t.Errorf("Top() = %v, want [{c.com 3} {b.com 2}]", got)
+	}
+}
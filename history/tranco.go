@@ -0,0 +1,154 @@
+// part of the history package, supplies hostnames from the Tranco list of
+// top sites, for users with no readable browser history. It downloads the
+// list, so it is only read when picked by name.
+package history
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/datadir"
+)
+
+// TRANCO_SOURCE is the name of the Tranco source, e.g. for -domain_source.
+const TRANCO_SOURCE = "tranco"
+
+// TRANCO_URL is the latest Tranco top 1M list: a zipped CSV of rank,domain.
+var TRANCO_URL = "https://tranco-list.eu/top-1m.csv.zip"
+
+const (
+	// TRANCO_FILE caches the list in the data directory, one domain per line
+	// in rank order.
+	TRANCO_FILE = "tranco.txt"
+
+	// TRANCO_MAX_AGE is how long the cached list is used before it is
+	// downloaded again.
+	TRANCO_MAX_AGE = 30 * 24 * time.Hour
+
+	// TRANCO_TIMEOUT bounds the download.
+	TRANCO_TIMEOUT = 2 * time.Minute
+
+	// TRANCO_SAMPLE is how many domains are picked from the list, weighted
+	// toward the top the way real browsing is.
+	TRANCO_SAMPLE = 2000
+)
+
+// trancoSource samples the cached Tranco list, downloading it when it is
+// missing or older than TRANCO_MAX_AGE.
+type trancoSource struct{}
+
+func (trancoSource) Name() string { return TRANCO_SOURCE }
+
+func (trancoSource) Hostnames(days int) ([]string, error) {
+	domains, err := trancoDomains()
+	if err != nil {
+		return nil, err
+	}
+	return sampleByRank(domains, TRANCO_SAMPLE), nil
+}
+
+// trancoDomains returns the cached list, refreshing it if it is stale. A
+// stale list is still used if the download fails.
+func trancoDomains() ([]string, error) {
+	path, err := datadir.Path(TRANCO_FILE)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > TRANCO_MAX_AGE {
+		domains, derr := downloadTranco()
+		if derr == nil {
+			if werr := datadir.WriteFile(path, []byte(strings.Join(domains, "\n")+"\n")); werr != nil {
+				log.Printf("Caching the Tranco list failed: %s", werr)
+			}
+			return domains, nil
+		}
+		if err != nil {
+			return nil, derr
+		}
+		log.Printf("Tranco download failed, using the list from %s: %s", info.ModTime().Format("2006-01-02"), derr)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// downloadTranco fetches TRANCO_URL and returns its domains in rank order.
+func downloadTranco() ([]string, error) {
+	log.Printf("Downloading the Tranco list from %s", TRANCO_URL)
+	client := &http.Client{Timeout: TRANCO_TIMEOUT}
+	resp, err := client.Get(TRANCO_URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", TRANCO_URL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(archive.File) == 0 {
+		return nil, fmt.Errorf("%s: empty archive", TRANCO_URL)
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseTranco(f)
+}
+
+// parseTranco reads a Tranco CSV of rank,domain lines.
+func parseTranco(r io.Reader) (domains []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ",", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			continue
+		}
+		domains = append(domains, fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no domains in the Tranco list")
+	}
+	return domains, nil
+}
+
+// sampleByRank picks n of domains, ordered by rank, without replacement and
+// with the chance of each proportional to 1/rank, so that popular sites
+// dominate as they do in real browsing while the long tail still shows.
+func sampleByRank(domains []string, n int) []string {
+	weights := make([]float64, len(domains))
+	for i := range domains {
+		weights[i] = 1 / float64(i+1)
+	}
+	var sample []string
+	for _, rank := range weightedPick(newRand(nil), weights, n) {
+		sample = append(sample, domains[rank])
+	}
+	return sample
+}
+
+func init() {
+	RegisterExplicit(trancoSource{})
+}
@@ -0,0 +1,496 @@
+// the i18n package holds the translated message catalog shared by the UI and reports.
+package i18n
+
+import (
+	"sort"
+	"strings"
+)
+
+// DEFAULT_LANG is used whenever a language or message is missing.
+const DEFAULT_LANG = "en"
+
+// catalog maps a language to its message keys and translations.
+var catalog = map[string]map[string]string{
+	"en": {
+		"title":                   "namebench",
+		"lead":                    "Find the fastest DNS server, tuned just for you.",
+		"browser":                 "Browser",
+		"country":                 "Country",
+		"start":                   "Start!",
+		"report.nameserver":       "Nameserver",
+		"report.average":          "Average",
+		"report.fastest":          "Fastest nameserver",
+		"report.unsuccessful":     "Unsuccessful queries",
+		"report.recommended":      "Recommended configuration",
+		"report.connect":          "Connect",
+		"report.amortized":        "Amortized",
+		"failure.timeout":         "Timeout",
+		"failure.servfail":        "SERVFAIL",
+		"failure.refused":         "REFUSED",
+		"failure.nxdomain":        "NXDOMAIN",
+		"failure.network":         "Network",
+		"failure.other":           "Other",
+		"report.security":         "Security",
+		"check.responses":         "Duplicate/spoofed responses",
+		"check.cname":             "CNAME chains",
+		"report.analysis":         "Analysis",
+		"check.geo":               "Answer distance",
+		"check.asn":               "Network (ASN)",
+		"check.ndots":             "Search path overhead",
+		"check.location":          "Resolver location",
+		"check.atlas":             "RIPE Atlas",
+		"check.peers":             "Other users",
+		"check.mdns":              "Local names (.local)",
+		"check.fallback":          "LLMNR/NetBIOS fallback",
+		"report.local":            "This computer",
+		"check.internal":          "Internal zones",
+		"report.ranking":          "Ranking",
+		"check.rank":              "Rank",
+		"report.censorship":       "Censorship",
+		"check.blocking":          "Blocked domains",
+		"check.egress":            "Resolver egress",
+		"check.interception":      "DNS interception",
+		"check.vantage":           "Vantage point",
+		"check.dnssec":            "Cost of DNSSEC",
+		"check.ttl":               "Short TTLs",
+		"check.ratelimit":         "Rate limit",
+		"report.blocked":          "Blocked",
+		"check.blocked":           "Blocked domains",
+		"check.ddr":               "Designated resolvers",
+		"check.replay":            "Timeline replay",
+		"check.fastest":           "Fastest per network",
+		"check.timeofday":         "Time of day",
+		"check.plugin":            "Plugin check",
+		"report.latency":          "Latency",
+		"report.min":              "Min",
+		"report.median":           "Median",
+		"report.p90":              "p90",
+		"report.p99":              "p99",
+		"report.max":              "Max",
+		"report.stddev":           "Std. dev.",
+		"check.retries":           "Retried queries",
+		"check.cache":             "Cache",
+		"report.hijacks":          "Hijacks NXDOMAIN",
+		"report.yes":              "yes",
+		"report.no":               "no",
+		"check.nxdomain":          "NXDOMAIN hijacking",
+		"check.tampering":         "Tampered answers",
+		"check.previous_run":      "Since last run",
+		"report.record_types":     "Record types",
+		"report.type":             "Type",
+		"check.cdn":               "CDN endpoints",
+		"report.dnssec":           "DNSSEC",
+		"check.dnssec_validation": "DNSSEC validation",
+		"check.reachable":         "Reachable",
+		"check.loss":              "Packet loss and jitter",
+		"check.interrupted":       "Interrupted",
+		"report.consensus":        "Consensus",
+		"check.consensus":         "Answer consensus",
+		"check.filtering":         "Content filtering",
+		"check.reverse_dns":       "Reverse DNS",
+		"report.families":         "IPv4 vs IPv6",
+		"report.resolver":         "Resolver",
+		"report.difference":       "Difference",
+		"report.warnings":         "Warnings",
+		"report.made_with":        "Benchmarked with [namebench](https://github.com/google/namebench)",
+		"results.title":           "Results",
+		"results.running":         "Benchmarking your nameservers…",
+		"results.failed":          "The benchmark stopped early:",
+		"results.distribution":    "Latency distribution",
+		"results.histogram":       "Latency histogram",
+		"results.download":        "Download the report:",
+		"results.again":           "Run another benchmark",
+		"configure.title":         "Configure the benchmark",
+		"configure.link":          "Choose nameservers, domains and record types…",
+		"configure.curated":       "Nameservers",
+		"configure.preferred":     "A few good defaults",
+		"configure.global":        "Public resolvers for everyone",
+		"configure.regional":      "Regional resolvers",
+		"configure.dnssec":        "Resolvers validating DNSSEC",
+		"configure.filtering":     "Resolvers filtering malware or adult content",
+		"configure.nameservers":   "More nameservers, separated by commas",
+		"configure.queries":       "Queries",
+		"configure.source":        "Domains from",
+		"configure.any_source":    "the first source that works",
+		"configure.count":         "Number of domains",
+		"configure.record_types":  "Record types",
+		"check.recommended":       "Recommended",
+		"check.query_mix":         "Query mix",
+		"check.ttl_watch":         "TTL rewriting",
+		"check.case_0x20":         "0x20 case preserved",
+		"check.source_ports":      "Source port randomization",
+		"report.security_score":   "Security",
+		"check.edns":              "EDNS compliance",
+	},
+	"de": {
+		"lead":                    "Finde den schnellsten DNS-Server, passend für dich.",
+		"browser":                 "Browser",
+		"country":                 "Land",
+		"start":                   "Los!",
+		"report.nameserver":       "Nameserver",
+		"report.average":          "Durchschnitt",
+		"report.fastest":          "Schnellster Nameserver",
+		"report.unsuccessful":     "Fehlgeschlagene Anfragen",
+		"report.recommended":      "Empfohlene Konfiguration",
+		"report.connect":          "Verbindung",
+		"report.amortized":        "Amortisiert",
+		"failure.timeout":         "Zeitüberschreitung",
+		"failure.network":         "Netzwerk",
+		"failure.other":           "Sonstige",
+		"report.security":         "Sicherheit",
+		"check.responses":         "Doppelte/gefälschte Antworten",
+		"check.cname":             "CNAME-Ketten",
+		"report.analysis":         "Analyse",
+		"check.geo":               "Entfernung der Antworten",
+		"check.asn":               "Netzwerk (ASN)",
+		"check.ndots":             "Mehraufwand durch Suchpfad",
+		"check.location":          "Standort des Resolvers",
+		"check.atlas":             "RIPE Atlas",
+		"check.peers":             "Andere Nutzer",
+		"check.mdns":              "Lokale Namen (.local)",
+		"check.fallback":          "LLMNR/NetBIOS-Rückfall",
+		"report.local":            "Dieser Computer",
+		"check.internal":          "Interne Zonen",
+		"report.ranking":          "Rangliste",
+		"check.rank":              "Rang",
+		"report.censorship":       "Zensur",
+		"check.blocking":          "Gesperrte Domains",
+		"check.egress":            "Resolver-Ausgang",
+		"check.interception":      "DNS-Abfangen",
+		"check.vantage":           "Messpunkt",
+		"check.dnssec":            "Kosten von DNSSEC",
+		"check.ttl":               "Kurze TTLs",
+		"check.ratelimit":         "Ratenbegrenzung",
+		"report.blocked":          "Blockiert",
+		"check.blocked":           "Blockierte Domains",
+		"check.ddr":               "Designierte Resolver",
+		"check.replay":            "Verlaufswiedergabe",
+		"check.fastest":           "Schnellster pro Netzwerk",
+		"check.timeofday":         "Tageszeit",
+		"check.plugin":            "Plugin-Prüfung",
+		"report.latency":          "Latenz",
+		"report.min":              "Min.",
+		"report.median":           "Median",
+		"report.p90":              "p90",
+		"report.p99":              "p99",
+		"report.max":              "Max.",
+		"report.stddev":           "Std.-Abw.",
+		"check.retries":           "Wiederholte Anfragen",
+		"check.cache":             "Cache",
+		"report.hijacks":          "Kapert NXDOMAIN",
+		"report.yes":              "ja",
+		"report.no":               "nein",
+		"check.nxdomain":          "NXDOMAIN-Umleitung",
+		"check.tampering":         "Manipulierte Antworten",
+		"check.previous_run":      "Seit dem letzten Lauf",
+		"report.record_types":     "Eintragstypen",
+		"report.type":             "Typ",
+		"check.cdn":               "CDN-Endpunkte",
+		"report.dnssec":           "DNSSEC",
+		"check.dnssec_validation": "DNSSEC-Validierung",
+		"check.reachable":         "Erreichbar",
+		"check.loss":              "Paketverlust und Jitter",
+		"check.interrupted":       "Abgebrochen",
+		"report.consensus":        "Konsens",
+		"check.consensus":         "Übereinstimmung der Antworten",
+		"check.filtering":         "Inhaltsfilter",
+		"check.reverse_dns":       "Reverse-DNS",
+		"report.families":         "IPv4 und IPv6 im Vergleich",
+		"report.resolver":         "Resolver",
+		"report.difference":       "Differenz",
+		"report.warnings":         "Warnungen",
+		"report.made_with":        "Gemessen mit [namebench](https://github.com/google/namebench)",
+		"results.title":           "Ergebnisse",
+		"results.running":         "Deine Nameserver werden gemessen…",
+		"results.failed":          "Der Benchmark wurde vorzeitig beendet:",
+		"results.distribution":    "Verteilung der Latenz",
+		"results.histogram":       "Histogramm der Latenz",
+		"results.download":        "Bericht herunterladen:",
+		"results.again":           "Neuen Benchmark starten",
+		"configure.title":         "Benchmark einrichten",
+		"configure.link":          "Nameserver, Domains und Eintragstypen wählen…",
+		"configure.curated":       "Nameserver",
+		"configure.preferred":     "Einige gute Standards",
+		"configure.global":        "Öffentliche Resolver für alle",
+		"configure.regional":      "Regionale Resolver",
+		"configure.dnssec":        "Resolver mit DNSSEC-Validierung",
+		"configure.filtering":     "Resolver, die Malware oder Inhalte für Erwachsene filtern",
+		"configure.nameservers":   "Weitere Nameserver, durch Kommas getrennt",
+		"configure.queries":       "Abfragen",
+		"configure.source":        "Domains aus",
+		"configure.any_source":    "der ersten funktionierenden Quelle",
+		"configure.count":         "Anzahl der Domains",
+		"configure.record_types":  "Eintragstypen",
+		"check.recommended":       "Empfohlen",
+		"check.query_mix":         "Abfragemix",
+		"check.ttl_watch":         "TTL-Umschreibung",
+		"check.case_0x20":         "0x20-Schreibweise erhalten",
+		"check.source_ports":      "Zufällige Quellports",
+		"report.security_score":   "Sicherheit",
+		"check.edns":              "EDNS-Konformität",
+	},
+	"es": {
+		"lead":                    "Encuentra el servidor DNS más rápido, ajustado a ti.",
+		"browser":                 "Navegador",
+		"country":                 "País",
+		"start":                   "¡Empezar!",
+		"report.nameserver":       "Servidor de nombres",
+		"report.average":          "Promedio",
+		"report.fastest":          "Servidor más rápido",
+		"report.unsuccessful":     "Consultas fallidas",
+		"report.recommended":      "Configuración recomendada",
+		"report.connect":          "Conexión",
+		"report.amortized":        "Amortizado",
+		"failure.timeout":         "Tiempo agotado",
+		"failure.network":         "Red",
+		"failure.other":           "Otros",
+		"report.security":         "Seguridad",
+		"check.responses":         "Respuestas duplicadas/falsificadas",
+		"check.cname":             "Cadenas CNAME",
+		"report.analysis":         "Análisis",
+		"check.geo":               "Distancia de las respuestas",
+		"check.asn":               "Red (ASN)",
+		"check.ndots":             "Sobrecarga de la ruta de búsqueda",
+		"check.location":          "Ubicación del resolvedor",
+		"check.atlas":             "RIPE Atlas",
+		"check.peers":             "Otros usuarios",
+		"check.mdns":              "Nombres locales (.local)",
+		"check.fallback":          "Recurso a LLMNR/NetBIOS",
+		"report.local":            "Este equipo",
+		"check.internal":          "Zonas internas",
+		"report.ranking":          "Clasificación",
+		"check.rank":              "Puesto",
+		"report.censorship":       "Censura",
+		"check.blocking":          "Dominios bloqueados",
+		"check.egress":            "Salida del resolvedor",
+		"check.interception":      "Interceptación de DNS",
+		"check.vantage":           "Punto de observación",
+		"check.dnssec":            "Coste de DNSSEC",
+		"check.ttl":               "TTL cortos",
+		"check.ratelimit":         "Límite de tasa",
+		"report.blocked":          "Bloqueadas",
+		"check.blocked":           "Dominios bloqueados",
+		"check.ddr":               "Resolvedores designados",
+		"check.replay":            "Reproducción del historial",
+		"check.fastest":           "Más rápido por red",
+		"check.timeofday":         "Hora del día",
+		"check.plugin":            "Comprobación de plugin",
+		"report.latency":          "Latencia",
+		"report.min":              "Mín.",
+		"report.median":           "Mediana",
+		"report.p90":              "p90",
+		"report.p99":              "p99",
+		"report.max":              "Máx.",
+		"report.stddev":           "Desv. est.",
+		"check.retries":           "Consultas reintentadas",
+		"check.cache":             "Caché",
+		"report.hijacks":          "Secuestra NXDOMAIN",
+		"report.yes":              "sí",
+		"report.no":               "no",
+		"check.nxdomain":          "Secuestro de NXDOMAIN",
+		"check.tampering":         "Respuestas manipuladas",
+		"check.previous_run":      "Desde la última ejecución",
+		"report.record_types":     "Tipos de registro",
+		"report.type":             "Tipo",
+		"check.cdn":               "Servidores CDN",
+		"report.dnssec":           "DNSSEC",
+		"check.dnssec_validation": "Validación DNSSEC",
+		"check.reachable":         "Accesible",
+		"check.loss":              "Pérdida de paquetes y jitter",
+		"check.interrupted":       "Interrumpido",
+		"report.consensus":        "Consenso",
+		"check.consensus":         "Consenso de respuestas",
+		"check.filtering":         "Filtrado de contenido",
+		"check.reverse_dns":       "DNS inverso",
+		"report.families":         "IPv4 frente a IPv6",
+		"report.resolver":         "Resolvedor",
+		"report.difference":       "Diferencia",
+		"report.warnings":         "Advertencias",
+		"report.made_with":        "Medido con [namebench](https://github.com/google/namebench)",
+		"results.title":           "Resultados",
+		"results.running":         "Midiendo tus servidores de nombres…",
+		"results.failed":          "La prueba terminó antes de tiempo:",
+		"results.distribution":    "Distribución de la latencia",
+		"results.histogram":       "Histograma de la latencia",
+		"results.download":        "Descargar el informe:",
+		"results.again":           "Hacer otra prueba",
+		"configure.title":         "Configurar la prueba",
+		"configure.link":          "Elegir servidores de nombres, dominios y tipos de registro…",
+		"configure.curated":       "Servidores de nombres",
+		"configure.preferred":     "Algunas buenas opciones por defecto",
+		"configure.global":        "Resolvers públicos para todos",
+		"configure.regional":      "Resolvers regionales",
+		"configure.dnssec":        "Resolvers que validan DNSSEC",
+		"configure.filtering":     "Resolvers que filtran malware o contenido para adultos",
+		"configure.nameservers":   "Más servidores de nombres, separados por comas",
+		"configure.queries":       "Consultas",
+		"configure.source":        "Dominios de",
+		"configure.any_source":    "la primera fuente que funcione",
+		"configure.count":         "Número de dominios",
+		"configure.record_types":  "Tipos de registro",
+		"check.recommended":       "Recomendado",
+		"check.query_mix":         "Mezcla de consultas",
+		"check.ttl_watch":         "Reescritura de TTL",
+		"check.case_0x20":         "Mayúsculas 0x20 conservadas",
+		"check.source_ports":      "Aleatorización de puertos de origen",
+		"report.security_score":   "Seguridad",
+		"check.edns":              "Conformidad EDNS",
+	},
+	"fr": {
+		"lead":                    "Trouvez le serveur DNS le plus rapide, adapté à vous.",
+		"browser":                 "Navigateur",
+		"country":                 "Pays",
+		"start":                   "Démarrer !",
+		"report.nameserver":       "Serveur de noms",
+		"report.average":          "Moyenne",
+		"report.fastest":          "Serveur le plus rapide",
+		"report.unsuccessful":     "Requêtes échouées",
+		"report.recommended":      "Configuration recommandée",
+		"report.connect":          "Connexion",
+		"report.amortized":        "Amorti",
+		"failure.timeout":         "Délai dépassé",
+		"failure.network":         "Réseau",
+		"failure.other":           "Autres",
+		"report.security":         "Sécurité",
+		"check.responses":         "Réponses dupliquées/usurpées",
+		"check.cname":             "Chaînes CNAME",
+		"report.analysis":         "Analyse",
+		"check.geo":               "Distance des réponses",
+		"check.asn":               "Réseau (ASN)",
+		"check.ndots":             "Surcoût du chemin de recherche",
+		"check.location":          "Emplacement du résolveur",
+		"check.atlas":             "RIPE Atlas",
+		"check.peers":             "Autres utilisateurs",
+		"check.mdns":              "Noms locaux (.local)",
+		"check.fallback":          "Repli sur LLMNR/NetBIOS",
+		"report.local":            "Cet ordinateur",
+		"check.internal":          "Zones internes",
+		"report.ranking":          "Classement",
+		"check.rank":              "Rang",
+		"report.censorship":       "Censure",
+		"check.blocking":          "Domaines bloqués",
+		"check.egress":            "Sortie du résolveur",
+		"check.interception":      "Interception DNS",
+		"check.vantage":           "Point de vue",
+		"check.dnssec":            "Coût de DNSSEC",
+		"check.ttl":               "TTL courts",
+		"check.ratelimit":         "Limite de débit",
+		"report.blocked":          "Bloquées",
+		"check.blocked":           "Domaines bloqués",
+		"check.ddr":               "Résolveurs désignés",
+		"check.replay":            "Relecture de l'historique",
+		"check.fastest":           "Le plus rapide par réseau",
+		"check.timeofday":         "Moment de la journée",
+		"check.plugin":            "Vérification de plugin",
+		"report.latency":          "Latence",
+		"report.min":              "Min.",
+		"report.median":           "Médiane",
+		"report.p90":              "p90",
+		"report.p99":              "p99",
+		"report.max":              "Max.",
+		"report.stddev":           "Écart type",
+		"check.retries":           "Requêtes relancées",
+		"check.cache":             "Cache",
+		"report.hijacks":          "Détourne NXDOMAIN",
+		"report.yes":              "oui",
+		"report.no":               "non",
+		"check.nxdomain":          "Détournement NXDOMAIN",
+		"check.tampering":         "Réponses falsifiées",
+		"check.previous_run":      "Depuis la dernière exécution",
+		"report.record_types":     "Types d'enregistrement",
+		"report.type":             "Type",
+		"check.cdn":               "Serveurs CDN",
+		"report.dnssec":           "DNSSEC",
+		"check.dnssec_validation": "Validation DNSSEC",
+		"check.reachable":         "Joignable",
+		"check.loss":              "Perte de paquets et gigue",
+		"check.interrupted":       "Interrompu",
+		"report.consensus":        "Consensus",
+		"check.consensus":         "Consensus des réponses",
+		"check.filtering":         "Filtrage de contenu",
+		"check.reverse_dns":       "DNS inverse",
+		"report.families":         "IPv4 et IPv6 comparés",
+		"report.resolver":         "Résolveur",
+		"report.difference":       "Différence",
+		"report.warnings":         "Avertissements",
+		"report.made_with":        "Mesuré avec [namebench](https://github.com/google/namebench)",
+		"results.title":           "Résultats",
+		"results.running":         "Mesure de vos serveurs de noms…",
+		"results.failed":          "Le test s'est arrêté prématurément :",
+		"results.distribution":    "Distribution de la latence",
+		"results.histogram":       "Histogramme de la latence",
+		"results.download":        "Télécharger le rapport :",
+		"results.again":           "Lancer un autre test",
+		"configure.title":         "Configurer le test",
+		"configure.link":          "Choisir les serveurs de noms, domaines et types d'enregistrement…",
+		"configure.curated":       "Serveurs de noms",
+		"configure.preferred":     "Quelques bons choix par défaut",
+		"configure.global":        "Résolveurs publics pour tous",
+		"configure.regional":      "Résolveurs régionaux",
+		"configure.dnssec":        "Résolveurs validant DNSSEC",
+		"configure.filtering":     "Résolveurs filtrant les logiciels malveillants ou les contenus pour adultes",
+		"configure.nameservers":   "Autres serveurs de noms, séparés par des virgules",
+		"configure.queries":       "Requêtes",
+		"configure.source":        "Domaines de",
+		"configure.any_source":    "la première source qui fonctionne",
+		"configure.count":         "Nombre de domaines",
+		"configure.record_types":  "Types d'enregistrement",
+		"check.recommended":       "Recommandé",
+		"check.query_mix":         "Mélange de requêtes",
+		"check.ttl_watch":         "Réécriture des TTL",
+		"check.case_0x20":         "Casse 0x20 préservée",
+		"check.source_ports":      "Ports source aléatoires",
+		"report.security_score":   "Sécurité",
+		"check.edns":              "Conformité EDNS",
+	},
+}
+
+// Languages returns the supported language codes, sorted.
+func Languages() (langs []string) {
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return
+}
+
+// Supported returns true if there is a catalog for lang.
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// T returns the translation of key in lang, falling back to English and then the key itself.
+func T(lang, key string) string {
+	if msg, ok := catalog[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := catalog[DEFAULT_LANG][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// Messages returns every message for lang, with English filling any gaps.
+func Messages(lang string) map[string]string {
+	messages := make(map[string]string)
+	for k, v := range catalog[DEFAULT_LANG] {
+		messages[k] = v
+	}
+	for k, v := range catalog[lang] {
+		messages[k] = v
+	}
+	return messages
+}
+
+// Match picks the best supported language from an Accept-Language header.
+func Match(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		tag = strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if Supported(tag) {
+			return tag
+		}
+	}
+	return DEFAULT_LANG
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/report"
+)
+
+var per_interface = flag.Bool("interfaces", false,
+	"Repeat the benchmark from each active network interface (ethernet, Wi-Fi, VPN, ...) on multi-homed hosts (cli mode)")
+
+// VIRTUAL_INTERFACES are name prefixes of local bridges that do not lead to
+// another network, such as those created by container and VM software.
+var VIRTUAL_INTERFACES = []string{"docker", "br-", "veth", "virbr", "vboxnet", "vmnet", "cni", "flannel"}
+
+// netInterface is an active interface and the address queries are sent from.
+type netInterface struct {
+	Name string
+	Addr net.IP
+}
+
+func (i netInterface) String() string {
+	return fmt.Sprintf("%s (%s)", i.Name, i.Addr)
+}
+
+// activeInterfaces returns each interface that is up, is not a loopback or
+// local bridge, and has a global unicast address, preferring IPv4.
+func activeInterfaces() (active []netInterface, err error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || virtualInterface(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Printf("Addresses of %s unavailable: %s", iface.Name, err)
+			continue
+		}
+		var found net.IP
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || !ipnet.IP.IsGlobalUnicast() {
+				continue
+			}
+			if found == nil || found.To4() == nil && ipnet.IP.To4() != nil {
+				found = ipnet.IP
+			}
+		}
+		if found != nil {
+			active = append(active, netInterface{Name: iface.Name, Addr: found})
+		}
+	}
+	return active, nil
+}
+
+// virtualInterface returns true if name looks like a local bridge.
+func virtualInterface(name string) bool {
+	for _, prefix := range VIRTUAL_INTERFACES {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runInterfaceBenchmarks repeats the benchmark from each active interface,
+// labelling the summaries with it. Only plain DNS and DNS over TLS
+// nameservers, given by an address of the same family as the interface, can
+// be bound to it; others are skipped.
+func runInterfaceBenchmarks(ctx context.Context, servers []string, hostnames []string) ([]*report.Summary, error) {
+	if !dnsqueue.InterfaceBindingSupported {
+		return nil, fmt.Errorf("-interfaces can not bind queries to an interface on this platform")
+	}
+	ifaces, err := activeInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if len(ifaces) < 2 {
+		return nil, fmt.Errorf("found %d active interfaces, -interfaces needs at least 2", len(ifaces))
+	}
+	var all []*report.Summary
+	for _, iface := range ifaces {
+		var bound []string
+		for _, ns := range servers {
+			if sameFamily(ns, iface.Addr) {
+				bound = append(bound, ns)
+			}
+		}
+		log.Printf("Benchmarking %d nameservers from %s", len(bound), iface)
+		summaries, err := runCliBenchmark(ctx, bound, hostnames, benchmarkOptions{dnssecOK: *dnssec, source: &dnsqueue.Source{Interface: iface.Name, IP: iface.Addr}})
+		for _, s := range summaries {
+			s.Vantage = iface.String()
+		}
+		all = append(all, summaries...)
+		if err != nil {
+			return all, err
+		}
+	}
+	return all, nil
+}
+
+// sameFamily returns true if ns is a plain DNS or DNS over TLS nameserver
+// reachable from addr: an IPv4 nameserver from an IPv4 address, or IPv6 from IPv6.
+func sameFamily(ns string, addr net.IP) bool {
+	if dnsqueue.IsDoH(ns) || dnsqueue.IsDoQ(ns) {
+		return false
+	}
+	host, _, err := net.SplitHostPort(strings.TrimPrefix(ns, parse.DOT_SCHEME))
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.To4() != nil) == (addr.To4() != nil)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var interleave = flag.Bool("interleave", false,
+	"Query every nameserver at once in a random order, rather than one after another (cli mode)")
+var server_qps = flag.Float64("server_qps", 0,
+	"Most queries per second sent to any one nameserver, 0 for no limit (cli mode)")
+
+// runInterleavedBenchmark benchmarks every nameserver concurrently, sending
+// the queries for all of them in a random order through one queue. Unlike
+// runCliBenchmark's turns, no nameserver is measured against upstream caches
+// warmed by the ones before it, or against a quieter network.
+func runInterleavedBenchmark(ctx context.Context, servers []string, hostnames []string, opts benchmarkOptions) ([]*report.Summary, error) {
+	log.Printf("Benchmarking %d nameservers at once with %d hostnames", len(servers), len(hostnames))
+	var summaries []*report.Summary
+	byServer := make(map[string]*report.Summary)
+	var requests []*dnsqueue.Request
+	for _, ns := range servers {
+		summary := report.NewSummary(ns)
+		summaries = append(summaries, summary)
+		byServer[ns] = summary
+		for _, h := range hostnames {
+			requests = append(requests, benchmarkRequests(ns, h, opts)...)
+		}
+	}
+	workloadRand().Shuffle(len(requests), func(i, j int) { requests[i], requests[j] = requests[j], requests[i] })
+
+	warmUp(ctx, servers, opts)
+	q := dnsqueue.StartQueueFrom(ctx, ui.QUEUE_LENGTH, ui.WORKERS, opts.source)
+	q.Deadline = time.Duration(len(servers)) * ui.JOB_DEADLINE
+	limitRate(q)
+	opts.display.Watch(q)
+	opts.stats.Watch(q)
+	ordered, flush := dnsqueue.InOrder(func(r *dnsqueue.Result) {
+		byServer[r.Request.Destination].Add(r)
+		opts.display.Add(r)
+	})
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, r := range requests {
+			if opts.display.Aborted(r.Destination) {
+				continue
+			}
+			if err := add(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, ordered)
+	flush()
+	if missing, ok := err.(*dnsqueue.MissingResultsError); ok && (missing.Cause == nil || missing.Cause == dnsqueue.ErrDeadline) {
+		log.Printf("%s", missing)
+		counts := make(map[string]int)
+		for _, r := range missing.Requests {
+			counts[r.Destination]++
+		}
+		for ns, n := range counts {
+			byServer[ns].AddMissing(n)
+		}
+	} else if interrupted(ctx, err) {
+		markInterrupted(summaries, len(hostnames)*queriesPerHostname())
+		return withoutAborted(summaries, opts.display), err
+	} else if err != nil {
+		return nil, err
+	}
+	return withoutAborted(summaries, opts.display), nil
+}
@@ -0,0 +1,251 @@
+// the parse package validates user-supplied nameservers, domains and URLs, so
+// main, the UI API and configuration loading share one implementation.
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DEFAULT_PORT is used for nameservers given without one.
+const DEFAULT_PORT = "53"
+
+// DOT_SCHEME prefixes DNS over TLS nameservers.
+const DOT_SCHEME = "dot://"
+
+// DOT_PORT is the default DNS over TLS port.
+const DOT_PORT = "853"
+
+// DOQ_SCHEME prefixes DNS over QUIC nameservers.
+const DOQ_SCHEME = "doq://"
+
+// DOQ_PORT is the default DNS over QUIC port.
+const DOQ_PORT = "853"
+
+// Address families, as Family returns them.
+const (
+	IPV4 = "IPv4"
+	IPV6 = "IPv6"
+)
+
+// Nameserver validates a single nameserver, returning it as host:port.
+// Accepted forms are IPv4 (1.2.3.4, 1.2.3.4:53), IPv6 (2001:db8::1,
+// [2001:db8::1], [2001:db8::1]:53) and the same with surrounding
+// whitespace. DNS over TLS nameservers (dot://9.9.9.9,
+// dot://dns.quad9.net:853) are returned as dot://host:port; they may be
+// named by hostname, which their certificate is then checked against. DNS
+// over QUIC nameservers (doq://) are the same.
+func Nameserver(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty nameserver")
+	}
+	if strings.HasPrefix(s, DOT_SCHEME) {
+		return encryptedNameserver(s, DOT_SCHEME, DOT_PORT)
+	}
+	if strings.HasPrefix(s, DOQ_SCHEME) {
+		return encryptedNameserver(s, DOQ_SCHEME, DOQ_PORT)
+	}
+	host, port := unbracket(s), DEFAULT_PORT
+	// A bare IPv6 literal contains colons but no port.
+	if net.ParseIP(host) == nil {
+		h, p, err := net.SplitHostPort(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid nameserver %q: %s", s, err)
+		}
+		host, port = h, p
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid nameserver %q: %q is not an IP address", s, host)
+	}
+	if err := Port(port); err != nil {
+		return "", fmt.Errorf("invalid nameserver %q: %s", s, err)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Family returns IPV4 or IPV6 for a nameserver given by address, as
+// Nameserver returns it, or "" for one given by hostname or URL.
+func Family(ns string) string {
+	hostport := strings.TrimPrefix(strings.TrimPrefix(ns, DOT_SCHEME), DOQ_SCHEME)
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	if ip.To4() != nil {
+		return IPV4
+	}
+	return IPV6
+}
+
+// unbracket removes the brackets around an IPv6 literal given without a
+// port, e.g. [2001:db8::1].
+func unbracket(s string) string {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// encryptedNameserver validates a dot:// or doq:// nameserver, given its
+// scheme and default port.
+func encryptedNameserver(s string, scheme string, default_port string) (string, error) {
+	hostport := strings.TrimPrefix(s, scheme)
+	host, port := unbracket(hostport), default_port
+	if net.ParseIP(host) == nil && strings.Contains(hostport, ":") {
+		h, p, err := net.SplitHostPort(hostport)
+		if err != nil {
+			return "", fmt.Errorf("invalid nameserver %q: %s", s, err)
+		}
+		host, port = h, p
+	}
+	if net.ParseIP(host) == nil {
+		name, err := Domain(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid nameserver %q: %s", s, err)
+		}
+		host = name
+	}
+	if err := Port(port); err != nil {
+		return "", fmt.Errorf("invalid nameserver %q: %s", s, err)
+	}
+	return scheme + net.JoinHostPort(host, port), nil
+}
+
+// NameserverFields splits a comma or whitespace separated list of nameservers.
+func NameserverFields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// Nameservers parses a comma or whitespace separated list of nameservers.
+func Nameservers(s string) (servers []string, err error) {
+	fields := NameserverFields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no nameservers given")
+	}
+	for _, f := range fields {
+		ns, err := Nameserver(f)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, ns)
+	}
+	return servers, nil
+}
+
+// Port validates a numeric port.
+func Port(p string) error {
+	var n int
+	if _, err := fmt.Sscanf(p, "%d", &n); err != nil || fmt.Sprint(n) != p || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", p)
+	}
+	return nil
+}
+
+// Domain validates a hostname, returning it lowercased without a trailing dot.
+func Domain(s string) (string, error) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	if name == "" {
+		return "", fmt.Errorf("empty domain name")
+	}
+	if len(name) > 253 {
+		return "", fmt.Errorf("domain %q is longer than 253 characters", s)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", fmt.Errorf("domain %q has an empty label", s)
+		}
+		if len(label) > 63 {
+			return "", fmt.Errorf("domain %q has a label longer than 63 characters", s)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("domain %q has a label starting or ending with '-'", s)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return "", fmt.Errorf("domain %q contains invalid character %q", s, c)
+			}
+		}
+	}
+	return name, nil
+}
+
+// Hostname extracts and validates the hostname of a URL, without any port.
+func Hostname(rawurl string) (string, error) {
+	u, err := url.ParseRequestURI(rawurl)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+	if host == "" {
+		return "", fmt.Errorf("%q has no hostname", rawurl)
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return Domain(host)
+}
+
+// DomainFile loads a list of domains, one per line. Blank lines and lines
+// starting with # are ignored, and a line may also hold a URL. Errors name
+// the offending line.
+func DomainFile(path string) (domains []string, err error) {
+	err = ScanDomainFile(path, func(name string) error {
+		domains = append(domains, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("%s: no domains found", path)
+	}
+	return domains, nil
+}
+
+// ScanDomainFile calls fn for each domain in a DomainFile without holding the
+// whole list in memory, so it suits very large files. It stops at the first
+// error returned by fn.
+func ScanDomainFile(path string, fn func(string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var name string
+		if strings.Contains(text, "://") {
+			name, err = Hostname(text)
+		} else {
+			name, err = Domain(strings.Fields(text)[0])
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
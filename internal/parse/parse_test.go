@@ -0,0 +1,60 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestNameserver(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"1.2.3.4", "1.2.3.4:53", false},
+		{" 1.2.3.4:5353 ", "1.2.3.4:5353", false},
+		{"2001:db8::1", "[2001:db8::1]:53", false},
+		{"[2001:db8::1]", "[2001:db8::1]:53", false},
+		{"[2001:db8::1]:5353", "[2001:db8::1]:5353", false},
+		{"dot://9.9.9.9", "dot://9.9.9.9:853", false},
+		{"dot://9.9.9.9:8853", "dot://9.9.9.9:8853", false},
+		{"dot://dns.Quad9.net", "dot://dns.quad9.net:853", false},
+		{"dot://dns.quad9.net.:853", "dot://dns.quad9.net:853", false},
+		{"dot://2001:db8::1", "dot://[2001:db8::1]:853", false},
+		{"dot://[2001:db8::1]", "dot://[2001:db8::1]:853", false},
+		{"dot://[2001:db8::1]:853", "dot://[2001:db8::1]:853", false},
+		{"doq://dns.adguard.com", "doq://dns.adguard.com:853", false},
+		{"dot://", "", true},
+		{"dot://:853", "", true},
+		{"dot://dns..quad9.net", "", true},
+		{"dot://9.9.9.9:0", "", true},
+		{"dot://dns.quad9.net:dot", "", true},
+		{"example.com", "", true},
+		{"1.2.3.4:65536", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Nameserver(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Nameserver(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tests := []struct {
+		ns   string
+		want string
+	}{
+		{"1.2.3.4:53", IPV4},
+		{"[2001:db8::1]:53", IPV6},
+		{"dot://9.9.9.9:853", IPV4},
+		{"dot://[2001:db8::1]:853", IPV6},
+		{"dot://dns.quad9.net:853", ""},
+		{"https://dns.google/dns-query", ""},
+	}
+	for _, tt := range tests {
+		if got := Family(tt.ns); got != tt.want {
+			t.Errorf("Family(%q) = %q, want %q", tt.ns, got, tt.want)
+		}
+	}
+}
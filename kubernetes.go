@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/namebench/cluster"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var kubernetes = flag.Bool("kubernetes", false,
+	"Benchmark cluster DNS from inside a Kubernetes pod: query service names as well as external ones, "+
+		"and report the ndots/search path overhead (cli mode, default nameserver: the kube-dns service IP)")
+
+// flagSet returns true if the named flag was given on the command line.
+func flagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
+// kubernetesWorkload returns the cluster's nameservers, unless -nameservers
+// was given, and the names to benchmark: the fully qualified service names
+// followed by the external hostnames. relative holds the same names the way
+// a pod would look them up, for the search path analysis.
+func kubernetesWorkload(conf *cluster.ResolvConf, servers []string, external []string) (nameservers, absolute, relative []string) {
+	nameservers = servers
+	if !flagSet("nameservers") {
+		nameservers = conf.Nameservers
+	}
+	domain, _ := conf.Domain()
+	namespace := conf.Namespace()
+	for _, name := range cluster.ServiceNames() {
+		absolute = append(absolute, cluster.FQDN(name, namespace, domain))
+		relative = append(relative, name)
+	}
+	absolute = append(absolute, external...)
+	relative = append(relative, external...)
+	return
+}
+
+// streamQueries sends an A query for each name to ns, handing each result to
+// collect. Result IDs start at 1 in the order of names.
+func streamQueries(ctx context.Context, ns string, names []string, collect func(*dnsqueue.Result)) error {
+	q := dnsqueue.StartQueue(ctx, ui.QUEUE_LENGTH, ui.WORKERS)
+	q.Deadline = ui.JOB_DEADLINE
+	q.LimitDestinationWorkers(ui.WORKERS)
+	return q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, name := range names {
+			if err := add(&dnsqueue.Request{Destination: ns, RecordType: "A", RecordName: name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, collect)
+}
+
+// analyzeSearchPath looks up each relative name through the search path, as
+// a pod's stub resolver would, and records an "ndots" finding comparing the
+// queries and time each lookup takes against a single absolute query.
+func analyzeSearchPath(ctx context.Context, conf *cluster.ResolvConf, summaries []*report.Summary, relative []string) {
+	// Each name expands into a run of queries; owner maps a query to its name.
+	var queries []string
+	var owner []int
+	for i, name := range relative {
+		for _, q := range conf.Expand(name) {
+			queries = append(queries, q)
+			owner = append(owner, i)
+		}
+	}
+	for _, s := range summaries {
+		results := make([]*dnsqueue.Result, len(queries))
+		err := streamQueries(ctx, s.Nameserver, queries, func(r *dnsqueue.Result) {
+			results[r.ID-1] = r
+		})
+		if err != nil {
+			log.Printf("%s: search path analysis: %s", s.Nameserver, err)
+		}
+
+		// A lookup stops at the first query that returns an answer.
+		sent := make([]int, len(relative))
+		spent := make([]time.Duration, len(relative))
+		done := make([]bool, len(relative))
+		for i, r := range results {
+			n := owner[i]
+			if done[n] || r == nil {
+				continue
+			}
+			sent[n] += 1
+			spent[n] += r.Duration
+			done[n] = r.Rcode == "NOERROR" && len(r.Answers) > 0
+		}
+		total, elapsed := 0, time.Duration(0)
+		for n := range relative {
+			total += sent[n]
+			elapsed += spent[n]
+		}
+		perLookup := float64(total) / float64(len(relative))
+		result := fmt.Sprintf("ndots:%d, %d search domains: %.1f queries and %.2fms per lookup, vs %.2fms absolute",
+			conf.Ndots, len(conf.Search), perLookup,
+			float64(elapsed)/float64(len(relative))/float64(time.Millisecond),
+			float64(s.Average())/float64(time.Millisecond))
+		s.AddFinding(report.ANALYSIS, "ndots", result, perLookup > 1.5)
+	}
+}
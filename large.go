@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/ui"
+)
+
+var large = flag.Bool("large", false,
+	"Large-scale mode: stream every domain in -domains instead of sampling, keeping only running totals in memory (cli mode)")
+var raw_samples = flag.String("raw_samples", "", "File to write every query result to as CSV, flushed in batches (cli mode)")
+var batch_size = flag.Int("batch_size", 1000, "Number of results buffered before raw samples are flushed to disk")
+var progress_interval = flag.Duration("progress_interval", 30*time.Second, "How often to log a partial summary in large-scale mode")
+
+// runningSummary aggregates results in constant memory.
+type runningSummary struct {
+	mu       sync.Mutex
+	count    int
+	failures int
+	total    time.Duration
+	min      time.Duration
+	max      time.Duration
+}
+
+// add records a single result.
+func (s *runningSummary) add(r *dnsqueue.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Failed() {
+		s.failures += 1
+		return
+	}
+	if s.count == 0 || r.Duration < s.min {
+		s.min = r.Duration
+	}
+	if r.Duration > s.max {
+		s.max = r.Duration
+	}
+	s.count += 1
+	s.total += r.Duration
+}
+
+// String returns a one line summary of the results so far.
+func (s *runningSummary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	avg := time.Duration(0)
+	if s.count > 0 {
+		avg = s.total / time.Duration(s.count)
+	}
+	return fmt.Sprintf("%d ok, %d failed, avg %s, min %s, max %s", s.count, s.failures, avg, s.min, s.max)
+}
+
+// sampleWriter writes raw results to CSV, flushing every batch_size rows.
+type sampleWriter struct {
+	f       *os.File
+	w       *csv.Writer
+	pending int
+}
+
+func newSampleWriter(path string) (*sampleWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(bufio.NewWriter(f))
+	w.Write([]string{"id", "nameserver", "domain", "duration_ms", "rcode", "error"})
+	return &sampleWriter{f: f, w: w}, nil
+}
+
+// write buffers one result, flushing the batch to disk when it is full.
+func (s *sampleWriter) write(r *dnsqueue.Result) error {
+	s.w.Write([]string{
+		strconv.FormatUint(r.ID, 10),
+		r.Request.Destination,
+		r.Request.RecordName,
+		strconv.FormatFloat(float64(r.Duration)/float64(time.Millisecond), 'f', 3, 64),
+		r.Rcode,
+		r.Error,
+	})
+	s.pending += 1
+	if s.pending >= *batch_size {
+		s.pending = 0
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *sampleWriter) flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *sampleWriter) Close() error {
+	if err := s.flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// runLargeBenchmark streams every domain in path to each nameserver in turn,
+// logging partial summaries as it goes. Memory use does not depend on the
+// number of domains.
+func runLargeBenchmark(ctx context.Context, servers []string, path string) error {
+	var samples *sampleWriter
+	if *raw_samples != "" {
+		var err error
+		if samples, err = newSampleWriter(outputPath(*raw_samples)); err != nil {
+			return err
+		}
+		defer samples.Close()
+	}
+
+	summaries := make([]*runningSummary, len(servers))
+	for i, ns := range servers {
+		summary := &runningSummary{}
+		summaries[i] = summary
+
+		ticker := time.NewTicker(*progress_interval)
+		done := make(chan bool)
+		go func(ns string) {
+			for {
+				select {
+				case <-ticker.C:
+					log.Printf("Partial summary for %s: %s", ns, summary)
+				case <-done:
+					return
+				}
+			}
+		}(ns)
+
+		q := dnsqueue.StartQueue(ctx, ui.QUEUE_LENGTH, ui.WORKERS)
+		q.LimitDestinationWorkers(ui.WORKERS)
+		var writeErr error
+		err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+			return parse.ScanDomainFile(path, func(name string) error {
+				return add(&dnsqueue.Request{
+					Destination:      ns,
+					RecordType:       "A",
+					RecordName:       name + ".",
+					VerifySignature:  *dnssec,
+					EDNS:             *edns,
+					KernelTimestamps: *kernel_timestamps,
+				})
+			})
+		}, func(result *dnsqueue.Result) {
+			summary.add(result)
+			if samples != nil && writeErr == nil {
+				writeErr = samples.write(result)
+			}
+		})
+		ticker.Stop()
+		close(done)
+		if err == nil {
+			err = writeErr
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Finished %s: %s", ns, summary)
+	}
+
+	for i, ns := range servers {
+		fmt.Printf("%s\t%s\n", ns, summaries[i])
+	}
+	return nil
+}
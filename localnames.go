@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/mdns"
+	"github.com/google/namebench/report"
+)
+
+var mdns_check = flag.Bool("mdns", false,
+	"Discover .local hosts with multicast DNS, time their resolution and check whether each nameserver resolves them (cli mode)")
+
+// analyzeLocalNames discovers .local hosts and records an "mdns" finding per
+// nameserver: how many of them it resolves over unicast DNS, next to how
+// quickly the hosts themselves answer over multicast.
+func analyzeLocalNames(ctx context.Context, summaries []*report.Summary) error {
+	hosts, err := mdns.Discover()
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no .local hosts found")
+	}
+	log.Printf("Found %d .local hosts: %v", len(hosts), hosts)
+
+	var multicast []time.Duration
+	for _, h := range hosts {
+		rtt, ok, err := mdns.Resolve(h)
+		if err != nil {
+			return err
+		}
+		if ok {
+			multicast = append(multicast, rtt)
+		}
+	}
+	mcast := fmt.Sprintf("multicast %d/%d", len(multicast), len(hosts))
+	if len(multicast) > 0 {
+		var total time.Duration
+		for _, d := range multicast {
+			total += d
+		}
+		mcast += fmt.Sprintf(" in %.2fms", float64(total/time.Duration(len(multicast)))/float64(time.Millisecond))
+	}
+
+	for _, s := range summaries {
+		resolved := 0
+		var elapsed time.Duration
+		err := streamQueries(ctx, s.Nameserver, hosts, func(r *dnsqueue.Result) {
+			if r.Rcode == "NOERROR" && len(r.Answers) > 0 {
+				resolved += 1
+				elapsed += r.Duration
+			}
+		})
+		if err != nil {
+			log.Printf("%s: .local queries: %s", s.Nameserver, err)
+		}
+		result := fmt.Sprintf("%d/%d .local hosts resolved", resolved, len(hosts))
+		if resolved > 0 {
+			result += fmt.Sprintf(" in %.2fms", float64(elapsed/time.Duration(resolved))/float64(time.Millisecond))
+		}
+		s.AddFinding(report.ANALYSIS, "mdns", result+", "+mcast, false)
+	}
+	return nil
+}
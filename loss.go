@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/namebench/dnschecks"
+	"github.com/google/namebench/report"
+)
+
+var loss_probes = flag.Int("loss_probes", 0,
+	"Probe queries to send each nameserver after the benchmark, reporting packet loss and jitter; 0 to skip (cli mode)")
+
+// LOSS_WARN_RATE is the share of lost probes that is flagged: more than a
+// healthy network drops.
+const LOSS_WARN_RATE = 0.01
+
+// checkLoss sends -loss_probes queries for the first hostname to each
+// nameserver, recording the loss and jitter as findings.
+func checkLoss(summaries []*report.Summary, hostnames []string) error {
+	hostname := PREFLIGHT_HOSTNAME
+	if len(hostnames) > 0 {
+		hostname = hostnames[0]
+	}
+	var servers []string
+	for _, s := range summaries {
+		if !s.Local {
+			servers = append(servers, s.Nameserver)
+		}
+	}
+	losses, err := dnschecks.MeasureLoss(servers, hostname, *loss_probes)
+	if err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		l, ok := losses[s.Nameserver]
+		if !ok || l.Sent == 0 {
+			continue
+		}
+		stddev, iqr := l.Jitter()
+		s.AddFinding(report.ANALYSIS, "loss", fmt.Sprintf("%d/%d lost (%.1f%%), jitter %.2fms stddev, %.2fms IQR",
+			l.Lost(), l.Sent, 100*l.Rate(), float64(stddev)/float64(time.Millisecond), float64(iqr)/float64(time.Millisecond)), l.Rate() > LOSS_WARN_RATE)
+	}
+	return nil
+}
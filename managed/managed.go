@@ -0,0 +1,112 @@
+// the managed package loads the configuration IT departments deploy to lock
+// namebench down: which resolvers may be benchmarked, whether browser history
+// may be read, and where results may go. Managed settings override flags and
+// preferences.
+package managed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/google/namebench/internal/parse"
+)
+
+// PATHS is where the managed configuration is deployed on each platform. On
+// Windows a registry policy may point elsewhere, see policyPath.
+var PATHS = map[string]string{
+	"linux":   "/etc/namebench/managed.json",
+	"darwin":  "/Library/Application Support/namebench/managed.json",
+	"windows": `C:\ProgramData\namebench\managed.json`,
+}
+
+// Config is the managed configuration.
+type Config struct {
+	// AllowedResolvers, if not empty, are the only nameservers that may be benchmarked.
+	AllowedResolvers []string `json:"allowed_resolvers"`
+	// DisableHistory stops namebench reading browser history.
+	DisableHistory bool `json:"disable_history"`
+	// OutputDir, if set, is where reports are written instead of stdout, and
+	// where every other file namebench writes goes: its data directory,
+	// including the results database, and files named by flags.
+	OutputDir string `json:"output_dir"`
+	// ResultsServer, if present, pins the results service; "" forbids sharing.
+	ResultsServer *string `json:"results_server"`
+}
+
+var (
+	active     *Config
+	activeErr  error
+	activeOnce sync.Once
+)
+
+// Active returns the managed configuration, loading it on first use. It
+// returns nil if none is deployed.
+func Active() (*Config, error) {
+	activeOnce.Do(func() {
+		path := policyPath()
+		if path == "" {
+			path = PATHS[runtime.GOOS]
+		}
+		if path == "" {
+			return
+		}
+		active, activeErr = Load(path)
+		if os.IsNotExist(activeErr) {
+			active, activeErr = nil, nil
+		}
+		if active != nil {
+			log.Printf("Using managed configuration from %s", path)
+		}
+	})
+	return active, activeErr
+}
+
+// Load reads and validates a managed configuration file.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for i, ns := range c.AllowedResolvers {
+		if c.AllowedResolvers[i], err = parse.Nameserver(ns); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return c, nil
+}
+
+// Path returns where a file namebench writes to path must go: in OutputDir,
+// under the same name, if it is set, or path itself otherwise.
+func (c *Config) Path(path string) string {
+	if c == nil || c.OutputDir == "" {
+		return path
+	}
+	return filepath.Join(c.OutputDir, filepath.Base(path))
+}
+
+// Allowed returns an error naming the first server the configuration does not allow.
+func (c *Config) Allowed(servers []string) error {
+	if c == nil || len(c.AllowedResolvers) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	for _, ns := range c.AllowedResolvers {
+		allowed[ns] = true
+	}
+	for _, ns := range servers {
+		if !allowed[ns] {
+			return fmt.Errorf("%s is not an allowed resolver in the managed configuration", ns)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+// part of the managed package, only Windows has a policy override.
+package managed
+
+// policyPath returns "" outside of Windows.
+func policyPath() string {
+	return ""
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+// part of the managed package, reads the configuration path from Group Policy.
+package managed
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// POLICY_KEY may hold a ManagedConfig value with the configuration's path.
+const POLICY_KEY = `HKLM\SOFTWARE\Policies\namebench`
+
+// policyPath returns the path set by Group Policy, if any.
+func policyPath() string {
+	out, err := exec.Command("reg", "query", POLICY_KEY, "/v", "ManagedConfig").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// "    ManagedConfig    REG_SZ    C:\path\managed.json"
+		fields := strings.SplitN(strings.TrimSpace(line), "    ", 3)
+		if len(fields) == 3 && strings.EqualFold(fields[0], "ManagedConfig") {
+			return strings.TrimSpace(fields[2])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+// the mdns package discovers .local hosts on the LAN with multicast DNS and
+// measures how quickly they resolve.
+package mdns
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// GROUP is the IPv4 mDNS multicast address.
+	GROUP = "224.0.0.251:5353"
+
+	// SERVICES is the DNS-SD meta query listing every advertised service type.
+	SERVICES = "_services._dns-sd._udp.local."
+
+	// BROWSE_TIMEOUT is how long to collect responses to each browse query.
+	BROWSE_TIMEOUT = 2 * time.Second
+
+	// RESOLVE_TIMEOUT is how long to wait for a host to answer.
+	RESOLVE_TIMEOUT = time.Second
+)
+
+// exchange sends a query to the multicast group from an ephemeral port, so
+// responders answer with unicast (RFC 6762, 6.7), and hands each response to
+// fn until timeout passes or fn returns false.
+func exchange(name string, qtype uint16, timeout time.Duration, fn func(*dns.Msg) bool) error {
+	group, err := net.ResolveUDPAddr("udp4", GROUP)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	packed, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.WriteToUDP(packed, group); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil
+			}
+			return err
+		}
+		in := new(dns.Msg)
+		if in.Unpack(buf[:n]) != nil || !in.Response {
+			continue
+		}
+		if !fn(in) {
+			return nil
+		}
+	}
+}
+
+// records returns every record in a response.
+func records(m *dns.Msg) []dns.RR {
+	return append(append(append([]dns.RR{}, m.Answer...), m.Ns...), m.Extra...)
+}
+
+// Discover browses DNS-SD service types and their instances, returning the
+// .local hostnames that advertise them, sorted.
+func Discover() ([]string, error) {
+	var types []string
+	err := exchange(SERVICES, dns.TypePTR, BROWSE_TIMEOUT, func(m *dns.Msg) bool {
+		for _, rr := range m.Answer {
+			if ptr, ok := rr.(*dns.PTR); ok {
+				types = append(types, ptr.Ptr)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make(map[string]bool)
+	for _, t := range uniq(types) {
+		err := exchange(t, dns.TypePTR, BROWSE_TIMEOUT, func(m *dns.Msg) bool {
+			for _, rr := range records(m) {
+				switch v := rr.(type) {
+				case *dns.SRV:
+					hosts[strings.ToLower(v.Target)] = true
+				case *dns.A:
+					hosts[strings.ToLower(v.Hdr.Name)] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var found []string
+	for h := range hosts {
+		if strings.HasSuffix(h, ".local.") {
+			found = append(found, h)
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// Resolve returns how long host took to answer an A query over multicast.
+// ok is false if nothing answered within RESOLVE_TIMEOUT.
+func Resolve(host string) (rtt time.Duration, ok bool, err error) {
+	start := time.Now()
+	err = exchange(host, dns.TypeA, RESOLVE_TIMEOUT, func(m *dns.Msg) bool {
+		for _, rr := range m.Answer {
+			if a, isA := rr.(*dns.A); isA && strings.EqualFold(a.Hdr.Name, host) {
+				rtt, ok = time.Since(start), true
+				return false
+			}
+		}
+		return true
+	})
+	return rtt, ok, err
+}
+
+// uniq returns values without duplicates, in their original order.
+func uniq(values []string) (out []string) {
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return
+}
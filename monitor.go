@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/cluster"
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/monitor"
+)
+
+var interval = flag.Duration("interval", 5*time.Minute, "Time between benchmarks (monitor mode)")
+var webhook = flag.String("webhook", "", "URL to POST a JSON alert to when assertions or runs start or stop failing (monitor mode)")
+var slack_webhook = flag.String("slack_webhook", "", "Slack incoming webhook URL to alert when assertions or runs start or stop failing (monitor mode)")
+var health_addr = flag.String("health_addr", "127.0.0.1:9053",
+	"Address serving /healthz, 503 while any assertion or the last run fails, and Prometheus /metrics (monitor mode, empty to disable)")
+
+// CURRENT names the system's own resolver in an assertion.
+const CURRENT = "current"
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var assertions stringList
+
+func init() {
+	flag.Var(&assertions, "assert",
+		"Condition every run must meet, e.g. \"current p95 < 40ms\" or \"loss < 1%\"; may be repeated (monitor mode)")
+}
+
+// currentResolver returns the first nameserver in the system resolver configuration.
+func currentResolver() (string, error) {
+	conf, err := cluster.ReadResolvConf(cluster.RESOLV_CONF)
+	if err != nil {
+		return "", err
+	}
+	if len(conf.Nameservers) == 0 {
+		return "", fmt.Errorf("%s lists no nameserver", cluster.RESOLV_CONF)
+	}
+	return parse.Nameserver(conf.Nameservers[0])
+}
+
+// assertionNameserver parses the nameserver an assertion is about.
+func assertionNameserver(s string) (string, error) {
+	if s == CURRENT {
+		return currentResolver()
+	}
+	return parse.Nameserver(s)
+}
+
+// runMonitor implements -mode=monitor: benchmarks the nameservers every
+// -interval, checking -assert conditions after each run and saving it to
+// the results database.
+func runMonitor() error {
+	servers, _, err := bootstrapNameservers(*nameservers)
+	if err != nil {
+		return err
+	}
+	if servers, err = familyServers(servers); err != nil {
+		return err
+	}
+	if err := parseRecordTypes(); err != nil {
+		return err
+	}
+	if err := parseClientSubnet(); err != nil {
+		return err
+	}
+	if err := parseRateLimits(); err != nil {
+		return err
+	}
+	m := &monitor.Monitor{}
+	for _, s := range assertions {
+		a, err := monitor.ParseAssertion(s, assertionNameserver)
+		if err != nil {
+			return err
+		}
+		m.Assertions = append(m.Assertions, a)
+		if a.Nameserver != "" && !contains(servers, a.Nameserver) {
+			servers = append(servers, a.Nameserver)
+		}
+	}
+	if err := checkAllowed(servers); err != nil {
+		return err
+	}
+	if *webhook != "" {
+		m.Notifiers = append(m.Notifiers, monitor.Webhook{URL: *webhook})
+	}
+	if *slack_webhook != "" {
+		m.Notifiers = append(m.Notifiers, monitor.Slack{URL: *slack_webhook})
+	}
+	metrics := &monitor.Metrics{}
+	if *health_addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", m)
+		mux.Handle("/metrics", metrics)
+		log.Printf("Health status at http://%s/healthz, metrics at http://%s/metrics", *health_addr, *health_addr)
+		go func() {
+			log.Fatal(http.ListenAndServe(*health_addr, mux))
+		}()
+	}
+
+	// Ctrl-C stops the run in progress, and monitoring with it.
+	ctx, stop := interruptContext()
+	defer stop()
+	for {
+		hostnames, err := cliHostnames()
+		if err != nil {
+			return err
+		}
+		stats := &dnsqueue.StatsSet{}
+		summaries, err := runCliBenchmark(ctx, servers, hostnames, benchmarkOptions{dnssecOK: *dnssec, stats: stats})
+		if interrupted(ctx, err) {
+			return nil
+		} else if err != nil {
+			log.Printf("Benchmark failed: %s", err)
+			m.Fail(err)
+		} else {
+			m.Record(summaries)
+			metrics.Record(summaries, stats.Stats())
+			if *store_results || *compare_last {
+				if serr := storeResults(summaries); serr != nil {
+					log.Printf("Results database failed: %s", serr)
+				}
+			}
+		}
+		select {
+		case <-time.After(*interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// contains returns true if s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// the monitor package turns repeated benchmarks into an SLO checker:
+// assertions over each run's results, notifications when they are violated
+// or recover, and a health endpoint reporting the current state.
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/report"
+)
+
+// Metrics assertions can test. Durations are compared in milliseconds,
+// loss as a percentage of queries.
+var metrics = map[string]func(*report.Summary) float64{
+	"avg":    func(s *report.Summary) float64 { return msOf(s.Average()) },
+	"p50":    func(s *report.Summary) float64 { return msOf(s.Percentile(50)) },
+	"p90":    func(s *report.Summary) float64 { return msOf(s.Percentile(90)) },
+	"p95":    func(s *report.Summary) float64 { return msOf(s.Percentile(95)) },
+	"p99":    func(s *report.Summary) float64 { return msOf(s.Percentile(99)) },
+	"jitter": func(s *report.Summary) float64 { return msOf(s.Jitter()) },
+	"min":    func(s *report.Summary) float64 { return msOf(s.Latency().Min) },
+	"median": func(s *report.Summary) float64 { return msOf(s.Latency().Median) },
+	"max":    func(s *report.Summary) float64 { return msOf(s.Latency().Max) },
+	"stddev": func(s *report.Summary) float64 { return msOf(s.Latency().Stddev) },
+	"loss":   loss,
+}
+
+// msOf returns d in milliseconds.
+func msOf(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// loss returns the percentage of queries that got no usable answer:
+// timeouts, network errors and failed rcodes such as SERVFAIL and REFUSED.
+// NXDOMAIN is an answer, and blocked domains were answered on purpose, so
+// neither counts.
+func loss(s *report.Summary) float64 {
+	if s.Total() == 0 {
+		return 0
+	}
+	lost := s.FailureCount() - s.Failures[report.NXDOMAIN]
+	return 100 * float64(lost) / float64(s.Total())
+}
+
+// Assertion is a condition every run must meet, e.g. "8.8.8.8 p95 < 40ms".
+type Assertion struct {
+	// Nameserver limits the assertion to one nameserver; "" means all of them.
+	Nameserver string
+	Metric     string
+	Op         string
+	Value      float64
+	text       string
+}
+
+func (a Assertion) String() string {
+	return a.text
+}
+
+// ParseAssertion parses "[nameserver] metric op value", where metric is one
+// of avg, p50, p90, p95, p99, jitter or loss, op is <, <=, > or >=, and value
+// is a duration ("40ms") or, for loss, a percentage ("1%").
+func ParseAssertion(s string, parseNameserver func(string) (string, error)) (a Assertion, err error) {
+	a.text = strings.TrimSpace(s)
+	fields := strings.Fields(s)
+	if len(fields) == 4 {
+		if a.Nameserver, err = parseNameserver(fields[0]); err != nil {
+			return a, err
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return a, fmt.Errorf("invalid assertion %q, expected [nameserver] metric op value", s)
+	}
+	a.Metric, a.Op = fields[0], fields[1]
+	if _, ok := metrics[a.Metric]; !ok {
+		return a, fmt.Errorf("invalid assertion %q: unknown metric %q", s, a.Metric)
+	}
+	switch a.Op {
+	case "<", "<=", ">", ">=":
+	default:
+		return a, fmt.Errorf("invalid assertion %q: unknown operator %q", s, a.Op)
+	}
+	value := fields[2]
+	if a.Metric == "loss" {
+		a.Value, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	} else {
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		a.Value = msOf(d)
+	}
+	if err != nil {
+		return a, fmt.Errorf("invalid assertion %q: %s", s, err)
+	}
+	return a, nil
+}
+
+// Violation is an assertion a nameserver failed.
+type Violation struct {
+	Nameserver string  `json:"nameserver"`
+	Assertion  string  `json:"assertion"`
+	Value      float64 `json:"value"`
+	// Unanswered is set when a latency assertion could not be measured
+	// because no query was answered; Value is then meaningless.
+	Unanswered bool `json:"unanswered,omitempty"`
+}
+
+func (v Violation) String() string {
+	if v.Unanswered {
+		return fmt.Sprintf("%s: %s (no queries answered)", v.Nameserver, v.Assertion)
+	}
+	return fmt.Sprintf("%s: %s (is %.2f)", v.Nameserver, v.Assertion, v.Value)
+}
+
+// Check evaluates the assertion against each summary it applies to. A
+// latency assertion fails for a nameserver that was queried but answered
+// nothing, rather than passing on a latency of 0.
+func (a Assertion) Check(summaries []*report.Summary) (violations []Violation) {
+	for _, s := range summaries {
+		if s.Local || a.Nameserver != "" && s.Nameserver != a.Nameserver {
+			continue
+		}
+		if a.Metric != "loss" && s.Total() > 0 && len(s.Durations) == 0 {
+			violations = append(violations, Violation{Nameserver: s.Nameserver, Assertion: a.String(), Unanswered: true})
+			continue
+		}
+		value := metrics[a.Metric](s)
+		var ok bool
+		switch a.Op {
+		case "<":
+			ok = value < a.Value
+		case "<=":
+			ok = value <= a.Value
+		case ">":
+			ok = value > a.Value
+		case ">=":
+			ok = value >= a.Value
+		}
+		if !ok {
+			violations = append(violations, Violation{Nameserver: s.Nameserver, Assertion: a.String(), Value: value})
+		}
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/namebench/report"
+)
+
+func summary(durations []time.Duration, failures map[string]int) *report.Summary {
+	s := report.NewSummary("1.1.1.1:53")
+	s.Durations = durations
+	for class, n := range failures {
+		s.Failures[class] = n
+	}
+	return s
+}
+
+func TestLoss(t *testing.T) {
+	answered := []time.Duration{time.Millisecond, time.Millisecond}
+	tests := []struct {
+		name     string
+		failures map[string]int
+		want     float64
+	}{
+		{"none", nil, 0},
+		{"timeouts", map[string]int{report.TIMEOUT: 1, report.NETWORK: 1}, 50},
+		{"servfail", map[string]int{report.SERVFAIL: 2}, 50},
+		{"refused", map[string]int{report.REFUSED: 2}, 50},
+		{"nxdomain is an answer", map[string]int{report.NXDOMAIN: 2}, 0},
+	}
+	for _, tt := range tests {
+		if got := loss(summary(answered, tt.failures)); got != tt.want {
+			t.Errorf("%s: loss = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	fast := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+	tests := []struct {
+		name       string
+		assertion  string
+		summary    *report.Summary
+		violated   bool
+		unanswered bool
+	}{
+		{"fast", "p95 < 40ms", summary(fast, nil), false, false},
+		{"slow", "p95 < 15ms", summary(fast, nil), true, false},
+		{"all timed out", "p95 < 40ms", summary(nil, map[string]int{report.TIMEOUT: 5}), true, true},
+		{"all refused", "avg < 40ms", summary(nil, map[string]int{report.REFUSED: 5}), true, true},
+		{"not queried", "p95 < 40ms", summary(nil, nil), false, false},
+		{"all servfail", "loss < 1%", summary(nil, map[string]int{report.SERVFAIL: 5}), true, false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAssertion(tt.assertion, nil)
+		if err != nil {
+			t.Errorf("%s: ParseAssertion(%q) failed: %s", tt.name, tt.assertion, err)
+			continue
+		}
+		got := a.Check([]*report.Summary{tt.summary})
+		if len(got) > 0 != tt.violated || len(got) > 0 && got[0].Unanswered != tt.unanswered {
+			t.Errorf("%s: Check(%q) = %v, want violated %t, unanswered %t", tt.name, tt.assertion, got, tt.violated, tt.unanswered)
+		}
+	}
+}
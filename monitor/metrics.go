@@ -0,0 +1,152 @@
+// part of the monitor package, exports per-nameserver metrics in the
+// Prometheus text format.
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+// LATENCY_BUCKETS are the upper bounds, in seconds, of the query latency histogram.
+var LATENCY_BUCKETS = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// series accumulates one nameserver's metrics across runs.
+type series struct {
+	buckets  []uint64
+	count    uint64
+	sum      float64
+	queries  uint64
+	failures map[dnsqueue.Failure]uint64
+	success  float64
+}
+
+// Metrics keeps per-nameserver latency histograms, query and failure
+// counters and the last run's success ratio, serving them on /metrics.
+// Counters only grow, as Prometheus expects, for as long as the process runs.
+// Query and failure counts come from the benchmark queues' Stats, latencies
+// from the summaries.
+type Metrics struct {
+	mu      sync.Mutex
+	servers map[string]*series
+	runs    uint64
+	last    time.Time
+}
+
+// Record adds a run's summaries to the metrics, along with the Stats of the
+// queues it ran through.
+func (m *Metrics) Record(summaries []*report.Summary, stats dnsqueue.Stats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.servers == nil {
+		m.servers = make(map[string]*series)
+	}
+	for _, s := range summaries {
+		if s.Local || s.Total() == 0 {
+			continue
+		}
+		label := s.Label()
+		ser, ok := m.servers[label]
+		if !ok {
+			ser = &series{buckets: make([]uint64, len(LATENCY_BUCKETS)), failures: make(map[dnsqueue.Failure]uint64)}
+			m.servers[label] = ser
+		}
+		for _, d := range s.Durations {
+			seconds := d.Seconds()
+			for i, le := range LATENCY_BUCKETS {
+				if seconds <= le {
+					ser.buckets[i] += 1
+				}
+			}
+			ser.count += 1
+			ser.sum += seconds
+		}
+		completed := stats.DestinationCompleted[s.Nameserver]
+		ser.queries += uint64(completed)
+		for failure, n := range stats.DestinationFailures[s.Nameserver] {
+			ser.failures[failure] += uint64(n)
+		}
+		if completed > 0 {
+			ser.success = 1 - float64(stats.DestinationErrors[s.Nameserver])/float64(completed)
+		}
+	}
+	m.runs += 1
+	m.last = time.Now()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.write(w)
+}
+
+// write outputs every metric, with nameservers in a stable order.
+func (m *Metrics) write(w io.Writer) {
+	var names []string
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP namebench_runs_total Benchmark runs completed.")
+	fmt.Fprintln(w, "# TYPE namebench_runs_total counter")
+	fmt.Fprintf(w, "namebench_runs_total %d\n", m.runs)
+	if !m.last.IsZero() {
+		fmt.Fprintln(w, "# HELP namebench_last_run_timestamp_seconds When the last run completed.")
+		fmt.Fprintln(w, "# TYPE namebench_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "namebench_last_run_timestamp_seconds %d\n", m.last.Unix())
+	}
+
+	fmt.Fprintln(w, "# HELP namebench_query_duration_seconds Latency of successful queries.")
+	fmt.Fprintln(w, "# TYPE namebench_query_duration_seconds histogram")
+	for _, name := range names {
+		ser, ns := m.servers[name], labelValue(name)
+		for i, le := range LATENCY_BUCKETS {
+			fmt.Fprintf(w, "namebench_query_duration_seconds_bucket{nameserver=\"%s\",le=\"%g\"} %d\n", ns, le, ser.buckets[i])
+		}
+		fmt.Fprintf(w, "namebench_query_duration_seconds_bucket{nameserver=\"%s\",le=\"+Inf\"} %d\n", ns, ser.count)
+		fmt.Fprintf(w, "namebench_query_duration_seconds_sum{nameserver=\"%s\"} %g\n", ns, ser.sum)
+		fmt.Fprintf(w, "namebench_query_duration_seconds_count{nameserver=\"%s\"} %d\n", ns, ser.count)
+	}
+
+	fmt.Fprintln(w, "# HELP namebench_queries_total Queries sent.")
+	fmt.Fprintln(w, "# TYPE namebench_queries_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "namebench_queries_total{nameserver=\"%s\"} %d\n", labelValue(name), m.servers[name].queries)
+	}
+
+	fmt.Fprintln(w, "# HELP namebench_query_failures_total Failed queries by cause.")
+	fmt.Fprintln(w, "# TYPE namebench_query_failures_total counter")
+	for _, name := range names {
+		ser := m.servers[name]
+		var classes []string
+		for failure := range ser.failures {
+			classes = append(classes, string(failure))
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(w, "namebench_query_failures_total{nameserver=\"%s\",class=\"%s\"} %d\n",
+				labelValue(name), labelValue(class), ser.failures[dnsqueue.Failure(class)])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP namebench_success_ratio Share of the last run's queries that succeeded.")
+	fmt.Fprintln(w, "# TYPE namebench_success_ratio gauge")
+	for _, name := range names {
+		fmt.Fprintf(w, "namebench_success_ratio{nameserver=\"%s\"} %g\n", labelValue(name), m.servers[name].success)
+	}
+}
+
+// labelValue escapes a Prometheus label value.
+func labelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,73 @@
+// part of the monitor package, tracks health across runs.
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/report"
+)
+
+// Monitor checks assertions after every run, notifying on each change
+// between healthy and unhealthy. A run that fails counts as unhealthy. It
+// serves the current state over HTTP.
+type Monitor struct {
+	Assertions []Assertion
+	Notifiers  []Notifier
+
+	mu   sync.Mutex
+	last Alert
+	runs int
+}
+
+// Record checks a run's summaries against the assertions and notifies if
+// the health changed since the previous run.
+func (m *Monitor) Record(summaries []*report.Summary) {
+	var violations []Violation
+	for _, a := range m.Assertions {
+		violations = append(violations, a.Check(summaries)...)
+	}
+	m.update(Alert{Healthy: len(violations) == 0, Time: time.Now(), Violations: violations})
+}
+
+// Fail records a run that could not complete as unhealthy, notifying if
+// the previous run was healthy.
+func (m *Monitor) Fail(err error) {
+	m.update(Alert{Healthy: false, Time: time.Now(), Error: err.Error()})
+}
+
+// update makes alert the current state, notifying if the health changed.
+func (m *Monitor) update(alert Alert) {
+	m.mu.Lock()
+	changed := m.runs == 0 && !alert.Healthy || m.runs > 0 && alert.Healthy != m.last.Healthy
+	m.last = alert
+	m.runs += 1
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	log.Print(alert.Text())
+	for _, n := range m.Notifiers {
+		if err := n.Notify(alert); err != nil {
+			log.Printf("Notification failed: %s", err)
+		}
+	}
+}
+
+// ServeHTTP reports the latest run as JSON, with status 503 while any
+// assertion fails or the run failed, or before the first run completes.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	alert, runs := m.last, m.runs
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if runs == 0 || !alert.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(alert)
+}
@@ -0,0 +1,79 @@
+// part of the monitor package, sends alerts to webhooks.
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NOTIFY_TIMEOUT bounds each notification request.
+const NOTIFY_TIMEOUT = 10 * time.Second
+
+// Alert is sent when assertions start failing, or a run fails, and again
+// when they recover.
+type Alert struct {
+	Healthy    bool        `json:"healthy"`
+	Time       time.Time   `json:"time"`
+	Violations []Violation `json:"violations"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Text returns a one-line description of the alert.
+func (a Alert) Text() string {
+	if a.Healthy {
+		return "namebench: all resolver assertions pass again"
+	}
+	if a.Error != "" {
+		return "namebench: monitoring run failed: " + a.Error
+	}
+	var parts []string
+	for _, v := range a.Violations {
+		parts = append(parts, v.String())
+	}
+	return "namebench: resolver assertions failing: " + strings.Join(parts, "; ")
+}
+
+// Notifier delivers alerts somewhere.
+type Notifier interface {
+	Notify(Alert) error
+}
+
+// postJSON POSTs v as JSON to url.
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: NOTIFY_TIMEOUT}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Webhook POSTs each alert as JSON.
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Notify(a Alert) error {
+	return postJSON(w.URL, a)
+}
+
+// Slack posts each alert to a Slack incoming webhook.
+type Slack struct {
+	URL string
+}
+
+func (s Slack) Notify(a Alert) error {
+	return postJSON(s.URL, map[string]string{"text": a.Text()})
+}
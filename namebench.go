@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
@@ -8,44 +9,166 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"runtime"
+	"strings"
 
+	"github.com/google/namebench/datadir"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/managed"
 	"github.com/google/namebench/ui"
 )
 
-var nw_path = flag.String("nw_path", "/Applications/node-webkit.app/Contents/MacOS/node-webkit",
-	"Path to nodejs-webkit binary")
-var nw_package = flag.String("nw_package", "./ui/app.nw", "Path to nodejs-webkit package")
 var port = flag.Int("port", 0, "Port to listen on")
+var bind = flag.String("bind", "127.0.0.1", "Address to listen on when -port is set. Non-loopback addresses require TLS and token auth")
+var tls_cert = flag.String("tls_cert", "", "Path to a PEM TLS certificate")
+var tls_key = flag.String("tls_key", "", "Path to the PEM private key for -tls_cert")
+var tls_self_signed = flag.Bool("tls_self_signed", false, "Generate a self-signed TLS certificate at startup")
+var auth_token = flag.String("auth_token", "", "Token required by the UI when listening on a non-loopback address (random if empty)")
 
-// openWindow opens a nodejs-webkit window, and points it at the given URL.
-func openWindow(url string) (err error) {
-	os.Setenv("APP_URL", url)
-	cmd := exec.Command(*nw_path, *nw_package)
-	if err := cmd.Run(); err != nil {
-		log.Printf("error running %s %s: %s", *nw_path, *nw_package, err)
+// openBrowser opens the given URL in the system's default browser.
+func openBrowser(url string) (err error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("error opening %s, open it in your browser: %s", url, err)
 		return err
 	}
-	return
+	return cmd.Wait()
 }
 
-func main() {
-	flag.Parse()
-	ui.RegisterHandlers()
+// tlsConfig returns the TLS configuration requested by flags, or nil for plaintext.
+func tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case *tls_cert != "" || *tls_key != "":
+		cert, err = tls.LoadX509KeyPair(*tls_cert, *tls_key)
+	case *tls_self_signed:
+		hosts := []string{"localhost", *bind}
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name)
+		}
+		cert, err = ui.SelfSignedCertificate(hosts...)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
 
-	if *port != 0 {
-		log.Printf("Listening at :%d", *port)
-		err := http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
-		if err != nil {
-			log.Fatalf("Failed to listen on %d: %s", *port, err)
+// serve listens on -bind:-port, enforcing TLS and token auth for remote addresses.
+func serve() error {
+	config, err := tlsConfig()
+	if err != nil {
+		return fmt.Errorf("tls setup: %s", err)
+	}
+	var handler http.Handler = http.DefaultServeMux
+	scheme := "http"
+	query := ""
+	if config != nil {
+		scheme = "https"
+	}
+	if !ui.IsLoopback(*bind) {
+		if config == nil {
+			return fmt.Errorf("listening on %s requires -tls_cert/-tls_key or -tls_self_signed", *bind)
+		}
+		token := *auth_token
+		if token == "" {
+			if token, err = ui.NewToken(); err != nil {
+				return err
+			}
 		}
-	} else {
-		listener, err := net.Listen("tcp4", "127.0.0.1:0")
-		if err != nil {
-			log.Fatalf("Failed to listen: %s", err)
+		handler = ui.RequireToken(token, handler)
+		query = "?token=" + token
+	}
+
+	addr := net.JoinHostPort(*bind, fmt.Sprintf("%d", *port))
+	log.Printf("Listening at %s://%s/%s", scheme, addr, query)
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: config}
+	if config != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// parseFlags parses args with fs and applies -config, exiting if it can not be.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if err := applyConfigFile(fs); err != nil {
+		log.Fatalf("Invalid settings: %s", err)
+	}
+}
+
+// applyManaged enforces the managed configuration's settings that override flags.
+func applyManaged() {
+	config, err := managed.Active()
+	if err != nil {
+		log.Fatalf("Invalid managed configuration: %s", err)
+	}
+	if config == nil {
+		return
+	}
+	if config.OutputDir != "" {
+		datadir.Use(config.OutputDir)
+	}
+	if config.DisableHistory {
+		history.Disable()
+	}
+	if config.ResultsServer != nil {
+		*results_server = *config.ResultsServer
+	}
+}
+
+// runServe implements "namebench serve": serves the user interface on
+// -port, or opens it in the browser on a random loopback port.
+func runServe() error {
+	ui.RegisterHandlers()
+	if *port != 0 {
+		if err := serve(); err != nil {
+			return fmt.Errorf("listening on %d: %s", *port, err)
 		}
-		url := fmt.Sprintf("http://%s/", listener.Addr().String())
-		log.Printf("URL: %s", url)
-		go openWindow(url)
-		panic(http.Serve(listener, nil))
+		return nil
+	}
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s/", listener.Addr().String())
+	log.Printf("URL: %s", url)
+	go openBrowser(url)
+	return http.Serve(listener, nil)
+}
+
+func main() {
+	flag.Usage = modeUsage
+	// Commands come before any flags, e.g. "namebench check 8.8.8.8".
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	// Without a command, -mode picks what to run, as it did before commands.
+	parseFlags(flag.CommandLine, os.Args[1:])
+	applyManaged()
+	loadPlugins()
+	var err error
+	switch *mode {
+	case "cli":
+		err = runCli()
+	case "monitor":
+		err = runMonitor()
+	default:
+		err = runServe()
+	}
+	if err != nil {
+		log.Fatalf("%s failed: %s", *mode, err)
 	}
 }
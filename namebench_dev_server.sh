@@ -3,11 +3,11 @@
 # requires: watchdog 0.6.0
 
 killall namebench
-./build.sh && go run namebench.go --port 9080 &
+./build.sh && go run . --port 9080 &
 
 watchmedo shell-command \
   --patterns="*.go;*.html;*.css;*.tmpl" \
   --ignore-directories \
   --recursive \
-  --command='killall namebench; ./build.sh; go run namebench.go --port 9080' \
+  --command='killall namebench; ./build.sh; go run . --port 9080' \
   .
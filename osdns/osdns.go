@@ -0,0 +1,127 @@
+// the osdns package changes which resolvers this computer uses, keeping a
+// backup of the previous settings so they can be put back.
+package osdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/datadir"
+)
+
+// Name of the backup file within the data directory.
+const BACKUP_FILE = "dns-backup.json"
+
+// Interface is a network interface (a network service on macOS) and the
+// resolvers set on it. No Servers means they come from DHCP or the network
+// manager rather than being set by hand.
+type Interface struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+}
+
+// Backup is what each interface used before the resolvers were changed.
+type Backup struct {
+	Time       time.Time   `json:"time"`
+	Interfaces []Interface `json:"interfaces"`
+}
+
+// Command is a program and its arguments that changes resolver settings.
+type Command []string
+
+// String returns the command as it would be typed in a shell.
+func (c Command) String() string {
+	var words []string
+	for _, w := range c {
+		if w == "" || strings.ContainsAny(w, " \t\"'\\$") {
+			w = fmt.Sprintf("%q", w)
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// Current returns the interfaces whose resolvers Apply changes, with the
+// resolvers set on them now.
+func Current() ([]Interface, error) {
+	ifaces, err := current()
+	if err != nil {
+		return nil, err
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no network interfaces to configure")
+	}
+	return ifaces, nil
+}
+
+// SetCommands returns the commands making each interface use servers, which
+// are IP addresses, in order of preference.
+func SetCommands(ifaces []Interface, servers []string) (commands []Command) {
+	for _, iface := range ifaces {
+		commands = append(commands, setCommands(iface.Name, servers)...)
+	}
+	return commands
+}
+
+// RestoreCommands returns the commands putting back the resolvers of backup.
+func RestoreCommands(backup Backup) (commands []Command) {
+	for _, iface := range backup.Interfaces {
+		commands = append(commands, restoreCommands(iface)...)
+	}
+	return commands
+}
+
+// Run runs commands in order, stopping at the first that fails.
+func Run(commands []Command) error {
+	for _, c := range commands {
+		log.Printf("Running %s", c)
+		out, err := exec.Command(c[0], c[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s %s", c, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// SaveBackup stores backup in the data directory, replacing any earlier one.
+func SaveBackup(backup Backup) error {
+	path, err := datadir.Path(BACKUP_FILE)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	return datadir.WriteFile(path, data)
+}
+
+// LoadBackup reads the backup SaveBackup stored. If there is none, the
+// error satisfies os.IsNotExist.
+func LoadBackup() (backup Backup, err error) {
+	path, err := datadir.Path(BACKUP_FILE)
+	if err != nil {
+		return backup, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return backup, err
+	}
+	err = json.Unmarshal(data, &backup)
+	return backup, err
+}
+
+// RemoveBackup deletes the backup once it has been restored.
+func RemoveBackup() error {
+	path, err := datadir.Path(BACKUP_FILE)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
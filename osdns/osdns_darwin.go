@@ -0,0 +1,65 @@
+// part of the osdns package, sets the resolvers of macOS network services
+// with networksetup.
+package osdns
+
+import (
+	"bufio"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// current returns every enabled network service and its resolvers.
+func current() ([]Interface, error) {
+	out, err := exec.Command("networksetup", "-listallnetworkservices").Output()
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []Interface
+	for _, service := range parseServices(string(out)) {
+		out, err := exec.Command("networksetup", "-getdnsservers", service).Output()
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, Interface{Name: service, Servers: parseAddresses(string(out))})
+	}
+	return ifaces, nil
+}
+
+// parseServices returns the enabled services networksetup lists, skipping
+// its heading and the disabled services it marks with an asterisk.
+func parseServices(text string) (services []string) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(scanner.Text())
+		if first || line == "" || strings.HasPrefix(line, "*") {
+			continue
+		}
+		services = append(services, line)
+	}
+	return services
+}
+
+// parseAddresses returns the addresses in networksetup -getdnsservers
+// output, which is a sentence instead when there are none.
+func parseAddresses(text string) (servers []string) {
+	for _, line := range strings.Fields(text) {
+		if net.ParseIP(line) != nil {
+			servers = append(servers, line)
+		}
+	}
+	return servers
+}
+
+func setCommands(name string, servers []string) []Command {
+	return []Command{append(Command{"networksetup", "-setdnsservers", name}, servers...)}
+}
+
+// restoreCommands puts back an interface's resolvers; "Empty" goes back to
+// those from DHCP.
+func restoreCommands(iface Interface) []Command {
+	if len(iface.Servers) == 0 {
+		return setCommands(iface.Name, []string{"Empty"})
+	}
+	return setCommands(iface.Name, iface.Servers)
+}
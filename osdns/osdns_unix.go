@@ -0,0 +1,80 @@
+//go:build !windows && !darwin
+
+// part of the osdns package, sets the resolvers of Linux interfaces with
+// resolvectl, so only systemd-resolved is supported; resolv.conf, resolvconf
+// and NetworkManager without it are not. The change lasts until the
+// interface is reconfigured; to keep it, set the same nameservers in netplan
+// or NetworkManager.
+package osdns
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// ROUTES lists the kernel's IPv4 routing table.
+const ROUTES = "/proc/net/route"
+
+// current returns the interfaces with a default route, and the resolvers
+// systemd-resolved uses on each.
+func current() ([]Interface, error) {
+	if _, err := exec.LookPath("resolvectl"); err != nil {
+		return nil, fmt.Errorf("only systemd-resolved is supported here, and resolvectl was not found: %s", err)
+	}
+	data, err := ioutil.ReadFile(ROUTES)
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []Interface
+	for _, name := range defaultRoutes(string(data)) {
+		out, err := exec.Command("resolvectl", "dns", name).Output()
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, Interface{Name: name, Servers: parseLinkServers(string(out))})
+	}
+	return ifaces, nil
+}
+
+// defaultRoutes returns the interfaces of the default routes in a
+// /proc/net/route table, without duplicates.
+func defaultRoutes(table string) (names []string) {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" || seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		names = append(names, fields[0])
+	}
+	return names
+}
+
+// parseLinkServers returns the servers of "Link 2 (eth0): 192.168.1.1 ...",
+// as resolvectl dns prints them.
+func parseLinkServers(text string) []string {
+	i := strings.Index(text, "):")
+	if i == -1 {
+		return nil
+	}
+	return strings.Fields(text[i+2:])
+}
+
+func setCommands(name string, servers []string) []Command {
+	return []Command{append(Command{"resolvectl", "dns", name}, servers...)}
+}
+
+// restoreCommands puts back an interface's resolvers; revert drops the
+// ones set with resolvectl, going back to those of the network manager.
+func restoreCommands(iface Interface) []Command {
+	if len(iface.Servers) == 0 {
+		return []Command{{"resolvectl", "revert", iface.Name}}
+	}
+	return setCommands(iface.Name, iface.Servers)
+}
@@ -0,0 +1,112 @@
+// part of the osdns package, sets the resolvers of Windows adapters with netsh.
+package osdns
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// ADAPTERS_BUFFER is the initial GetAdaptersAddresses buffer size Microsoft
+// recommends; it grows if there are more adapters.
+const ADAPTERS_BUFFER = 15000
+
+// Registry keys holding each adapter's static DNS servers, by IP version.
+var STATIC_SERVER_KEYS = map[string]string{
+	"ipv4": `SYSTEM\CurrentControlSet\Services\Tcpip\Parameters\Interfaces\`,
+	"ipv6": `SYSTEM\CurrentControlSet\Services\Tcpip6\Parameters\Interfaces\`,
+}
+
+// current returns each adapter that is up and has a gateway, with its static
+// DNS servers; those from DHCP are not listed, as restoring goes back to them.
+func current() ([]Interface, error) {
+	size := uint32(ADAPTERS_BUFFER)
+	flags := uint32(windows.GAA_FLAG_SKIP_UNICAST | windows.GAA_FLAG_SKIP_ANYCAST | windows.GAA_FLAG_SKIP_MULTICAST | windows.GAA_FLAG_INCLUDE_GATEWAYS)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, flags, 0, first, &size)
+		if err == windows.ERROR_BUFFER_OVERFLOW {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("GetAdaptersAddresses", err)
+		}
+		var ifaces []Interface
+		for a := first; a != nil; a = a.Next {
+			if a.OperStatus != windows.IfOperStatusUp || a.FirstGatewayAddress == nil {
+				continue
+			}
+			iface := Interface{Name: windows.UTF16PtrToString(a.FriendlyName)}
+			guid := windows.BytePtrToString(a.AdapterName)
+			for _, family := range []string{"ipv4", "ipv6"} {
+				iface.Servers = append(iface.Servers, staticServers(STATIC_SERVER_KEYS[family]+guid)...)
+			}
+			ifaces = append(ifaces, iface)
+		}
+		return ifaces, nil
+	}
+}
+
+// staticServers returns the servers in the NameServer value of a key, which
+// is empty unless they were set by hand.
+func staticServers(path string) []string {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer k.Close()
+	value, _, err := k.GetStringValue("NameServer")
+	if err != nil {
+		return nil
+	}
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// byFamily splits servers into the netsh contexts of their IP versions.
+func byFamily(servers []string) map[string][]string {
+	families := make(map[string][]string)
+	for _, s := range servers {
+		family := "ipv4"
+		if ip := net.ParseIP(s); ip != nil && ip.To4() == nil {
+			family = "ipv6"
+		}
+		families[family] = append(families[family], s)
+	}
+	return families
+}
+
+// setCommands replaces the adapter's servers of each IP version in servers,
+// leaving the other version alone.
+func setCommands(name string, servers []string) (commands []Command) {
+	families := byFamily(servers)
+	for _, family := range []string{"ipv4", "ipv6"} {
+		for i, s := range families[family] {
+			if i == 0 {
+				commands = append(commands, Command{"netsh", "interface", family, "set", "dnsservers", "name=" + name,
+					"source=static", "address=" + s, "register=primary", "validate=no"})
+				continue
+			}
+			commands = append(commands, Command{"netsh", "interface", family, "add", "dnsservers", "name=" + name,
+				"address=" + s, "index=" + strconv.Itoa(i+1), "validate=no"})
+		}
+	}
+	return commands
+}
+
+// restoreCommands puts back an adapter's static servers, or DHCP for an IP
+// version it had none of.
+func restoreCommands(iface Interface) (commands []Command) {
+	families := byFamily(iface.Servers)
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if len(families[family]) == 0 {
+			commands = append(commands, Command{"netsh", "interface", family, "set", "dnsservers", "name=" + iface.Name, "source=dhcp"})
+		}
+	}
+	return append(commands, setCommands(iface.Name, iface.Servers)...)
+}
@@ -0,0 +1,78 @@
+// csvexport is an example namebench exporter plugin: it appends each
+// nameserver's results to the CSV file named by the NAMEBENCH_CSV environment
+// variable (default namebench.csv), with a header if the file is new.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type result struct {
+	Nameserver string         `json:"nameserver"`
+	Vantage    string         `json:"vantage"`
+	AverageMs  float64        `json:"average_ms"`
+	P95Ms      float64        `json:"p95_ms"`
+	Queries    int            `json:"queries"`
+	Blocked    int            `json:"blocked"`
+	Failures   map[string]int `json:"failures"`
+}
+
+type request struct {
+	Action  string   `json:"action"`
+	Results []result `json:"results"`
+}
+
+func export(results []result) error {
+	path := os.Getenv("NAMEBENCH_CSV")
+	if path == "" {
+		path = "namebench.csv"
+	}
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if created {
+		w.Write([]string{"time", "nameserver", "vantage", "average_ms", "p95_ms", "queries", "blocked", "failures"})
+	}
+	now := time.Now().Format(time.RFC3339)
+	for _, r := range results {
+		failures := 0
+		for _, n := range r.Failures {
+			failures += n
+		}
+		w.Write([]string{now, r.Nameserver, r.Vantage, fmt.Sprintf("%.2f", r.AverageMs), fmt.Sprintf("%.2f", r.P95Ms),
+			fmt.Sprint(r.Queries), fmt.Sprint(r.Blocked), fmt.Sprint(failures)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	var req request
+	resp := make(map[string]interface{})
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		resp["error"] = err.Error()
+	}
+	switch req.Action {
+	case "describe":
+		resp["name"] = "csvexport"
+		resp["kind"] = "exporter"
+		resp["description"] = "appends results to $NAMEBENCH_CSV"
+	case "export":
+		if err := export(req.Results); err != nil {
+			resp["error"] = err.Error()
+		}
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+}
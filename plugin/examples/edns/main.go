@@ -0,0 +1,66 @@
+// edns is an example namebench check plugin: it reports the EDNS UDP payload
+// size each nameserver advertises, warning about sizes above the 1232 bytes
+// recommended to avoid IP fragmentation.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/miekg/dns"
+)
+
+// SAFE_PAYLOAD is the largest UDP payload that avoids fragmentation on most paths.
+const SAFE_PAYLOAD = 1232
+
+type request struct {
+	Action      string   `json:"action"`
+	Nameservers []string `json:"nameservers"`
+}
+
+type finding struct {
+	Nameserver string `json:"nameserver"`
+	Result     string `json:"result"`
+	Warning    bool   `json:"warning"`
+}
+
+func check(ns string) finding {
+	f := finding{Nameserver: ns}
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	m.SetEdns0(SAFE_PAYLOAD, false)
+	in, _, err := new(dns.Client).Exchange(m, ns)
+	switch {
+	case err != nil:
+		f.Result, f.Warning = err.Error(), true
+	case in.IsEdns0() == nil:
+		f.Result, f.Warning = "no EDNS support", true
+	default:
+		size := in.IsEdns0().UDPSize()
+		f.Result = fmt.Sprintf("advertises %d byte UDP payloads", size)
+		f.Warning = size > SAFE_PAYLOAD
+	}
+	return f
+}
+
+func main() {
+	var req request
+	resp := make(map[string]interface{})
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		resp["error"] = err.Error()
+	}
+	switch req.Action {
+	case "describe":
+		resp["name"] = "edns"
+		resp["kind"] = "check"
+		resp["description"] = "EDNS UDP payload size advertised by each nameserver"
+	case "check":
+		var findings []finding
+		for _, ns := range req.Nameservers {
+			findings = append(findings, check(ns))
+		}
+		resp["findings"] = findings
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+}
@@ -0,0 +1,57 @@
+// toplist is an example namebench source plugin: it supplies the top
+// hostnames of a Tranco-style "rank,domain" CSV list, named by the
+// TOPLIST_CSV environment variable.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// MAX_HOSTNAMES bounds how many entries of the list are returned.
+const MAX_HOSTNAMES = 1000
+
+type request struct {
+	Action string `json:"action"`
+	Days   int    `json:"days"`
+}
+
+func hostnames() ([]string, error) {
+	f, err := os.Open(os.Getenv("TOPLIST_CSV"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var found []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(found) < MAX_HOSTNAMES {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) == 2 && fields[1] != "" {
+			found = append(found, fields[1])
+		}
+	}
+	return found, scanner.Err()
+}
+
+func main() {
+	var req request
+	resp := make(map[string]interface{})
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		resp["error"] = err.Error()
+	}
+	switch req.Action {
+	case "describe":
+		resp["name"] = "toplist"
+		resp["kind"] = "source"
+		resp["description"] = "top sites from the CSV list in $TOPLIST_CSV"
+	case "hostnames":
+		found, err := hostnames()
+		if err != nil {
+			resp["error"] = err.Error()
+		}
+		resp["hostnames"] = found
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+}
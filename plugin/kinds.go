@@ -0,0 +1,103 @@
+// part of the plugin package, adapts each kind of plugin to namebench.
+package plugin
+
+import (
+	"time"
+
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/report"
+)
+
+// source is a domain source plugin, usable wherever history sources are.
+type source struct {
+	Plugin
+}
+
+func (s source) Name() string { return s.Plugin.Name }
+
+func (s source) Hostnames(days int) ([]string, error) {
+	resp, err := s.do(request{Action: "hostnames", Days: days})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Hostnames, nil
+}
+
+// RegisterSources registers every source plugin with the history package.
+func RegisterSources(plugins []Plugin) {
+	for _, p := range plugins {
+		if p.Kind == SOURCE {
+			history.Register(source{p})
+		}
+	}
+}
+
+// Finding is a check plugin's result for one nameserver.
+type Finding struct {
+	Nameserver string `json:"nameserver"`
+	Result     string `json:"result"`
+	Warning    bool   `json:"warning"`
+}
+
+// Check runs a check plugin against every nameserver of summaries, recording
+// a "plugin" finding, prefixed with the plugin name, for each one it returns.
+func (p Plugin) Check(summaries []*report.Summary) error {
+	byNameserver := make(map[string]*report.Summary)
+	var nameservers []string
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" {
+			continue
+		}
+		byNameserver[s.Nameserver] = s
+		nameservers = append(nameservers, s.Nameserver)
+	}
+	resp, err := p.do(request{Action: "check", Nameservers: nameservers})
+	if err != nil {
+		return err
+	}
+	for _, f := range resp.Findings {
+		if s, ok := byNameserver[f.Nameserver]; ok {
+			s.AddFinding(report.ANALYSIS, "plugin", p.Name+": "+f.Result, f.Warning)
+		}
+	}
+	return nil
+}
+
+// Result is a nameserver's results, as sent to exporters.
+type Result struct {
+	Nameserver string           `json:"nameserver"`
+	Vantage    string           `json:"vantage,omitempty"`
+	AverageMs  float64          `json:"average_ms"`
+	P95Ms      float64          `json:"p95_ms"`
+	Queries    int              `json:"queries"`
+	Blocked    int              `json:"blocked"`
+	Failures   map[string]int   `json:"failures"`
+	Findings   []report.Finding `json:"findings"`
+}
+
+// msOf returns d in milliseconds.
+func msOf(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Export sends the results of summaries to an exporter plugin.
+func (p Plugin) Export(summaries []*report.Summary) error {
+	var results []Result
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		results = append(results, Result{
+			Nameserver: s.Nameserver,
+			Vantage:    s.Vantage,
+			AverageMs:  msOf(s.Average()),
+			P95Ms:      msOf(s.Percentile(95)),
+			Queries:    s.Total(),
+			Blocked:    s.Blocked,
+			Failures:   s.Failures,
+			Findings:   s.Findings,
+		})
+	}
+	_, err := p.do(request{Action: "export", Results: results})
+	return err
+}
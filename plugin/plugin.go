@@ -0,0 +1,180 @@
+// the plugin package runs third-party extensions: executables that add
+// domain sources, checks or result exporters without changes to namebench.
+//
+// A plugin is any executable in the plugin directory that only its owner may
+// write to, in a directory only its owner may write to. namebench writes one
+// JSON request to its standard input and reads one JSON response from its
+// standard output; anything written to standard error is logged. Every
+// request has an "action":
+//
+//	{"action": "describe"}
+//	  -> {"name": "toplist", "kind": "source", "description": "..."}
+//	{"action": "hostnames", "days": 30}                    (kind "source")
+//	  -> {"hostnames": ["example.com", ...]}
+//	{"action": "check", "nameservers": ["1.1.1.1:53"]}     (kind "check")
+//	  -> {"findings": [{"nameserver": "1.1.1.1:53", "result": "...", "warning": false}]}
+//	{"action": "export", "results": [{"nameserver": ...}]} (kind "exporter")
+//	  -> {}
+//
+// A response with a non-empty "error" fails the request.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/datadir"
+)
+
+// Plugin kinds.
+const (
+	SOURCE   = "source"
+	CHECK    = "check"
+	EXPORTER = "exporter"
+)
+
+// DIR is the plugin directory within the data directory.
+const DIR = "plugins"
+
+// TIMEOUT bounds each request to a plugin.
+const TIMEOUT = 2 * time.Minute
+
+// Plugin is an executable that described itself.
+type Plugin struct {
+	Path        string `json:"-"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+// request is sent to a plugin's standard input.
+type request struct {
+	Action      string   `json:"action"`
+	Days        int      `json:"days,omitempty"`
+	Nameservers []string `json:"nameservers,omitempty"`
+	Results     []Result `json:"results,omitempty"`
+}
+
+// response is read from a plugin's standard output.
+type response struct {
+	Error     string    `json:"error"`
+	Hostnames []string  `json:"hostnames"`
+	Findings  []Finding `json:"findings"`
+}
+
+// Dir returns the default plugin directory.
+func Dir() (string, error) {
+	return datadir.Path(DIR)
+}
+
+// call sends req to the executable at path, decoding its response into v.
+func call(path string, req request, v interface{}) error {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if stderr.Len() > 0 {
+		log.Printf("%s: %s", filepath.Base(path), strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %s", filepath.Base(path), req.Action, err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("%s %s returned invalid JSON: %s", filepath.Base(path), req.Action, err)
+	}
+	return nil
+}
+
+// do sends req to the plugin, returning its response.
+func (p Plugin) do(req request) (*response, error) {
+	var resp response
+	if err := call(p.Path, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s: %s", p.Name, resp.Error)
+	}
+	return &resp, nil
+}
+
+// executable returns true if the file can be run as a plugin.
+func executable(info os.FileInfo) bool {
+	if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+	}
+	return info.Mode()&0111 != 0
+}
+
+// shared returns true if users other than the owner may write to the file:
+// anyone who can replace a plugin could run code as the user running
+// namebench. Windows permissions are not mode bits, so it is never shared.
+func shared(info os.FileInfo) bool {
+	return runtime.GOOS != "windows" && info.Mode().Perm()&0022 != 0
+}
+
+// Discover asks each executable in dir to describe itself, skipping any
+// that fail, claim an unknown kind or that others may write to. A missing
+// directory has no plugins, and one that others may write to is refused.
+func Discover(dir string) (plugins []Plugin, err error) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if shared(info) {
+		return nil, fmt.Errorf("%s is group or world writable", dir)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range files {
+		if !executable(info) {
+			continue
+		}
+		if shared(info) {
+			log.Printf("Skipping plugin %s: group or world writable", filepath.Join(dir, info.Name()))
+			continue
+		}
+		p := Plugin{Path: filepath.Join(dir, info.Name())}
+		if err := call(p.Path, request{Action: "describe"}, &p); err != nil {
+			log.Printf("Skipping plugin: %s", err)
+			continue
+		}
+		switch p.Kind {
+		case SOURCE, CHECK, EXPORTER:
+		default:
+			log.Printf("Skipping plugin %s: unknown kind %q", p.Path, p.Kind)
+			continue
+		}
+		if p.Name == "" {
+			p.Name = info.Name()
+		}
+		log.Printf("Loaded %s plugin %s: %s", p.Kind, p.Name, p.Description)
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
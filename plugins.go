@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/google/namebench/managed"
+	"github.com/google/namebench/plugin"
+	"github.com/google/namebench/report"
+)
+
+var use_plugins = flag.Bool("plugins", false, "Load the source, check and exporter plugins in the data directory's plugins directory")
+var plugin_dir = flag.String("plugin_dir", "", "Directory of source, check and exporter plugins to load (implies -plugins)")
+
+// plugins holds the plugins found by loadPlugins.
+var plugins []plugin.Plugin
+
+// loadPlugins discovers the plugins in -plugin_dir and registers the domain
+// sources among them. Plugins are programs run with the user's rights, so
+// none are loaded unless -plugins or -plugin_dir asks for them, nor ever
+// under a managed configuration.
+func loadPlugins() {
+	if !*use_plugins && *plugin_dir == "" {
+		return
+	}
+	if config, _ := managed.Active(); config != nil {
+		log.Printf("Plugins are disabled by the managed configuration")
+		return
+	}
+	dir := *plugin_dir
+	if dir == "" {
+		var err error
+		if dir, err = plugin.Dir(); err != nil {
+			log.Printf("No plugin directory: %s", err)
+			return
+		}
+	}
+	found, err := plugin.Discover(dir)
+	if err != nil {
+		log.Printf("Loading plugins from %s failed: %s", dir, err)
+		return
+	}
+	plugins = found
+	plugin.RegisterSources(plugins)
+}
+
+// runPluginChecks runs every check plugin against the nameservers.
+func runPluginChecks(summaries []*report.Summary) {
+	for _, p := range plugins {
+		if p.Kind != plugin.CHECK {
+			continue
+		}
+		if err := p.Check(summaries); err != nil {
+			log.Printf("Plugin check failed: %s", err)
+		}
+	}
+}
+
+// runPluginExporters sends the results to every exporter plugin.
+func runPluginExporters(summaries []*report.Summary) {
+	for _, p := range plugins {
+		if p.Kind != plugin.EXPORTER {
+			continue
+		}
+		if err := p.Export(summaries); err != nil {
+			log.Printf("Plugin export failed: %s", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// the portal package talks to a central results service: it uploads
+// summaries users have opted in to share, and downloads what other users in
+// the same country or network see for the same resolvers.
+package portal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VERSION is the version of the submission format.
+const VERSION = 1
+
+// REQUEST_TIMEOUT bounds each request to the results service.
+const REQUEST_TIMEOUT = 30 * time.Second
+
+// Resolver is what is shared about one benchmarked nameserver. Hostnames are
+// never sent, as they come from the user's browsing history.
+type Resolver struct {
+	Nameserver string  `json:"nameserver"`
+	Queries    int     `json:"queries"`
+	Failures   int     `json:"failures"`
+	AverageMs  float64 `json:"average_ms"`
+}
+
+// Submission is one opted-in benchmark run.
+type Submission struct {
+	Version   int        `json:"version"`
+	Country   string     `json:"country,omitempty"`
+	ASN       uint       `json:"asn,omitempty"`
+	Resolvers []Resolver `json:"resolvers"`
+}
+
+// Aggregate is what users in one country or network see for a nameserver.
+type Aggregate struct {
+	Nameserver string  `json:"nameserver"`
+	Country    string  `json:"country,omitempty"`
+	ASN        uint    `json:"asn,omitempty"`
+	Users      int     `json:"users"`
+	MedianMs   float64 `json:"median_ms"`
+}
+
+// Client talks to the results service at Base, e.g. https://results.example.org.
+type Client struct {
+	Base string
+	http *http.Client
+}
+
+// NewClient returns a client for the results service at base.
+func NewClient(base string) *Client {
+	return &Client{
+		Base: strings.TrimSuffix(base, "/"),
+		http: &http.Client{Timeout: REQUEST_TIMEOUT},
+	}
+}
+
+// Upload sends a submission.
+func (c *Client) Upload(s Submission) error {
+	s.Version = VERSION
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.Base+"/v1/results", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("results service returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Aggregates returns statistics for the given nameservers from users in
+// country and, if asn is not zero, on network asn.
+func (c *Client) Aggregates(nameservers []string, country string, asn uint) ([]Aggregate, error) {
+	query := url.Values{"nameserver": nameservers}
+	if country != "" {
+		query.Set("country", country)
+	}
+	if asn != 0 {
+		query.Set("asn", strconv.FormatUint(uint64(asn), 10))
+	}
+	resp, err := c.http.Get(c.Base + "/v1/aggregates?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("results service returned %s", resp.Status)
+	}
+	var aggregates []Aggregate
+	if err := json.NewDecoder(resp.Body).Decode(&aggregates); err != nil {
+		return nil, err
+	}
+	return aggregates, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var skip_unreachable = flag.Bool("skip_unreachable", true,
+	"Leave out nameservers that answer none of a few probe queries, rather than waiting out -timeout for every query (cli mode)")
+
+// PREFLIGHT_QUERIES is how many probes each nameserver is sent.
+const PREFLIGHT_QUERIES = 2
+
+// PREFLIGHT_HOSTNAME is the hostname nameservers are probed and warmed up
+// with. It is outside any workload, so probing does not warm the resolvers'
+// caches for the hostnames the benchmark then times.
+const PREFLIGHT_HOSTNAME = "www.example.com"
+
+// preflight probes each of servers with PREFLIGHT_QUERIES queries for
+// PREFLIGHT_HOSTNAME, sent the way the benchmark sends them, and returns
+// those that gave no answer at all. Any answer, even SERVFAIL, shows a
+// nameserver is up.
+func preflight(ctx context.Context, servers []string) ([]string, error) {
+	answered := make(map[string]bool)
+	q := dnsqueue.StartQueue(ctx, ui.QUEUE_LENGTH, ui.WORKERS)
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, ns := range servers {
+			for i := 0; i < PREFLIGHT_QUERIES; i++ {
+				if err := add(benchmarkRequests(ns, PREFLIGHT_HOSTNAME, benchmarkOptions{dnssecOK: *dnssec})[0]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		if r.Error == "" {
+			answered[r.Request.Destination] = true
+		}
+	})
+	if _, missing := err.(*dnsqueue.MissingResultsError); err != nil && !missing {
+		return nil, err
+	}
+	var unreachable []string
+	for _, ns := range servers {
+		if !answered[ns] {
+			unreachable = append(unreachable, ns)
+		}
+	}
+	return unreachable, nil
+}
+
+// skipUnreachable probes servers before the benchmark, returning the ones
+// to benchmark, with -skip_unreachable those that answered, otherwise all of
+// them, along with the unreachable ones for flagUnreachable.
+func skipUnreachable(ctx context.Context, servers []string) (kept []string, unreachable []string, err error) {
+	if unreachable, err = preflight(ctx, servers); err != nil {
+		return nil, nil, err
+	}
+	if len(unreachable) == 0 || !*skip_unreachable {
+		return servers, unreachable, nil
+	}
+	dead := make(map[string]bool)
+	for _, ns := range unreachable {
+		log.Printf("Skipping %s, which answered none of the pre-flight queries", ns)
+		dead[ns] = true
+	}
+	for _, ns := range servers {
+		if !dead[ns] {
+			kept = append(kept, ns)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, nil, fmt.Errorf("no nameserver answered the pre-flight queries")
+	}
+	return kept, unreachable, nil
+}
+
+// flagUnreachable records a "reachable" finding for each unreachable
+// nameserver: on its summary if it was benchmarked anyway, with
+// -skip_unreachable=false, or else on a local summary it appends, so that
+// skipped nameservers are still listed in the report.
+func flagUnreachable(summaries []*report.Summary, unreachable []string) []*report.Summary {
+	if len(unreachable) == 0 {
+		return summaries
+	}
+	if !*skip_unreachable {
+		for _, ns := range unreachable {
+			for _, s := range summaries {
+				if s.Nameserver == ns {
+					s.AddFinding(report.ANALYSIS, "reachable", "no answer to the pre-flight queries", true)
+				}
+			}
+		}
+		return summaries
+	}
+	local := report.NewLocalSummary()
+	for _, ns := range unreachable {
+		local.AddFinding(report.ANALYSIS, "reachable", fmt.Sprintf("skipped %s, which answered none of the pre-flight queries", ns), true)
+	}
+	return append(summaries, local)
+}
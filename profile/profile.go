@@ -0,0 +1,110 @@
+// the profile package holds presets that tune a benchmark for a purpose:
+// which hostnames to query, how long to sample and how to rank the results.
+package profile
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/namebench/report"
+)
+
+//go:embed data/gaming.txt
+var gamingDomains string
+
+//go:embed data/censorship.txt
+var censorshipDomains string
+
+// Checks a profile can turn on.
+const (
+	// CENSORSHIP runs the blocking, consensus and interception checks.
+	CENSORSHIP = "censorship"
+)
+
+// Profile is a benchmark preset.
+type Profile struct {
+	Name        string
+	Description string
+	// Domains is the workload; nil keeps the usual hostname sources.
+	Domains []string
+	// Rounds is how many times each hostname is queried.
+	Rounds int
+	// Weights ranks the results; a zero value keeps the usual report.
+	Weights report.Weights
+	// Checks lists extra checks to run after the benchmark.
+	Checks []string
+}
+
+// Has returns true if the profile runs the named check.
+func (p *Profile) Has(check string) bool {
+	for _, c := range p.Checks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// profiles holds every preset by name.
+var profiles = map[string]*Profile{
+	"gaming": {
+		Name:        "gaming",
+		Description: "game platform and CDN hostnames, sampled longer, ranked by p99 and jitter",
+		Domains:     lines(gamingDomains),
+		Rounds:      5,
+		Weights:     report.Weights{Average: 1, P99: 3, Jitter: 3},
+	},
+	"censorship": {
+		Name:        "censorship",
+		Description: "frequently blocked hostnames, checked for blocking, answer consensus and interception",
+		Domains:     lines(censorshipDomains),
+		Rounds:      1,
+		Checks:      []string{CENSORSHIP},
+	},
+}
+
+// Lookup returns the profile with the given name.
+func Lookup(name string) (*Profile, error) {
+	if p, ok := profiles[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of every profile, sorted.
+func Names() (names []string) {
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Workload returns hostnames, or the profile's domains if there are none,
+// repeated for each round. Rounds are whole passes, so repeated queries for
+// one hostname are spread out.
+func (p *Profile) Workload(hostnames []string) []string {
+	if hostnames == nil {
+		hostnames = p.Domains
+	}
+	var workload []string
+	for i := 0; i < p.Rounds || i == 0; i++ {
+		workload = append(workload, hostnames...)
+	}
+	return workload
+}
+
+// lines returns the non-blank, non-comment lines of an embedded list.
+func lines(text string) (out []string) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return
+}
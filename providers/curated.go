@@ -0,0 +1,191 @@
+// part of the providers package, a curated list of well-known public
+// resolvers shipped with namebench, so they need not be typed in by hand.
+package providers
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+)
+
+//go:embed data/resolvers.txt
+var curatedData string
+
+// Selections for -include; any other value selects resolvers by tag.
+const (
+	// GLOBAL selects resolvers meant for users anywhere.
+	GLOBAL = "global"
+	// REGIONAL selects resolvers meant for users in one country.
+	REGIONAL = "regional"
+	// PREFERRED selects a handful of good defaults to compare against.
+	PREFERRED = "preferred"
+)
+
+// Tags a curated resolver can have.
+const (
+	DNSSEC    = "dnssec"
+	FILTERING = "filtering"
+)
+
+// Curated is a resolver from the embedded list.
+type Curated struct {
+	Provider
+	// Region is GLOBAL, or the ISO 3166 country code of the users the
+	// resolver is meant for.
+	Region string
+	Tags   []string
+}
+
+// Has returns true if the resolver has the tag.
+func (c Curated) Has(tag string) bool {
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// CuratedList returns every resolver in the embedded list.
+func CuratedList() ([]Curated, error) {
+	return parseCurated(curatedData)
+}
+
+// parseCurated parses lines of "nameserver region tags name".
+func parseCurated(text string) (list []Curated, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("curated resolver %q: want nameserver, region, tags and name", line)
+		}
+		c := Curated{
+			Provider: Provider{Name: strings.Join(fields[3:], " ")},
+			Region:   fields[1],
+		}
+		if dnsqueue.IsDoH(fields[0]) {
+			c.URL = fields[0]
+		} else if c.Address, err = parse.Nameserver(fields[0]); err != nil {
+			return nil, fmt.Errorf("curated resolver %s: %s", c.Name, err)
+		}
+		if fields[2] != "-" {
+			c.Tags = strings.Split(fields[2], ",")
+		}
+		list = append(list, c)
+	}
+	return list, scanner.Err()
+}
+
+// Select returns the curated resolvers matching any of the selections:
+// GLOBAL, REGIONAL (only those for country, if it is given), PREFERRED or
+// a tag such as DNSSEC. Unknown selections are an error. IPv6 addresses are
+// left out, as many networks can't reach them; FamilyAddress finds them.
+func Select(selections []string, country string) (selected []Provider, err error) {
+	list, err := CuratedList()
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{GLOBAL: true, REGIONAL: true}
+	for _, c := range list {
+		for _, t := range c.Tags {
+			known[t] = true
+		}
+	}
+	for _, s := range selections {
+		if !known[s] {
+			var names []string
+			for k := range known {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown resolver selection %q, want one of %s", s, strings.Join(names, ", "))
+		}
+	}
+	country = strings.ToUpper(country)
+	for _, c := range list {
+		if parse.Family(c.Address) == parse.IPV6 {
+			continue
+		}
+		for _, s := range selections {
+			if c.matches(s, country) {
+				selected = append(selected, c.Provider)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+// matches returns true if the resolver is picked by a selection.
+func (c Curated) matches(selection string, country string) bool {
+	switch selection {
+	case GLOBAL:
+		return c.Region == GLOBAL
+	case REGIONAL:
+		return c.Region != GLOBAL && (country == "" || c.Region == country)
+	}
+	return c.Has(selection)
+}
+
+// ResolverName returns the name of the curated resolver that nameserver ns,
+// as parse.Nameserver returns it, is an address of.
+func ResolverName(ns string) (string, bool) {
+	list, err := CuratedList()
+	if err != nil {
+		return "", false
+	}
+	for _, c := range list {
+		if c.Address == ns {
+			return c.Name, true
+		}
+	}
+	return "", false
+}
+
+// FamilyAddress returns the address in family (parse.IPV4 or parse.IPV6) of
+// the curated resolver that nameserver ns is an address of, e.g.
+// [2606:4700:4700::1111]:53 for 1.1.1.1:53: the one at the same position
+// among the resolver's addresses of that family, or else the first.
+func FamilyAddress(ns string, family string) (string, bool) {
+	list, err := CuratedList()
+	if err != nil {
+		return "", false
+	}
+	name, ok := ResolverName(ns)
+	if !ok {
+		return "", false
+	}
+	own := parse.Family(ns)
+	position, seen := 0, 0
+	var addresses []string
+	for _, c := range list {
+		if c.Name != name || c.Address == "" {
+			continue
+		}
+		if c.Address == ns {
+			position = seen
+		}
+		if parse.Family(c.Address) == own {
+			seen += 1
+		}
+		if parse.Family(c.Address) == family {
+			addresses = append(addresses, c.Address)
+		}
+	}
+	if len(addresses) == 0 {
+		return "", false
+	}
+	if position >= len(addresses) {
+		position = 0
+	}
+	return addresses[position], true
+}
@@ -0,0 +1,181 @@
+// the providers package imports public resolver lists maintained by the
+// community, so DNS over HTTPS candidates need not be maintained by hand.
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/datadir"
+)
+
+const (
+	// CURL_LIST is the curl project's wiki page of public DoH servers.
+	CURL_LIST = "https://raw.githubusercontent.com/wiki/curl/curl/DNS-over-HTTPS.md"
+
+	// DNSCRYPT_LIST is dnscrypt-proxy's public resolver list, as DNS stamps.
+	DNSCRYPT_LIST = "https://download.dnscrypt.info/resolvers-list/v3/public-resolvers.md"
+
+	// FETCH_TIMEOUT bounds downloading a list.
+	FETCH_TIMEOUT = 30 * time.Second
+)
+
+// Provider is a public resolver found in a list.
+type Provider struct {
+	Name string
+	// URL is the DoH endpoint, if the provider offers one.
+	URL string
+	// Address is a plain DNS host:port, if the provider offers one.
+	Address string
+}
+
+// Destination returns what the provider is benchmarked at: its DoH URL, or
+// its plain DNS address.
+func (p Provider) Destination() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return p.Address
+}
+
+// fetch downloads url, caching it in the data directory. If the download
+// fails the last cached copy is used instead.
+func fetch(url, cacheName string) ([]byte, error) {
+	path, perr := datadir.Path(cacheName)
+	client := &http.Client{Timeout: FETCH_TIMEOUT}
+	resp, err := client.Get(url)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+	}
+	var data []byte
+	if err == nil {
+		data, err = ioutil.ReadAll(resp.Body)
+	}
+	if err == nil {
+		if perr == nil {
+			if werr := datadir.WriteFile(path, data); werr != nil {
+				log.Printf("Failed to cache %s: %s", url, werr)
+			}
+		}
+		return data, nil
+	}
+	if perr != nil {
+		return nil, err
+	}
+	data, cerr := ioutil.ReadFile(path)
+	if cerr != nil {
+		return nil, err
+	}
+	log.Printf("Fetching %s failed (%s), using cached copy", url, err)
+	return data, nil
+}
+
+// curlURL matches a DoH URL in a row of the curl wiki table.
+var curlURL = regexp.MustCompile("https://[^\\s|<>`)\\]]+")
+
+// parseCurl returns the DoH URLs in the table of the curl wiki page, named
+// after the first column of their row.
+func parseCurl(data []byte) (providers []Provider) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cols := strings.Split(line, "|")
+		if len(cols) < 3 {
+			continue
+		}
+		name := strings.TrimSpace(cols[1])
+		if i := strings.Index(name, "]("); strings.HasPrefix(name, "[") && i > 0 {
+			name = name[1:i]
+		}
+		for _, url := range curlURL.FindAllString(cols[2], -1) {
+			providers = append(providers, Provider{Name: name, URL: url})
+		}
+	}
+	return providers
+}
+
+// parseDnscrypt returns the DoH and plain DNS resolvers in a dnscrypt-proxy
+// list, where each "## name" heading is followed by a description and stamps.
+func parseDnscrypt(data []byte) (providers []Provider) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	name := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "## ") {
+			name = strings.TrimSpace(line[3:])
+			continue
+		}
+		if !strings.HasPrefix(line, "sdns://") {
+			continue
+		}
+		stamp, err := ParseStamp(line)
+		if err != nil {
+			log.Printf("Skipping %s: %s", name, err)
+			continue
+		}
+		switch stamp.Protocol {
+		case STAMP_DOH:
+			providers = append(providers, Provider{Name: name, URL: stamp.URL()})
+		case STAMP_PLAIN:
+			providers = append(providers, Provider{Name: name, Address: stamp.Address})
+		}
+	}
+	return providers
+}
+
+// Curl returns the providers in the curl DoH list.
+func Curl() ([]Provider, error) {
+	data, err := fetch(CURL_LIST, "curl-doh.md")
+	if err != nil {
+		return nil, err
+	}
+	return parseCurl(data), nil
+}
+
+// Dnscrypt returns the DoH and plain DNS providers in the dnscrypt-proxy list.
+func Dnscrypt() ([]Provider, error) {
+	data, err := fetch(DNSCRYPT_LIST, "dnscrypt-resolvers.md")
+	if err != nil {
+		return nil, err
+	}
+	return parseDnscrypt(data), nil
+}
+
+// All returns the providers of every list, without duplicate destinations.
+// It only fails if no list could be read.
+func All() (providers []Provider, err error) {
+	seen := make(map[string]bool)
+	read := 0
+	for _, list := range []func() ([]Provider, error){Curl, Dnscrypt} {
+		found, lerr := list()
+		if lerr != nil {
+			log.Printf("Provider list unavailable: %s", lerr)
+			err = lerr
+			continue
+		}
+		read += 1
+		for _, p := range found {
+			if !seen[p.Destination()] {
+				seen[p.Destination()] = true
+				providers = append(providers, p)
+			}
+		}
+	}
+	if read > 0 {
+		err = nil
+	}
+	return providers, err
+}
@@ -0,0 +1,106 @@
+// part of the providers package, decodes DNS stamps (sdns:// URIs).
+package providers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/google/namebench/internal/parse"
+)
+
+// Stamp protocols namebench can benchmark; others are skipped.
+const (
+	STAMP_PLAIN = 0x00
+	STAMP_DOH   = 0x02
+)
+
+// Stamp is a decoded DNS stamp, see https://dnscrypt.info/stamps-specifications
+type Stamp struct {
+	Protocol byte
+	// Address is host:port for plain DNS, and the optional bootstrap
+	// address, as written in the stamp, for DoH.
+	Address  string
+	Hostname string
+	Path     string
+}
+
+// URL returns the DoH endpoint of a STAMP_DOH stamp.
+func (s Stamp) URL() string {
+	return "https://" + s.Hostname + s.Path
+}
+
+// stampReader reads the length-prefixed fields of a stamp.
+type stampReader struct {
+	b   []byte
+	err error
+}
+
+// take returns the next n bytes.
+func (r *stampReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = fmt.Errorf("truncated stamp")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+// lp reads a length-prefixed string.
+func (r *stampReader) lp() string {
+	n := r.take(1)
+	if n == nil {
+		return ""
+	}
+	return string(r.take(int(n[0])))
+}
+
+// vlp skips a set of length-prefixed values, where a set high bit in the
+// length means another value follows.
+func (r *stampReader) vlp() {
+	for r.err == nil {
+		n := r.take(1)
+		if n == nil {
+			return
+		}
+		r.take(int(n[0] & 0x7f))
+		if n[0]&0x80 == 0 {
+			return
+		}
+	}
+}
+
+// ParseStamp decodes plain DNS and DoH stamps.
+func ParseStamp(s string) (stamp Stamp, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, "sdns://"))
+	if err != nil {
+		return stamp, err
+	}
+	r := &stampReader{b: raw}
+	proto := r.take(1)
+	r.take(8) // DNSSEC, no-log and no-filter properties, unused.
+	if r.err != nil {
+		return stamp, r.err
+	}
+	stamp.Protocol = proto[0]
+
+	switch stamp.Protocol {
+	case STAMP_PLAIN:
+		stamp.Address, err = parse.Nameserver(r.lp())
+		if r.err != nil {
+			return stamp, r.err
+		}
+		return stamp, err
+	case STAMP_DOH:
+		stamp.Address = r.lp()
+		r.vlp() // certificate hashes
+		stamp.Hostname = r.lp()
+		stamp.Path = r.lp()
+		return stamp, r.err
+	}
+	return stamp, fmt.Errorf("unsupported stamp protocol 0x%02x", stamp.Protocol)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/internal/parse"
+)
+
+var server_burst = flag.Int("server_burst", 1,
+	"Queries sent to a nameserver at once before -server_qps and -qps_limits apply (cli mode)")
+var qps_limits = flag.String("qps_limits", "",
+	"Most queries per second for particular nameservers, overriding -server_qps, as nameserver=qps pairs, e.g. 10.0.0.53=5 (cli mode)")
+
+// rate_limits holds -qps_limits by nameserver, once parsed by parseRateLimits.
+var rate_limits map[string]float64
+
+// parseRateLimits validates -qps_limits into rate_limits.
+func parseRateLimits() error {
+	rate_limits = make(map[string]float64)
+	for _, pair := range strings.FieldsFunc(*qps_limits, func(r rune) bool { return r == ',' || r == ' ' }) {
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return fmt.Errorf("-qps_limits: %q is not nameserver=qps", pair)
+		}
+		ns, err := parse.Nameserver(pair[:i])
+		if err != nil {
+			return fmt.Errorf("-qps_limits: %s", err)
+		}
+		qps, err := strconv.ParseFloat(pair[i+1:], 64)
+		if err != nil || qps < 0 {
+			return fmt.Errorf("-qps_limits: invalid rate %q for %s", pair[i+1:], ns)
+		}
+		rate_limits[ns] = qps
+	}
+	return nil
+}
+
+// limitRate applies -server_qps, -server_burst and -qps_limits to q.
+func limitRate(q *dnsqueue.Queue) {
+	q.LimitRate(*server_qps)
+	q.LimitBurst(*server_burst)
+	for ns, qps := range rate_limits {
+		q.LimitDestination(ns, qps)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var replay = flag.Bool("replay", false,
+	"Replay the last -count visits in browser history with their real timing, instead of querying flat out (cli mode)")
+var replay_speed = flag.Float64("replay_speed", 10, "How many times faster than real time -replay runs")
+
+// MAX_REPLAY_GAP is the longest pause between visits that is replayed; longer
+// idle periods are shortened to it before -replay_speed applies.
+const MAX_REPLAY_GAP = 30 * time.Second
+
+// replayStep is a lookup to make at an offset from the start of the replay.
+type replayStep struct {
+	Offset   time.Duration
+	Hostname string
+}
+
+// replaySchedule spaces visits out as they were browsed, divided by speed,
+// keeping bursts intact but cutting idle periods to MAX_REPLAY_GAP.
+func replaySchedule(visits []history.Visit, speed float64) (steps []replayStep) {
+	var offset time.Duration
+	for i, v := range visits {
+		if i > 0 {
+			gap := v.Time.Sub(visits[i-1].Time)
+			if gap > MAX_REPLAY_GAP {
+				gap = MAX_REPLAY_GAP
+			}
+			offset += time.Duration(float64(gap) / speed)
+		}
+		steps = append(steps, replayStep{Offset: offset, Hostname: v.Hostname})
+	}
+	return steps
+}
+
+// runReplayBenchmark replays recent browsing against every nameserver at
+// once, so they all see the same pattern of bursts and pauses, returning the
+// summaries and the hostnames visited. The lookups go through one queue, so
+// -server_qps, -rate_limit and -protocol apply as they do to the benchmark.
+// Once ctx is done no more visits are replayed, and the summaries so far are
+// returned, marked as interrupted, with ctx's error.
+func runReplayBenchmark(ctx context.Context, servers []string, opts benchmarkOptions) ([]*report.Summary, []string, error) {
+	if *replay_speed <= 0 {
+		return nil, nil, fmt.Errorf("-replay_speed must be positive")
+	}
+	visits, err := history.Timeline(*domain_source, ui.HISTORY_DAYS)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(visits) == 0 {
+		return nil, nil, fmt.Errorf("no visits found in browser history")
+	}
+	if len(visits) > *count {
+		visits = visits[len(visits)-*count:]
+	}
+	steps := replaySchedule(visits, *replay_speed)
+	span := visits[len(visits)-1].Time.Sub(visits[0].Time)
+	log.Printf("Replaying %d visits from %s of browsing in %s", len(steps), span, steps[len(steps)-1].Offset)
+
+	var summaries []*report.Summary
+	byServer := make(map[string]*report.Summary)
+	for _, ns := range servers {
+		summary := report.NewSummary(ns)
+		summaries = append(summaries, summary)
+		byServer[ns] = summary
+	}
+	seen := make(map[string]bool)
+	var hostnames []string
+	q := dnsqueue.StartQueueFrom(ctx, ui.QUEUE_LENGTH, ui.WORKERS, opts.source)
+	q.Deadline = steps[len(steps)-1].Offset + time.Duration(len(servers))*ui.JOB_DEADLINE
+	limitRate(q)
+	opts.stats.Watch(q)
+	ordered, flush := dnsqueue.InOrder(func(r *dnsqueue.Result) {
+		byServer[r.Request.Destination].Add(r)
+	})
+	start := time.Now()
+	err = q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, step := range steps {
+			wait := time.NewTimer(time.Until(start.Add(step.Offset)))
+			select {
+			case <-wait.C:
+			case <-ctx.Done():
+				wait.Stop()
+				return ctx.Err()
+			}
+			if !seen[step.Hostname] {
+				seen[step.Hostname] = true
+				hostnames = append(hostnames, step.Hostname)
+			}
+			for _, ns := range servers {
+				for _, r := range benchmarkRequests(ns, step.Hostname, opts) {
+					if err := add(r); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}, ordered)
+	flush()
+	for _, s := range summaries {
+		s.AddFinding(report.ANALYSIS, "replay", fmt.Sprintf("%d lookups from %s of browsing at %gx speed, p95 %.2fms",
+			s.Total(), span.Round(time.Second), *replay_speed, float64(s.Percentile(95))/float64(time.Millisecond)), false)
+	}
+	if missing, ok := err.(*dnsqueue.MissingResultsError); ok && (missing.Cause == nil || missing.Cause == dnsqueue.ErrDeadline) {
+		log.Printf("%s", missing)
+		counts := make(map[string]int)
+		for _, r := range missing.Requests {
+			counts[r.Destination]++
+		}
+		for ns, n := range counts {
+			byServer[ns].AddMissing(n)
+		}
+	} else if interrupted(ctx, err) {
+		markInterrupted(summaries, len(steps)*queriesPerHostname())
+		return summaries, hostnames, ctx.Err()
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return summaries, hostnames, nil
+}
@@ -0,0 +1,77 @@
+// part of the report package, labels resolvers and answers with their AS.
+package report
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/google/namebench/asn"
+)
+
+// ANSWER_ASNS is how many of the most common answer ASes are listed.
+const ANSWER_ASNS = 3
+
+// AnalyzeASN records an "asn" finding per nameserver, naming the network the
+// resolver lives in and the networks its answers are served from, e.g.
+// "AS15169 (GOOGLE); answers from AS13335 (CLOUDFLARENET) 60%".
+func AnalyzeASN(summaries []*Summary, lookup asn.Lookup) {
+	for _, s := range summaries {
+		resolver := "unknown"
+		if host, _, err := net.SplitHostPort(s.Nameserver); err == nil {
+			if info, err := lookup.Lookup(net.ParseIP(host)); err == nil {
+				resolver = info.String()
+			}
+		}
+
+		counts := make(map[string]int)
+		total := 0
+		for _, ip := range s.Answers() {
+			info, err := lookup.Lookup(ip)
+			if err != nil {
+				continue
+			}
+			counts[info.String()] += 1
+			total += 1
+		}
+		var names []string
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if counts[names[i]] != counts[names[j]] {
+				return counts[names[i]] > counts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		if len(names) > ANSWER_ASNS {
+			names = names[:ANSWER_ASNS]
+		}
+		var parts []string
+		for _, name := range names {
+			parts = append(parts, fmt.Sprintf("%s %d%%", name, counts[name]*100/total))
+		}
+		result := resolver
+		if len(parts) > 0 {
+			result += "; answers from " + strings.Join(parts, ", ")
+		}
+		s.AddFinding(ANALYSIS, "asn", result, false)
+	}
+}
+
+// GroupByASN groups nameservers by the AS they are in, so resolvers run by
+// the same operator can be shown together.
+func GroupByASN(summaries []*Summary, lookup asn.Lookup) map[string][]string {
+	groups := make(map[string][]string)
+	for _, s := range summaries {
+		key := "unknown"
+		if host, _, err := net.SplitHostPort(s.Nameserver); err == nil {
+			if info, err := lookup.Lookup(net.ParseIP(host)); err == nil {
+				key = info.String()
+			}
+		}
+		groups[key] = append(groups[key], s.Nameserver)
+	}
+	return groups
+}
@@ -0,0 +1,94 @@
+// part of the report package, tells filtering resolvers' deliberate blocks
+// apart from failures.
+package report
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/miekg/dns"
+)
+
+// BLOCKED is the outcome of a query a filtering resolver chose not to answer.
+// It is not a failure class: blocked queries are counted apart from failures.
+const BLOCKED = "blocked"
+
+// BLOCKPAGES are networks filtering resolvers send blocked domains to.
+var BLOCKPAGES = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{
+		"146.112.61.104/29", // OpenDNS / Cisco Umbrella block pages
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return
+}()
+
+// BLOCKING_ERRORS are the extended DNS error codes that mean a domain was
+// blocked by policy rather than failed to resolve.
+var BLOCKING_ERRORS = map[uint16]bool{
+	dns.ExtendedErrorCodeBlocked:    true,
+	dns.ExtendedErrorCodeCensored:   true,
+	dns.ExtendedErrorCodeFiltered:   true,
+	dns.ExtendedErrorCodeProhibited: true,
+}
+
+// Blocked returns true if the resolver refused to resolve r's domain by
+// policy: an extended error saying so, or answers pointing only at 0.0.0.0,
+// ::, loopback or a known block page.
+func Blocked(r *dnsqueue.Result) bool {
+	for _, code := range r.ExtendedErrors {
+		if BLOCKING_ERRORS[code] {
+			return true
+		}
+	}
+	found := false
+	for _, a := range r.Answers {
+		if a.IP == nil {
+			continue
+		}
+		if !blockpage(a.IP) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// blockpage returns true if ip is where filtering resolvers send blocked domains.
+func blockpage(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() {
+		return true
+	}
+	for _, n := range BLOCKPAGES {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeBlocking records a "blocked" finding on each summary with blocked
+// domains, giving the share of the workload the resolver filters.
+func AnalyzeBlocking(summaries []*Summary) {
+	for _, s := range summaries {
+		if s.Blocked == 0 {
+			continue
+		}
+		var domains []string
+		for domain, class := range s.Outcomes {
+			if class == BLOCKED {
+				domains = append(domains, domain)
+			}
+		}
+		sort.Strings(domains)
+		if len(domains) > MAX_LISTED_DOMAINS {
+			domains = domains[:MAX_LISTED_DOMAINS]
+		}
+		s.AddFinding(ANALYSIS, "blocked", fmt.Sprintf("%d/%d (%.1f%%) of queries blocked: %s", s.Blocked, s.Total(),
+			100*float64(s.Blocked)/float64(s.Total()), strings.Join(domains, ", ")), false)
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/miekg/dns"
+)
+
+func answers(ips ...string) (as []dnsqueue.Answer) {
+	for _, ip := range ips {
+		as = append(as, dnsqueue.Answer{IP: net.ParseIP(ip)})
+	}
+	return as
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		name   string
+		result dnsqueue.Result
+		want   bool
+	}{
+		{"no answer", dnsqueue.Result{}, false},
+		{"public address", dnsqueue.Result{Answers: answers("93.184.216.34")}, false},
+		{"unspecified", dnsqueue.Result{Answers: answers("0.0.0.0")}, true},
+		{"unspecified v6", dnsqueue.Result{Answers: answers("::")}, true},
+		{"loopback", dnsqueue.Result{Answers: answers("127.0.0.1")}, true},
+		{"block page", dnsqueue.Result{Answers: answers("146.112.61.106")}, true},
+		{"outside the block pages", dnsqueue.Result{Answers: answers("146.112.61.112")}, false},
+		{"some real", dnsqueue.Result{Answers: answers("0.0.0.0", "93.184.216.34")}, false},
+		{"only a CNAME", dnsqueue.Result{Answers: []dnsqueue.Answer{{String: "a.example. CNAME b.example."}}}, false},
+		{"blocked error", dnsqueue.Result{ExtendedErrors: []uint16{dns.ExtendedErrorCodeBlocked}}, true},
+		{"filtered error", dnsqueue.Result{ExtendedErrors: []uint16{dns.ExtendedErrorCodeFiltered}}, true},
+		{"other error", dnsqueue.Result{ExtendedErrors: []uint16{dns.ExtendedErrorCodeStaleAnswer}}, false},
+	}
+	for _, tt := range tests {
+		if got := Blocked(&tt.result); got != tt.want {
+			t.Errorf("%s: Blocked = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	blocked := []uint16{dns.ExtendedErrorCodeCensored}
+	tests := []struct {
+		name   string
+		result dnsqueue.Result
+		want   string
+	}{
+		{"answered", dnsqueue.Result{Answers: answers("93.184.216.34")}, ""},
+		{"truncated", dnsqueue.Result{Failure: dnsqueue.FAILURE_TRUNCATED}, ""},
+		{"timeout", dnsqueue.Result{Failure: dnsqueue.FAILURE_TIMEOUT}, TIMEOUT},
+		{"unreachable", dnsqueue.Result{Failure: dnsqueue.FAILURE_UNREACHABLE}, NETWORK},
+		{"bad response", dnsqueue.Result{Failure: dnsqueue.FAILURE_BAD_RESPONSE}, NETWORK},
+		{"invalid", dnsqueue.Result{Failure: dnsqueue.FAILURE_INVALID}, OTHER},
+		{"servfail", dnsqueue.Result{Failure: dnsqueue.FAILURE_SERVFAIL}, SERVFAIL},
+		{"refused", dnsqueue.Result{Failure: dnsqueue.FAILURE_REFUSED}, REFUSED},
+		{"nxdomain", dnsqueue.Result{Failure: dnsqueue.FAILURE_NXDOMAIN}, NXDOMAIN},
+		{"other rcode", dnsqueue.Result{Failure: dnsqueue.FAILURE_RCODE}, OTHER},
+		{"blocked answer", dnsqueue.Result{Answers: answers("0.0.0.0")}, BLOCKED},
+		{"blocked nxdomain", dnsqueue.Result{Failure: dnsqueue.FAILURE_NXDOMAIN, ExtendedErrors: blocked}, BLOCKED},
+		{"blocked refusal", dnsqueue.Result{Failure: dnsqueue.FAILURE_REFUSED, ExtendedErrors: blocked}, BLOCKED},
+		{"timeout is never blocked", dnsqueue.Result{Failure: dnsqueue.FAILURE_TIMEOUT, ExtendedErrors: blocked}, TIMEOUT},
+	}
+	for _, tt := range tests {
+		if got := Classify(&tt.result); got != tt.want {
+			t.Errorf("%s: Classify = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
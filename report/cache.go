@@ -0,0 +1,39 @@
+// part of the report package, separates cached from uncached latency.
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/namebench/stats"
+)
+
+// AnalyzeCache records each nameserver's median latency for cached answers
+// and for uncached ones, given durations of queries it must have answered
+// from cache and of queries it could not have. Benchmark queries no slower
+// than halfway between the two are counted as cache hits, to estimate the
+// nameserver's hit ratio for this workload.
+func AnalyzeCache(summaries []*Summary, cached, uncached map[string][]time.Duration) {
+	for _, s := range summaries {
+		hits, misses := cached[s.Nameserver], uncached[s.Nameserver]
+		if s.Local || s.Vantage != "" || len(hits) == 0 || len(misses) == 0 || len(s.Durations) == 0 {
+			continue
+		}
+		hit := stats.Median(stats.Sorted(hits))
+		miss := stats.Median(stats.Sorted(misses))
+		result := fmt.Sprintf("cached %s, uncached %s", ms(hit), ms(miss))
+		if miss <= hit {
+			s.AddFinding(ANALYSIS, "cache", result+": no slower when uncached, hit ratio unknown", false)
+			continue
+		}
+		threshold := (hit + miss) / 2
+		n := 0
+		for _, d := range s.Durations {
+			if d <= threshold {
+				n++
+			}
+		}
+		s.AddFinding(ANALYSIS, "cache", fmt.Sprintf("%s, ~%.0f%% of queries answered from cache",
+			result, 100*float64(n)/float64(len(s.Durations))), false)
+	}
+}
@@ -0,0 +1,33 @@
+// part of the report package, compares CNAME chains across nameservers.
+package report
+
+import "fmt"
+
+// AnalyzeChains compares, for every domain resolved by more than one
+// nameserver, the CNAME chain each returned. A nameserver that returns no
+// chain where others follow one has flattened it; one that returns a shorter
+// chain has truncated it. Each affected nameserver gets a "cname" finding.
+func AnalyzeChains(summaries []*Summary) {
+	longest := make(map[string]int)
+	for _, s := range summaries {
+		for domain, n := range s.Chains {
+			if n > longest[domain] {
+				longest[domain] = n
+			}
+		}
+	}
+	for _, s := range summaries {
+		flattened, truncated := 0, 0
+		for domain, n := range s.Chains {
+			switch {
+			case n == longest[domain]:
+			case n == 0:
+				flattened += 1
+			default:
+				truncated += 1
+			}
+		}
+		result := fmt.Sprintf("avg %.2f, %d flattened, %d truncated", s.AverageChain(), flattened, truncated)
+		s.AddFinding(ANALYSIS, "cname", result, flattened+truncated > 0)
+	}
+}
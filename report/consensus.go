@@ -0,0 +1,162 @@
+// part of the report package, compares the answers resolvers give for the
+// same domains, flagging those that stray from the consensus.
+package report
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	// SERVICE_PREFIX_BITS is the network size within which addresses are
+	// taken to be the same service: CDNs answer with different nodes from
+	// one network by location.
+	SERVICE_PREFIX_BITS = 16
+
+	// CONSENSUS_MIN_RESOLVERS is how many resolvers must answer a domain for
+	// there to be a consensus to stray from.
+	CONSENSUS_MIN_RESOLVERS = 3
+
+	// CONSENSUS_WARN is the agreement below which a resolver is flagged.
+	CONSENSUS_WARN = 0.8
+)
+
+// serviceNetwork returns the SERVICE_PREFIX_BITS network ip is in.
+func serviceNetwork(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(SERVICE_PREFIX_BITS, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(SERVICE_PREFIX_BITS*2, 128)).String()
+}
+
+// serviceNetworks returns the networks of addrs, as serviceNetwork does.
+func serviceNetworks(addrs []string) map[string]bool {
+	networks := make(map[string]bool)
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil {
+			networks[serviceNetwork(ip)] = true
+		}
+	}
+	return networks
+}
+
+// SameService returns true if any of addresses a is in the same network as
+// any of b, so that both answers lead to the same service. Every comparison
+// of answers between resolvers goes through it.
+func SameService(a, b []string) bool {
+	return overlaps(serviceNetworks(a), serviceNetworks(b))
+}
+
+// verdict returns what s answered for domain, as the networks of its
+// addresses, or NXDOMAIN or BLOCKED. Failures such as timeouts say nothing
+// about correctness, so they have no verdict.
+func verdict(s *Summary, domain string) map[string]bool {
+	switch class := s.Outcomes[domain]; class {
+	case NXDOMAIN, BLOCKED:
+		return map[string]bool{class: true}
+	case "":
+		if networks := serviceNetworks(s.Addresses[domain]); len(networks) > 0 {
+			return networks
+		}
+	}
+	return nil
+}
+
+// overlaps returns true if a and b share any network or outcome.
+func overlaps(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeConsensus sets each resolver's Consensus: the share of domains on
+// which its answer agrees with at least half of the other resolvers. It
+// records a finding listing the domains it differs on, a warning below
+// CONSENSUS_WARN: hijacking, stale caches or filtering.
+func AnalyzeConsensus(summaries []*Summary) {
+	var resolvers []*Summary
+	domains := make(map[string]bool)
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		resolvers = append(resolvers, s)
+		for d := range s.Outcomes {
+			domains[d] = true
+		}
+	}
+	if len(resolvers) < CONSENSUS_MIN_RESOLVERS {
+		return
+	}
+	verdicts := make([]map[string]map[string]bool, len(resolvers))
+	for i, s := range resolvers {
+		verdicts[i] = make(map[string]map[string]bool)
+		for d := range domains {
+			if v := verdict(s, d); v != nil {
+				verdicts[i][d] = v
+			}
+		}
+	}
+	for i, s := range resolvers {
+		compared, agreed := 0, 0
+		var differs []string
+		for d, v := range verdicts[i] {
+			others, agreeing := 0, 0
+			for j := range resolvers {
+				if o, ok := verdicts[j][d]; ok && j != i {
+					others++
+					if overlaps(v, o) {
+						agreeing++
+					}
+				}
+			}
+			if others < CONSENSUS_MIN_RESOLVERS-1 {
+				continue
+			}
+			compared++
+			if agreeing*2 >= others {
+				agreed++
+			} else {
+				differs = append(differs, d)
+			}
+		}
+		if compared == 0 {
+			continue
+		}
+		agreement := float64(agreed) / float64(compared)
+		s.Consensus = &agreement
+		result := fmt.Sprintf("agrees with most resolvers on %d/%d domains (%.0f%%)", agreed, compared, 100*agreement)
+		if len(differs) > 0 {
+			sort.Strings(differs)
+			if len(differs) > MAX_LISTED_DOMAINS {
+				differs = differs[:MAX_LISTED_DOMAINS]
+			}
+			result += ", differs on " + strings.Join(differs, ", ")
+		}
+		s.AddFinding(ANALYSIS, "consensus", result, agreement < CONSENSUS_WARN)
+	}
+}
+
+// Agreement returns the resolver's Consensus as a percentage, or "" if it
+// was not analyzed.
+func (s *Summary) Agreement() string {
+	if s.Consensus == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%%", 100**s.Consensus)
+}
+
+// consensusChecked returns true if any resolver's answers were compared.
+func consensusChecked(summaries []*Summary) bool {
+	for _, s := range summaries {
+		if s.Consensus != nil {
+			return true
+		}
+	}
+	return false
+}
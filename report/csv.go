@@ -0,0 +1,51 @@
+// part of the report package, writes a spreadsheet-friendly CSV table.
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes one row per nameserver, ranked by score as in WriteMarkdown,
+// with durations in milliseconds and a column per failure class. Column names
+// are not translated, so that scripts can rely on them.
+func WriteCSV(w io.Writer, summaries []*Summary) error {
+	ranked := make([]*Summary, len(summaries))
+	copy(ranked, summaries)
+	sortByScore(ranked, DEFAULT_WEIGHTS)
+
+	out := csv.NewWriter(w)
+	header := []string{"rank", "nameserver", "score", "queries", "blocked", "failures", "average_ms",
+		"min_ms", "median_ms", "p90_ms", "p99_ms", "max_ms", "stddev_ms"}
+	for _, class := range FailureClasses {
+		header = append(header, class)
+	}
+	out.Write(header)
+	for i, s := range ranked {
+		if s.Local {
+			continue
+		}
+		rank := ""
+		if !unranked(s) {
+			rank = fmt.Sprint(i + 1)
+		}
+		l := s.Latency()
+		row := []string{rank, s.Label(), strconv.FormatFloat(s.Score(DEFAULT_WEIGHTS), 'f', 2, 64),
+			fmt.Sprint(s.Total()), fmt.Sprint(s.Blocked), fmt.Sprint(s.FailureCount()), csvMs(s.Average()),
+			csvMs(l.Min), csvMs(l.Median), csvMs(l.P90), csvMs(l.P99), csvMs(l.Max), csvMs(l.Stddev)}
+		for _, class := range FailureClasses {
+			row = append(row, fmt.Sprint(s.Failures[class]))
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvMs formats a duration in milliseconds, without a unit.
+func csvMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+}
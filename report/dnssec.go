@@ -0,0 +1,38 @@
+// part of the report package, quantifies what DNSSEC costs each resolver.
+package report
+
+import (
+	"fmt"
+	"time"
+)
+
+// CompareDNSSEC records a "dnssec" finding on each of summaries, against the
+// summary in other for the same nameserver, run over the same hostnames with
+// the DNSSEC OK bit flipped. signed is true if summaries had the bit set. The
+// finding gives the latency penalty of asking for signatures, how many
+// answers the resolver claimed to validate, and warns if the bit costs answers.
+func CompareDNSSEC(summaries, other []*Summary, signed bool) {
+	byNameserver := make(map[string]*Summary)
+	for _, s := range other {
+		byNameserver[s.Nameserver] = s
+	}
+	for _, s := range summaries {
+		o, ok := byNameserver[s.Nameserver]
+		if !ok {
+			continue
+		}
+		on, off := s, o
+		if !signed {
+			on, off = o, s
+		}
+		result := fmt.Sprintf("avg %+.2fms, p95 %+.2fms with DO bit; %d/%d answers validated",
+			float64(on.Average()-off.Average())/float64(time.Millisecond),
+			float64(on.Percentile(95)-off.Percentile(95))/float64(time.Millisecond),
+			on.Validated, len(on.Durations))
+		lost := on.FailureCount() - off.FailureCount()
+		if lost > 0 {
+			result += fmt.Sprintf("; %d more failures with DO bit", lost)
+		}
+		s.AddFinding(ANALYSIS, "dnssec", result, lost > 0)
+	}
+}
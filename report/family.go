@@ -0,0 +1,58 @@
+// part of the report package, compares the IPv4 and IPv6 paths to the same
+// resolver.
+package report
+
+import (
+	"github.com/google/namebench/internal/parse"
+)
+
+// familyPair is a resolver benchmarked over both IPv4 and IPv6.
+type familyPair struct {
+	Resolver string
+	IPv4     *Summary
+	IPv6     *Summary
+}
+
+// Difference returns how much slower, on average, the resolver answered
+// over IPv6 than over IPv4; negative if IPv6 was faster.
+func (p familyPair) Difference() string {
+	d := p.IPv6.Average() - p.IPv4.Average()
+	if d > 0 {
+		return "+" + ms(d)
+	}
+	return ms(d)
+}
+
+// familyPairs returns the resolvers with a Resolver name that were
+// benchmarked over both families, pairing the first address of each.
+func familyPairs(summaries []*Summary) (pairs []familyPair) {
+	index := make(map[string]int)
+	var found []familyPair
+	for _, s := range summaries {
+		if s.Local || s.Resolver == "" {
+			continue
+		}
+		i, ok := index[s.Resolver]
+		if !ok {
+			i = len(found)
+			index[s.Resolver] = i
+			found = append(found, familyPair{Resolver: s.Resolver})
+		}
+		switch parse.Family(s.Nameserver) {
+		case parse.IPV4:
+			if found[i].IPv4 == nil {
+				found[i].IPv4 = s
+			}
+		case parse.IPV6:
+			if found[i].IPv6 == nil {
+				found[i].IPv6 = s
+			}
+		}
+	}
+	for _, p := range found {
+		if p.IPv4 != nil && p.IPv6 != nil {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
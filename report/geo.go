@@ -0,0 +1,27 @@
+// part of the report package, measures how far away answers are from the user.
+package report
+
+import (
+	"fmt"
+
+	"github.com/google/namebench/geo"
+	"github.com/google/namebench/stats"
+)
+
+// AnalyzeAnswerDistance geolocates every answer IP each nameserver returned
+// and records the median distance from the user as a "geo" finding. Low
+// distances mean the resolver steers the user to nearby CDN nodes.
+func AnalyzeAnswerDistance(summaries []*Summary, db *geo.DB, user geo.Location) {
+	for _, s := range summaries {
+		var distances []float64
+		for _, ip := range s.Answers() {
+			if loc, ok := db.Locate(ip); ok {
+				distances = append(distances, geo.Distance(user, loc))
+			}
+		}
+		if len(distances) == 0 {
+			continue
+		}
+		s.AddFinding(ANALYSIS, "geo", fmt.Sprintf("median %.0f km over %d answers", stats.MedianFloat(distances), len(distances)), false)
+	}
+}
@@ -0,0 +1,115 @@
+// part of the report package, renders a standalone HTML report.
+package report
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/google/namebench/i18n"
+)
+
+var htmlTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"T":  i18n.T,
+	"ms": ms,
+}).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>namebench</title>
+<style>
+body { font-family: 'Open Sans', sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.warning td { background-color: #fdd; }
+</style>
+</head>
+<body>
+<h1>namebench</h1>
+<table>
+<tr>
+<th>{{T .Lang "report.nameserver"}}</th><th>{{T .Lang "report.average"}}</th>
+<th>{{T .Lang "report.connect"}}</th><th>{{T .Lang "report.amortized"}}</th>
+<th>{{T .Lang "report.blocked"}}</th><th>{{T .Lang "report.unsuccessful"}}</th>
+{{range .Classes}}<th>{{T $.Lang (printf "failure.%s" .)}}</th>{{end}}
+{{if .Hijack}}<th>{{T .Lang "report.hijacks"}}</th>{{end}}
+{{if .DNSSEC}}<th>{{T .Lang "report.dnssec"}}</th>{{end}}
+{{if .Security}}<th>{{T .Lang "report.security_score"}}</th>{{end}}
+{{if .Consensus}}<th>{{T .Lang "report.consensus"}}</th>{{end}}
+</tr>
+{{range .Summaries}}{{if not .Local}}{{$s := .}}<tr>
+<td>{{.Label}}</td><td>{{ms .Average}}</td><td>{{ms .AverageConnect}}</td><td>{{ms .Amortized}}</td>
+<td>{{.Blocked}}</td><td>{{.FailureCount}}/{{.Total}}</td>
+{{range $.Classes}}<td>{{index $s.Failures .}}</td>{{end}}
+{{if $.Hijack}}<td>{{with .Hijacks}}{{T $.Lang (printf "report.%s" .)}}{{else}}-{{end}}</td>{{end}}
+{{if $.DNSSEC}}<td>{{with .DNSSEC}}{{T $.Lang (printf "report.%s" .)}}{{else}}-{{end}}</td>{{end}}
+{{if $.Security}}<td>{{with .Security}}{{.}}{{else}}-{{end}}</td>{{end}}
+{{if $.Consensus}}<td>{{with .Agreement}}{{.}}{{else}}-{{end}}</td>{{end}}
+</tr>
+{{end}}{{end}}</table>
+<h2>{{T .Lang "report.latency"}}</h2>
+<table>
+<tr>
+<th>{{T .Lang "report.nameserver"}}</th><th>{{T .Lang "report.min"}}</th><th>{{T .Lang "report.median"}}</th>
+<th>{{T .Lang "report.p90"}}</th><th>{{T .Lang "report.p99"}}</th><th>{{T .Lang "report.max"}}</th>
+<th>{{T .Lang "report.stddev"}}</th>
+</tr>
+{{range .Summaries}}{{if not .Local}}{{$l := .Latency}}<tr>
+<td>{{.Label}}</td><td>{{ms $l.Min}}</td><td>{{ms $l.Median}}</td><td>{{ms $l.P90}}</td>
+<td>{{ms $l.P99}}</td><td>{{ms $l.Max}}</td><td>{{ms $l.Stddev}}</td>
+</tr>
+{{end}}{{end}}</table>
+{{if .Types}}
+<h2>{{T .Lang "report.record_types"}}</h2>
+<table>
+<tr>
+<th>{{T .Lang "report.nameserver"}}</th><th>{{T .Lang "report.type"}}</th><th>{{T .Lang "report.average"}}</th>
+<th>{{T .Lang "report.median"}}</th><th>{{T .Lang "report.p99"}}</th><th>{{T .Lang "report.unsuccessful"}}</th>
+</tr>
+{{range .Summaries}}{{if not .Local}}{{$s := .}}{{range $name := $.Types}}{{with index $s.Types $name}}{{$l := .Latency}}<tr>
+<td>{{$s.Label}}</td><td>{{$name}}</td><td>{{ms $l.Mean}}</td><td>{{ms $l.Median}}</td><td>{{ms $l.P99}}</td>
+<td>{{.Failures}}/{{.Total}}</td>
+</tr>
+{{end}}{{end}}{{end}}{{end}}</table>
+{{end}}
+{{if .Families}}
+<h2>{{T .Lang "report.families"}}</h2>
+<table>
+<tr>
+<th>{{T .Lang "report.resolver"}}</th><th>IPv4</th><th>{{T .Lang "report.average"}}</th>
+<th>IPv6</th><th>{{T .Lang "report.average"}}</th><th>{{T .Lang "report.difference"}}</th>
+</tr>
+{{range .Families}}<tr>
+<td>{{.Resolver}}</td><td>{{.IPv4.Label}}</td><td>{{ms .IPv4.Average}}</td>
+<td>{{.IPv6.Label}}</td><td>{{ms .IPv6.Average}}</td><td>{{.Difference}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+{{range .Sections}}
+<h2>{{T $.Lang (printf "report.%s" .Name)}}</h2>
+<table>
+{{range .Rows}}<tr{{if .Warning}} class="warning"{{end}}>
+<td>{{.Nameserver}}</td><td>{{T $.Lang (printf "check.%s" .Check)}}</td><td>{{.Result}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a standalone HTML report of per-nameserver results.
+func WriteHTML(w io.Writer, summaries []*Summary, lang string) error {
+	return htmlTmpl.Execute(w, struct {
+		Lang      string
+		Classes   []string
+		Summaries []*Summary
+		Sections  []findingSection
+		Hijack    bool
+		DNSSEC    bool
+		Security  bool
+		Consensus bool
+		Types     []string
+		Families  []familyPair
+	}{lang, FailureClasses, summaries, groupFindings(summaries, lang), hijackChecked(summaries), dnssecChecked(summaries),
+		securityChecked(summaries), consensusChecked(summaries), recordTypes(summaries), familyPairs(summaries)})
+}
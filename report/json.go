@@ -0,0 +1,41 @@
+// part of the report package, saves summaries as JSON to be reported later.
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes summaries as JSON, which ReadJSON reads back, e.g. to
+// render the same results as text or HTML later.
+func WriteJSON(w io.Writer, summaries []*Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summaries)
+}
+
+// ReadJSON reads summaries written by WriteJSON.
+func ReadJSON(r io.Reader) ([]*Summary, error) {
+	var summaries []*Summary
+	if err := json.NewDecoder(r).Decode(&summaries); err != nil {
+		return nil, err
+	}
+	for _, s := range summaries {
+		if s.Failures == nil {
+			s.Failures = make(map[string]int)
+		}
+		if s.Chains == nil {
+			s.Chains = make(map[string]int)
+		}
+		if s.Outcomes == nil {
+			s.Outcomes = make(map[string]string)
+		}
+		if s.Types == nil {
+			s.Types = make(map[string]*TypeSummary)
+		}
+		if s.Addresses == nil {
+			s.Addresses = make(map[string][]string)
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,54 @@
+// part of the report package, locates the nameservers themselves.
+package report
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/namebench/geo"
+	"github.com/google/namebench/stats"
+)
+
+// anycast lists well known anycast resolvers. They answer from the nearest of
+// many sites, so the location of their address says nothing useful.
+var anycast = map[string]bool{
+	"1.1.1.1": true, "1.0.0.1": true,
+	"8.8.8.8": true, "8.8.4.4": true,
+	"9.9.9.9": true, "149.112.112.112": true,
+	"208.67.222.222": true, "208.67.220.220": true,
+	"4.2.2.1": true, "4.2.2.2": true,
+	"94.140.14.14": true, "94.140.15.15": true,
+	"2606:4700:4700::1111": true, "2606:4700:4700::1001": true,
+	"2001:4860:4860::8888": true, "2001:4860:4860::8844": true,
+	"2620:fe::fe": true, "2620:fe::9": true,
+}
+
+// AnalyzeResolverLocation records a "location" finding with the country, city
+// and distance of each unicast nameserver. A nameserver that answered faster
+// than light could travel to its location and back is flagged: its queries
+// are most likely being intercepted by something closer.
+func AnalyzeResolverLocation(summaries []*Summary, db *geo.DB, user geo.Location) {
+	for _, s := range summaries {
+		host, _, err := net.SplitHostPort(s.Nameserver)
+		if err != nil || anycast[host] {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		loc, ok := db.Locate(ip)
+		if !ok {
+			continue
+		}
+		km := geo.Distance(user, loc)
+		result := fmt.Sprintf("%s, %.0f km", loc, km)
+		fastest := stats.Min(s.Durations)
+		suspicious := fastest > 0 && fastest < geo.MinRTT(km)
+		if suspicious {
+			result += fmt.Sprintf(": answered in %s, faster than the %s light needs, likely intercepted",
+				ms(fastest), ms(geo.MinRTT(km)))
+		}
+		s.AddFinding(ANALYSIS, "location", result, suspicious)
+	}
+}
@@ -0,0 +1,56 @@
+// part of the report package, renders GitHub-flavored Markdown for pasting
+// results into issues, wikis and forums.
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/namebench/i18n"
+)
+
+// WriteMarkdown writes the nameservers ranked by score, best first, with
+// their key statistics, followed by the findings that warn of a problem.
+func WriteMarkdown(w io.Writer, summaries []*Summary, lang string) error {
+	ranked := make([]*Summary, len(summaries))
+	copy(ranked, summaries)
+	sortByScore(ranked, DEFAULT_WEIGHTS)
+
+	fmt.Fprintf(w, "| # | %s | %s | %s | %s | %s |\n", i18n.T(lang, "report.nameserver"), i18n.T(lang, "report.average"),
+		i18n.T(lang, "report.median"), i18n.T(lang, "report.p99"), i18n.T(lang, "report.unsuccessful"))
+	fmt.Fprintln(w, "|--:|---|--:|--:|--:|--:|")
+	for i, s := range ranked {
+		if s.Local {
+			continue
+		}
+		rank := "-"
+		if !unranked(s) {
+			rank = fmt.Sprint(i + 1)
+		}
+		l := s.Latency()
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %d/%d |\n", rank, markdownCell(s.Label()), ms(s.Average()),
+			ms(l.Median), ms(l.P99), s.FailureCount(), s.Total())
+	}
+
+	var warnings []string
+	for _, section := range groupFindings(summaries, lang) {
+		for _, row := range section.Rows {
+			if row.Warning {
+				warnings = append(warnings, fmt.Sprintf("- **%s** %s: %s", markdownCell(row.Nameserver),
+					i18n.T(lang, "check."+row.Check), markdownCell(row.Result)))
+			}
+		}
+	}
+	if len(warnings) > 0 {
+		fmt.Fprintf(w, "\n**%s**\n\n%s\n", i18n.T(lang, "report.warnings"), strings.Join(warnings, "\n"))
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", i18n.T(lang, "report.made_with"))
+	return err
+}
+
+// markdownCell escapes text for a table cell or list item.
+func markdownCell(s string) string {
+	r := strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "\n", " ")
+	return r.Replace(s)
+}
@@ -0,0 +1,32 @@
+// part of the report package, sums up a benchmark of mixed record types.
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnalyzeQueryMix records each nameserver's blended average latency over a
+// mix of record types, with each type's share of the queries and average.
+// The blend is what clients sending that mix would see; a nameserver slow
+// only on rarer types matters less than its per-type table suggests.
+func AnalyzeQueryMix(summaries []*Summary, types []string) {
+	for _, s := range summaries {
+		if s.Local || s.Total() == 0 {
+			continue
+		}
+		var parts []string
+		for _, name := range types {
+			t, ok := s.Types[name]
+			if !ok {
+				continue
+			}
+			part := fmt.Sprintf("%s %.0f%%", name, 100*float64(t.Total)/float64(s.Total()))
+			if len(t.Durations) > 0 {
+				part += " " + ms(t.Latency().Mean)
+			}
+			parts = append(parts, part)
+		}
+		s.AddFinding(ANALYSIS, "query_mix", fmt.Sprintf("blended average %s over %s", ms(s.Average()), strings.Join(parts, ", ")), false)
+	}
+}
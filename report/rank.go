@@ -0,0 +1,118 @@
+// part of the report package, ranks nameservers by weighted latency statistics.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/namebench/stats"
+)
+
+// Weights sets how much each statistic counts towards a nameserver's score.
+// Security is how much the security sub-score (see SecurityScore) counts.
+type Weights struct {
+	Average  float64
+	P99      float64
+	Jitter   float64
+	Security float64
+}
+
+// IsZero returns true if no statistic is weighted.
+func (w Weights) IsZero() bool {
+	return w.Average == 0 && w.P99 == 0 && w.Jitter == 0 && w.Security == 0
+}
+
+// Percentile returns the p-th percentile (0-100) of successful query durations.
+func (s *Summary) Percentile(p float64) time.Duration {
+	return stats.Percentile(stats.Sorted(s.Durations), p)
+}
+
+// Jitter returns the mean difference between consecutive query durations.
+func (s *Summary) Jitter() time.Duration {
+	if len(s.Durations) < 2 {
+		return 0
+	}
+	var total time.Duration
+	for i := 1; i < len(s.Durations); i++ {
+		d := s.Durations[i] - s.Durations[i-1]
+		if d < 0 {
+			d = -d
+		}
+		total += d
+	}
+	return total / time.Duration(len(s.Durations)-1)
+}
+
+// DEFAULT_WEIGHTS ranks by average latency, and by the security sub-score
+// of nameservers that were checked for it, weighed against each other as in
+// DEFAULT_RECOMMEND_WEIGHTS.
+var DEFAULT_WEIGHTS = Weights{Average: 4, Security: 1}
+
+// insecurity returns the share, from 0 to 1, of the checked defences against
+// spoofed answers that the nameserver lacks. Nameservers that were not
+// checked lack none, so the security sub-score only ever costs them.
+func (s *Summary) insecurity() float64 {
+	score, ok := s.SecurityScore()
+	if !ok {
+		return 0
+	}
+	return 1 - score
+}
+
+// Score returns the weighted mean of a nameserver's statistics in ms; lower is
+// better. Each failed query, and each tampered sentinel domain, counts as if
+// it took FAILURE_PENALTY, so a fast resolver that lies does not rank first.
+// Missing defences against spoofing count the same way, as a share of
+// w.Security in the total of w.
+func (s *Summary) Score(w Weights) float64 {
+	total := w.Average + w.P99 + w.Jitter + w.Security
+	if total == 0 || s.Total() == 0 {
+		return 0
+	}
+	var score float64
+	if latency := total - w.Security; latency > 0 {
+		score = w.Average*float64(s.Average()) + w.P99*float64(s.Percentile(99)) + w.Jitter*float64(s.Jitter())
+		score /= latency
+	}
+	failed := float64(s.FailureCount()+s.Tampered) / float64(s.Total()+s.Sentinels)
+	score = score*(1-failed) + failed*float64(FAILURE_PENALTY)
+	insecure := w.Security / total * s.insecurity()
+	score = score*(1-insecure) + insecure*float64(FAILURE_PENALTY)
+	return score / float64(time.Millisecond)
+}
+
+// unranked returns true for summaries that Rank sorts last.
+func unranked(s *Summary) bool {
+	return s.Local || s.Total() == 0
+}
+
+// sortByScore sorts summaries by Score, best first, with unranked ones last.
+func sortByScore(summaries []*Summary, w Weights) {
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if unranked(a) || unranked(b) {
+			return !unranked(a) && unranked(b)
+		}
+		return a.Score(w) < b.Score(w)
+	})
+}
+
+// FAILURE_PENALTY is the duration a failed query counts as when scoring.
+const FAILURE_PENALTY = 2 * time.Second
+
+// Rank sorts summaries by Score, best first, and records a "rank" finding
+// for each nameserver. Local summaries and ones without queries sort last.
+func Rank(summaries []*Summary, w Weights) {
+	sortByScore(summaries, w)
+	for i, s := range summaries {
+		if unranked(s) {
+			break
+		}
+		text := fmt.Sprintf("#%d, score %.2f (avg %s, p99 %s, jitter %s", i+1, s.Score(w), ms(s.Average()), ms(s.Percentile(99)), ms(s.Jitter()))
+		if security := s.Security(); security != "" && w.Security > 0 {
+			text += ", security " + security
+		}
+		s.AddFinding(RANKING, "rank", text+")", false)
+	}
+}
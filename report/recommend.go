@@ -0,0 +1,206 @@
+// part of the report package, recommends a primary, secondary and tertiary
+// nameserver from everything a run measured, as the original namebench did.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RecommendWeights sets how much each aspect counts towards a nameserver's
+// recommendation score.
+type RecommendWeights struct {
+	// Latency compares average latency with the fastest nameserver's.
+	Latency float64
+	// Reliability is the share of queries answered.
+	Reliability float64
+	// Correctness is lost by hijacking NXDOMAIN and tampering with answers.
+	Correctness float64
+	// DNSSEC counts validating resolvers as better.
+	DNSSEC float64
+	// Security is the security sub-score: 0x20 case preserved and random
+	// source ports. It is judged as Score judges it for Weights.Security.
+	Security float64
+}
+
+// DEFAULT_RECOMMEND_WEIGHTS favours speed, then answering every query, then
+// honest answers, then DNSSEC validation and defences against spoofing.
+var DEFAULT_RECOMMEND_WEIGHTS = RecommendWeights{Latency: 4, Reliability: 3, Correctness: 2, DNSSEC: 1, Security: 1}
+
+// Recommended roles, in order.
+var RECOMMEND_ROLES = []string{"primary", "secondary", "tertiary"}
+
+// Recommendation is a nameserver chosen for a role, with its score from 0
+// to 100 and the reasons it was chosen.
+type Recommendation struct {
+	Role    string
+	Summary *Summary
+	Score   float64
+	Reasons []string
+}
+
+// aspects are a nameserver's scores, from 0 (worst) to 1 (best), for each of
+// RecommendWeights' aspects.
+type aspects struct {
+	latency, reliability, correctness, dnssec, security float64
+}
+
+// score combines the aspects into a score from 0 to 100.
+func (a aspects) score(w RecommendWeights) float64 {
+	total := w.Latency + w.Reliability + w.Correctness + w.DNSSEC + w.Security
+	if total == 0 {
+		return 0
+	}
+	return 100 * (w.Latency*a.latency + w.Reliability*a.reliability + w.Correctness*a.correctness + w.DNSSEC*a.dnssec +
+		w.Security*a.security) / total
+}
+
+// recommendable returns true for summaries of a nameserver queried directly.
+func recommendable(s *Summary) bool {
+	return !unranked(s) && s.Vantage == ""
+}
+
+// operator returns what a nameserver's diversity is judged by: the resolver
+// service or owner it belongs to, or else the nameserver itself.
+func operator(s *Summary) string {
+	if s.Resolver != "" {
+		return s.Resolver
+	}
+	if s.Owner != "" {
+		return s.Owner
+	}
+	return s.Nameserver
+}
+
+// rate scores each aspect of s, given the fastest average latency.
+func rate(s *Summary, fastest float64) aspects {
+	var a aspects
+	if avg := float64(s.Average()); avg > 0 {
+		a.latency = fastest / avg
+	}
+	a.reliability = 1 - float64(s.FailureCount())/float64(s.Total())
+	a.correctness = 1
+	if s.HijacksNXDOMAIN != nil && *s.HijacksNXDOMAIN {
+		a.correctness = 0
+	} else if s.Sentinels > 0 {
+		a.correctness -= float64(s.Tampered) / float64(s.Sentinels)
+	}
+	// Unchecked resolvers are neither rewarded nor punished.
+	a.dnssec = 0.5
+	if s.ValidatesDNSSEC != nil {
+		a.dnssec = 0
+		if *s.ValidatesDNSSEC {
+			a.dnssec = 1
+		}
+	}
+	a.security = 1 - s.insecurity()
+	return a
+}
+
+// reasons explains a recommendation: how the nameserver did on each aspect,
+// and for backups, that it is run by someone else than those chosen before.
+func reasons(s *Summary, fastest float64, chosen []Recommendation) []string {
+	var why []string
+	switch avg := float64(s.Average()); {
+	case len(s.Durations) == 0:
+	case avg <= fastest:
+		why = append(why, fmt.Sprintf("fastest average %s", ms(s.Average())))
+	default:
+		why = append(why, fmt.Sprintf("average %s, %.1fx the fastest", ms(s.Average()), avg/fastest))
+	}
+	why = append(why, fmt.Sprintf("%.1f%% of queries answered", 100*(1-float64(s.FailureCount())/float64(s.Total()))))
+	switch {
+	case s.HijacksNXDOMAIN != nil && *s.HijacksNXDOMAIN:
+		why = append(why, "hijacks NXDOMAIN")
+	case s.HijacksNXDOMAIN != nil:
+		why = append(why, "no NXDOMAIN hijacking")
+	}
+	if s.Tampered > 0 {
+		why = append(why, fmt.Sprintf("%d/%d sentinel domains tampered with", s.Tampered, s.Sentinels))
+	}
+	if s.ValidatesDNSSEC != nil {
+		if *s.ValidatesDNSSEC {
+			why = append(why, "validates DNSSEC")
+		} else {
+			why = append(why, "does not validate DNSSEC")
+		}
+	}
+	if s.PreservesCase != nil {
+		if *s.PreservesCase {
+			why = append(why, "preserves 0x20 case")
+		} else {
+			why = append(why, "changes the case of 0x20 queries")
+		}
+	}
+	if s.RandomizesPorts != nil {
+		if *s.RandomizesPorts {
+			why = append(why, "random source ports")
+		} else {
+			why = append(why, "predictable source ports")
+		}
+	}
+	if len(chosen) > 0 {
+		diverse := true
+		for _, c := range chosen {
+			diverse = diverse && operator(c.Summary) != operator(s)
+		}
+		if diverse {
+			why = append(why, "a different operator, in case the "+chosen[0].Role+" fails")
+		}
+	}
+	return why
+}
+
+// Recommend scores each nameserver queried directly on latency, reliability,
+// correctness, DNSSEC validation and its security sub-score, and picks the best for each of
+// RECOMMEND_ROLES. Backups are preferably run by other operators than the
+// nameservers already picked, so that one outage does not take out all of
+// them. It records a "recommended" finding for each pick, and returns the
+// picks, or none with fewer than two nameservers to choose from.
+func Recommend(summaries []*Summary, w RecommendWeights) []Recommendation {
+	var candidates []*Summary
+	fastest := 0.0
+	for _, s := range summaries {
+		if !recommendable(s) {
+			continue
+		}
+		candidates = append(candidates, s)
+		if avg := float64(s.Average()); avg > 0 && (fastest == 0 || avg < fastest) {
+			fastest = avg
+		}
+	}
+	if len(candidates) < 2 {
+		return nil
+	}
+	scores := make(map[*Summary]float64)
+	for _, s := range candidates {
+		scores[s] = rate(s, fastest).score(w)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+
+	var chosen []Recommendation
+	used := make(map[*Summary]bool)
+	operators := make(map[string]bool)
+	pick := func(s *Summary) {
+		r := Recommendation{RECOMMEND_ROLES[len(chosen)], s, scores[s], reasons(s, fastest, chosen)}
+		chosen = append(chosen, r)
+		used[s] = true
+		operators[operator(s)] = true
+		s.AddFinding(RANKING, "recommended", fmt.Sprintf("%s, score %.1f/100: %s", r.Role, r.Score, strings.Join(r.Reasons, ", ")), false)
+	}
+	// First prefer operators not picked yet, then fill any roles left.
+	for _, diverse := range []bool{true, false} {
+		for _, s := range candidates {
+			if len(chosen) == len(RECOMMEND_ROLES) {
+				return chosen
+			}
+			if !used[s] && (!diverse || !operators[operator(s)]) {
+				pick(s)
+			}
+		}
+	}
+	return chosen
+}
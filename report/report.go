@@ -0,0 +1,467 @@
+// the report package aggregates benchmark results and renders them as text or HTML.
+package report
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/i18n"
+	"github.com/google/namebench/stats"
+)
+
+// Failure classes, in the order they are reported.
+const (
+	TIMEOUT  = "timeout"
+	SERVFAIL = "servfail"
+	REFUSED  = "refused"
+	NXDOMAIN = "nxdomain"
+	NETWORK  = "network"
+	OTHER    = "other"
+)
+
+// FailureClasses lists every failure class, in report order.
+var FailureClasses = []string{TIMEOUT, SERVFAIL, REFUSED, NXDOMAIN, NETWORK, OTHER}
+
+// Classify returns the failure class of a result, BLOCKED if a filtering
+// resolver blocked it, or "" if it succeeded. Domains come from real
+// browsing, so NXDOMAIN is otherwise treated as a failure.
+func Classify(r *dnsqueue.Result) string {
+	switch r.Failure {
+	case dnsqueue.FAILURE_TIMEOUT:
+		return TIMEOUT
+	case dnsqueue.FAILURE_UNREACHABLE, dnsqueue.FAILURE_BAD_RESPONSE:
+		return NETWORK
+	case dnsqueue.FAILURE_INVALID:
+		return OTHER
+	}
+	if Blocked(r) {
+		return BLOCKED
+	}
+	switch r.Failure {
+	case "", dnsqueue.FAILURE_TRUNCATED:
+		return ""
+	case dnsqueue.FAILURE_SERVFAIL:
+		return SERVFAIL
+	case dnsqueue.FAILURE_REFUSED:
+		return REFUSED
+	case dnsqueue.FAILURE_NXDOMAIN:
+		return NXDOMAIN
+	}
+	return OTHER
+}
+
+// Summary holds the aggregated results for one nameserver.
+type Summary struct {
+	Nameserver string
+	Durations  []time.Duration
+
+	// Setup time of each new connection, kept apart from query durations.
+	Connects []time.Duration
+
+	// Unsuccessful queries by failure class.
+	Failures map[string]int
+
+	// Results of security checks, shown in their own section.
+	Findings []Finding
+
+	// CNAME chain length seen for each successfully resolved domain.
+	Chains map[string]int
+
+	// Local summaries hold findings about this computer rather than a
+	// nameserver, and are left out of the results table.
+	Local bool
+
+	// Vantage names the proxy queries went through, if any.
+	Vantage string
+
+	// Alias names the nameserver in reports, e.g. sources.SYSTEM_LABEL.
+	Alias string
+
+	// Owner names who runs the nameserver, e.g. "Cloudflare, AS13335", and
+	// Hostname is its reverse DNS name, if known.
+	Owner    string
+	Hostname string
+
+	// Resolver names the service the nameserver is an address of, e.g.
+	// "Cloudflare", so that its IPv4 and IPv6 addresses can be compared.
+	Resolver string
+
+	// Outcomes holds the failure class of each domain queried, "" if it
+	// resolved. With several record types, the first failure is kept.
+	Outcomes map[string]string
+
+	// Types breaks the results down by record type.
+	Types map[string]*TypeSummary
+
+	// Addresses holds the addresses answered for each resolved domain.
+	Addresses map[string][]string
+
+	// Blocked counts queries a filtering resolver blocked.
+	Blocked int
+
+	// Validated counts successful answers the resolver marked as DNSSEC validated.
+	Validated int
+
+	// Retried counts queries only answered after being sent again.
+	Retried int
+
+	// Tampered counts the Sentinels, commonly blocked domains compared with
+	// a trusted resolver, that the nameserver answered bogus or filtered.
+	Tampered  int
+	Sentinels int
+
+	// HijacksNXDOMAIN is set once checked with dnschecks.WildcardHijack: true
+	// if the nameserver answers nonexistent domains with addresses.
+	HijacksNXDOMAIN *bool
+
+	// ValidatesDNSSEC is set once checked with dnschecks.ValidateDNSSEC:
+	// true if the nameserver rejects bogus signatures and authenticates good ones.
+	ValidatesDNSSEC *bool
+
+	// PreservesCase is set once checked with dnschecks.CheckCase: true if
+	// the nameserver answers 0x20 mixed case questions unchanged.
+	PreservesCase *bool
+
+	// RandomizesPorts is set once checked with dnschecks.CheckPorts: true if
+	// the source ports the nameserver queries from were rated random enough.
+	RandomizesPorts *bool
+
+	// Consensus is set by AnalyzeConsensus: the share of domains, from 0 to
+	// 1, on which the nameserver's answers agree with most other nameservers.
+	Consensus *float64
+
+	answers []net.IP
+}
+
+// TypeSummary holds a nameserver's results for one record type.
+type TypeSummary struct {
+	Durations []time.Duration
+	Failures  int
+	Total     int
+}
+
+// Latency returns the spread and percentiles of the type's successful queries.
+func (t *TypeSummary) Latency() stats.Latency {
+	return stats.Describe(t.Durations)
+}
+
+// Report sections findings may belong to.
+const (
+	RANKING    = "ranking"
+	SECURITY   = "security"
+	CENSORSHIP = "censorship"
+	ANALYSIS   = "analysis"
+)
+
+// Sections lists the finding sections, in report order.
+var Sections = []string{RANKING, SECURITY, CENSORSHIP, ANALYSIS}
+
+// Finding is the outcome of a single check against a nameserver.
+type Finding struct {
+	Section string `json:"section"`
+	Check   string `json:"check"`
+	Result  string `json:"result"`
+	Warning bool   `json:"warning"`
+}
+
+// AddFinding records the outcome of a check in a report section.
+func (s *Summary) AddFinding(section, check, result string, warning bool) {
+	s.Findings = append(s.Findings, Finding{Section: section, Check: check, Result: result, Warning: warning})
+}
+
+// findingRow is a finding along with the nameserver it is about.
+type findingRow struct {
+	Nameserver string
+	Finding
+}
+
+// findingSection is every finding in one report section.
+type findingSection struct {
+	Name string
+	Rows []findingRow
+}
+
+// groupFindings collects findings by section, in report order, omitting empty sections.
+func groupFindings(summaries []*Summary, lang string) (sections []findingSection) {
+	for _, name := range Sections {
+		section := findingSection{Name: name}
+		for _, s := range summaries {
+			label := s.Label()
+			if s.Local {
+				label = i18n.T(lang, "report.local")
+			}
+			for _, f := range s.Findings {
+				if f.Section == name {
+					section.Rows = append(section.Rows, findingRow{Nameserver: label, Finding: f})
+				}
+			}
+		}
+		if len(section.Rows) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return
+}
+
+// Answers returns the IP addresses returned for successful queries.
+func (s *Summary) Answers() []net.IP {
+	return s.answers
+}
+
+// NewLocalSummary returns a summary for findings about this computer.
+func NewLocalSummary() *Summary {
+	s := NewSummary("")
+	s.Local = true
+	return s
+}
+
+// NewSummary returns an empty summary for a nameserver.
+func NewSummary(nameserver string) *Summary {
+	return &Summary{
+		Nameserver: nameserver,
+		Failures:   make(map[string]int),
+		Chains:     make(map[string]int),
+		Outcomes:   make(map[string]string),
+		Types:      make(map[string]*TypeSummary),
+		Addresses:  make(map[string][]string),
+	}
+}
+
+// Label returns the nameserver, along with its alias, owner and the vantage
+// point if there are any, e.g. "1.1.1.1:53 (Cloudflare, AS13335)".
+func (s *Summary) Label() string {
+	label := s.Nameserver
+	if s.Alias != "" {
+		label = s.Alias + " " + label
+	}
+	if s.Owner != "" {
+		label += " (" + s.Owner + ")"
+	}
+	if s.Vantage != "" {
+		return label + " via " + s.Vantage
+	}
+	return label
+}
+
+// Add records a single result.
+func (s *Summary) Add(r *dnsqueue.Result) {
+	if r.NewConnection {
+		s.Connects = append(s.Connects, r.Connect)
+	}
+	if r.Retried {
+		s.Retried += 1
+	}
+	domain := strings.TrimSuffix(r.Request.RecordName, ".")
+	class := Classify(r)
+	if s.Outcomes[domain] == "" {
+		s.Outcomes[domain] = class
+	}
+	t := s.Types[r.Request.RecordType]
+	if t == nil {
+		t = &TypeSummary{}
+		s.Types[r.Request.RecordType] = t
+	}
+	t.Total += 1
+	if class == BLOCKED {
+		s.Blocked += 1
+		return
+	}
+	if class != "" {
+		s.Failures[class] += 1
+		t.Failures += 1
+		return
+	}
+	s.Durations = append(s.Durations, r.Duration)
+	t.Durations = append(t.Durations, r.Duration)
+	if r.Authenticated {
+		s.Validated += 1
+	}
+	s.Chains[domain] = len(r.CNAMEChain)
+	for _, a := range r.Answers {
+		if a.IP != nil {
+			s.answers = append(s.answers, a.IP)
+			s.addAddress(domain, a.IP.String())
+		}
+	}
+}
+
+// addAddress records addr as an answer for domain, once.
+func (s *Summary) addAddress(domain, addr string) {
+	for _, a := range s.Addresses[domain] {
+		if a == addr {
+			return
+		}
+	}
+	s.Addresses[domain] = append(s.Addresses[domain], addr)
+}
+
+// AverageChain returns the mean CNAME chain length of resolved domains.
+func (s *Summary) AverageChain() float64 {
+	if len(s.Chains) == 0 {
+		return 0
+	}
+	total := 0
+	for _, n := range s.Chains {
+		total += n
+	}
+	return float64(total) / float64(len(s.Chains))
+}
+
+// AddMissing records requests that never returned a result as timeouts.
+func (s *Summary) AddMissing(n int) {
+	s.Failures[TIMEOUT] += n
+}
+
+// FailureCount returns the number of unsuccessful queries.
+func (s *Summary) FailureCount() (n int) {
+	for _, c := range s.Failures {
+		n += c
+	}
+	return
+}
+
+// Total returns the number of queries made.
+func (s *Summary) Total() int {
+	return len(s.Durations) + s.Blocked + s.FailureCount()
+}
+
+// Average returns the mean duration of successful queries.
+func (s *Summary) Average() time.Duration {
+	return stats.Mean(s.Durations)
+}
+
+// AverageConnect returns the mean time spent establishing a new connection.
+func (s *Summary) AverageConnect() time.Duration {
+	return stats.Mean(s.Connects)
+}
+
+// Amortized returns the mean cost per query with connection setup spread
+// across every query that shared the connections.
+func (s *Summary) Amortized() time.Duration {
+	if len(s.Durations) == 0 {
+		return 0
+	}
+	total := s.Average() * time.Duration(len(s.Durations))
+	for _, d := range s.Connects {
+		total += d
+	}
+	return total / time.Duration(len(s.Durations))
+}
+
+// Hijacks returns "yes" or "no" for whether the nameserver hijacks NXDOMAIN
+// answers, or "" if that was not checked.
+func (s *Summary) Hijacks() string {
+	if s.HijacksNXDOMAIN == nil {
+		return ""
+	}
+	if *s.HijacksNXDOMAIN {
+		return "yes"
+	}
+	return "no"
+}
+
+// hijackChecked returns true if any nameserver was checked for NXDOMAIN hijacking.
+func hijackChecked(summaries []*Summary) bool {
+	for _, s := range summaries {
+		if s.HijacksNXDOMAIN != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DNSSEC returns "yes" or "no" for whether the nameserver validates DNSSEC,
+// or "" if that was not checked.
+func (s *Summary) DNSSEC() string {
+	if s.ValidatesDNSSEC == nil {
+		return ""
+	}
+	if *s.ValidatesDNSSEC {
+		return "yes"
+	}
+	return "no"
+}
+
+// dnssecChecked returns true if any nameserver was checked for DNSSEC validation.
+func dnssecChecked(summaries []*Summary) bool {
+	for _, s := range summaries {
+		if s.ValidatesDNSSEC != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SecurityScore returns the share, from 0 to 1, of the defences against
+// spoofed answers that the nameserver has, of those checked: preserving 0x20
+// case and random source ports. It returns false if neither was checked.
+func (s *Summary) SecurityScore() (float64, bool) {
+	checked, passed := 0, 0
+	for _, c := range []*bool{s.PreservesCase, s.RandomizesPorts} {
+		if c == nil {
+			continue
+		}
+		checked += 1
+		if *c {
+			passed += 1
+		}
+	}
+	if checked == 0 {
+		return 0, false
+	}
+	return float64(passed) / float64(checked), true
+}
+
+// Security returns the security sub-score out of 100, or "" if it was not
+// checked.
+func (s *Summary) Security() string {
+	score, ok := s.SecurityScore()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.0f/100", 100*score)
+}
+
+// securityChecked returns true if any nameserver has a security sub-score.
+func securityChecked(summaries []*Summary) bool {
+	for _, s := range summaries {
+		if _, ok := s.SecurityScore(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTypes returns every record type queried, sorted, if there was more
+// than one; a single type needs no breakdown.
+func recordTypes(summaries []*Summary) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, s := range summaries {
+		for t := range s.Types {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	if len(types) < 2 {
+		return nil
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Latency returns the spread and percentiles of successful query durations.
+func (s *Summary) Latency() stats.Latency {
+	return stats.Describe(s.Durations)
+}
+
+// ms formats a duration in milliseconds.
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
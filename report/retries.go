@@ -0,0 +1,24 @@
+// part of the report package, tells lossy nameservers apart from slow ones.
+package report
+
+import (
+	"fmt"
+)
+
+// AnalyzeRetries records how many of each nameserver's queries were only
+// answered once they were sent again. Those answers came quickly when they
+// came at all: the nameserver, or the path to it, loses queries rather than
+// being slow to answer them.
+func AnalyzeRetries(summaries []*Summary) {
+	for _, s := range summaries {
+		if s.Local || s.Total() == 0 {
+			continue
+		}
+		if s.Retried == 0 {
+			s.AddFinding(ANALYSIS, "retries", "no query needed a retry", false)
+			continue
+		}
+		s.AddFinding(ANALYSIS, "retries", fmt.Sprintf("%d/%d (%.1f%%) of queries were only answered after a retry: lossy rather than slow",
+			s.Retried, s.Total(), 100*float64(s.Retried)/float64(s.Total())), true)
+	}
+}
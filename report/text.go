@@ -0,0 +1,177 @@
+// part of the report package, renders plain text tables for the CLI.
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/google/namebench/i18n"
+	"github.com/google/namebench/internal/parse"
+)
+
+// WriteText writes a table of per-nameserver results, with unsuccessful
+// queries broken down by failure class.
+func WriteText(w io.Writer, summaries []*Summary, lang string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s", i18n.T(lang, "report.nameserver"), i18n.T(lang, "report.average"),
+		i18n.T(lang, "report.connect"), i18n.T(lang, "report.amortized"), i18n.T(lang, "report.blocked"),
+		i18n.T(lang, "report.unsuccessful"))
+	for _, class := range FailureClasses {
+		fmt.Fprintf(tw, "\t%s", i18n.T(lang, "failure."+class))
+	}
+	hijack := hijackChecked(summaries)
+	if hijack {
+		fmt.Fprintf(tw, "\t%s", i18n.T(lang, "report.hijacks"))
+	}
+	dnssec := dnssecChecked(summaries)
+	if dnssec {
+		fmt.Fprintf(tw, "\t%s", i18n.T(lang, "report.dnssec"))
+	}
+	security := securityChecked(summaries)
+	if security {
+		fmt.Fprintf(tw, "\t%s", i18n.T(lang, "report.security_score"))
+	}
+	consensus := consensusChecked(summaries)
+	if consensus {
+		fmt.Fprintf(tw, "\t%s", i18n.T(lang, "report.consensus"))
+	}
+	fmt.Fprintln(tw)
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d/%d", s.Label(), ms(s.Average()), ms(s.AverageConnect()),
+			ms(s.Amortized()), s.Blocked, s.FailureCount(), s.Total())
+		for _, class := range FailureClasses {
+			fmt.Fprintf(tw, "\t%d", s.Failures[class])
+		}
+		if hijack {
+			cell := "-"
+			if h := s.Hijacks(); h != "" {
+				cell = i18n.T(lang, "report."+h)
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		if dnssec {
+			cell := "-"
+			if v := s.DNSSEC(); v != "" {
+				cell = i18n.T(lang, "report."+v)
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		if security {
+			cell := "-"
+			if v := s.Security(); v != "" {
+				cell = v
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		if consensus {
+			cell := "-"
+			if a := s.Agreement(); a != "" {
+				cell = a
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if err := writeTextLatency(w, summaries, lang); err != nil {
+		return err
+	}
+	if err := writeTextTypes(w, summaries, lang); err != nil {
+		return err
+	}
+	if err := writeTextFamilies(w, summaries, lang); err != nil {
+		return err
+	}
+	return writeTextFindings(w, summaries, lang)
+}
+
+// writeTextLatency writes the spread and percentiles of each nameserver's
+// successful queries, which the average alone hides.
+func writeTextLatency(w io.Writer, summaries []*Summary, lang string) error {
+	fmt.Fprintf(w, "\n%s\n", i18n.T(lang, "report.latency"))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i18n.T(lang, "report.nameserver"), i18n.T(lang, "report.min"),
+		i18n.T(lang, "report.median"), i18n.T(lang, "report.p90"), i18n.T(lang, "report.p99"),
+		i18n.T(lang, "report.max"), i18n.T(lang, "report.stddev"))
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		l := s.Latency()
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Label(), ms(l.Min), ms(l.Median), ms(l.P90),
+			ms(l.P99), ms(l.Max), ms(l.Stddev))
+	}
+	return tw.Flush()
+}
+
+// writeTextTypes writes each nameserver's latency and failures per record
+// type, if more than one was queried.
+func writeTextTypes(w io.Writer, summaries []*Summary, lang string) error {
+	types := recordTypes(summaries)
+	if types == nil {
+		return nil
+	}
+	fmt.Fprintf(w, "\n%s\n", i18n.T(lang, "report.record_types"))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", i18n.T(lang, "report.nameserver"), i18n.T(lang, "report.type"),
+		i18n.T(lang, "report.average"), i18n.T(lang, "report.median"), i18n.T(lang, "report.p99"),
+		i18n.T(lang, "report.unsuccessful"))
+	for _, s := range summaries {
+		if s.Local {
+			continue
+		}
+		for _, name := range types {
+			t, ok := s.Types[name]
+			if !ok {
+				continue
+			}
+			l := t.Latency()
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d/%d\n", s.Label(), name, ms(l.Mean), ms(l.Median), ms(l.P99),
+				t.Failures, t.Total)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeTextFamilies compares the average latency of each resolver over IPv4
+// and IPv6, if any was benchmarked over both.
+func writeTextFamilies(w io.Writer, summaries []*Summary, lang string) error {
+	pairs := familyPairs(summaries)
+	if pairs == nil {
+		return nil
+	}
+	fmt.Fprintf(w, "\n%s\n", i18n.T(lang, "report.families"))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", i18n.T(lang, "report.resolver"), parse.IPV4,
+		i18n.T(lang, "report.average"), parse.IPV6, i18n.T(lang, "report.average"), i18n.T(lang, "report.difference"))
+	for _, p := range pairs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Resolver, p.IPv4.Label(), ms(p.IPv4.Average()),
+			p.IPv6.Label(), ms(p.IPv6.Average()), p.Difference())
+	}
+	return tw.Flush()
+}
+
+// writeTextFindings writes a section per finding type, if any checks were run.
+func writeTextFindings(w io.Writer, summaries []*Summary, lang string) error {
+	for _, section := range groupFindings(summaries, lang) {
+		fmt.Fprintf(w, "\n%s\n", i18n.T(lang, "report."+section.Name))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range section.Rows {
+			mark := ""
+			if row.Warning {
+				mark = "!"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s%s\n", row.Nameserver, i18n.T(lang, "check."+row.Check), mark, row.Result)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
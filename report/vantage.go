@@ -0,0 +1,72 @@
+// part of the report package, compares results across vantage points.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MAX_LISTED_DOMAINS bounds how many domains a finding names.
+const MAX_LISTED_DOMAINS = 5
+
+// CompareVantage records a "vantage" finding on each summary in via, against
+// the direct summary for the same nameserver: the difference in average
+// latency, and the domains that fail directly but resolve through the proxy,
+// which points at tampering on the local network.
+func CompareVantage(direct, via []*Summary) {
+	byNameserver := make(map[string]*Summary)
+	for _, s := range direct {
+		byNameserver[s.Nameserver] = s
+	}
+	for _, v := range via {
+		d, ok := byNameserver[v.Nameserver]
+		if !ok {
+			continue
+		}
+		var tampered []string
+		for domain, class := range d.Outcomes {
+			if class != "" && class != TIMEOUT {
+				if other, ok := v.Outcomes[domain]; ok && other == "" {
+					tampered = append(tampered, domain)
+				}
+			}
+		}
+		sort.Strings(tampered)
+		result := fmt.Sprintf("avg %s vs %s direct", ms(v.Average()), ms(d.Average()))
+		if len(tampered) > 0 {
+			listed := tampered
+			if len(listed) > MAX_LISTED_DOMAINS {
+				listed = listed[:MAX_LISTED_DOMAINS]
+			}
+			result += fmt.Sprintf("; %d domains fail directly but resolve via %s: %s",
+				len(tampered), v.Vantage, strings.Join(listed, ", "))
+		}
+		v.AddFinding(SECURITY, "vantage", result, len(tampered) > 0)
+	}
+}
+
+// AnalyzeVantages records a "fastest" finding on the nameserver with the
+// lowest amortized latency from each vantage point, so that users with
+// several networks can choose a nameserver for each of them.
+func AnalyzeVantages(summaries []*Summary) {
+	best := make(map[string]*Summary)
+	count := make(map[string]int)
+	var vantages []string
+	for _, s := range summaries {
+		if s.Local || s.Vantage == "" || len(s.Durations) == 0 {
+			continue
+		}
+		if _, ok := best[s.Vantage]; !ok {
+			vantages = append(vantages, s.Vantage)
+		}
+		count[s.Vantage] += 1
+		if b, ok := best[s.Vantage]; !ok || s.Amortized() < b.Amortized() {
+			best[s.Vantage] = s
+		}
+	}
+	for _, v := range vantages {
+		s := best[v]
+		s.AddFinding(ANALYSIS, "fastest", fmt.Sprintf("fastest of %d nameservers on this network (%s)", count[v], ms(s.Amortized())), false)
+	}
+}
@@ -0,0 +1,262 @@
+// the results package stores every benchmark run in a SQLite database in the
+// data directory, so a run can be compared with the previous one of the same
+// nameservers.
+package results
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/datadir"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/report"
+)
+
+// FILE is the results database within the data directory.
+const FILE = "results.db"
+
+// SCHEMA creates the tables on first use. A run's nameserver set is its
+// sorted, comma separated nameservers, so runs of the same set can be found.
+const SCHEMA = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	nameserver_set TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_by_set ON runs (nameserver_set, time);
+CREATE TABLE IF NOT EXISTS results (
+	run_id INTEGER NOT NULL REFERENCES runs (id),
+	nameserver TEXT NOT NULL,
+	vantage TEXT NOT NULL,
+	queries INTEGER NOT NULL,
+	failures INTEGER NOT NULL,
+	average_ms REAL NOT NULL,
+	median_ms REAL NOT NULL,
+	p95_ms REAL NOT NULL,
+	p99_ms REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_by_run ON results (run_id);
+`
+
+// Result is one nameserver's results in a run. Hostnames are not kept.
+type Result struct {
+	Nameserver string
+	Vantage    string
+	Queries    int
+	Failures   int
+	AverageMs  float64
+	MedianMs   float64
+	P95Ms      float64
+	P99Ms      float64
+}
+
+// Run is a stored benchmark run.
+type Run struct {
+	ID      int64
+	Time    time.Time
+	Results []Result
+}
+
+// Store is an open results database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the results database, creating it if necessary.
+func Open() (*Store, error) {
+	path, err := datadir.Path(FILE)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(history.SQLITE_DRIVER, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(SCHEMA); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// msOf returns d in milliseconds.
+func msOf(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// NameserverSet returns the key runs of the same nameservers share: the
+// nameservers queried directly, sorted and without duplicates.
+func NameserverSet(summaries []*report.Summary) string {
+	seen := make(map[string]bool)
+	var set []string
+	for _, s := range summaries {
+		if s.Local || s.Vantage != "" || seen[s.Nameserver] {
+			continue
+		}
+		seen[s.Nameserver] = true
+		set = append(set, s.Nameserver)
+	}
+	sort.Strings(set)
+	return strings.Join(set, ",")
+}
+
+// NewRun returns a not yet stored run of the nameserver summaries.
+func NewRun(summaries []*report.Summary, t time.Time) *Run {
+	run := &Run{Time: t}
+	for _, s := range summaries {
+		if s.Local || s.Total() == 0 {
+			continue
+		}
+		l := s.Latency()
+		run.Results = append(run.Results, Result{
+			Nameserver: s.Nameserver,
+			Vantage:    s.Vantage,
+			Queries:    s.Total(),
+			Failures:   s.FailureCount(),
+			AverageMs:  msOf(l.Mean),
+			MedianMs:   msOf(l.Median),
+			P95Ms:      msOf(s.Percentile(95)),
+			P99Ms:      msOf(l.P99),
+		})
+	}
+	return run
+}
+
+// Save stores a run's nameserver summaries, returning the run's ID.
+func (s *Store) Save(summaries []*report.Summary, t time.Time) (id int64, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	res, err := tx.Exec("INSERT INTO runs (time, nameserver_set) VALUES (?, ?)", t.UnixNano(), NameserverSet(summaries))
+	if err != nil {
+		return 0, err
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+	for _, r := range NewRun(summaries, t).Results {
+		_, err = tx.Exec("INSERT INTO results VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", id, r.Nameserver, r.Vantage,
+			r.Queries, r.Failures, r.AverageMs, r.MedianMs, r.P95Ms, r.P99Ms)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// Load returns the run with the given ID.
+func (s *Store) Load(id int64) (*Run, error) {
+	run := &Run{ID: id}
+	var nanos int64
+	if err := s.db.QueryRow("SELECT time FROM runs WHERE id = ?", id).Scan(&nanos); err != nil {
+		return nil, err
+	}
+	run.Time = time.Unix(0, nanos)
+	rows, err := s.db.Query(`SELECT nameserver, vantage, queries, failures, average_ms, median_ms, p95_ms, p99_ms
+		FROM results WHERE run_id = ? ORDER BY rowid`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Result
+		if err := rows.Scan(&r.Nameserver, &r.Vantage, &r.Queries, &r.Failures, &r.AverageMs, &r.MedianMs, &r.P95Ms, &r.P99Ms); err != nil {
+			return nil, err
+		}
+		run.Results = append(run.Results, r)
+	}
+	return run, rows.Err()
+}
+
+// Last returns the most recent run of the nameserver set, or nil if there is none.
+func (s *Store) Last(set string) (*Run, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT id FROM runs WHERE nameserver_set = ? ORDER BY time DESC LIMIT 1", set).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Load(id)
+}
+
+// All returns every stored run, oldest first.
+func (s *Store) All() (runs []*Run, err error) {
+	rows, err := s.db.Query(`SELECT runs.id, runs.time, nameserver, vantage, queries, failures, average_ms, median_ms, p95_ms, p99_ms
+		FROM runs JOIN results ON results.run_id = runs.id ORDER BY runs.time, runs.id, results.rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, nanos int64
+		var r Result
+		if err := rows.Scan(&id, &nanos, &r.Nameserver, &r.Vantage, &r.Queries, &r.Failures, &r.AverageMs, &r.MedianMs, &r.P95Ms, &r.P99Ms); err != nil {
+			return nil, err
+		}
+		if len(runs) == 0 || runs[len(runs)-1].ID != id {
+			runs = append(runs, &Run{ID: id, Time: time.Unix(0, nanos)})
+		}
+		run := runs[len(runs)-1]
+		run.Results = append(run.Results, r)
+	}
+	return runs, rows.Err()
+}
+
+// Delta is how a nameserver's results changed since a previous run.
+// Positive latency deltas are slower.
+type Delta struct {
+	Nameserver string
+	Vantage    string
+	Previous   Result
+	AverageMs  float64
+	P95Ms      float64
+	// FailureRate is the change in the share of failed queries.
+	FailureRate float64
+}
+
+// failureRate returns the share of r's queries that failed.
+func (r Result) failureRate() float64 {
+	if r.Queries == 0 {
+		return 0
+	}
+	return float64(r.Failures) / float64(r.Queries)
+}
+
+// Compare returns the delta of each result in current that previous also has.
+func Compare(previous, current *Run) (deltas []Delta) {
+	before := make(map[string]Result)
+	for _, r := range previous.Results {
+		before[r.Nameserver+" "+r.Vantage] = r
+	}
+	for _, r := range current.Results {
+		p, ok := before[r.Nameserver+" "+r.Vantage]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, Delta{
+			Nameserver:  r.Nameserver,
+			Vantage:     r.Vantage,
+			Previous:    p,
+			AverageMs:   r.AverageMs - p.AverageMs,
+			P95Ms:       r.P95Ms - p.P95Ms,
+			FailureRate: r.failureRate() - p.failureRate(),
+		})
+	}
+	return deltas
+}
@@ -0,0 +1,160 @@
+// the runs package breaks latency down by when the runs stored in the
+// results database were made.
+package runs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/results"
+)
+
+// MIN_HOURS is how many distinct hours of the day a nameserver needs runs in
+// before its latency can be broken down by time.
+const MIN_HOURS = 3
+
+// Evening peak hours, in local time: [PEAK_START, PEAK_END).
+const (
+	PEAK_START = 19
+	PEAK_END   = 24
+)
+
+// PEAK_SLOWDOWN is how much slower than the rest of the day a nameserver
+// must be during the evening peak to be flagged.
+const PEAK_SLOWDOWN = 1.5
+
+// TimeOfDay is a nameserver's average latency by hour of day and day of week.
+type TimeOfDay struct {
+	Runs    int
+	Hours   [24]bucket
+	Days    [7]bucket
+	Peak    bucket
+	OffPeak bucket
+}
+
+// bucket accumulates the average latency of runs within a time slot.
+type bucket struct {
+	total float64
+	n     int
+}
+
+func (b *bucket) add(ms float64) {
+	b.total += ms
+	b.n += 1
+}
+
+// Mean returns the mean latency in ms, or 0 with no runs.
+func (b bucket) Mean() float64 {
+	if b.n == 0 {
+		return 0
+	}
+	return b.total / float64(b.n)
+}
+
+// ByTimeOfDay groups the runs' results by nameserver and vantage point,
+// bucketing their average latency by the local time each run was made.
+func ByTimeOfDay(runs []*results.Run) map[string]*TimeOfDay {
+	byKey := make(map[string]*TimeOfDay)
+	for _, run := range runs {
+		local := run.Time.Local()
+		for _, r := range run.Results {
+			if r.Queries == r.Failures {
+				continue
+			}
+			key := label(r.Nameserver, r.Vantage)
+			t, ok := byKey[key]
+			if !ok {
+				t = &TimeOfDay{}
+				byKey[key] = t
+			}
+			t.Runs += 1
+			t.Hours[local.Hour()].add(r.AverageMs)
+			t.Days[local.Weekday()].add(r.AverageMs)
+			if local.Hour() >= PEAK_START && local.Hour() < PEAK_END {
+				t.Peak.add(r.AverageMs)
+			} else {
+				t.OffPeak.add(r.AverageMs)
+			}
+		}
+	}
+	return byKey
+}
+
+// label matches report.Summary.Label.
+func label(nameserver, vantage string) string {
+	if vantage != "" {
+		return nameserver + " via " + vantage
+	}
+	return nameserver
+}
+
+// hours returns the number of hours of the day with runs.
+func (t *TimeOfDay) hours() (n int) {
+	for _, b := range t.Hours {
+		if b.n > 0 {
+			n += 1
+		}
+	}
+	return
+}
+
+// extremes returns the indexes of the slowest and fastest buckets with runs.
+func extremes(buckets []bucket) (slowest, fastest int) {
+	slowest, fastest = -1, -1
+	for i, b := range buckets {
+		if b.n == 0 {
+			continue
+		}
+		if slowest < 0 || b.Mean() > buckets[slowest].Mean() {
+			slowest = i
+		}
+		if fastest < 0 || b.Mean() < buckets[fastest].Mean() {
+			fastest = i
+		}
+	}
+	return
+}
+
+// Describe summarizes the breakdown, returning true if the nameserver slows
+// down by more than PEAK_SLOWDOWN in the evening peak.
+func (t *TimeOfDay) Describe() (string, bool) {
+	if t.hours() < MIN_HOURS {
+		return fmt.Sprintf("%d runs in %d hours of the day, need %d hours", t.Runs, t.hours(), MIN_HOURS), false
+	}
+	slowHour, fastHour := extremes(t.Hours[:])
+	result := fmt.Sprintf("%d runs: slowest %02d:00 (%.2fms), fastest %02d:00 (%.2fms)",
+		t.Runs, slowHour, t.Hours[slowHour].Mean(), fastHour, t.Hours[fastHour].Mean())
+	if slowDay, fastDay := extremes(t.Days[:]); t.Days[slowDay].Mean() > t.Days[fastDay].Mean() {
+		result += fmt.Sprintf(", slowest day %s (%.2fms)", time.Weekday(slowDay), t.Days[slowDay].Mean())
+	}
+	degraded := false
+	if t.Peak.n > 0 && t.OffPeak.n > 0 {
+		ratio := t.Peak.Mean() / t.OffPeak.Mean()
+		result += fmt.Sprintf("; evening peak %.1fx the rest of the day", ratio)
+		degraded = ratio > PEAK_SLOWDOWN
+	}
+	return result, degraded
+}
+
+// AnalyzeTimeOfDay records a "timeofday" finding on each summary with
+// stored runs, from the results database.
+func AnalyzeTimeOfDay(summaries []*report.Summary) error {
+	store, err := results.Open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	runs, err := store.All()
+	if err != nil {
+		return err
+	}
+	byKey := ByTimeOfDay(runs)
+	for _, s := range summaries {
+		if t, ok := byKey[s.Label()]; ok {
+			result, degraded := t.Describe()
+			s.AddFinding(report.ANALYSIS, "timeofday", result, degraded)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/namebench/history"
+)
+
+var seed = flag.Int64("seed", 0,
+	"Seed for picking hostnames and ordering queries, so that runs with the same seed send the same workload, e.g. before and after changing resolvers; 0 for a different one every run (cli mode)")
+
+// seedWorkload makes the hostnames picked repeatable with -seed. Random
+// subdomains for cache misses and security checks are never seeded, as
+// repeating them would find them cached.
+func seedWorkload() {
+	if *seed != 0 {
+		log.Printf("Picking the workload with seed %d", *seed)
+		history.Seed(*seed)
+	}
+}
+
+// workloadRand returns a generator for ordering queries, seeded by -seed or
+// else from the clock.
+func workloadRand() *rand.Rand {
+	if *seed != 0 {
+		return rand.New(rand.NewSource(*seed))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
@@ -0,0 +1,183 @@
+// the settings package reads namebench.yaml files, which hold the flags of a
+// benchmark run so they need not be typed each time. Only the part of YAML
+// such files need is understood: top-level "name: value" settings, lists
+// (block or [inline]) and, under nameservers, named groups of nameservers.
+package settings
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// DEFAULT_FILE is where "namebench config init" writes a new file.
+const DEFAULT_FILE = "namebench.yaml"
+
+// NAMESERVERS is the setting that may hold groups instead of a list.
+const NAMESERVERS = "nameservers"
+
+// File is a parsed settings file.
+type File struct {
+	Path string
+	// Settings holds values by flag name; lists are joined with commas.
+	Settings map[string]string
+	// Groups holds the nameservers of each group, named in GroupNames in file order.
+	Groups     map[string][]string
+	GroupNames []string
+}
+
+// Load reads and parses a settings file.
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data), path)
+}
+
+// Parse parses the text of a settings file; path is only used in errors.
+func Parse(text string, path string) (*File, error) {
+	f := &File{Path: path, Settings: make(map[string]string), Groups: make(map[string][]string)}
+	lists := make(map[string][]string)
+	key, group := "", ""
+	groupIndent := 0
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		raw := stripComment(scanner.Text())
+		item := strings.TrimSpace(raw)
+		if item == "" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		if strings.Contains(raw[:indent], "\t") {
+			return nil, fmt.Errorf("%s:%d: indent with spaces, not tabs", path, line)
+		}
+		if indent == 0 {
+			name, value, ok := splitKey(item)
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected \"name: value\"", path, line)
+			}
+			key, group = name, ""
+			if _, dup := f.Settings[key]; dup || lists[key] != nil {
+				return nil, fmt.Errorf("%s:%d: %s is set twice", path, line, key)
+			}
+			if value != "" {
+				f.Settings[key] = strings.Join(parseValue(value), ",")
+			}
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: indented line outside a setting", path, line)
+		}
+		if item == "-" || strings.HasPrefix(item, "- ") {
+			value := unquote(strings.TrimSpace(item[1:]))
+			if group != "" && indent > groupIndent {
+				f.Groups[group] = append(f.Groups[group], value)
+			} else if group != "" {
+				return nil, fmt.Errorf("%s:%d: %s mixes a list with groups", path, line, key)
+			} else {
+				lists[key] = append(lists[key], value)
+			}
+			continue
+		}
+		if key != NAMESERVERS {
+			return nil, fmt.Errorf("%s:%d: only %s may hold groups", path, line, NAMESERVERS)
+		}
+		if lists[key] != nil {
+			return nil, fmt.Errorf("%s:%d: %s mixes a list with groups", path, line, key)
+		}
+		name, value, ok := splitKey(item)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"group:\" or \"- nameserver\"", path, line)
+		}
+		if _, dup := f.Groups[name]; dup {
+			return nil, fmt.Errorf("%s:%d: group %s is defined twice", path, line, name)
+		}
+		group, groupIndent = name, indent
+		f.GroupNames = append(f.GroupNames, name)
+		f.Groups[name] = nil
+		if value != "" {
+			f.Groups[name] = parseValue(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for k, values := range lists {
+		f.Settings[k] = strings.Join(values, ",")
+	}
+	return f, nil
+}
+
+// Nameservers returns the nameservers of the named groups, or of every
+// group if none are named, in file order.
+func (f *File) Nameservers(groups []string) ([]string, error) {
+	if len(groups) == 0 {
+		groups = f.GroupNames
+	}
+	var servers []string
+	for _, g := range groups {
+		members, ok := f.Groups[g]
+		if !ok {
+			return nil, fmt.Errorf("%s has no nameserver group %q, want one of %s", f.Path, g, strings.Join(f.GroupNames, ", "))
+		}
+		servers = append(servers, members...)
+	}
+	return servers, nil
+}
+
+// splitKey splits "name: value", accepting dashes in the name for the
+// underscores flag names use.
+func splitKey(item string) (name string, value string, ok bool) {
+	i := strings.Index(item, ":")
+	if i < 1 {
+		return "", "", false
+	}
+	name = strings.Replace(strings.TrimSpace(item[:i]), "-", "_", -1)
+	return name, strings.TrimSpace(item[i+1:]), true
+}
+
+// parseValue returns the items of an [inline, list], or the single scalar.
+func parseValue(value string) (values []string) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return []string{unquote(value)}
+	}
+	for _, v := range strings.Split(value[1:len(value)-1], ",") {
+		if v = unquote(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// unquote removes matching single or double quotes around a scalar.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// stripComment removes a # comment that is not inside quotes. As in YAML,
+// a # only starts a comment at the start of a line or after whitespace, so
+// URLs with fragments survive.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
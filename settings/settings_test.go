@@ -0,0 +1,103 @@
+package settings
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		settings   map[string]string
+		groups     map[string][]string
+		groupNames []string
+	}{
+		{"empty", "", map[string]string{}, map[string][]string{}, nil},
+		{"scalars", "count: 100\nprotocol: tcp\n", map[string]string{"count": "100", "protocol": "tcp"}, map[string][]string{}, nil},
+		{"dashes", "record-type: AAAA\n", map[string]string{"record_type": "AAAA"}, map[string][]string{}, nil},
+		{"double quoted", `domains: "a b.txt"`, map[string]string{"domains": "a b.txt"}, map[string][]string{}, nil},
+		{"single quoted", "domains: 'a.txt'", map[string]string{"domains": "a.txt"}, map[string][]string{}, nil},
+		{"unmatched quote", `domains: "a.txt`, map[string]string{"domains": `"a.txt`}, map[string][]string{}, nil},
+		{"inline list", "record_type: [A, 'AAAA', \"MX\"]", map[string]string{"record_type": "A,AAAA,MX"}, map[string][]string{}, nil},
+		{"block list", "record_type:\n  - A\n  - \"AAAA\"\n", map[string]string{"record_type": "A,AAAA"}, map[string][]string{}, nil},
+		{"comments", "# settings\ncount: 5 # five\n  \n", map[string]string{"count": "5"}, map[string][]string{}, nil},
+		{"hash in a value", "results_server: https://example.com/#top", map[string]string{"results_server": "https://example.com/#top"}, map[string][]string{}, nil},
+		{"hash in quotes", `sentinels: "a #b"`, map[string]string{"sentinels": "a #b"}, map[string][]string{}, nil},
+		{"nameserver list", "nameservers:\n  - 1.1.1.1\n  - 8.8.8.8\n", map[string]string{"nameservers": "1.1.1.1,8.8.8.8"}, map[string][]string{}, nil},
+		{
+			"nameserver groups",
+			"nameservers:\n  home:\n    - 192.168.1.1\n  public: [1.1.1.1, 9.9.9.9]\n  empty:\ncount: 10\n",
+			map[string]string{"count": "10"},
+			map[string][]string{"home": {"192.168.1.1"}, "public": {"1.1.1.1", "9.9.9.9"}, "empty": nil},
+			[]string{"home", "public", "empty"},
+		},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.text, "test.yaml")
+		if err != nil {
+			t.Errorf("%s: Parse(%q) failed: %s", tt.name, tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.Settings, tt.settings) {
+			t.Errorf("%s: Parse(%q) settings = %v, want %v", tt.name, tt.text, f.Settings, tt.settings)
+		}
+		if !reflect.DeepEqual(f.Groups, tt.groups) {
+			t.Errorf("%s: Parse(%q) groups = %v, want %v", tt.name, tt.text, f.Groups, tt.groups)
+		}
+		if !reflect.DeepEqual(f.GroupNames, tt.groupNames) {
+			t.Errorf("%s: Parse(%q) group names = %v, want %v", tt.name, tt.text, f.GroupNames, tt.groupNames)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"no colon", "count 5", "test.yaml:1: expected \"name: value\""},
+		{"no name", ": 5", "test.yaml:1: expected \"name: value\""},
+		{"tab indent", "record_type:\n\t- A", "test.yaml:2: indent with spaces"},
+		{"set twice", "count: 5\ncount: 6", "test.yaml:2: count is set twice"},
+		{"list set twice", "record_type:\n  - A\nrecord_type: MX", "test.yaml:3: record_type is set twice"},
+		{"indented first", "  - A", "test.yaml:1: indented line outside a setting"},
+		{"groups elsewhere", "record_type:\n  main: A", "test.yaml:2: only nameservers may hold groups"},
+		{"list then group", "nameservers:\n  - 1.1.1.1\n  home:", "test.yaml:3: nameservers mixes a list with groups"},
+		{"group then list", "nameservers:\n  home:\n    - 1.1.1.1\n  - 8.8.8.8", "test.yaml:4: nameservers mixes a list with groups"},
+		{"group twice", "nameservers:\n  home:\n  home:", "test.yaml:3: group home is defined twice"},
+		{"not a group", "nameservers:\n  1.1.1.1", "test.yaml:2: expected \"group:\" or \"- nameserver\""},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.text, "test.yaml")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: Parse(%q) error = %v, want %q", tt.name, tt.text, err, tt.want)
+		}
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	f, err := Parse("nameservers:\n  home: [192.168.1.1]\n  public: [1.1.1.1, 9.9.9.9]\n", "test.yaml")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	tests := []struct {
+		name   string
+		groups []string
+		want   []string
+		err    bool
+	}{
+		{"every group", nil, []string{"192.168.1.1", "1.1.1.1", "9.9.9.9"}, false},
+		{"one group", []string{"public"}, []string{"1.1.1.1", "9.9.9.9"}, false},
+		{"in the order named", []string{"public", "home"}, []string{"1.1.1.1", "9.9.9.9", "192.168.1.1"}, false},
+		{"unknown group", []string{"work"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := f.Nameservers(tt.groups)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Nameservers(%v) = %v, %v, want %v", tt.name, tt.groups, got, err, tt.want)
+		}
+	}
+}
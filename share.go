@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/asn"
+	"github.com/google/namebench/portal"
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/ui"
+)
+
+var results_server = flag.String("results_server", "",
+	"URL of a results service to compare with other users in your country and network (cli mode)")
+var upload_results = flag.Bool("upload_results", false,
+	"Share this run's per-nameserver summary with -results_server, leaving out hostnames and local or private resolvers")
+
+// SHARE_CONSENT is the preferences consent key for sharing results.
+const SHARE_CONSENT = "share_results"
+
+// shareConsent returns true if the user opted in to sharing results, on the
+// command line or in the UI preferences.
+func shareConsent() bool {
+	if *upload_results {
+		return true
+	}
+	prefs, err := ui.LoadPreferences()
+	return err == nil && prefs.Consent[SHARE_CONSENT]
+}
+
+// CGNAT is the shared address space of carrier-grade NAT, RFC 6598.
+var CGNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// INTERNAL_SUFFIXES end hostnames that only resolve inside a network.
+var INTERNAL_SUFFIXES = []string{".local", ".lan", ".home", ".internal", ".intranet", ".corp", ".home.arpa"}
+
+// publicNameserver returns true if ns is reachable from the Internet, and so
+// says nothing about the user's network when shared: not a loopback,
+// RFC 1918, link-local, CGNAT or unique local address, nor an internal name.
+func publicNameserver(ns string) bool {
+	host := ns
+	if u, err := url.Parse(ns); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := net.ParseIP(host); ip != nil {
+		return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || CGNAT.Contains(ip))
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range INTERNAL_SUFFIXES {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// userASN returns the AS of the user's public IP, or 0 without -asn_source.
+func userASN() uint {
+	if *asn_source == "" {
+		return 0
+	}
+	lookup, err := asn.Open(*asn_source)
+	if err != nil {
+		return 0
+	}
+	ip, err := publicIP()
+	if err != nil {
+		return 0
+	}
+	info, err := lookup.Lookup(ip)
+	if err != nil {
+		return 0
+	}
+	return info.Number
+}
+
+// shareResults uploads the summaries if the user opted in, and records a
+// "peers" finding with what other users in the same network or country see.
+// Local resolvers are left out of both: their addresses describe the user's
+// network, and nobody else could compare with them.
+func shareResults(summaries []*report.Summary) error {
+	var public []*report.Summary
+	for _, s := range summaries {
+		if publicNameserver(s.Nameserver) {
+			public = append(public, s)
+		} else {
+			log.Printf("Not sharing %s, which is not a public resolver", s.Nameserver)
+		}
+	}
+	summaries = public
+	if len(summaries) == 0 {
+		return nil
+	}
+	cc, err := userCountry()
+	if err != nil {
+		log.Printf("Not comparing by country: %s", err)
+	}
+	number := userASN()
+	client := portal.NewClient(*results_server)
+
+	if shareConsent() {
+		s := portal.Submission{Country: cc, ASN: number}
+		for _, summary := range summaries {
+			s.Resolvers = append(s.Resolvers, portal.Resolver{
+				Nameserver: summary.Nameserver,
+				Queries:    summary.Total(),
+				Failures:   summary.FailureCount(),
+				AverageMs:  float64(summary.Average()) / float64(time.Millisecond),
+			})
+		}
+		if err := client.Upload(s); err != nil {
+			return err
+		}
+		log.Printf("Shared results with %s", *results_server)
+	}
+
+	var nameservers []string
+	for _, s := range summaries {
+		nameservers = append(nameservers, s.Nameserver)
+	}
+	aggregates, err := client.Aggregates(nameservers, cc, number)
+	if err != nil {
+		return err
+	}
+	// Prefer the network's numbers to the whole country's.
+	best := make(map[string]portal.Aggregate)
+	for _, a := range aggregates {
+		if prev, ok := best[a.Nameserver]; !ok || prev.ASN == 0 && a.ASN != 0 {
+			best[a.Nameserver] = a
+		}
+	}
+	for _, s := range summaries {
+		a, ok := best[s.Nameserver]
+		if !ok {
+			continue
+		}
+		where := "in " + a.Country
+		if a.ASN != 0 {
+			where = fmt.Sprintf("on AS%d", a.ASN)
+		}
+		s.AddFinding(report.ANALYSIS, "peers",
+			fmt.Sprintf("%d other users %s see %.2fms", a.Users, where, a.MedianMs), false)
+	}
+	return nil
+}
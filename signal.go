@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/google/namebench/report"
+)
+
+// INTERRUPTED_EXIT is the exit status after a second Ctrl-C, as shells
+// report a process killed by SIGINT.
+const INTERRUPTED_EXIT = 130
+
+var (
+	exitMu   sync.Mutex
+	exitHook func()
+)
+
+// onExit sets a function to run before a second interrupt exits, e.g. one
+// giving back the terminal -tui took over.
+func onExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHook = f
+}
+
+// interruptContext returns a context cancelled by the first Ctrl-C (or
+// SIGTERM), so that the benchmark stops and reports what it has. A second
+// one exits at once. Call stop to stop listening for signals.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		log.Printf("Interrupted, stopping to report the results so far; interrupt again to quit")
+		cancel()
+		select {
+		case <-signals:
+			exitMu.Lock()
+			if exitHook != nil {
+				exitHook()
+			}
+			log.Printf("Interrupted again, quitting")
+			os.Exit(INTERRUPTED_EXIT)
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// interrupted reports whether err is from an interrupted benchmark, whose
+// results so far are kept.
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
+
+// markInterrupted records a finding on each summary with fewer than
+// expected queries, which the interruption cut short.
+func markInterrupted(summaries []*report.Summary, expected int) {
+	for _, s := range summaries {
+		if s.Total() < expected {
+			s.AddFinding(report.ANALYSIS, "interrupted", fmt.Sprintf("interrupted after %d of %d queries", s.Total(), expected), true)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// the sources package finds the resolvers this computer is currently
+// configured to use, so they can be benchmarked alongside the alternatives.
+package sources
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/namebench/internal/parse"
+)
+
+// SYSTEM_LABEL names the system resolvers in reports.
+const SYSTEM_LABEL = "SYS-current"
+
+// RESOLV_CONF lists the system resolvers on Linux and other Unix systems.
+const RESOLV_CONF = "/etc/resolv.conf"
+
+// WINDOWS_PLACEHOLDERS is the prefix of the site-local addresses Windows
+// lists as IPv6 DNS servers on interfaces that have none configured.
+const WINDOWS_PLACEHOLDERS = "fec0:0:0:ffff::"
+
+// System returns the resolvers this computer is configured to use, as
+// host:port, in the order they are tried and without duplicates.
+func System() ([]string, error) {
+	ips, err := systemResolvers()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var servers []string
+	for _, ip := range ips {
+		if !usable(ip) {
+			continue
+		}
+		ns, err := parse.Nameserver(ip.String())
+		if err != nil || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		servers = append(servers, ns)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no system resolvers found")
+	}
+	return servers, nil
+}
+
+// usable returns false for addresses that can not be queried without an
+// interface (link-local ones) and for Windows' placeholder DNS servers.
+func usable(ip net.IP) bool {
+	if ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return false
+	}
+	return !strings.HasPrefix(ip.String(), WINDOWS_PLACEHOLDERS)
+}
+
+// parseResolvConf returns the nameserver addresses in a resolv.conf file,
+// skipping any it can not parse.
+func parseResolvConf(text string) (ips []net.IP) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		// Scoped addresses (fe80::1%en0) lose their zone, and are then
+		// dropped as link-local.
+		if ip := net.ParseIP(strings.SplitN(fields[1], "%", 2)[0]); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
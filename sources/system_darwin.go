@@ -0,0 +1,57 @@
+// part of the sources package, asks macOS for its resolvers with scutil.
+package sources
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// systemResolvers returns the nameservers of the default resolvers scutil
+// reports, falling back to RESOLV_CONF, which macOS keeps only for
+// compatibility and may be stale.
+func systemResolvers() ([]net.IP, error) {
+	out, err := exec.Command("scutil", "--dns").Output()
+	if err == nil {
+		if ips := parseScutil(string(out)); len(ips) > 0 {
+			return ips, nil
+		}
+	}
+	data, rerr := ioutil.ReadFile(RESOLV_CONF)
+	if rerr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, rerr
+	}
+	return parseResolvConf(string(data)), nil
+}
+
+// parseScutil returns the nameservers in the "DNS configuration" section of
+// scutil --dns output, leaving out the scoped and per-domain resolvers,
+// such as those for .local names.
+func parseScutil(text string) (ips []net.IP) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	perDomain := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "DNS configuration (for scoped queries)"):
+			return ips
+		case strings.HasPrefix(line, "resolver #"):
+			perDomain = false
+		case strings.HasPrefix(line, "domain"):
+			perDomain = true
+		case strings.HasPrefix(line, "nameserver[") && !perDomain:
+			if i := strings.Index(line, ":"); i != -1 {
+				value := strings.SplitN(strings.TrimSpace(line[i+1:]), "%", 2)[0]
+				if ip := net.ParseIP(value); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+	return ips
+}
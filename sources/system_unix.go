@@ -0,0 +1,18 @@
+//go:build !windows && !darwin
+
+// part of the sources package, reads the system resolvers from resolv.conf.
+package sources
+
+import (
+	"io/ioutil"
+	"net"
+)
+
+// systemResolvers returns the nameservers listed in RESOLV_CONF.
+func systemResolvers() ([]net.IP, error) {
+	data, err := ioutil.ReadFile(RESOLV_CONF)
+	if err != nil {
+		return nil, err
+	}
+	return parseResolvConf(string(data)), nil
+}
@@ -0,0 +1,43 @@
+// part of the sources package, asks Windows for each adapter's DNS servers.
+package sources
+
+import (
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ADAPTERS_BUFFER is the initial GetAdaptersAddresses buffer size Microsoft
+// recommends; it grows if there are more adapters.
+const ADAPTERS_BUFFER = 15000
+
+// systemResolvers returns the DNS servers of each adapter that is up.
+func systemResolvers() ([]net.IP, error) {
+	size := uint32(ADAPTERS_BUFFER)
+	flags := uint32(windows.GAA_FLAG_SKIP_UNICAST | windows.GAA_FLAG_SKIP_ANYCAST | windows.GAA_FLAG_SKIP_MULTICAST)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, flags, 0, first, &size)
+		if err == windows.ERROR_BUFFER_OVERFLOW {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("GetAdaptersAddresses", err)
+		}
+		var ips []net.IP
+		for a := first; a != nil; a = a.Next {
+			if a.OperStatus != windows.IfOperStatusUp {
+				continue
+			}
+			for d := a.FirstDnsServerAddress; d != nil; d = d.Next {
+				if ip := d.Address.IP(); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+		return ips, nil
+	}
+}
@@ -0,0 +1,124 @@
+// the stats package summarizes query latencies, so that the CLI, reports and
+// monitor describe a nameserver with the same numbers.
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Latency describes a set of query durations. Averages hide tail latency,
+// so it carries the spread and percentiles as well.
+type Latency struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	Median time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Stddev time.Duration
+}
+
+// Describe returns the statistics of durations, all zero if there are none.
+func Describe(durations []time.Duration) Latency {
+	if len(durations) == 0 {
+		return Latency{}
+	}
+	sorted := Sorted(durations)
+	return Latency{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   Mean(sorted),
+		Median: Median(sorted),
+		P90:    Percentile(sorted, 90),
+		P99:    Percentile(sorted, 99),
+		Stddev: Stddev(sorted),
+	}
+}
+
+// Sorted returns a sorted copy of durations.
+func Sorted(durations []time.Duration) []time.Duration {
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// Percentile returns the p-th percentile (0-100) of sorted durations,
+// interpolating between the two nearest samples.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	i := int(rank)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + time.Duration((rank-float64(i))*float64(sorted[i+1]-sorted[i]))
+}
+
+// Median returns the median of sorted durations, averaging the middle two
+// of an even number.
+func Median(sorted []time.Duration) time.Duration {
+	return Percentile(sorted, 50)
+}
+
+// MedianFloat returns the median of values, such as distances, that are
+// not durations, or 0 if there are none.
+func MedianFloat(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// IQR returns the interquartile range of sorted durations: the spread of
+// the middle half, which unlike Stddev ignores a few outliers.
+func IQR(sorted []time.Duration) time.Duration {
+	return Percentile(sorted, 75) - Percentile(sorted, 25)
+}
+
+// Mean returns the average of durations, or 0 if there are none.
+func Mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// Stddev returns the population standard deviation of durations.
+func Stddev(durations []time.Duration) time.Duration {
+	if len(durations) < 2 {
+		return 0
+	}
+	mean := float64(Mean(durations))
+	var sum float64
+	for _, d := range durations {
+		diff := float64(d) - mean
+		sum += diff * diff
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(durations))))
+}
+
+// Min returns the shortest of durations, or 0 if there are none.
+func Min(durations []time.Duration) (min time.Duration) {
+	for i, d := range durations {
+		if i == 0 || d < min {
+			min = d
+		}
+	}
+	return min
+}
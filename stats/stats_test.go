@@ -0,0 +1,115 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func ms(values ...int) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, v := range values {
+		durations[i] = time.Duration(v) * time.Millisecond
+	}
+	return durations
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 50, 0},
+		{"p0", ms(10, 20, 30, 40), 0, 10 * time.Millisecond},
+		{"p100", ms(10, 20, 30, 40), 100, 40 * time.Millisecond},
+		{"one sample p0", ms(7), 0, 7 * time.Millisecond},
+		{"one sample p50", ms(7), 50, 7 * time.Millisecond},
+		{"one sample p100", ms(7), 100, 7 * time.Millisecond},
+		{"exact sample", ms(10, 20, 30, 40, 50), 25, 20 * time.Millisecond},
+		{"interpolated", ms(10, 20, 30, 40), 50, 25 * time.Millisecond},
+		{"interpolated p90", ms(0, 100), 90, 90 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("%s: Percentile(%v, %v) = %v, want %v", tt.name, tt.sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		want   time.Duration
+	}{
+		{"empty", nil, 0},
+		{"odd", ms(1, 2, 9), 2 * time.Millisecond},
+		{"even", ms(1, 2, 4, 9), 3 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Median(tt.sorted); got != tt.want {
+			t.Errorf("%s: Median(%v) = %v, want %v", tt.name, tt.sorted, got, tt.want)
+		}
+	}
+}
+
+func TestMedianFloat(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"odd", []float64{9, 1, 2}, 2},
+		{"even", []float64{9, 4, 1, 2}, 3},
+	}
+	for _, tt := range tests {
+		if got := MedianFloat(tt.values); got != tt.want {
+			t.Errorf("%s: MedianFloat(%v) = %v, want %v", tt.name, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestStddev(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{"empty", nil, 0},
+		{"one sample", ms(42), 0},
+		{"constant", ms(5, 5, 5), 0},
+		{"spread", ms(2, 4, 4, 4, 5, 5, 7, 9), 2 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Stddev(tt.durations); got != tt.want {
+			t.Errorf("%s: Stddev(%v) = %v, want %v", tt.name, tt.durations, got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      Latency
+	}{
+		{"empty", nil, Latency{}},
+		{"unsorted", ms(30, 10, 20), Latency{
+			Count:  3,
+			Min:    10 * time.Millisecond,
+			Max:    30 * time.Millisecond,
+			Mean:   20 * time.Millisecond,
+			Median: 20 * time.Millisecond,
+			P90:    28 * time.Millisecond,
+			P99:    29*time.Millisecond + 800*time.Microsecond,
+			Stddev: 8164965 * time.Nanosecond,
+		}},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.durations); got != tt.want {
+			t.Errorf("%s: Describe(%v) = %+v, want %+v", tt.name, tt.durations, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/sources"
+)
+
+// -system_resolvers is off by default: the system resolver is often a local
+// stub, such as systemd-resolved's 127.0.0.53, which would change the set of
+// nameservers -compare_last matches runs by as soon as apply takes effect.
+var system_resolvers = flag.Bool("system_resolvers", false,
+	"Also benchmark the resolvers this computer currently uses, labelled "+sources.SYSTEM_LABEL+", to compare them with the alternatives (cli mode)")
+
+// withSystemResolvers adds the system resolvers missing from servers, and
+// returns the set of system resolvers. Those the managed configuration does
+// not allow are left out rather than failing the run.
+func withSystemResolvers(servers []string) ([]string, map[string]bool) {
+	found, err := sources.System()
+	if err != nil {
+		log.Printf("System resolvers unavailable: %s", err)
+		return servers, nil
+	}
+	listed := make(map[string]bool)
+	for _, ns := range servers {
+		listed[ns] = true
+	}
+	system := make(map[string]bool)
+	for _, ns := range found {
+		if err := checkAllowed([]string{ns}); err != nil {
+			log.Printf("Skipping system resolver: %s", err)
+			continue
+		}
+		system[ns] = true
+		if !listed[ns] {
+			servers = append(servers, ns)
+		}
+	}
+	log.Printf("System resolvers: %v", found)
+	return servers, system
+}
+
+// labelSystem marks the summaries of system resolvers with SYSTEM_LABEL.
+func labelSystem(summaries []*report.Summary, system map[string]bool) {
+	for _, s := range summaries {
+		if system[s.Nameserver] {
+			s.Alias = sources.SYSTEM_LABEL
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/namebench/report"
+	"github.com/google/namebench/tui"
+)
+
+var show_tui = flag.Bool("tui", false,
+	"Show live progress in the terminal, where arrow keys select a nameserver, a aborts it, s sorts and q aborts them all; Ctrl-C stops and reports as without it (cli mode)")
+
+// startDisplay returns the -tui display for a benchmark of servers, each
+// expecting total results, or nil to log progress as usual, e.g. when stdout
+// is not a terminal.
+func startDisplay(servers []string, total int) *tui.Display {
+	if !*show_tui {
+		return nil
+	}
+	if !tui.IsTerminal(os.Stdout) {
+		log.Printf("stdout is not a terminal, logging progress instead of -tui")
+		return nil
+	}
+	d, err := tui.New(os.Stdout, os.Stdin, servers, total)
+	if err != nil {
+		log.Printf("Terminal UI unavailable, logging progress instead: %s", err)
+		return nil
+	}
+	onExit(d.Close)
+	return d
+}
+
+// withoutAborted leaves out the summaries of nameservers aborted in the
+// display, whose results are incomplete.
+func withoutAborted(summaries []*report.Summary, d *tui.Display) []*report.Summary {
+	var kept []*report.Summary
+	for _, s := range summaries {
+		if d.Aborted(s.Nameserver) {
+			log.Printf("%s was aborted, leaving it out of the report", s.Nameserver)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept
+}
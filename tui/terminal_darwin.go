@@ -0,0 +1,9 @@
+// part of the tui package, names the termios ioctls on macOS.
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	GET_TERMIOS = unix.TIOCGETA
+	SET_TERMIOS = unix.TIOCSETA
+)
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+// part of the tui package, for systems without termios support, where
+// progress is logged instead.
+package tui
+
+import "fmt"
+
+// isTerminal returns false: the display is not supported.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw fails: the display is not supported.
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("terminal input is not supported")
+}
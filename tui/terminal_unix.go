@@ -0,0 +1,31 @@
+//go:build linux || darwin
+
+// part of the tui package, switches a terminal to raw input.
+package tui
+
+import "golang.org/x/sys/unix"
+
+// isTerminal returns true if fd is a terminal.
+func isTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, GET_TERMIOS)
+	return err == nil
+}
+
+// makeRaw passes each keypress on fd straight through, without echo,
+// returning a function that restores the previous settings. Ctrl-C still
+// interrupts the process, so that the benchmark stops and reports as it
+// does without the display.
+func makeRaw(fd int) (func(), error) {
+	old, err := unix.IoctlGetTermios(fd, GET_TERMIOS)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO | unix.IEXTEN
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, SET_TERMIOS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(fd, SET_TERMIOS, old) }, nil
+}
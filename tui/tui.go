@@ -0,0 +1,404 @@
+// the tui package shows a benchmark's progress live in a terminal: a bar,
+// latency figures and a histogram per nameserver, in a table that can be
+// sorted, and where single nameservers can be aborted.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/stats"
+)
+
+// REFRESH is how often the screen is redrawn.
+const REFRESH = 200 * time.Millisecond
+
+// Widths of the progress bar and the latency histogram, in characters.
+const (
+	BAR_WIDTH         = 20
+	HISTOGRAM_BUCKETS = 12
+	// Log lines are cut short rather than wrapped, which would push the
+	// table off the screen.
+	LOG_WIDTH = 100
+)
+
+// SPARKS draws histogram buckets from empty to full.
+var SPARKS = []rune(" ▁▂▃▄▅▆▇█")
+
+// Orders the table can be sorted in, cycled through with "s".
+const (
+	BY_NAME     = "name"
+	BY_AVERAGE  = "average"
+	BY_FAILURES = "failures"
+	BY_PROGRESS = "progress"
+)
+
+var SORT_ORDERS = []string{BY_NAME, BY_AVERAGE, BY_FAILURES, BY_PROGRESS}
+
+// Terminal control sequences.
+const (
+	ALT_SCREEN   = "\x1b[?1049h\x1b[?25l"
+	MAIN_SCREEN  = "\x1b[?25h\x1b[?1049l"
+	HOME         = "\x1b[H"
+	CLEAR_LINE   = "\x1b[K"
+	CLEAR_BELOW  = "\x1b[J"
+	REVERSE      = "\x1b[7m"
+	RESET        = "\x1b[0m"
+	ARROW_PREFIX = "\x1b["
+)
+
+// row is the progress of one nameserver: done and failed are read from the
+// watched queues' Stats, the durations collected from results.
+type row struct {
+	nameserver string
+	total      int
+	done       int
+	failed     int
+	aborted    bool
+	durations  []time.Duration
+}
+
+// average returns the mean duration of the row's successful queries.
+func (r *row) average() time.Duration {
+	return stats.Mean(r.durations)
+}
+
+// Display draws the progress of a benchmark until it is closed. A nil
+// Display does nothing, so callers need not check whether one is shown.
+type Display struct {
+	mu       sync.Mutex
+	out      *os.File
+	in       *os.File
+	restore  func()
+	rows     []*row
+	byServer map[string]*row
+	queues   dnsqueue.StatsSet
+	selected int
+	order    int
+	lastLog  string
+	done     chan struct{}
+	closed   sync.WaitGroup
+	close    sync.Once
+}
+
+// IsTerminal returns true if f is a terminal the display can draw on.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(int(f.Fd()))
+}
+
+// New takes over out, which must be a terminal, to show the progress of
+// queries to each of nameservers, expecting total results for each. Keys are
+// read from in if it is a terminal too. Log output is shown in the last line
+// until the display is closed.
+func New(out, in *os.File, nameservers []string, total int) (*Display, error) {
+	if !IsTerminal(out) {
+		return nil, fmt.Errorf("%s is not a terminal", out.Name())
+	}
+	d := &Display{out: out, in: in, byServer: make(map[string]*row), done: make(chan struct{})}
+	for _, ns := range nameservers {
+		r := &row{nameserver: ns, total: total}
+		d.rows = append(d.rows, r)
+		d.byServer[ns] = r
+	}
+	if IsTerminal(in) {
+		restore, err := makeRaw(int(in.Fd()))
+		if err != nil {
+			return nil, err
+		}
+		d.restore = restore
+		go d.readKeys()
+	}
+	io.WriteString(out, ALT_SCREEN)
+	log.SetOutput(d)
+	d.closed.Add(1)
+	go d.refresh()
+	return d, nil
+}
+
+// Close restores the terminal and log output, after drawing the display one
+// last time. Calls after the first do nothing.
+func (d *Display) Close() {
+	if d == nil {
+		return
+	}
+	d.close.Do(func() {
+		close(d.done)
+		d.closed.Wait()
+		log.SetOutput(os.Stderr)
+		io.WriteString(d.out, MAIN_SCREEN)
+		if d.restore != nil {
+			d.restore()
+		}
+	})
+}
+
+// Watch counts the queries of q towards the nameservers' progress.
+func (d *Display) Watch(q *dnsqueue.Queue) {
+	if d == nil {
+		return
+	}
+	d.queues.Watch(q)
+}
+
+// Add records a result's duration if it was answered.
+func (d *Display) Add(r *dnsqueue.Result) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	row, ok := d.byServer[r.Request.Destination]
+	if !ok || r.Failed() {
+		return
+	}
+	row.durations = append(row.durations, r.Duration)
+}
+
+// update reads each row's completed and failed queries from the watched
+// queues.
+func (d *Display) update() {
+	stats := d.queues.Stats()
+	for _, r := range d.rows {
+		r.done = int(stats.DestinationCompleted[r.nameserver])
+		r.failed = int(stats.DestinationErrors[r.nameserver])
+	}
+}
+
+// Aborted returns true if the user aborted the nameserver, whose remaining
+// queries should not be sent.
+func (d *Display) Aborted(nameserver string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.byServer[nameserver]
+	return ok && r.aborted
+}
+
+// Write keeps the last line of log output to show below the table.
+func (d *Display) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if line := strings.TrimSpace(string(p)); line != "" {
+		if i := strings.LastIndexByte(line, '\n'); i >= 0 {
+			line = line[i+1:]
+		}
+		if runes := []rune(line); len(runes) > LOG_WIDTH {
+			line = string(runes[:LOG_WIDTH-1]) + "…"
+		}
+		d.lastLog = line
+	}
+	return len(p), nil
+}
+
+// refresh redraws the screen every REFRESH until the display is closed.
+func (d *Display) refresh() {
+	defer d.closed.Done()
+	ticker := time.NewTicker(REFRESH)
+	defer ticker.Stop()
+	for {
+		d.draw()
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys handles keypresses: up and down (or k and j) select a
+// nameserver, "a" aborts it, "s" changes the sort order and "q" aborts
+// every nameserver. Ctrl-C is not a key here: it interrupts the process.
+func (d *Display) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := d.in.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+		key := string(buf[:n])
+		d.mu.Lock()
+		d.update()
+		switch {
+		case key == ARROW_PREFIX+"A" || key == "k":
+			if d.selected > 0 {
+				d.selected--
+			}
+		case key == ARROW_PREFIX+"B" || key == "j":
+			if d.selected < len(d.rows)-1 {
+				d.selected++
+			}
+		case key == "a" && len(d.rows) > 0:
+			if r := d.sorted()[d.selected]; r.done < r.total {
+				r.aborted = true
+			}
+		case key == "s":
+			d.order = (d.order + 1) % len(SORT_ORDERS)
+		case key == "q" || key == "\x1b":
+			for _, r := range d.rows {
+				if r.done < r.total {
+					r.aborted = true
+				}
+			}
+		}
+		d.mu.Unlock()
+		d.draw()
+	}
+}
+
+// sorted returns the rows in the selected order.
+func (d *Display) sorted() []*row {
+	rows := append([]*row{}, d.rows...)
+	order := SORT_ORDERS[d.order]
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch order {
+		case BY_AVERAGE:
+			// Nameservers with no answers yet go last.
+			if len(a.durations) == 0 || len(b.durations) == 0 {
+				return len(b.durations) == 0 && len(a.durations) > 0
+			}
+			return a.average() < b.average()
+		case BY_FAILURES:
+			return a.failed < b.failed
+		case BY_PROGRESS:
+			return a.done > b.done
+		}
+		return a.nameserver < b.nameserver
+	})
+	return rows
+}
+
+// draw writes the whole screen in one go.
+func (d *Display) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.update()
+	var b bytes.Buffer
+	b.WriteString(HOME)
+	done, total := 0, 0
+	for _, r := range d.rows {
+		done += r.done
+		total += r.total
+	}
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString(CLEAR_LINE + "\n")
+	}
+	line("namebench: %d/%d queries, sorted by %s", done, total, SORT_ORDERS[d.order])
+	line("↑/↓ select  a abort nameserver  s sort  q abort all  Ctrl-C stop and report")
+	line("")
+	width := len("Nameserver")
+	for _, r := range d.rows {
+		if len(r.nameserver) > width {
+			width = len(r.nameserver)
+		}
+	}
+	line("  %-*s  %-*s  %9s  %9s  %9s  %6s  %s", width, "Nameserver", BAR_WIDTH+12, "Progress",
+		"Average", "Median", "p90", "Failed", "Latency histogram")
+	low, high := d.latencyRange()
+	for i, r := range d.sorted() {
+		l := stats.Describe(r.durations)
+		status := fmt.Sprintf("%s %d/%d", bar(r.done, r.total), r.done, r.total)
+		if r.aborted {
+			status = fmt.Sprintf("%-*s %d/%d", BAR_WIDTH, "aborted", r.done, r.total)
+		}
+		mark := "  "
+		if i == d.selected {
+			b.WriteString(REVERSE)
+			mark = "> "
+		}
+		fmt.Fprintf(&b, "%s%-*s  %-*s  %9s  %9s  %9s  %6d  %s", mark, width, r.nameserver, BAR_WIDTH+12, status,
+			ms(l.Mean), ms(l.Median), ms(l.P90), r.failed, histogram(r.durations, low, high))
+		if i == d.selected {
+			b.WriteString(RESET)
+		}
+		b.WriteString(CLEAR_LINE + "\n")
+	}
+	if low > 0 {
+		line("  histograms span %s to %s", ms(low), ms(high))
+	}
+	line("")
+	line("%s", d.lastLog)
+	b.WriteString(CLEAR_BELOW)
+	d.out.Write(b.Bytes())
+}
+
+// latencyRange returns the fastest and slowest answer of any nameserver, so
+// that every histogram has the same scale.
+func (d *Display) latencyRange() (low, high time.Duration) {
+	for _, r := range d.rows {
+		for _, t := range r.durations {
+			if low == 0 || t < low {
+				low = t
+			}
+			if t > high {
+				high = t
+			}
+		}
+	}
+	return low, high
+}
+
+// bar draws done out of total as a BAR_WIDTH progress bar.
+func bar(done, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = BAR_WIDTH * done / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", BAR_WIDTH-filled) + "]"
+}
+
+// histogram draws durations in HISTOGRAM_BUCKETS spaced logarithmically
+// between low and high, each as tall as its share of the fullest.
+func histogram(durations []time.Duration, low, high time.Duration) string {
+	if len(durations) == 0 || high <= low {
+		return ""
+	}
+	counts := make([]int, HISTOGRAM_BUCKETS)
+	span := math.Log(float64(high) / float64(low))
+	most := 0
+	for _, t := range durations {
+		i := int(math.Log(float64(t)/float64(low)) / span * HISTOGRAM_BUCKETS)
+		if i >= HISTOGRAM_BUCKETS {
+			i = HISTOGRAM_BUCKETS - 1
+		}
+		counts[i]++
+		if counts[i] > most {
+			most = counts[i]
+		}
+	}
+	sparks := make([]rune, HISTOGRAM_BUCKETS)
+	for i, c := range counts {
+		level := 0
+		if c > 0 {
+			// Any answer at all shows, however few.
+			level = 1 + c*(len(SPARKS)-2)/most
+		}
+		sparks[i] = SPARKS[level]
+	}
+	return string(sparks)
+}
+
+// ms formats a duration in milliseconds, or "-" if there is none.
+func ms(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
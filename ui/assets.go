@@ -0,0 +1,21 @@
+// part of the ui package, embeds the templates and static files in the binary,
+// so the UI works wherever namebench is run from.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed templates static
+var assets embed.FS
+
+// staticFiles serves the embedded static directory.
+func staticFiles() http.Handler {
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(static))
+}
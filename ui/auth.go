@@ -0,0 +1,160 @@
+// part of the ui package, provides token authentication and TLS helpers for remote access.
+package ui
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Name of the cookie used to remember a valid token.
+	TOKEN_COOKIE = "namebench_token"
+
+	// How long generated self-signed certificates remain valid.
+	SELF_SIGNED_VALIDITY = 365 * 24 * time.Hour
+)
+
+// NewToken returns a random hex token suitable for RequireToken.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requestToken extracts a token from the Authorization header, query string or cookie.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t
+	}
+	if c, err := r.Cookie(TOKEN_COOKIE); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// RequireToken rejects requests that do not present the given token. A token
+// passed in the query string is stored in a cookie, so that a browser opened
+// with ?token=X can keep navigating the UI.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := requestToken(r)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("token") != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     TOKEN_COOKIE,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SelfSignedCertificate generates an in-memory certificate for the given hosts.
+func SelfSignedCertificate(hosts ...string) (cert tls.Certificate, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return cert, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return cert, err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"namebench"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SELF_SIGNED_VALIDITY),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return cert, err
+	}
+	cert.Certificate = [][]byte{der}
+	cert.PrivateKey = key
+	return cert, nil
+}
+
+// IsLoopback returns true if the bind address only accepts local connections.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hostName returns the host of a Host header or URL host, without the port.
+func hostName(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
+
+// sameOrigin fails requests that another web site may have made: ones whose
+// Origin, if sent, is not the UI itself, and, when the UI listens on a
+// loopback address, ones naming any other Host, as a DNS rebinding attack
+// would. Without a token the loopback UI has no other defence.
+func sameOrigin(r *http.Request) error {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if tcp, ok := addr.(*net.TCPAddr); ok && tcp.IP.IsLoopback() && !IsLoopback(hostName(r.Host)) {
+			return fmt.Errorf("host %q is not a loopback address", r.Host)
+		}
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return fmt.Errorf("cross-origin request from %q", origin)
+	}
+	return nil
+}
+
+// requireJSON fails requests whose body is not declared as JSON, which a
+// cross-site form or text/plain request can not do without a CORS preflight.
+func requireJSON(r *http.Request) error {
+	media, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || media != "application/json" {
+		return fmt.Errorf("Content-Type must be application/json")
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+// part of the ui package, provides a JSON API to start benchmark runs and
+// fetch their progress and results, for automation and other frontends.
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/providers"
+	"github.com/google/namebench/report"
+)
+
+const (
+	// Runs allowed at the same time; more are refused until one finishes.
+	MAX_RUNNING = 2
+
+	// Finished runs kept for their results; the oldest are forgotten first.
+	MAX_FINISHED_RUNS = 20
+)
+
+// Run states.
+const (
+	RUNNING = "running"
+	DONE    = "done"
+	FAILED  = "failed"
+)
+
+// BenchmarkConfig is the payload of POST /api/benchmarks. Empty fields take
+// the UI defaults: 8.8.8.8, COUNT hostnames from browser history, A records.
+// Include adds the built-in resolvers of curated selections, as -include
+// does, and Source names the domain source to use rather than the first that
+// works. If more Domains than Count are given, Count of them are picked at
+// random.
+type BenchmarkConfig struct {
+	Nameservers []string `json:"nameservers"`
+	Include     []string `json:"include"`
+	Source      string   `json:"source"`
+	Domains     []string `json:"domains"`
+	Count       int      `json:"count"`
+	RecordTypes []string `json:"record_types"`
+}
+
+// normalize validates the configuration and fills in the defaults.
+func (c *BenchmarkConfig) normalize() error {
+	if len(c.Include) > 0 {
+		found, err := providers.Select(c.Include, "")
+		if err != nil {
+			return err
+		}
+		for _, p := range found {
+			// DNS over HTTPS resolvers can not be benchmarked from the UI yet.
+			if p.Address != "" {
+				c.Nameservers = append(c.Nameservers, p.Address)
+			}
+		}
+	}
+	if len(c.Nameservers) == 0 {
+		c.Nameservers = []string{"8.8.8.8:53"}
+	}
+	servers, err := parse.Nameservers(strings.Join(c.Nameservers, ","))
+	if err != nil {
+		return err
+	}
+	servers = history.Uniq(servers)
+	if err := allowed(servers); err != nil {
+		return err
+	}
+	c.Nameservers = servers
+	if c.Source != "" && !knownSource(c.Source) {
+		return fmt.Errorf("unknown domain source: %s", c.Source)
+	}
+	for i, d := range c.Domains {
+		if c.Domains[i], err = parse.Domain(d); err != nil {
+			return err
+		}
+	}
+	if c.Count == 0 {
+		c.Count = COUNT
+	}
+	if c.Count < 0 || c.Count > MAX_COUNT {
+		return fmt.Errorf("count must be between 1 and %d", MAX_COUNT)
+	}
+	c.RecordTypes, err = dnsqueue.RecordTypes(c.RecordTypes)
+	return err
+}
+
+// BenchmarkStatus describes a run, as returned by POST /api/benchmarks and
+// GET /api/benchmarks/{id}.
+type BenchmarkStatus struct {
+	ID       string     `json:"id"`
+	State    string     `json:"state"`
+	Source   string     `json:"source"`
+	Progress Progress   `json:"progress"`
+	Error    string     `json:"error,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// ResolverResult is one resolver's results, with durations in milliseconds.
+// Durations holds every successful query's, for charting their distribution.
+type ResolverResult struct {
+	Resolver  string         `json:"resolver"`
+	Queries   int            `json:"queries"`
+	Blocked   int            `json:"blocked"`
+	Failures  map[string]int `json:"failures"`
+	Average   float64        `json:"average_ms"`
+	Min       float64        `json:"min_ms"`
+	Median    float64        `json:"median_ms"`
+	P90       float64        `json:"p90_ms"`
+	P99       float64        `json:"p99_ms"`
+	Max       float64        `json:"max_ms"`
+	Stddev    float64        `json:"stddev_ms"`
+	Durations []float64      `json:"durations_ms"`
+}
+
+// BenchmarkResults is returned by GET /api/benchmarks/{id}/results once a
+// run is no longer running.
+type BenchmarkResults struct {
+	BenchmarkStatus
+	Config    BenchmarkConfig  `json:"config"`
+	Ranking   []Standing       `json:"ranking"`
+	Resolvers []ResolverResult `json:"resolvers"`
+}
+
+// benchmarkRun is a run started through the API.
+type benchmarkRun struct {
+	mu        sync.Mutex
+	status    BenchmarkStatus
+	config    BenchmarkConfig
+	summaries []*report.Summary
+}
+
+var (
+	runsMu sync.Mutex
+	// runs holds every run by ID, and runOrder their IDs oldest first.
+	runs     = make(map[string]*benchmarkRun)
+	runOrder []string
+)
+
+// newRun registers a run, forgetting the oldest finished runs beyond
+// MAX_FINISHED_RUNS. It fails if MAX_RUNNING runs are already running.
+func newRun(config BenchmarkConfig) (*benchmarkRun, error) {
+	id, err := NewToken()
+	if err != nil {
+		return nil, err
+	}
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	running := 0
+	var finished []string
+	for _, id := range runOrder {
+		if runs[id].snapshot().State == RUNNING {
+			running++
+		} else {
+			finished = append(finished, id)
+		}
+	}
+	if running >= MAX_RUNNING {
+		return nil, fmt.Errorf("%d benchmarks are already running", running)
+	}
+	for len(finished) >= MAX_FINISHED_RUNS {
+		delete(runs, finished[0])
+		finished = finished[1:]
+	}
+	kept := runOrder[:0]
+	for _, id := range runOrder {
+		if runs[id] != nil {
+			kept = append(kept, id)
+		}
+	}
+	run := &benchmarkRun{
+		status: BenchmarkStatus{ID: id, State: RUNNING, Started: time.Now()},
+		config: config,
+	}
+	runs[id] = run
+	runOrder = append(kept, id)
+	return run, nil
+}
+
+// lookupRun returns the run with the given ID, or nil.
+func lookupRun(id string) *benchmarkRun {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	return runs[id]
+}
+
+// snapshot returns a copy of the run's status.
+func (b *benchmarkRun) snapshot() BenchmarkStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// execute benchmarks the run's configuration, recording progress as it goes.
+// It is not tied to the request that started it.
+func (b *benchmarkRun) execute() {
+	hostnames, source := b.config.Domains, "request"
+	var err error
+	if len(hostnames) > b.config.Count {
+		hostnames = history.Random(b.config.Count, hostnames)
+	} else if len(hostnames) == 0 {
+		hostnames, source, err = pickHostnames(b.config.Source, b.config.Count)
+	}
+
+	byServer := make(map[string]*report.Summary)
+	for _, server := range b.config.Nameservers {
+		s := report.NewSummary(server)
+		b.summaries = append(b.summaries, s)
+		byServer[server] = s
+	}
+	b.mu.Lock()
+	b.status.Source = source
+	b.status.Progress.Total = len(b.config.Nameservers) * len(hostnames) * len(b.config.RecordTypes)
+	b.mu.Unlock()
+
+	if err == nil {
+		stats := &dnsqueue.StatsSet{}
+		err = benchmarkTypes(context.Background(), b.config.Nameservers, hostnames, b.config.RecordTypes, stats, func(result *dnsqueue.Result) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if s, ok := byServer[result.Request.Destination]; ok {
+				s.Add(result)
+			}
+			b.status.Progress.update(stats.Stats())
+		})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	finished := time.Now()
+	b.status.Finished = &finished
+	b.status.State = DONE
+	if err != nil {
+		log.Printf("Benchmark %s failed: %s", b.status.ID, err)
+		b.status.State = FAILED
+		b.status.Error = err.Error()
+	}
+}
+
+// results returns the run's results, or false while it is still running.
+func (b *benchmarkRun) results() (BenchmarkResults, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	results := BenchmarkResults{BenchmarkStatus: b.status, Config: b.config}
+	if b.status.State == RUNNING {
+		return results, false
+	}
+	results.Ranking = ranking(b.summaries)
+	for _, s := range b.summaries {
+		l := s.Latency()
+		durations := make([]float64, len(s.Durations))
+		for i, d := range s.Durations {
+			durations[i] = msOf(d)
+		}
+		results.Resolvers = append(results.Resolvers, ResolverResult{
+			Resolver:  s.Nameserver,
+			Queries:   s.Total(),
+			Blocked:   s.Blocked,
+			Failures:  s.Failures,
+			Average:   msOf(s.Average()),
+			Min:       msOf(l.Min),
+			Median:    msOf(l.Median),
+			P90:       msOf(l.P90),
+			P99:       msOf(l.P99),
+			Max:       msOf(l.Max),
+			Stddev:    msOf(l.Stddev),
+			Durations: durations,
+		})
+	}
+	return results, true
+}
+
+// REPORT_TYPES maps each format of GET /api/benchmarks/{id}/report.{format}
+// to its content type.
+var REPORT_TYPES = map[string]string{
+	"json": "application/json",
+	"csv":  "text/csv; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+}
+
+// writeReport serves the run's report in format as a download, in the
+// request's language, or a 409 while the run is still running.
+func (b *benchmarkRun) writeReport(w http.ResponseWriter, r *http.Request, format string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.status.State == RUNNING {
+		writeJSON(w, http.StatusConflict, b.status)
+		return
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "json":
+		err = report.WriteJSON(&buf, b.summaries)
+	case "csv":
+		err = report.WriteCSV(&buf, b.summaries)
+	case "html":
+		err = report.WriteHTML(&buf, b.summaries, requestLang(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := fmt.Sprintf("namebench-%s.%s", b.status.Started.Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", REPORT_TYPES[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(buf.Bytes())
+}
+
+// msOf returns d in milliseconds.
+func msOf(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// BenchmarksHandler handles POST /api/benchmarks, which starts a run with a
+// BenchmarkConfig and returns 202 with its status, GET /api/benchmarks/{id}
+// for a run's status, GET /api/benchmarks/{id}/results for its results and
+// GET /api/benchmarks/{id}/report.{json,csv,html} to download its report.
+// Results and reports are a 409 while the run is still running.
+func BenchmarksHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/benchmarks"), "/")
+	parts := strings.Split(path, "/")
+	if path == "" {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		startBenchmark(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	run := lookupRun(parts[0])
+	var format string
+	if len(parts) == 2 && strings.HasPrefix(parts[1], "report.") {
+		format = strings.TrimPrefix(parts[1], "report.")
+	}
+	switch {
+	case run == nil || len(parts) > 2:
+		http.NotFound(w, r)
+	case len(parts) == 1:
+		writeJSON(w, http.StatusOK, run.snapshot())
+	case REPORT_TYPES[format] != "":
+		run.writeReport(w, r, format)
+	case parts[1] != "results":
+		http.NotFound(w, r)
+	default:
+		results, ok := run.results()
+		if !ok {
+			writeJSON(w, http.StatusConflict, results.BenchmarkStatus)
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+	}
+}
+
+// startBenchmark starts a run from the BenchmarkConfig in the request body,
+// which must be JSON posted by the UI's own origin.
+func startBenchmark(w http.ResponseWriter, r *http.Request) {
+	if err := sameOrigin(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := requireJSON(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	var config BenchmarkConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := config.normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := newRun(config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	go run.execute()
+	w.Header().Set("Location", "/api/benchmarks/"+run.status.ID)
+	writeJSON(w, http.StatusAccepted, run.snapshot())
+}
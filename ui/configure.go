@@ -0,0 +1,107 @@
+// part of the ui package, serves the page configuring a benchmark run.
+package ui
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/internal/parse"
+	"github.com/google/namebench/providers"
+)
+
+// Record types offered on the configure page; the API accepts any.
+var CONFIGURE_RECORD_TYPES = []string{"A", "AAAA", "MX", "TXT", "NS", "HTTPS"}
+
+// Curated selections offered on the configure page, in order.
+var CONFIGURE_SELECTIONS = []string{providers.PREFERRED, providers.GLOBAL, providers.REGIONAL, providers.DNSSEC, providers.FILTERING}
+
+var configureTmpl = loadTemplate("templates/configure.html")
+
+// curatedSelection is a curated selection and the resolvers it adds.
+type curatedSelection struct {
+	Name      string
+	Resolvers []string
+}
+
+// configureData is passed to the configure page.
+type configureData struct {
+	pageData
+	Selections  []curatedSelection
+	Sources     []string
+	RecordTypes []string
+	// Nameservers, Source and Count prefill the form from the preferences.
+	Nameservers string
+	Source      string
+	Count       int
+}
+
+// curatedSelections lists the resolvers of each of CONFIGURE_SELECTIONS, by
+// name, leaving out DNS over HTTPS ones as normalize does.
+func curatedSelections() ([]curatedSelection, error) {
+	var selections []curatedSelection
+	for _, name := range CONFIGURE_SELECTIONS {
+		found, err := providers.Select([]string{name}, "")
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, p := range found {
+			if p.Address != "" {
+				names = append(names, p.Name)
+			}
+		}
+		selections = append(selections, curatedSelection{name, history.Uniq(names)})
+	}
+	return selections, nil
+}
+
+// Configure handles /configure, the page to pick nameservers, the domain
+// source, query count and record types before starting a run. Its form is
+// prefilled from the saved preferences and posted to /api/benchmarks, or to
+// /submit without JavaScript.
+func Configure(w http.ResponseWriter, r *http.Request) {
+	selections, err := curatedSelections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefs, err := LoadPreferences()
+	if err != nil {
+		log.Printf("Failed to load preferences: %s", err)
+	}
+	data := configureData{
+		pageData:    pageData{Lang: requestLang(r)},
+		Selections:  selections,
+		Sources:     sourceNames(),
+		RecordTypes: CONFIGURE_RECORD_TYPES,
+		Nameservers: strings.Join(prefs.Nameservers, ", "),
+		Source:      prefs.DomainSource,
+		Count:       prefs.Count,
+	}
+	if err := configureTmpl.ExecuteTemplate(w, "configure.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formConfig returns the BenchmarkConfig of a posted configure form: fields
+// named after its JSON keys, with nameservers separated by commas or spaces.
+// Fields left out take the defaults. Only the posted body is read, never the
+// URL's query string, which any page can put in a link.
+func formConfig(r *http.Request) (config BenchmarkConfig, err error) {
+	if err := r.ParseForm(); err != nil {
+		return config, err
+	}
+	config = BenchmarkConfig{
+		Nameservers: parse.NameserverFields(r.PostForm.Get("nameservers")),
+		Include:     r.PostForm["include"],
+		Source:      r.PostForm.Get("source"),
+		RecordTypes: r.PostForm["record_types"],
+	}
+	if count := r.PostForm.Get("count"); count != "" {
+		config.Count, err = strconv.Atoi(count)
+	}
+	return config, err
+}
@@ -0,0 +1,129 @@
+// part of the ui package, streams benchmark progress as server-sent events.
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/report"
+)
+
+// How many results pass between partial rankings.
+const RANKING_EVERY = 10
+
+// RANKING_WEIGHTS orders resolvers in partial and final rankings.
+var RANKING_WEIGHTS = report.DEFAULT_WEIGHTS
+
+// Progress is how far a benchmark has got, as emitted by /api/events.
+type Progress struct {
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+// update sets the queries done from the Stats of the benchmark's queue.
+func (p *Progress) update(stats dnsqueue.Stats) {
+	p.Done = int(stats.Completed)
+	if p.Total > 0 {
+		p.Percent = 100 * float64(p.Done) / float64(p.Total)
+	}
+}
+
+// Standing is a resolver's place in a partial or final ranking.
+type Standing struct {
+	Resolver string  `json:"resolver"`
+	Average  float64 `json:"average_ms"`
+	Score    float64 `json:"score"`
+	Queries  int     `json:"queries"`
+	Failures int     `json:"failures"`
+}
+
+// ranking orders summaries by score, best first. Resolvers without results
+// yet come last.
+func ranking(summaries []*report.Summary) []Standing {
+	standings := make([]Standing, 0, len(summaries))
+	for _, s := range summaries {
+		standings = append(standings, Standing{
+			Resolver: s.Nameserver,
+			Average:  float64(s.Average()) / float64(time.Millisecond),
+			Score:    s.Score(RANKING_WEIGHTS),
+			Queries:  s.Total(),
+			Failures: s.FailureCount(),
+		})
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Queries == 0 || b.Queries == 0 {
+			return a.Queries > 0 && b.Queries == 0
+		}
+		return a.Score < b.Score
+	})
+	return standings
+}
+
+// ProgressEvents handles /api/events, running a benchmark and streaming it as
+// server-sent events: a "result" (a LatencySample) and a "progress" event per
+// query, a "ranking" every RANKING_EVERY queries, and finally "done" with the
+// final ranking, preceded by "error" if the benchmark ended early. Resolvers
+// are chosen as for /api/latency.
+func ProgressEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	servers, ok := requestedServers(w, r)
+	if !ok {
+		return
+	}
+	hostnames, source, err := selectHostnames()
+	if err != nil {
+		log.Printf("Failed to select hostnames: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Namebench-Source", source)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			log.Printf("Failed to encode %s event: %s", event, err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	summaries := make([]*report.Summary, len(servers))
+	byServer := make(map[string]*report.Summary)
+	for i, server := range servers {
+		summaries[i] = report.NewSummary(server)
+		byServer[server] = summaries[i]
+	}
+	progress := Progress{Total: len(servers) * len(hostnames)}
+	stats := &dnsqueue.StatsSet{}
+	results := 0
+	err = benchmarkTypes(r.Context(), servers, hostnames, []string{"A"}, stats, func(result *dnsqueue.Result) {
+		if s, ok := byServer[result.Request.Destination]; ok {
+			s.Add(result)
+		}
+		progress.update(stats.Stats())
+		send("result", newLatencySample(result))
+		send("progress", progress)
+		if results++; results%RANKING_EVERY == 0 {
+			send("ranking", ranking(summaries))
+		}
+	})
+	if err != nil {
+		log.Printf("Progress stream ended early: %s", err)
+		send("error", map[string]string{"error": err.Error()})
+	}
+	send("done", ranking(summaries))
+}
@@ -0,0 +1,26 @@
+// part of the ui package, serves translated UI strings.
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/namebench/i18n"
+)
+
+// Translations handles /api/i18n/{lang}, returning the message catalog as JSON.
+func Translations(w http.ResponseWriter, r *http.Request) {
+	lang := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/i18n/"), "/")
+	if lang == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(i18n.Languages())
+		return
+	}
+	if !i18n.Supported(lang) {
+		http.Error(w, "unsupported language: "+lang, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i18n.Messages(lang))
+}
@@ -0,0 +1,175 @@
+// part of the ui package, provides middleware wrapped around every handler.
+package ui
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// Largest request body accepted by any handler.
+	MAX_BODY_BYTES = 1 << 20
+
+	// Requests per second allowed per client address, and burst size.
+	RATE_LIMIT_QPS   = 10
+	RATE_LIMIT_BURST = 20
+
+	// How long a client's bucket is kept after its last request. It must be
+	// longer than a bucket takes to refill, so forgetting it changes nothing.
+	BUCKET_IDLE_TIMEOUT = time.Minute
+)
+
+// statusRecorder captures the status code and size written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Flush passes through to the underlying writer, so streaming handlers keep working.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// bucket is a simple token bucket for a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter hands out tokens per client address. Buckets idle for longer
+// than BUCKET_IDLE_TIMEOUT are evicted, at most that often, so that clients
+// that come and go do not grow the map without bound.
+type rateLimiter struct {
+	qps     float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newRateLimiter(qps, burst int) *rateLimiter {
+	return &rateLimiter{
+		qps:     float64(qps),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+// evict deletes the buckets of clients idle since before cutoff.
+func (rl *rateLimiter) evict(cutoff time.Time) {
+	for client, b := range rl.buckets {
+		if b.last.Before(cutoff) {
+			delete(rl.buckets, client)
+		}
+	}
+}
+
+// allow returns true if the client has a token left, consuming it.
+func (rl *rateLimiter) allow(client string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.swept) >= BUCKET_IDLE_TIMEOUT {
+		rl.evict(now.Add(-BUCKET_IDLE_TIMEOUT))
+		rl.swept = now
+	}
+	b, ok := rl.buckets[client]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.qps
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens -= 1
+	return true
+}
+
+// clientAddr returns the host portion of the remote address.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// withRecovery turns a panicking handler into a 500 instead of killing the
+// server. The panic is only logged: its value may hold paths or other
+// details that clients have no business seeing.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withLogging logs one line per request.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		log.Printf("http method=%s path=%q remote=%s status=%d bytes=%d duration=%s",
+			r.Method, r.URL.Path, clientAddr(r), rec.status, rec.bytes, time.Since(start))
+	})
+}
+
+// withRateLimit rejects clients that exceed the configured request rate.
+func withRateLimit(rl *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.allow(clientAddr(r)) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withBodyLimit caps the size of request bodies.
+func withBodyLimit(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware wraps a handler with logging, panic recovery, rate limiting and body limits.
+func Middleware(next http.Handler) http.Handler {
+	return withLogging(withRecovery(withRateLimit(limiter, withBodyLimit(MAX_BODY_BYTES, next))))
+}
@@ -0,0 +1,134 @@
+// part of the ui package, stores user preferences between launches.
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/namebench/datadir"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/internal/parse"
+)
+
+// Name of the preferences file within the data directory.
+const PREFERENCES_FILE = "preferences.json"
+
+// Preferences are the settings the UI remembers between launches.
+type Preferences struct {
+	Nameservers  []string        `json:"nameservers"`
+	DomainSource string          `json:"domain_source"`
+	Count        int             `json:"count"`
+	Consent      map[string]bool `json:"consent"`
+}
+
+var prefsMu sync.Mutex
+
+// defaultPreferences returns the preferences used before the user saves any.
+func defaultPreferences() Preferences {
+	return Preferences{
+		Nameservers:  []string{"8.8.8.8:53"},
+		DomainSource: "chrome",
+		Count:        COUNT,
+		Consent:      map[string]bool{},
+	}
+}
+
+// normalize validates preferences before they are stored, storing the
+// nameservers as parse.Nameservers returns them.
+func (p *Preferences) normalize() error {
+	if p.Count < 0 || p.Count > MAX_COUNT {
+		return fmt.Errorf("count must be between 0 and %d", MAX_COUNT)
+	}
+	if !knownSource(p.DomainSource) {
+		return fmt.Errorf("unknown domain source: %s", p.DomainSource)
+	}
+	servers, err := parse.Nameservers(strings.Join(p.Nameservers, ","))
+	if err != nil {
+		return err
+	}
+	servers = history.Uniq(servers)
+	if err := allowed(servers); err != nil {
+		return err
+	}
+	p.Nameservers = servers
+	return nil
+}
+
+// LoadPreferences reads the stored preferences, returning defaults if none exist.
+func LoadPreferences() (p Preferences, err error) {
+	p = defaultPreferences()
+	path, err := datadir.Path(PREFERENCES_FILE)
+	if err != nil {
+		return p, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// SavePreferences stores preferences in the data directory.
+func SavePreferences(p Preferences) error {
+	path, err := datadir.Path(PREFERENCES_FILE)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return datadir.WriteFile(path, data)
+}
+
+// PreferencesHandler handles GET and PUT /api/preferences
+func PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	prefsMu.Lock()
+	defer prefsMu.Unlock()
+
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		if err := sameOrigin(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		p := defaultPreferences()
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := p.normalize(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SavePreferences(p); err != nil {
+			log.Printf("Failed to save preferences: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := LoadPreferences()
+	if err != nil {
+		log.Printf("Failed to load preferences: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
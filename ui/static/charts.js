@@ -0,0 +1,118 @@
+// Small SVG charts for the results page: boxplots and histograms of query
+// latency per nameserver. Each chart takes series of {label, values}, with
+// values in milliseconds, and draws them on one shared axis.
+var charts = (function() {
+  var SVG = 'http://www.w3.org/2000/svg';
+  var WIDTH = 720, LABEL = 200, ROW = 36, AXIS = 24, BINS = 30;
+  var COLORS = ['#428bca', '#5cb85c', '#f0ad4e', '#d9534f', '#5bc0de', '#777'];
+
+  function el(name, attrs, parent) {
+    var e = document.createElementNS(SVG, name);
+    for (var k in attrs) {
+      e.setAttribute(k, attrs[k]);
+    }
+    if (parent) {
+      parent.appendChild(e);
+    }
+    return e;
+  }
+
+  function text(parent, x, y, s, anchor) {
+    var t = el('text', {x: x, y: y, 'font-size': 12, 'text-anchor': anchor || 'start'}, parent);
+    t.textContent = s;
+    return t;
+  }
+
+  // quantile returns the q quantile of sorted values, interpolating.
+  function quantile(sorted, q) {
+    var pos = (sorted.length - 1) * q, i = Math.floor(pos);
+    if (i + 1 >= sorted.length) {
+      return sorted[i];
+    }
+    return sorted[i] + (sorted[i + 1] - sorted[i]) * (pos - i);
+  }
+
+  // summarize returns the boxplot statistics of values, with whiskers at the
+  // furthest values within 1.5 IQR of the box.
+  function summarize(values) {
+    var sorted = values.slice().sort(function(a, b) { return a - b; });
+    var q1 = quantile(sorted, 0.25), q3 = quantile(sorted, 0.75), iqr = q3 - q1;
+    var inside = sorted.filter(function(v) { return v >= q1 - 1.5 * iqr && v <= q3 + 1.5 * iqr; });
+    return {
+      q1: q1, median: quantile(sorted, 0.5), q3: q3,
+      low: inside[0], high: inside[inside.length - 1],
+      outliers: sorted.filter(function(v) { return v < inside[0] || v > inside[inside.length - 1]; })
+    };
+  }
+
+  // frame returns an SVG for rows of series with an x axis up to max ms,
+  // and the function mapping ms to x.
+  function frame(container, rows, max) {
+    container.innerHTML = '';
+    var height = rows * ROW + AXIS;
+    var svg = el('svg', {width: WIDTH, height: height, viewBox: '0 0 ' + WIDTH + ' ' + height}, container);
+    var x = function(v) { return LABEL + (WIDTH - LABEL - 10) * Math.min(v, max) / max; };
+    var ticks = 5;
+    for (var i = 0; i <= ticks; i++) {
+      var v = max * i / ticks, tx = x(v);
+      el('line', {x1: tx, x2: tx, y1: 0, y2: rows * ROW, stroke: '#eee'}, svg);
+      text(svg, tx, rows * ROW + 16, v.toFixed(v < 10 ? 1 : 0) + 'ms', 'middle');
+    }
+    return {svg: svg, x: x};
+  }
+
+  // withValues drops series without any values.
+  function withValues(series) {
+    return series.filter(function(s) { return s.values && s.values.length > 0; });
+  }
+
+  // boxplot draws a horizontal boxplot per series.
+  function boxplot(container, series) {
+    series = withValues(series);
+    var stats = series.map(function(s) { return summarize(s.values); });
+    var max = Math.max.apply(null, stats.map(function(s) { return s.high; }).concat([1])) * 1.1;
+    var f = frame(container, series.length, max);
+    series.forEach(function(s, i) {
+      var b = stats[i], mid = i * ROW + ROW / 2, color = COLORS[i % COLORS.length];
+      text(f.svg, 0, mid + 4, s.label);
+      el('line', {x1: f.x(b.low), x2: f.x(b.high), y1: mid, y2: mid, stroke: '#333'}, f.svg);
+      el('rect', {x: f.x(b.q1), y: mid - 10, width: Math.max(f.x(b.q3) - f.x(b.q1), 1), height: 20,
+        fill: color, stroke: '#333'}, f.svg);
+      el('line', {x1: f.x(b.median), x2: f.x(b.median), y1: mid - 10, y2: mid + 10, stroke: '#000', 'stroke-width': 2}, f.svg);
+      b.outliers.forEach(function(v) {
+        el('circle', {cx: f.x(v), cy: mid, r: 2, fill: 'none', stroke: color}, f.svg);
+      });
+    });
+  }
+
+  // histogram draws a row of bars per series, over the same bins.
+  function histogram(container, series) {
+    series = withValues(series);
+    var all = [];
+    series.forEach(function(s) { all = all.concat(s.values); });
+    all.sort(function(a, b) { return a - b; });
+    // Bins stop at the 99th percentile, so that a few slow queries do not
+    // squeeze the rest into the first bar; the last bin holds them.
+    var max = Math.max(all.length ? quantile(all, 0.99) : 1, 1);
+    var f = frame(container, series.length, max);
+    var binWidth = (WIDTH - LABEL - 10) / BINS;
+    series.forEach(function(s, i) {
+      var counts = [];
+      for (var b = 0; b < BINS; b++) {
+        counts.push(0);
+      }
+      s.values.forEach(function(v) {
+        counts[Math.min(Math.floor(v / max * BINS), BINS - 1)]++;
+      });
+      var most = Math.max.apply(null, counts), bottom = (i + 1) * ROW - 4;
+      text(f.svg, 0, i * ROW + ROW / 2 + 4, s.label);
+      counts.forEach(function(n, b) {
+        var h = most ? (ROW - 8) * n / most : 0;
+        el('rect', {x: LABEL + b * binWidth, y: bottom - h, width: binWidth - 1, height: h,
+          fill: COLORS[i % COLORS.length]}, f.svg);
+      });
+    });
+  }
+
+  return {boxplot: boxplot, histogram: histogram};
+})();
@@ -0,0 +1,92 @@
+// part of the ui package, streams per-query latency samples while a job runs.
+package ui
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/history"
+	"github.com/google/namebench/internal/parse"
+)
+
+// LatencySample is a single completed query, as emitted by /api/latency.
+type LatencySample struct {
+	Resolver string  `json:"resolver"`
+	Domain   string  `json:"domain"`
+	Duration float64 `json:"duration_ms"`
+	Rcode    string  `json:"rcode,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Failure  string  `json:"failure,omitempty"`
+}
+
+// newLatencySample converts a dnsqueue result into a sample.
+func newLatencySample(r *dnsqueue.Result) LatencySample {
+	return LatencySample{
+		Resolver: r.Request.Destination,
+		Domain:   strings.TrimSuffix(r.Request.RecordName, "."),
+		Duration: float64(r.Duration) / float64(time.Millisecond),
+		Rcode:    r.Rcode,
+		Error:    r.Error,
+		Failure:  string(r.Failure),
+	}
+}
+
+// requestedServers returns the resolvers chosen with repeated ?ns=IP:port
+// parameters, or the default. If they are invalid or not allowed it writes
+// the error response and returns false.
+func requestedServers(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	servers := []string{"8.8.8.8:53"}
+	if ns := r.URL.Query()["ns"]; len(ns) > 0 {
+		var err error
+		if servers, err = parse.Nameservers(strings.Join(ns, ",")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		servers = history.Uniq(servers)
+	}
+	if err := allowed(servers); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return servers, true
+}
+
+// LatencyStream handles /api/latency, running a benchmark and writing one
+// JSON object per line as each query completes. Resolvers may be chosen with
+// repeated ?ns=IP:port parameters.
+func LatencyStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	servers, ok := requestedServers(w, r)
+	if !ok {
+		return
+	}
+	hostnames, source, err := selectHostnames()
+	if err != nil {
+		log.Printf("Failed to select hostnames: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Namebench-Source", source)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	enc := json.NewEncoder(w)
+	err = benchmark(r.Context(), servers, hostnames, func(result *dnsqueue.Result) {
+		if err := enc.Encode(newLatencySample(result)); err != nil {
+			log.Printf("Failed to stream sample: %s", err)
+			return
+		}
+		flusher.Flush()
+	})
+	if err != nil {
+		log.Printf("Latency stream ended early: %s", err)
+	}
+}
@@ -2,19 +2,26 @@
 package ui
 
 import (
+	"context"
 	"html/template"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/namebench/dnschecks"
 	"github.com/google/namebench/dnsqueue"
 	"github.com/google/namebench/history"
+	"github.com/google/namebench/i18n"
+	"github.com/google/namebench/managed"
 )
 
 const (
 	// How many requests/responses can be queued at once
-	QUEUE_LENGTH = 65535
+	QUEUE_LENGTH = WORKERS * 4
+
+	// Largest number of tests a user may ask for
+	MAX_COUNT = 65535
 
 	// Number of workers (same as Chrome's DNS prefetch queue)
 	WORKERS = 8
@@ -24,33 +31,65 @@ const (
 
 	// How far back to reach into browser history
 	HISTORY_DAYS = 30
+
+	// Longest a benchmark may run before missing results are given up on
+	JOB_DEADLINE = 10 * time.Minute
 )
 
 var (
-	indexTmpl = loadTemplate("ui/templates/index.html")
+	indexTmpl   = loadTemplate("templates/index.html")
+	resultsTmpl = loadTemplate("templates/results.html")
+	limiter     = newRateLimiter(RATE_LIMIT_QPS, RATE_LIMIT_BURST)
 )
 
 // RegisterHandler registers all known handlers.
 func RegisterHandlers() {
-	http.HandleFunc("/", Index)
-	http.Handle("/static/", http.StripPrefix("/static", http.FileServer(http.Dir("ui/static"))))
-	http.HandleFunc("/submit", Submit)
-	http.HandleFunc("/dnssec", DnsSec)
+	handle("/", http.HandlerFunc(Index))
+	handle("/static/", http.StripPrefix("/static", staticFiles()))
+	handle("/configure", http.HandlerFunc(Configure))
+	handle("/submit", http.HandlerFunc(Submit))
+	handle("/results/", http.HandlerFunc(Results))
+	handle("/dnssec", http.HandlerFunc(DnsSec))
+	handle("/api/latency", http.HandlerFunc(LatencyStream))
+	handle("/api/events", http.HandlerFunc(ProgressEvents))
+	handle("/api/benchmarks", http.HandlerFunc(BenchmarksHandler))
+	handle("/api/benchmarks/", http.HandlerFunc(BenchmarksHandler))
+	handle("/api/i18n/", http.HandlerFunc(Translations))
+	handle("/api/preferences", http.HandlerFunc(PreferencesHandler))
+}
+
+// handle registers a handler wrapped in the standard middleware.
+func handle(pattern string, h http.Handler) {
+	http.Handle(pattern, Middleware(h))
 }
 
-// loadTemplate loads a set of templates.
+// loadTemplate loads a set of embedded templates.
 func loadTemplate(paths ...string) *template.Template {
-	t := template.New(strings.Join(paths, ","))
-	_, err := t.ParseFiles(paths...)
+	t := template.New(strings.Join(paths, ",")).Funcs(template.FuncMap{"T": i18n.T})
+	_, err := t.ParseFS(assets, paths...)
 	if err != nil {
 		panic(err)
 	}
 	return t
 }
 
+// pageData is passed to every template.
+type pageData struct {
+	Lang string
+}
+
+// requestLang returns the language for a request, from ?lang= or Accept-Language.
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); i18n.Supported(lang) {
+		return lang
+	}
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
 // Index handles /
 func Index(w http.ResponseWriter, r *http.Request) {
-	if err := indexTmpl.ExecuteTemplate(w, "index.html", nil); err != nil {
+	data := pageData{Lang: requestLang(r)}
+	if err := indexTmpl.ExecuteTemplate(w, "index.html", data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 	return
@@ -66,33 +105,149 @@ func DnsSec(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, ip := range servers {
 		result, err := dnschecks.DnsSec(ip)
-		log.Printf("%s DNSSEC: %s (%s)", ip, result, err)
+		log.Printf("%s DNSSEC: %t (%v)", ip, result, err)
 	}
 }
 
-// Submit handles /submit
-func Submit(w http.ResponseWriter, r *http.Request) {
-	records, err := history.Chrome(HISTORY_DAYS)
+// selectHostnames picks COUNT hostnames from the first history source that
+// works, falling back to the embedded default list. It also returns the name
+// of the source used.
+func selectHostnames() (hostnames []string, source string, err error) {
+	return pickHostnames("", COUNT)
+}
+
+// pickHostnames is selectHostnames for any count, from the named source if
+// it is not "". If that source fails or has no hostnames, the others are
+// tried as selectHostnames does; the source returned is the one used.
+func pickHostnames(source string, count int) ([]string, string, error) {
+	var hostnames []string
+	var err error
+	if source != "" {
+		var s history.Source
+		if s, err = history.Lookup(source); err == nil {
+			hostnames, err = s.Hostnames(HISTORY_DAYS)
+		}
+		if err != nil {
+			log.Printf("%s source failed, trying the others: %s", source, err)
+		} else if len(hostnames) == 0 {
+			log.Printf("%s source returned no hostnames, trying the others", source)
+		}
+	}
+	if source == "" || err != nil || len(hostnames) == 0 {
+		hostnames, source, err = history.Hostnames(HISTORY_DAYS)
+	}
 	if err != nil {
-		panic(err)
+		return nil, source, err
+	}
+	hostnames = history.Uniq(hostnames)
+	if len(hostnames) > count {
+		hostnames = history.Random(count, hostnames)
 	}
+	log.Printf("Using %d hostnames from %s", len(hostnames), source)
+	return hostnames, source, nil
+}
 
-	q := dnsqueue.StartQueue(QUEUE_LENGTH, WORKERS)
-	hostnames := history.Random(COUNT, history.Uniq(history.ExternalHostnames(records)))
+// sourceNames returns the domain sources a benchmark may be configured with.
+func sourceNames() []string {
+	names := []string{history.DEFAULT_SOURCE}
+	for _, s := range history.Sources() {
+		names = append(names, s.Name())
+	}
+	return names
+}
 
-	for _, record := range hostnames {
-		q.Add("8.8.8.8:53", "A", record+".")
-		log.Printf("Added %s", record)
+// knownSource returns true if name is one of sourceNames. Query logs, which
+// history.Lookup also accepts, are left out: they name files on the server.
+func knownSource(name string) bool {
+	for _, n := range sourceNames() {
+		if n == name {
+			return true
+		}
 	}
-	q.SendCompletionSignal()
-	answered := 0
-	for {
-		if answered == len(hostnames) {
-			break
+	return false
+}
+
+// allowed fails if the managed configuration forbids any of servers.
+func allowed(servers []string) error {
+	config, _ := managed.Active()
+	return config.Allowed(servers)
+}
+
+// benchmark queries each hostname against each server, calling fn for every
+// result. It stops early if ctx is cancelled, e.g. when the client goes away.
+func benchmark(ctx context.Context, servers []string, hostnames []string, fn func(*dnsqueue.Result)) error {
+	return benchmarkTypes(ctx, servers, hostnames, []string{"A"}, nil, fn)
+}
+
+// benchmarkTypes is benchmark, querying each hostname for every record type.
+// If stats is not nil, it watches the queue, for progress to be read from.
+func benchmarkTypes(ctx context.Context, servers []string, hostnames []string, types []string, stats *dnsqueue.StatsSet, fn func(*dnsqueue.Result)) error {
+	q := dnsqueue.StartQueue(ctx, QUEUE_LENGTH, WORKERS)
+	q.Deadline = JOB_DEADLINE
+	stats.Watch(q)
+	return q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, server := range servers {
+			for _, record := range hostnames {
+				for _, t := range types {
+					r := &dnsqueue.Request{Destination: server, RecordType: t, RecordName: record + "."}
+					if err := add(r); err != nil {
+						return err
+					}
+				}
+				log.Printf("Added %s", record)
+			}
 		}
-		result := <-q.Results
-		answered += 1
-		log.Printf("%s", result)
+		return nil
+	}, fn)
+}
+
+// Submit handles /submit, starting a benchmark configured by the posted
+// configure form, or with the UI defaults, and redirecting to its results page.
+// Only POST is accepted: browsers send cross-site GETs, from links and
+// images, without an Origin header for sameOrigin to check.
+func Submit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := sameOrigin(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	config, err := formConfig(r)
+	if err == nil {
+		err = config.normalize()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := newRun(config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	go run.execute()
+	http.Redirect(w, r, "/results/"+run.status.ID, http.StatusSeeOther)
+}
+
+// resultsData is passed to the results page.
+type resultsData struct {
+	pageData
+	ID string
+}
+
+// Results handles /results/{id}, the page showing a run's progress, then
+// charts and a ranking of its results, with links to download its report.
+func Results(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/results/"), "/")
+	if lookupRun(id) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := resultsData{pageData{Lang: requestLang(r)}, id}
+	if err := resultsTmpl.ExecuteTemplate(w, "results.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	return
 }
@@ -0,0 +1,138 @@
+// part of the upstream package, writes forwarder configuration for local resolvers.
+package upstream
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/namebench/internal/parse"
+)
+
+// DOT_PORT is the DNS over TLS port; upstreams on it are configured for DoT.
+const DOT_PORT = "853"
+
+// TLS_NAMES are the certificate names of well known public resolvers, used
+// to authenticate DoT upstreams.
+var TLS_NAMES = map[string]string{
+	"1.1.1.1":         "cloudflare-dns.com",
+	"1.0.0.1":         "cloudflare-dns.com",
+	"8.8.8.8":         "dns.google",
+	"8.8.4.4":         "dns.google",
+	"9.9.9.9":         "dns.quad9.net",
+	"149.112.112.112": "dns.quad9.net",
+	"208.67.222.222":  "dns.opendns.com",
+	"208.67.220.220":  "dns.opendns.com",
+}
+
+// dot returns true for a server listening on DOT_PORT.
+func dot(ns string) bool {
+	_, port, err := net.SplitHostPort(ns)
+	return err == nil && port == DOT_PORT
+}
+
+// allDoT returns true if every server, DoQ ones aside, listens on DOT_PORT.
+func allDoT(servers []string) bool {
+	n := 0
+	for _, ns := range servers {
+		if quic(ns) {
+			continue
+		}
+		if !dot(ns) {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+// tlsSuffix returns "#name" for hosts with a known TLS name.
+func tlsSuffix(host string) string {
+	if name, ok := TLS_NAMES[host]; ok {
+		return "#" + name
+	}
+	return ""
+}
+
+// formatUnbound writes a forward-zone for the root. Unbound cannot mix DoT and
+// plain upstreams in one zone, so DoT is only enabled if every server uses it;
+// otherwise DoT upstreams are left as comments.
+func formatUnbound(b *strings.Builder, servers []string) {
+	tls := allDoT(servers)
+	b.WriteString("# unbound.conf\nforward-zone:\n    name: \".\"\n")
+	if tls {
+		b.WriteString("    forward-tls-upstream: yes\n")
+	}
+	for _, ns := range servers {
+		if quic(ns) {
+			fmt.Fprintf(b, "    # forward-addr: %s skipped: unbound does not support DNS over QUIC\n", ns)
+			continue
+		}
+		if !tls && dot(ns) {
+			fmt.Fprintf(b, "    # forward-addr: %s skipped: unbound cannot mix DNS over TLS and plain upstreams\n", ns)
+			continue
+		}
+		host, port, _ := net.SplitHostPort(ns)
+		addr := host + "@" + port
+		if tls {
+			addr += tlsSuffix(host)
+		}
+		fmt.Fprintf(b, "    forward-addr: %s\n", addr)
+	}
+}
+
+// formatDnsmasq writes server= lines. dnsmasq has no DoT support, so DoT
+// upstreams are left as comments.
+func formatDnsmasq(b *strings.Builder, servers []string) {
+	b.WriteString("# dnsmasq.conf\nno-resolv\n")
+	for _, ns := range servers {
+		if quic(ns) {
+			fmt.Fprintf(b, "# server=%s skipped: dnsmasq does not support DNS over QUIC\n", ns)
+			continue
+		}
+		host, port, _ := net.SplitHostPort(ns)
+		switch port {
+		case DOT_PORT:
+			fmt.Fprintf(b, "# server=%s skipped: dnsmasq does not support DNS over TLS\n", ns)
+		case parse.DEFAULT_PORT:
+			fmt.Fprintf(b, "server=%s\n", host)
+		default:
+			fmt.Fprintf(b, "server=%s#%s\n", host, port)
+		}
+	}
+}
+
+// formatResolved writes a resolved.conf drop-in. DNSOverTLS is enabled if
+// every server uses it, as the setting applies to all of them; otherwise DoT
+// upstreams are left as comments.
+func formatResolved(b *strings.Builder, servers []string) {
+	tls := allDoT(servers)
+	var addrs, skipped []string
+	for _, ns := range servers {
+		if quic(ns) {
+			skipped = append(skipped, ns+" skipped: systemd-resolved does not support DNS over QUIC")
+			continue
+		}
+		if !tls && dot(ns) {
+			skipped = append(skipped, ns+" skipped: systemd-resolved cannot mix DNS over TLS and plain upstreams")
+			continue
+		}
+		host, port, _ := net.SplitHostPort(ns)
+		addr := host
+		if port != parse.DEFAULT_PORT && !(tls && port == DOT_PORT) {
+			addr = net.JoinHostPort(host, port)
+		}
+		if tls {
+			addr += tlsSuffix(host)
+		}
+		addrs = append(addrs, addr)
+	}
+	b.WriteString("# /etc/systemd/resolved.conf.d/namebench.conf\n[Resolve]\n")
+	for _, note := range skipped {
+		fmt.Fprintf(b, "# %s\n", note)
+	}
+	fmt.Fprintf(b, "DNS=%s\n", strings.Join(addrs, " "))
+	if tls {
+		b.WriteString("DNSOverTLS=yes\n")
+	}
+}
@@ -0,0 +1,124 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		text    string
+		kind    string
+		want    []string
+		wantErr string
+	}{
+		{
+			"setupVars.conf", "setupVars.conf",
+			"WEBPASSWORD=x\nPIHOLE_DNS_1=1.1.1.1\nPIHOLE_DNS_2=192.168.1.2#5353\nPIHOLE_DNS_3=2001:db8::1#5353\nPIHOLE_DNS_4=\n",
+			PIHOLE, []string{"1.1.1.1:53", "192.168.1.2:5353", "[2001:db8::1]:5353"}, "",
+		},
+		{
+			"pihole.toml", "pihole.toml",
+			"[dns]\n  upstreams = [\n    \"9.9.9.9\",\n    \"2001:db8::2#53\" ]\n",
+			PIHOLE6, []string{"9.9.9.9:53", "[2001:db8::2]:53"}, "",
+		},
+		{
+			"pihole.toml inline", "inline.toml",
+			"[dns]\nupstreams = [\"8.8.8.8\", \"1.1.1.1#853\"]\n",
+			PIHOLE6, []string{"8.8.8.8:53", "dot://1.1.1.1:853"}, "",
+		},
+		{
+			"AdGuardHome.yaml", "AdGuardHome.yaml",
+			"dns:\n  bind_hosts: []\nupstream_dns:\n  - 8.8.8.8\n  - \"tls://dns.quad9.net\"\n  - '[/lan/]192.168.1.1'\n  - 9.9.9.9:53\nbootstrap_dns: []\n",
+			ADGUARD, []string{"8.8.8.8:53", "9.9.9.9:53"}, "",
+		},
+		{"nothing plain", "none.yaml", "upstream_dns:\n  - https://dns.google/dns-query\n", ADGUARD, nil, "no plain DNS upstreams found"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := ioutil.WriteFile(path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		source := PIHOLE
+		if tt.kind == ADGUARD {
+			source = ADGUARD
+		}
+		kind, servers, err := Import(source + ":" + path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: Import error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Import failed: %s", tt.name, err)
+			continue
+		}
+		if kind != tt.kind || !reflect.DeepEqual(servers, tt.want) {
+			t.Errorf("%s: Import = %s, %v, want %s, %v", tt.name, kind, servers, tt.kind, tt.want)
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"setupVars.conf", "invalid upstream source"},
+		{"bind:/etc/named.conf", "unknown upstream source"},
+		{"pihole:" + filepath.Join(t.TempDir(), "missing.conf"), "no such file"},
+	}
+	for _, tt := range tests {
+		if _, _, err := Import(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Import(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestHashAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.1.1.1", "1.1.1.1"},
+		{"1.1.1.1#5353", "1.1.1.1:5353"},
+		{"2001:db8::1#53", "[2001:db8::1]:53"},
+		{"[2001:db8::1]#53", "[2001:db8::1]:53"},
+		{"2001:db8::1", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		if got := hashAddress(tt.in); got != tt.want {
+			t.Errorf("hashAddress(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPihole(t *testing.T) {
+	servers := []string{"1.1.1.1:53", "[2001:db8::1]:5353", "dot://9.9.9.9:853", "doq://dns.adguard.com:853"}
+	tests := []struct {
+		kind string
+		want []string
+	}{
+		{PIHOLE, []string{"PIHOLE_DNS_1=1.1.1.1\n", "PIHOLE_DNS_2=2001:db8::1#5353\n", "# 9.9.9.9:853 skipped", "# doq://dns.adguard.com:853 skipped"}},
+		{PIHOLE6, []string{"[dns]\n  upstreams = [\n    \"1.1.1.1\",\n    \"2001:db8::1#5353\"\n  ]\n", "# 9.9.9.9:853 skipped"}},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.kind, servers)
+		if err != nil {
+			t.Errorf("Format(%s) failed: %s", tt.kind, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("Format(%s) = %q, want it to contain %q", tt.kind, got, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/google/namebench/dnsqueue"
+	"github.com/google/namebench/ui"
+)
+
+var warmup = flag.Int("warmup", 2,
+	"Queries sent to each nameserver and discarded before it is measured, so first-packet delays are not counted (cli mode)")
+var no_prime = flag.Bool("no_prime", false, "Skip the -warmup queries (cli mode)")
+
+// warmUp sends -warmup queries for PREFLIGHT_HOSTNAME to each of servers
+// and discards the results. A hostname outside the workload keeps the
+// measured hostnames out of the resolvers' caches, and a queue of its own
+// keeps the warm-up connections out of the measured ones.
+func warmUp(ctx context.Context, servers []string, opts benchmarkOptions) {
+	if *no_prime || *warmup <= 0 {
+		return
+	}
+	q := dnsqueue.StartQueueFrom(ctx, ui.QUEUE_LENGTH, ui.WORKERS, opts.source)
+	q.Deadline = ui.JOB_DEADLINE
+	limitRate(q)
+	answered := 0
+	err := q.Stream(func(add func(*dnsqueue.Request) error) error {
+		for _, ns := range servers {
+			for i := 0; i < *warmup; i++ {
+				r := benchmarkRequests(ns, PREFLIGHT_HOSTNAME, opts)[0]
+				r.MaxRetries = 0
+				if err := add(r); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(r *dnsqueue.Result) {
+		if r.Error == "" {
+			answered++
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Warm-up: %s", err)
+	}
+	log.Printf("Warm-up: %d/%d queries answered", answered, len(servers)**warmup)
+}